@@ -0,0 +1,149 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Events recorded in audit_log.
+const (
+	auditUpdated     = "updated"
+	auditDisabled    = "disabled"
+	auditReenabled   = "re-enabled"
+	auditWebhookSent = "webhook_fired"
+)
+
+type auditEntry struct {
+	Code      string    `bson:"code"`
+	Event     string    `bson:"event"`
+	Actor     string    `bson:"actor,omitempty"`
+	Detail    string    `bson:"detail,omitempty"`
+	Timestamp time.Time `bson:"timestamp"`
+}
+
+// recordAudit appends an event to code's audit trail. Failures are only
+// logged: the change it describes has already been made.
+func recordAudit(code, event, actor, detail string) {
+	e := auditEntry{Code: code, Event: event, Actor: actor, Detail: detail, Timestamp: time.Now()}
+	if _, err := auditLog.InsertOne(context.Background(), e); err != nil {
+		log.Printf("Failed to record %s event for %s: %v", event, code, err)
+	}
+}
+
+// auditUpdate records a PATCH of code by actor: disabling or re-enabling
+// it, and the other fields it changed.
+func auditUpdate(code, actor string, set, unset bson.M) {
+	var fields []string
+	for _, m := range []bson.M{set, unset} {
+		for k := range m {
+			if k != "disabled" && k != "health_history" && k != "pre_delete_notified_at" {
+				fields = append(fields, k)
+			}
+		}
+	}
+	if len(fields) > 0 {
+		sort.Strings(fields)
+		recordAudit(code, auditUpdated, actor, strings.Join(fields, ", "))
+	}
+	if disabled, ok := set["disabled"].(bool); ok {
+		event := auditReenabled
+		if disabled {
+			event = auditDisabled
+		}
+		recordAudit(code, event, actor, "")
+	}
+}
+
+// ActivityEntry is one item of a short URL's timeline. Clicks are
+// reported per hour, with Timestamp at the start of the hour.
+type ActivityEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Event     string    `json:"event"`
+	Actor     string    `json:"actor,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Clicks    int64     `json:"clicks,omitempty"`
+}
+
+// apiActivityHandler returns everything that happened to a short URL,
+// oldest first: its creation, the audit_log entries, hourly click counts
+// from click_events and the recorded destination health checks.
+func apiActivityHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	mapping, err := findInMongoDB(code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to load %s: %v", code, err)
+		http.Error(w, "Failed to load activity", http.StatusInternalServerError)
+		return
+	}
+
+	feed, err := activity(r.Context(), mapping)
+	if err != nil {
+		log.Printf("Failed to load activity of %s: %v", code, err)
+		http.Error(w, "Failed to load activity", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, feed)
+}
+
+func activity(ctx context.Context, m URLMapping) ([]ActivityEntry, error) {
+	feed := []ActivityEntry{{Timestamp: m.CreatedAt, Event: "created", Detail: m.URL}}
+
+	cur, err := auditLog.Find(ctx, bson.M{"code": m.Code})
+	if err != nil {
+		return nil, err
+	}
+	var audit []auditEntry
+	if err := cur.All(ctx, &audit); err != nil {
+		return nil, err
+	}
+	for _, e := range audit {
+		feed = append(feed, ActivityEntry{Timestamp: e.Timestamp, Event: e.Event, Actor: e.Actor, Detail: e.Detail})
+	}
+
+	cur, err = clickEvents.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"code": m.Code}},
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%dT%H:00:00Z", "date": "$timestamp"}},
+			"count": clickCount,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var hours []CountEntry
+	if err := cur.All(ctx, &hours); err != nil {
+		return nil, err
+	}
+	for _, h := range hours {
+		t, err := time.Parse(time.RFC3339, h.Key)
+		if err != nil {
+			return nil, err
+		}
+		feed = append(feed, ActivityEntry{Timestamp: t, Event: "clicked", Clicks: h.Count})
+	}
+
+	for _, hc := range m.HealthHistory {
+		detail := fmt.Sprintf("%d in %s", hc.StatusCode, hc.Latency.Round(time.Millisecond))
+		if hc.Error != "" {
+			detail = hc.Error
+		}
+		feed = append(feed, ActivityEntry{Timestamp: hc.CheckedAt, Event: "health_check", Detail: detail})
+	}
+
+	sort.SliceStable(feed, func(i, j int) bool { return feed[i].Timestamp.Before(feed[j].Timestamp) })
+	return feed, nil
+}
@@ -0,0 +1,101 @@
+package urlshortener
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// themeCSS is assets/theme.css, the light and dark colours of the admin
+// page, inlined so that they apply without another request.
+var themeCSS = func() template.CSS {
+	b, err := assetsFS.ReadFile("assets/theme.css")
+	if err != nil {
+		panic(err)
+	}
+	return template.CSS(b)
+}()
+
+var adminTpl = template.Must(template.New("").Funcs(template.FuncMap{"asset": assetURL, "themeCSS": func() template.CSS { return themeCSS }}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>URL Shortener Admin</title>
+    <script src="{{asset "theme.js"}}"></script>
+    <style>{{themeCSS}}</style>
+    <style>
+        body { display: flex; gap: 2em; }
+        nav.collections { min-width: 14em; border-right: 1px solid #ccc; padding-right: 1em; }
+        nav.collections ul { padding-left: 1.2em; }
+    </style>
+</head>
+<body>
+    <nav class="collections">
+        <h2>Collections</h2>
+        {{range .Collections}}
+        <details data-collection="{{.ID.Hex}}">
+            <summary title="{{.Description}}">{{.Name}} ({{.URLCount}})</summary>
+            <ul></ul>
+        </details>
+        {{else}}
+        <p>No collections yet.</p>
+        {{end}}
+    </nav>
+    <main>
+    <button type="button" id="theme-toggle">Dark mode</button>
+    <h1>URL Shortener Admin</h1>
+    <form method="post" action="/admin/logout"><button type="submit">Log out</button></form>
+    <h2>Service stats</h2>
+    {{with .Summary}}
+    <ul class="stats">
+        <li>Short URLs: {{.TotalURLs}}</li>
+        <li>Redirects served: {{.TotalRedirects}}</li>
+        <li>Redirects in the last 24 hours: {{.Redirects24h}}</li>
+        <li>Average clicks per URL: {{printf "%.1f" .AvgClicksPerURL}}</li>
+    </ul>
+    {{else}}
+    <p>Stats are currently unavailable.</p>
+    {{end}}
+    <h2>Live</h2>
+    <p><a href="/admin/click-map">Click map</a></p>
+    <p id="live-status">Connecting&hellip;</p>
+    <ul>
+        <li>URLs created: <span id="count-created">0</span></li>
+        <li>Clicks: <span id="count-clicked">0</span></li>
+        <li>Rate limited: <span id="count-rate_limited">0</span></li>
+        <li>MongoDB circuit breaker: <span id="breaker">closed</span></li>
+    </ul>
+    <ol id="live-events" reversed></ol>
+    </main>
+    <script src="{{asset "admin.js"}}"></script>
+</body>
+</html>
+`))
+
+type AdminPageVariables struct {
+	Summary     *AnalyticsSummary
+	Collections []URLCollection
+}
+
+func adminHandler(w http.ResponseWriter, r *http.Request) {
+	var vars AdminPageVariables
+	// Under --dry-run there are no click events or collections to show.
+	if !cfg.DryRun {
+		var err error
+		if vars.Summary, err = cachedSummary(r.Context()); err != nil {
+			log.Printf("Failed to compute analytics summary: %v", err)
+		}
+		if vars.Collections, err = listCollections(r, bson.M{}); err != nil {
+			log.Printf("Failed to list collections: %v", err)
+		}
+	}
+
+	if err := adminTpl.Execute(w, vars); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,56 @@
+package urlshortener
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseCIDRs parses ADMIN_ALLOWED_CIDRS. A bare address is taken as a
+// single-host block.
+func parseCIDRs(s string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, block := range strings.Split(s, ",") {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		if ip := net.ParseIP(block); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(block)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a CIDR block", block)
+		}
+		out = append(out, cidr)
+	}
+	return out, nil
+}
+
+// adminClientAllowed reports whether r comes from ADMIN_ALLOWED_CIDRS, or
+// no allowlist is configured. requireAdmin checks it before any
+// credentials, so Basic Auth can't be brute-forced from elsewhere. The
+// client is realIP, so TRUST_PROXY decides whether a proxy's forwarding
+// headers count.
+func adminClientAllowed(r *http.Request) bool {
+	return len(cfg.AdminAllowedCIDRs) == 0 || adminIPAllowed(realIP(r))
+}
+
+func adminIPAllowed(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cfg.AdminAllowedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
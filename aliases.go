@@ -0,0 +1,113 @@
+package urlshortener
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ALIAS_MODE values. Clicks on an alias count for its canonical code in
+// both; they differ in what a redirect costs and what it sees:
+//
+//   - lookup: the alias stores only alias_of and every redirect reads the
+//     canonical mapping as well. The canonical's destination, expiry,
+//     redirect type and other settings apply to the alias at once.
+//   - copy: the alias stores the canonical's destination when it is
+//     created and redirects with a single lookup. Later changes to the
+//     canonical, and its settings, don't reach the alias.
+const (
+	aliasModeLookup = "lookup"
+	aliasModeCopy   = "copy"
+)
+
+type aliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// apiCreateAliasHandler creates the code in the body as an alias of the
+// path's code. An alias of an alias points at the canonical code itself.
+func apiCreateAliasHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireReady(w) {
+		return
+	}
+	code := codeParam(r)
+
+	var req aliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := validateCode(req.Alias); err != nil {
+		http.Error(w, err.Error(), validateCodeStatus(err))
+		return
+	}
+
+	canonical, err := findInMongoDB(code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		loggerFrom(r.Context()).Error("Failed to load short URL", "error", err.Error())
+		http.Error(w, "Failed to create alias", http.StatusInternalServerError)
+		return
+	}
+	if canonical.AliasOf != "" {
+		if canonical, err = findInMongoDB(canonical.AliasOf); err != nil {
+			loggerFrom(r.Context()).Error("Failed to load canonical short URL", "error", err.Error())
+			http.Error(w, "Failed to create alias", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	m := URLMapping{
+		Code:    req.Alias,
+		AliasOf: canonical.Code,
+		// The alias is its own code, so it needs its own signature.
+		RequiresSignature: canonical.RequiresSignature,
+	}
+	if cfg.AliasMode == aliasModeCopy {
+		m.URL = canonical.URL
+		m.TargetCode = canonical.TargetCode
+	}
+	alias, err := createShortURL(r.Context(), m)
+	if err != nil {
+		if errors.Is(err, errCodeTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		loggerFrom(r.Context()).Error("Failed to create alias", "alias", req.Alias, "error", err.Error())
+		http.Error(w, "Failed to create alias", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, alias)
+}
+
+// resolveAlias returns the mapping to redirect alias with under ALIAS_MODE
+// and the code its click counts for. It answers the request itself and
+// reports false when the canonical is gone, disabled or expired.
+func resolveAlias(w http.ResponseWriter, r *http.Request, alias URLMapping) (URLMapping, string, bool) {
+	if cfg.AliasMode == aliasModeCopy {
+		return alias, alias.AliasOf, true
+	}
+	canonical, err := lookupRedirect(alias.AliasOf)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		notFound(w, r)
+		return canonical, "", false
+	}
+	if err != nil {
+		writeError(w, r, "Failed to resolve code", http.StatusInternalServerError)
+		return canonical, "", false
+	}
+	if canonical.Disabled {
+		gone(w, r)
+		return canonical, "", false
+	}
+	if canonical.Expired() {
+		expiredHandler(w, r, canonical)
+		return canonical, "", false
+	}
+	return canonical, canonical.Code, true
+}
@@ -0,0 +1,160 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var clickEvents *mongo.Collection
+
+type ClickEvent struct {
+	Code      string    `bson:"code" json:"code"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+	IP        string    `bson:"ip" json:"ip"`
+	UserAgent string    `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Referrer  string    `bson:"referrer,omitempty" json:"referrer,omitempty"`
+	Country   string    `bson:"country,omitempty" json:"country,omitempty"`
+	Bot       bool      `bson:"bot" json:"bot"`
+	Chain     []string  `bson:"chain,omitempty" json:"chain,omitempty"`
+
+	// RedirectType is the status code the visitor was answered with. Meta
+	// refresh pages (bots, interstitials) are recorded as 200.
+	RedirectType int `bson:"redirect_type,omitempty" json:"redirect_type,omitempty"`
+
+	// LinkIndex is the link followed on a link-in-bio page.
+	LinkIndex *int `bson:"link_index,omitempty" json:"link_index,omitempty"`
+
+	// Source comes from the ?src= parameter, e.g. "qr" for QR code scans.
+	Source string `bson:"source,omitempty" json:"source,omitempty"`
+
+	// Split is the side of the URL's traffic split, "a" or "b", the
+	// visitor was sent to.
+	Split string `bson:"split,omitempty" json:"split,omitempty"`
+
+	// Weight is the number of clicks the event stands for when the URL's
+	// clicks are sampled; unset means one.
+	Weight int64 `bson:"weight,omitempty" json:"weight,omitempty"`
+}
+
+// weight is the number of clicks ev counts for.
+func (ev ClickEvent) weight() int64 {
+	if ev.Weight > 0 {
+		return ev.Weight
+	}
+	return 1
+}
+
+var errCodeNotFound = errors.New("short code not found")
+
+func newClickEvent(r *http.Request, code string) ClickEvent {
+	return ClickEvent{
+		Code:      code,
+		Timestamp: time.Now(),
+		IP:        realIP(r),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+		Country:   requestCountry(r),
+		Bot:       isBot(r.UserAgent()),
+	}
+}
+
+// requestCountry reads the ISO country code set by an edge proxy or CDN in
+// front of the service, if any.
+func requestCountry(r *http.Request) string {
+	for _, h := range []string{"CF-IPCountry", "X-Country-Code"} {
+		if c := r.Header.Get(h); c != "" && c != "XX" {
+			return strings.ToUpper(c)
+		}
+	}
+	return ""
+}
+
+// recordClick stores the click event and bumps the mapping's counter. It is
+// meant to run in its own goroutine so redirects never wait on analytics.
+// Repeat clicks inside the dedup window are dropped.
+func recordClick(ev ClickEvent) {
+	if duplicateClick(ev) {
+		return
+	}
+	if cfg.DryRun {
+		shortURLs.Update(ev.Code, func(m *URLMapping) {
+			m.Clicks += ev.weight()
+			if m.TrafficSplit == nil {
+				return
+			}
+			// Redirects may be reading the cached split.
+			split := *m.TrafficSplit
+			switch ev.Split {
+			case splitA:
+				split.ClicksA += ev.weight()
+			case splitB:
+				split.ClicksB += ev.weight()
+			}
+			m.TrafficSplit = &split
+		})
+		return
+	}
+	ctx := context.Background()
+	if _, err := clickEvents.InsertOne(ctx, ev); err != nil {
+		log.Printf("Error recording click for %s: %v", ev.Code, err)
+		return
+	}
+	exportClick(ev)
+	lifecycle.Publish(subjectClicked, ev)
+	if _, err := collection.UpdateOne(ctx, bson.M{"code": ev.Code}, bson.M{"$inc": bson.M{"clicks": ev.weight()}}); err != nil {
+		log.Printf("Error incrementing clicks for %s: %v", ev.Code, err)
+	}
+	if ev.Split != "" {
+		// The filter keeps a click that lands as the split is removed from
+		// bringing back a split without destinations.
+		filter := bson.M{"code": ev.Code, "traffic_split": bson.M{"$exists": true}}
+		if _, err := collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"traffic_split.clicks_" + ev.Split: ev.weight()}}); err != nil {
+			log.Printf("Error incrementing split clicks for %s: %v", ev.Code, err)
+		}
+	}
+}
+
+func analyticsResetHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	if err := resetAnalytics(r.Context(), code); err != nil {
+		if errors.Is(err, errCodeNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to reset analytics for %s: %v", code, err)
+		http.Error(w, "Failed to reset analytics", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetAnalytics zeroes the click counter and drops every click event for
+// code in a single transaction so the two never disagree.
+func resetAnalytics(ctx context.Context, code string) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		res, err := collection.UpdateOne(sc, bson.M{"code": code}, bson.M{"$set": bson.M{"clicks": 0}})
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount == 0 {
+			return nil, errCodeNotFound
+		}
+		return clickEvents.DeleteMany(sc, bson.M{"code": code})
+	})
+	return err
+}
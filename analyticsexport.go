@@ -0,0 +1,149 @@
+package urlshortener
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// analyticsExportFlushEvery is how many events are written between
+// flushes, which send them to the client as a chunk.
+const analyticsExportFlushEvery = 500
+
+// clfTime is the timestamp layout of the Common Log Format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+var analyticsExportFormats = map[string]struct{ contentType, ext string }{
+	"clf":  {"text/plain; charset=utf-8", "log"},
+	"json": {"application/x-ndjson", "ndjson"},
+	"csv":  {"text/csv; charset=utf-8", "csv"},
+}
+
+var analyticsCSVHeader = []string{"timestamp", "ip", "user_agent", "referrer", "country", "bot", "source", "redirect_type"}
+
+// analyticsExportHandler serves GET /api/v1/{code}/analytics?format=,
+// streaming every click event of the code, oldest first, as clf, json
+// (NDJSON) or csv. A separate /api/v1/{code}/analytics/export can't be
+// routed: for the code "users" it overlaps /api/v1/users/{id}/export, and
+// ServeMux rejects patterns where neither is more specific. CLF lines
+// read as an access log of the short URL, "ip - - [time] "GET /code
+// HTTP/1.1" status -", for log analysis tools such as GoAccess or AWStats;
+// events from before redirect types were recorded show
+// DEFAULT_REDIRECT_TYPE. It must not run behind timeoutMiddleware, which
+// buffers.
+func analyticsExportHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+	format := r.URL.Query().Get("format")
+	f, ok := analyticsExportFormats[format]
+	if !ok {
+		http.Error(w, "format must be clf, json or csv", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	if _, err := findInMongoDB(code); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Failed to export analytics", http.StatusInternalServerError)
+		return
+	}
+	cur, err := clickEvents.Find(ctx, bson.M{"code": code}, options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		log.Printf("Failed to export click events for %s: %v", code, err)
+		http.Error(w, "Failed to export analytics", http.StatusInternalServerError)
+		return
+	}
+	defer cur.Close(ctx)
+
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": code + "-clicks." + f.ext}))
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	var write func(ClickEvent) error
+	switch format {
+	case "clf":
+		write = func(e ClickEvent) error {
+			_, err := fmt.Fprintf(bw, "%s - - [%s] \"GET /%s HTTP/1.1\" %d -\n",
+				clfField(e.IP), e.Timestamp.Format(clfTime), code, clickStatus(e))
+			return err
+		}
+	case "json":
+		enc := json.NewEncoder(bw)
+		write = func(e ClickEvent) error { return enc.Encode(e) }
+	case "csv":
+		cw := csv.NewWriter(bw)
+		if err := cw.Write(analyticsCSVHeader); err != nil {
+			return
+		}
+		write = func(e ClickEvent) error {
+			cw.Write([]string{
+				e.Timestamp.UTC().Format(time.RFC3339), e.IP, e.UserAgent, e.Referrer, e.Country,
+				strconv.FormatBool(e.Bot), e.Source, strconv.Itoa(clickStatus(e)),
+			})
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	n := 0
+	for cur.Next(ctx) {
+		var e ClickEvent
+		if err := cur.Decode(&e); err != nil {
+			log.Printf("Failed to decode click event of %s: %v", code, err)
+			continue
+		}
+		if err := write(e); err != nil {
+			// The client went away.
+			return
+		}
+		if n++; n%analyticsExportFlushEvery == 0 {
+			if bw.Flush() != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+	if err := cur.Err(); err != nil && ctx.Err() == nil {
+		// The status is sent already; a truncated export is all that can
+		// be signalled.
+		log.Printf("Failed to export click events for %s: %v", code, err)
+	}
+	bw.Flush()
+	flusher.Flush()
+}
+
+// clickStatus is the status e's visitor was answered with.
+func clickStatus(e ClickEvent) int {
+	if e.RedirectType != 0 {
+		return e.RedirectType
+	}
+	return cfg.DefaultRedirectType
+}
+
+// clfField is s as a CLF field, where "-" stands for a missing value.
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,1053 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/idna"
+
+	"urlshortener/pkg/shortener"
+)
+
+const (
+	// maxBodyBytes caps request bodies on the create endpoints so an
+	// oversized upload can't exhaust memory.
+	maxBodyBytes = 64 << 10
+
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+var (
+	errCodeTaken   = errors.New("short code already in use")
+	errInvalidURL  = shortener.ErrInvalidURL
+	errInvalidCode = shortener.ErrInvalidCode
+
+	codePattern = shortener.CodePattern
+
+	// Codes that would be shadowed by a fixed route. Router.Handle adds
+	// those of every registered route; listed here are the ones served
+	// outside the router or only registered in some configurations.
+	reservedCodes = map[string]bool{"ping": true, "prestop": true, selfTestCode: true}
+)
+
+type shortenRequest struct {
+	URL         string     `json:"url"`
+	TargetCode  string     `json:"target_code"`
+	Prefix      string     `json:"prefix"`
+	Code        string     `json:"code"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Tags        []string   `json:"tags"`
+	PublicStats bool       `json:"public_stats"`
+	NotifyEmail string     `json:"notify_email"`
+	OwnerID     string     `json:"owner_id"`
+
+	// Cloak is refused here: a cloaked page is served from this origin, so
+	// only admins may turn it on, with PATCH.
+	Cloak bool `json:"cloak"`
+
+	ContactEmail string `json:"contact_email"`
+
+	// CampaignID tags the URL with a campaign's UTM parameters and tags.
+	CampaignID string `json:"campaign_id"`
+
+	RequiresSignature bool `json:"requires_signature"`
+
+	RedirectType int `json:"redirect_type"`
+
+	CustomHeaders map[string]string `json:"custom_headers"`
+
+	// NotifySlack set to false mutes the Slack notification for this URL.
+	NotifySlack *bool `json:"notify_slack"`
+
+	NotifyBeforeDeleteHours int `json:"notify_before_delete_hours"`
+
+	// AlwaysPreview overrides ALWAYS_PREVIEW for this URL.
+	AlwaysPreview *bool `json:"always_preview"`
+
+	VerifySSLPin bool     `json:"verify_ssl_pin"`
+	ExpectedPins []string `json:"expected_pins"`
+
+	AppDeepLink string `json:"app_deep_link"`
+	FallbackURL string `json:"fallback_url"`
+}
+
+type updateRequest struct {
+	URL          *string `json:"url"`
+	TargetCode   *string `json:"target_code"`
+	PublicStats  *bool   `json:"public_stats"`
+	DelaySeconds *int    `json:"delay_seconds"`
+	AdHTML       *string `json:"ad_html"`
+	ShadowURL    *string `json:"shadow_url"`
+	Disabled     *bool   `json:"disabled"`
+	Cloak        *bool   `json:"cloak"`
+	ContactEmail *string `json:"contact_email"`
+
+	// Replaces the URL's tags; an empty list removes them.
+	Tags *[]string `json:"tags"`
+
+	// "premium" or "free".
+	Priority *string `json:"priority"`
+
+	AlwaysPreview *bool `json:"always_preview"`
+
+	VerifySSLPin *bool `json:"verify_ssl_pin"`
+
+	// Replaces the pins; an empty list removes them.
+	ExpectedPins *[]string `json:"expected_pins"`
+
+	// An empty string removes the deep link or fallback.
+	AppDeepLink *string `json:"app_deep_link"`
+	FallbackURL *string `json:"fallback_url"`
+
+	// An empty object removes all custom headers.
+	CustomHeaders *map[string]string `json:"custom_headers"`
+
+	RequiresSignature *bool `json:"requires_signature"`
+
+	// 0 returns the URL to the namespace, owner or global default.
+	RedirectType *int `json:"redirect_type"`
+
+	// 0 turns the expiry notice off; a new value re-arms it.
+	NotifyBeforeDeleteHours *int `json:"notify_before_delete_hours"`
+
+	// 1 records every click again.
+	SamplingRate *float64 `json:"sampling_rate"`
+}
+
+type resolveResponse struct {
+	Code      string     `json:"code"`
+	ShortURL  string     `json:"short_url"`
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type listResponse struct {
+	URLs    []URLMapping `json:"urls"`
+	Page    int          `json:"page"`
+	PerPage int          `json:"per_page"`
+	Total   int64        `json:"total"`
+}
+
+type DailyClicks struct {
+	Date   string `bson:"_id" json:"date"`
+	Clicks int64  `bson:"clicks" json:"clicks"`
+}
+
+// codeStats is the per-code part of the analytics response, which the
+// batch endpoint returns on its own.
+type codeStats struct {
+	Code        string    `bson:"code" json:"code"`
+	URL         string    `bson:"url" json:"url"`
+	Clicks      int64     `bson:"clicks" json:"clicks"`
+	Impressions int64     `bson:"impressions" json:"impressions"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+}
+
+type analyticsResponse struct {
+	codeStats
+	Daily []DailyClicks `json:"daily"`
+
+	// CTR is clicks per impression.
+	CTR float64 `json:"ctr"`
+
+	ByRedirectType map[string]int64 `json:"by_redirect_type"`
+	BySource       map[string]int64 `json:"by_source"`
+}
+
+func validateURL(raw string) (string, error) {
+	return shortener.ValidateURL(raw)
+}
+
+// displayURL converts a stored URL's Punycode hostname back to Unicode for
+// showing to people. Anything that doesn't parse is returned unchanged, and so
+// is a host with a label mixing scripts, since "аpple.com" with a Cyrillic
+// "а" would otherwise read as apple.com.
+func displayURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	host, err := idna.Display.ToUnicode(u.Hostname())
+	if err != nil || host == u.Hostname() {
+		return raw
+	}
+	for _, label := range strings.Split(host, ".") {
+		if mixedScript(label) {
+			return raw
+		}
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	// url.URL.String would percent-encode the Unicode host, so splice it in.
+	sep := "//"
+	if u.User != nil {
+		sep = "@"
+	}
+	return strings.Replace(raw, sep+u.Host, sep+host, 1)
+}
+
+// hanScripts are written together in Chinese, Japanese and Korean names, so a
+// label mixing only these counts as one script.
+var hanScripts = []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo}
+
+// mixedScript reports whether label has letters from more than one script.
+// Digits, hyphens and combining marks belong to no script and are ignored.
+func mixedScript(label string) bool {
+	seen := ""
+	for _, r := range label {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		script := ""
+		if unicode.In(r, hanScripts...) {
+			script = "Han"
+		} else {
+			for name, table := range unicode.Scripts {
+				if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+					script = name
+					break
+				}
+			}
+		}
+		if script == "" {
+			continue
+		}
+		if seen != "" && seen != script {
+			return true
+		}
+		seen = script
+	}
+	return false
+}
+
+// isBodyTooLarge reports whether err came from a body exceeding its
+// http.MaxBytesReader limit.
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+func validateCode(code string) error {
+	if !codePattern.MatchString(code) || reservedCodes[normalizeCode(code)] {
+		return errInvalidCode
+	}
+	if isProfane(code) {
+		return errProfaneCode
+	}
+	return nil
+}
+
+// validateCodeStatus is the response status for a validateCode error: the
+// code is well-formed but refused when it is profane.
+func validateCodeStatus(err error) int {
+	if errors.Is(err, errProfaneCode) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
+
+func apiShortenHandler(w http.ResponseWriter, r *http.Request) {
+	defer shortenLatency.since(time.Now())
+	if !requireReady(w) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req shortenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	var dest string
+	if req.TargetCode != "" {
+		if req.URL != "" {
+			http.Error(w, "Specify either url or target_code, not both", http.StatusBadRequest)
+			return
+		}
+		if err := checkChain(req.Code, req.TargetCode); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		var err error
+		if dest, err = validateDestinationURL(req.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Code != "" {
+		if err := validateCode(req.Code); err != nil {
+			http.Error(w, err.Error(), validateCodeStatus(err))
+			return
+		}
+	}
+	if req.Prefix != "" && !codePattern.MatchString(req.Prefix) {
+		http.Error(w, "prefix must be 3-32 letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+	if req.Cloak {
+		http.Error(w, errCloakOnCreate.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	if req.RequiresSignature && cfg.HMACSecret == "" {
+		http.Error(w, errSigningDisabled.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateRedirectType(req.RedirectType); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateNotifyBeforeDelete(req.NotifyBeforeDeleteHours); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var notify string
+	if req.NotifyEmail != "" {
+		addr, err := mail.ParseAddress(req.NotifyEmail)
+		if err != nil {
+			http.Error(w, "notify_email is not a valid email address", http.StatusBadRequest)
+			return
+		}
+		notify = addr.Address
+	}
+
+	headers, err := validateCustomHeaders(req.CustomHeaders)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pins, err := validatePinning(dest, req.VerifySSLPin, req.ExpectedPins)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Anyone may shorten, so only admins may file the URL under another
+	// user. URLs shortened while impersonating belong to the impersonated
+	// user.
+	if req.OwnerID != "" && !isAdmin(r) {
+		http.Error(w, "owner_id requires admin credentials", http.StatusForbidden)
+		return
+	}
+	owner := req.OwnerID
+	if c, ok := impersonation(r); ok {
+		owner = c.Subject
+	}
+
+	var deepLink, fallback string
+	if req.AppDeepLink != "" {
+		if deepLink, err = validateDeepLink(req.AppDeepLink); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FallbackURL != "" {
+		if fallback, err = validateDestinationURL(req.FallbackURL); err != nil {
+			http.Error(w, "fallback_url: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var contact string
+	if req.ContactEmail != "" {
+		addr, err := mail.ParseAddress(req.ContactEmail)
+		if err != nil {
+			http.Error(w, "contact_email is not a valid email address", http.StatusBadRequest)
+			return
+		}
+		contact = addr.Address
+	}
+
+	tags := req.Tags
+	if req.CampaignID != "" {
+		c, err := lookupCampaign(r.Context(), req.CampaignID)
+		if errors.Is(err, errUnknownCampaign) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			loggerFrom(r.Context()).Error("Failed to load campaign", "campaign_id", req.CampaignID, "error", err.Error())
+			http.Error(w, "Failed to load campaign", http.StatusInternalServerError)
+			return
+		}
+		tags = mergeTags(tags, c.Tags)
+	}
+
+	create := createShortURL
+	status := http.StatusCreated
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		create = previewShortURL
+		status = http.StatusOK
+	}
+
+	m := URLMapping{
+		Code:         req.Code,
+		URL:          dest,
+		TargetCode:   req.TargetCode,
+		Prefix:       req.Prefix,
+		ExpiresAt:    req.ExpiresAt,
+		Tags:         tags,
+		PublicStats:  req.PublicStats,
+		OwnerID:      owner,
+		ContactEmail: contact,
+		CampaignID:   req.CampaignID,
+
+		CustomHeaders:     headers,
+		RequiresSignature: req.RequiresSignature,
+		RedirectType:      req.RedirectType,
+
+		NotifyBeforeDeleteHours: req.NotifyBeforeDeleteHours,
+		AlwaysPreview:           req.AlwaysPreview,
+
+		VerifySSLPin: req.VerifySSLPin,
+		ExpectedPins: pins,
+
+		AppDeepLink: deepLink,
+		FallbackURL: fallback,
+	}
+	if notify != "" {
+		token, err := newManageToken()
+		if err != nil {
+			http.Error(w, "Failed to save to database", http.StatusInternalServerError)
+			return
+		}
+		m.ManageToken = token
+	}
+
+	mapping, err := create(r.Context(), m)
+	if err != nil {
+		if errors.Is(err, errCodeTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		loggerFrom(r.Context()).Error("Failed to save to database", "error", err.Error())
+		http.Error(w, "Failed to save to database", http.StatusInternalServerError)
+		return
+	}
+
+	mapping.SuggestedTags = suggestTags(mapping)
+
+	if notify != "" && !mapping.DryRun {
+		go sendConfirmation(publicBaseURL(r), notify, mapping)
+	}
+	if (req.NotifySlack == nil || *req.NotifySlack) && !mapping.DryRun {
+		go notifySlack(publicBaseURL(r), mapping, realIP(r))
+	}
+	writeJSON(w, status, mapping)
+}
+
+func apiResolveHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	mapping, err := lookupCode(code)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Failed to resolve code", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || mapping.Expired() || mapping.Disabled {
+		http.NotFound(w, r)
+		return
+	}
+	if !checkSignature(w, r, mapping) {
+		return
+	}
+
+	final, err := resolveDestination(mapping)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resolveResponse{Code: mapping.Code, ShortURL: mapping.ShortURL(), URL: final.URL, ExpiresAt: mapping.ExpiresAt})
+}
+
+// resolveDestination follows m's alias and chain to the mapping whose URL
+// a redirect of m ends at. An unusable alias target is errCodeNotFound.
+func resolveDestination(m URLMapping) (URLMapping, error) {
+	if m.AliasOf != "" && cfg.AliasMode == aliasModeLookup {
+		target, err := lookupCode(m.AliasOf)
+		if err != nil {
+			return URLMapping{}, err
+		}
+		if target.Expired() || target.Disabled {
+			return URLMapping{}, errCodeNotFound
+		}
+		m = target
+	}
+	final, _, err := resolveChain(m)
+	return final, err
+}
+
+func apiListHandler(w http.ResponseWriter, r *http.Request) {
+	filter := bson.M{}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		filter["tags"] = tag
+	}
+	writeMappingPage(w, r, filter)
+}
+
+// apiReverseHandler lists the short codes whose destination is ?url=,
+// compared after the same normalisation applied on creation. With
+// strict=false it matches by content hash instead, ignoring tracking
+// parameters. With active=true only codes that still redirect are
+// included.
+func apiReverseHandler(w http.ResponseWriter, r *http.Request) {
+	dest, err := validateURL(r.URL.Query().Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{}
+	if active, _ := strconv.ParseBool(r.URL.Query().Get("active")); active {
+		filter = liveFilter()
+	}
+	if strict, err := strconv.ParseBool(r.URL.Query().Get("strict")); err == nil && !strict {
+		filter["content_hash"] = contentHash(dest)
+	} else {
+		filter["url"] = dest
+	}
+	writeMappingPage(w, r, filter)
+}
+
+// writeMappingPage answers with the page of mappings matching filter
+// selected by the page and per_page query parameters, newest first.
+func writeMappingPage(w http.ResponseWriter, r *http.Request, filter bson.M) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > maxPerPage {
+		perPage = defaultPerPage
+	}
+
+	urls, total, err := findMappingPage(r.Context(), filter, (page-1)*perPage, perPage)
+	if err != nil {
+		log.Printf("Failed to list URLs: %v", err)
+		http.Error(w, "Failed to list URLs", http.StatusInternalServerError)
+		return
+	}
+	codes := make([]string, len(urls))
+	for i, m := range urls {
+		codes[i] = m.Code
+	}
+	go recordImpressions(codes)
+
+	writeJSON(w, http.StatusOK, listResponse{URLs: urls, Page: page, PerPage: perPage, Total: total})
+}
+
+// findMappingPage returns limit mappings matching filter, newest first,
+// after skipping skip, and how many match in all.
+func findMappingPage(ctx context.Context, filter bson.M, skip, limit int) ([]URLMapping, int64, error) {
+	if cfg.DryRun {
+		return dryRunFind(filter, skip, limit)
+	}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	urls := []URLMapping{}
+	err = cur.All(ctx, &urls)
+	return urls, total, err
+}
+
+// apiDeleteHandler removes a short URL and its click events for good. Use
+// PATCH with "disabled": true to take a link down reversibly.
+func apiDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	found, err := deleteShortURL(r.Context(), code)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Failed to delete short URL", "error", err.Error())
+		http.Error(w, "Failed to delete URL", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteShortURL removes code with its click events and link-in-bio
+// profile, reporting false if there was no such short URL.
+func deleteShortURL(ctx context.Context, code string) (bool, error) {
+	if cfg.DryRun {
+		_, found := shortURLs.Get(code)
+		shortURLs.Delete(code)
+		return found, nil
+	}
+	res, err := collection.DeleteOne(ctx, bson.M{"code": code})
+	if err != nil || res.DeletedCount == 0 {
+		return false, err
+	}
+	if _, err := clickEvents.DeleteMany(ctx, bson.M{"code": code}); err != nil {
+		loggerFrom(ctx).Error("Failed to delete click events", "error", err.Error())
+	}
+	if _, err := linkInBio.DeleteOne(ctx, bson.M{"code": code}); err != nil {
+		loggerFrom(ctx).Error("Failed to delete link-in-bio profile", "error", err.Error())
+	}
+	shortURLs.Delete(code)
+	publishMutation(replicateDelete, URLMapping{Code: code})
+	lifecycle.PublishDeleted(code)
+	return true, nil
+}
+
+func apiUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	var req updateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	if req.URL != nil && req.TargetCode != nil {
+		http.Error(w, "Specify either url or target_code, not both", http.StatusBadRequest)
+		return
+	}
+	if req.URL != nil {
+		dest, err := validateDestinationURL(*req.URL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		set["url"] = dest
+		set["content_hash"] = contentHash(dest)
+		unset["target_code"] = ""
+		// The old destination's health says nothing about the new one.
+		unset["health_history"] = ""
+	}
+	if req.TargetCode != nil {
+		if err := checkChain(code, *req.TargetCode); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		set["target_code"] = normalizeCode(*req.TargetCode)
+		unset["url"] = ""
+		unset["content_hash"] = ""
+		unset["health_history"] = ""
+	}
+	if req.PublicStats != nil {
+		set["public_stats"] = *req.PublicStats
+	}
+	if req.DelaySeconds != nil {
+		if *req.DelaySeconds < 0 || *req.DelaySeconds > maxDelaySeconds {
+			http.Error(w, "delay_seconds must be between 0 and 60", http.StatusBadRequest)
+			return
+		}
+		set["delay_seconds"] = *req.DelaySeconds
+	}
+	if req.AdHTML != nil {
+		set["ad_html"] = *req.AdHTML
+	}
+	if req.ShadowURL != nil {
+		if *req.ShadowURL == "" {
+			unset["shadow_url"] = ""
+		} else {
+			shadow, err := validateURL(*req.ShadowURL)
+			if err != nil {
+				http.Error(w, "shadow_url: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			set["shadow_url"] = shadow
+		}
+	}
+	if req.Disabled != nil {
+		set["disabled"] = *req.Disabled
+	}
+	if req.Cloak != nil {
+		set["cloak"] = *req.Cloak
+	}
+	if req.RequiresSignature != nil {
+		if *req.RequiresSignature && cfg.HMACSecret == "" {
+			http.Error(w, errSigningDisabled.Error(), http.StatusBadRequest)
+			return
+		}
+		set["requires_signature"] = *req.RequiresSignature
+	}
+	if req.RedirectType != nil {
+		if err := validateRedirectType(*req.RedirectType); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if *req.RedirectType == 0 {
+			unset["redirect_type"] = ""
+		} else {
+			set["redirect_type"] = *req.RedirectType
+		}
+	}
+	if req.NotifyBeforeDeleteHours != nil {
+		if err := validateNotifyBeforeDelete(*req.NotifyBeforeDeleteHours); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if *req.NotifyBeforeDeleteHours == 0 {
+			unset["notify_before_delete_hours"] = ""
+		} else {
+			set["notify_before_delete_hours"] = *req.NotifyBeforeDeleteHours
+		}
+		unset["pre_delete_notified_at"] = ""
+	}
+	if req.SamplingRate != nil {
+		if err := validateSamplingRate(*req.SamplingRate); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if *req.SamplingRate == 1 {
+			unset["sampling_rate"] = ""
+		} else {
+			set["sampling_rate"] = *req.SamplingRate
+		}
+	}
+	if req.Priority != nil {
+		switch *req.Priority {
+		case priorityPremium:
+			set["priority"] = priorityPremium
+		case priorityFree, "":
+			unset["priority"] = ""
+		default:
+			http.Error(w, errInvalidPriority.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.AlwaysPreview != nil {
+		set["always_preview"] = *req.AlwaysPreview
+	}
+	if req.VerifySSLPin != nil {
+		set["verify_ssl_pin"] = *req.VerifySSLPin
+	}
+	if req.ExpectedPins != nil {
+		pins, err := normalizePins(*req.ExpectedPins)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(pins) == 0 {
+			unset["expected_pins"] = ""
+		} else {
+			set["expected_pins"] = pins
+		}
+	}
+	if req.AppDeepLink != nil {
+		if *req.AppDeepLink == "" {
+			unset["app_deep_link"] = ""
+		} else {
+			deepLink, err := validateDeepLink(*req.AppDeepLink)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			set["app_deep_link"] = deepLink
+		}
+	}
+	if req.FallbackURL != nil {
+		if *req.FallbackURL == "" {
+			unset["fallback_url"] = ""
+		} else {
+			fallback, err := validateDestinationURL(*req.FallbackURL)
+			if err != nil {
+				http.Error(w, "fallback_url: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			set["fallback_url"] = fallback
+		}
+	}
+	if req.Tags != nil {
+		if len(*req.Tags) == 0 {
+			unset["tags"] = ""
+		} else {
+			set["tags"] = mergeTags(nil, *req.Tags)
+		}
+	}
+	if req.CustomHeaders != nil {
+		headers, err := validateCustomHeaders(*req.CustomHeaders)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if headers == nil {
+			unset["custom_headers"] = ""
+		} else {
+			set["custom_headers"] = headers
+		}
+	}
+	if req.ContactEmail != nil {
+		if *req.ContactEmail == "" {
+			unset["contact_email"] = ""
+		} else {
+			addr, err := mail.ParseAddress(*req.ContactEmail)
+			if err != nil {
+				http.Error(w, "contact_email is not a valid email address", http.StatusBadRequest)
+				return
+			}
+			set["contact_email"] = addr.Address
+		}
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := updateShortURL(r.Context(), code, requestActor(r), set, unset)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		loggerFrom(r.Context()).Error("Failed to update short URL", "error", err.Error())
+		http.Error(w, "Failed to update URL", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// updateShortURL applies validated changes to code on behalf of actor and
+// returns the result. It fails with mongo.ErrNoDocuments for an unknown
+// code.
+func updateShortURL(ctx context.Context, code, actor string, set, unset bson.M) (URLMapping, error) {
+	if disabled, ok := set["disabled"].(bool); ok && disabled {
+		set["disabled_at"] = time.Now()
+	} else if ok {
+		if unset == nil {
+			unset = bson.M{}
+		}
+		unset["disabled_at"] = ""
+	}
+
+	if cfg.DryRun {
+		return dryRunUpdate(code, set, unset)
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var updated URLMapping
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"code": code},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		return updated, err
+	}
+
+	shortURLs.Update(code, func(m *URLMapping) { *m = updated })
+	publishMutation(replicateUpsert, updated)
+	verifiedPins.Delete(code)
+	if _, ok := set["url"]; ok {
+		enqueueScreenshot(code, updated.URL)
+	}
+	auditUpdate(code, actor, set, unset)
+	return updated, nil
+}
+
+func apiAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("format") {
+		analyticsExportHandler(w, r)
+		return
+	}
+	code := codeParam(r)
+
+	mapping, err := findInMongoDB(code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+
+	daily, err := dailyClicks(r.Context(), code, 30)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Failed to aggregate clicks", "error", err.Error())
+		http.Error(w, "Failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+
+	byType, err := clicksByRedirectType(r.Context(), code)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Failed to aggregate redirect types", "error", err.Error())
+		http.Error(w, "Failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+
+	bySource, err := clicksBySource(r.Context(), code)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Failed to aggregate sources", "error", err.Error())
+		http.Error(w, "Failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, analyticsResponse{
+		codeStats: codeStats{
+			Code:        mapping.Code,
+			URL:         mapping.URL,
+			Clicks:      mapping.Clicks,
+			Impressions: mapping.Impressions,
+			CreatedAt:   mapping.CreatedAt,
+		},
+		Daily:          daily,
+		CTR:            clickThroughRate(mapping.Clicks, mapping.Impressions),
+		ByRedirectType: byType,
+		BySource:       bySource,
+	})
+}
+
+const maxBatchStatsCodes = 50
+
+// apiBatchStatsHandler returns the analytics totals for up to 50 codes from
+// one query, keyed by code. Unknown codes are left out of the result.
+func apiBatchStatsHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req struct {
+		Codes []string `json:"codes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Codes) == 0 || len(req.Codes) > maxBatchStatsCodes {
+		http.Error(w, "codes must list between 1 and 50 codes", http.StatusBadRequest)
+		return
+	}
+	for i, code := range req.Codes {
+		req.Codes[i] = normalizeCode(code)
+	}
+
+	cur, err := collection.Find(r.Context(),
+		bson.M{"code": bson.M{"$in": req.Codes}},
+		options.Find().SetProjection(bson.M{"code": 1, "url": 1, "clicks": 1, "impressions": 1, "created_at": 1}),
+	)
+	if err != nil {
+		log.Printf("Failed to load batch stats: %v", err)
+		http.Error(w, "Failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+	var found []codeStats
+	if err := cur.All(r.Context(), &found); err != nil {
+		log.Printf("Failed to load batch stats: %v", err)
+		http.Error(w, "Failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+
+	stats := make(map[string]codeStats, len(found))
+	for _, s := range found {
+		stats[s.Code] = s
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// clicksBySource counts code's click events by their source. Events
+// without one are left out.
+func clicksBySource(ctx context.Context, code string) (map[string]int64, error) {
+	cur, err := clickEvents.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"code": code, "source": bson.M{"$exists": true}}},
+		bson.M{"$group": bson.M{"_id": "$source", "count": clickCount}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []CountEntry
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Key] = row.Count
+	}
+	return counts, nil
+}
+
+// clicksByRedirectType counts the click events for code by the status they
+// were redirected with. Events recorded before redirect_type existed are
+// left out.
+func clicksByRedirectType(ctx context.Context, code string) (map[string]int64, error) {
+	cur, err := clickEvents.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"code": code, "redirect_type": bson.M{"$exists": true}}},
+		bson.M{"$group": bson.M{"_id": "$redirect_type", "count": clickCount}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Status int   `bson:"_id"`
+		Count  int64 `bson:"count"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	byType := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		byType[strconv.Itoa(row.Status)] = row.Count
+	}
+	return byType, nil
+}
+
+// dailyClicks buckets the click events for code by UTC day over the last
+// days days, oldest first.
+func dailyClicks(ctx context.Context, code string, days int) ([]DailyClicks, error) {
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	cur, err := clickEvents.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"code": code, "timestamp": bson.M{"$gte": since}}},
+		bson.M{"$group": bson.M{
+			"_id":    bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$timestamp"}},
+			"clicks": clickCount,
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	daily := []DailyClicks{}
+	if err := cur.All(ctx, &daily); err != nil {
+		return nil, err
+	}
+	return daily, nil
+}
@@ -0,0 +1,172 @@
+package urlshortener
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIResolveHandler(t *testing.T) {
+	srv := newTestServer(t)
+	past := time.Now().Add(-time.Hour)
+
+	live := mustCreate(t, URLMapping{URL: "https://example.com/resolve"})
+	disabled := mustCreate(t, URLMapping{URL: "https://example.com/disabled", Disabled: true})
+	expired := mustCreate(t, URLMapping{URL: "https://example.com/expired", ExpiresAt: &past})
+	signed := mustCreate(t, URLMapping{URL: "https://example.com/signed", RequiresSignature: true})
+	chained := mustCreate(t, URLMapping{TargetCode: live.Code})
+
+	tests := []struct {
+		name    string
+		path    string
+		status  int
+		wantURL string
+	}{
+		{"live", "/api/v1/" + live.Code, http.StatusOK, "https://example.com/resolve"},
+		{"unknown", "/api/v1/resolve-missing", http.StatusNotFound, ""},
+		{"disabled", "/api/v1/" + disabled.Code, http.StatusNotFound, ""},
+		{"expired", "/api/v1/" + expired.Code, http.StatusNotFound, ""},
+		{"signature missing", "/api/v1/" + signed.Code, http.StatusForbidden, ""},
+		{"signature wrong", "/api/v1/" + signed.Code + "?sig=AAAA", http.StatusForbidden, ""},
+		{"signed", "/api/v1/" + signed.Code + "?sig=" + signCode(signed.Code), http.StatusOK, "https://example.com/signed"},
+		{"chain", "/api/v1/" + chained.Code, http.StatusOK, "https://example.com/resolve"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var got resolveResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if got.URL != tt.wantURL {
+				t.Errorf("GET %s: url = %q, want %q", tt.path, got.URL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	tests := []struct {
+		code            string
+		caseInsensitive bool
+		want            error
+	}{
+		{"abc", false, nil},
+		{"My_Code-42", false, nil},
+		{strings.Repeat("a", 32), false, nil},
+		{"ab", false, errInvalidCode},
+		{strings.Repeat("a", 33), false, errInvalidCode},
+		{"has space", false, errInvalidCode},
+		{"slash/code", false, errInvalidCode},
+		{"ping", false, errInvalidCode},
+		{"shorten", false, errInvalidCode},
+		{"api", false, errInvalidCode},
+		{"Ping", false, nil},
+		{"Ping", true, errInvalidCode},
+		{"SHORTEN", true, errInvalidCode},
+		{"fuck", false, errProfaneCode},
+		{"F-u_C-k", false, errProfaneCode},
+		{"sh1tbox", false, errProfaneCode},
+		{"document", false, nil},
+		{"b1tch", false, errProfaneCode},
+	}
+	saved := cfg.CaseInsensitive
+	t.Cleanup(func() { cfg.CaseInsensitive = saved })
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			cfg.CaseInsensitive = tt.caseInsensitive
+			if err := validateCode(tt.code); !errors.Is(err, tt.want) {
+				t.Errorf("validateCode(%q) with case-insensitive %v = %v, want %v", tt.code, tt.caseInsensitive, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://example.com/path", "https://example.com/path"},
+		{"https://xn--bcher-kva.example/", "https://bücher.example/"},
+		{"https://xn--e1afmkfd.xn--80akhbyknj4f/", "https://пример.испытание/"},
+		{"https://xn--mgbh0fb.xn--kgbechtv/", "https://مثال.إختبار/"},
+		{"https://xn--fsqu00a.xn--0zwm56d/", "https://例子.测试/"},
+		{"https://xn--r8jz45g.xn--zckzah/", "https://例え.テスト/"},
+		{"https://xn--bcher-kva.example:8443/x", "https://bücher.example:8443/x"},
+		{"https://user:pw@xn--bcher-kva.example/", "https://user:pw@bücher.example/"},
+		// Mixed Cyrillic and Latin stays Punycode so it can't pass for apple.com.
+		{"https://xn--pple-43d.com/", "https://xn--pple-43d.com/"},
+		{"https://xn--zz/", "https://xn--zz/"},
+		{"://bad", "://bad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := displayURL(tt.raw); got != tt.want {
+				t.Errorf("displayURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMixedScript(t *testing.T) {
+	tests := []struct {
+		label string
+		want  bool
+	}{
+		{"example", false},
+		{"bücher", false},
+		{"пример", false},
+		{"例え", false},
+		{"テスト123", false},
+		{"а-pple", true},
+		{"paypаl", true},
+		{"examplе例", true},
+	}
+	for _, tt := range tests {
+		if got := mixedScript(tt.label); got != tt.want {
+			t.Errorf("mixedScript(%q) = %v, want %v", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestShortenBodyTooLarge(t *testing.T) {
+	resetRateBuckets(t)
+	srv := newTestServer(t)
+	padding := strings.Repeat("a", maxBodyBytes)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"form", "/shorten", "application/x-www-form-urlencoded", "url=https%3A%2F%2Fexample.com%2F&pad=" + padding},
+		{"api", "/api/v1/shorten", "application/json", `{"url":"https://example.com/","pad":"` + padding + `"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+tt.path, tt.contentType, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusRequestEntityTooLarge {
+				t.Errorf("POST %s with a %d-byte body: status = %d, want %d", tt.path, len(tt.body), resp.StatusCode, http.StatusRequestEntityTooLarge)
+			}
+		})
+	}
+}
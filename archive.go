@@ -0,0 +1,220 @@
+package urlshortener
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/net/html"
+)
+
+const (
+	archiveMaxBytes = 1 << 20
+	goneProbeTTL    = 5 * time.Minute
+)
+
+// fetchClient fetches user-supplied destinations, for archives, robots.txt
+// and validation, so it only connects to public addresses.
+var fetchClient = &http.Client{Timeout: 10 * time.Second, Transport: publicTransport()}
+
+type Archive struct {
+	Title       string `bson:"title,omitempty" json:"title,omitempty"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	StatusCode  int    `bson:"status_code" json:"status_code"`
+}
+
+var goneTpl = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Link gone</title>
+</head>
+<body>
+    <h1>This link is gone</h1>
+    <p>The page at <code>{{.URL}}</code> no longer exists.</p>
+    {{with .Archive}}
+    <h2>It used to point to</h2>
+    {{if .Title}}<p><strong>{{.Title}}</strong></p>{{end}}
+    {{if .Description}}<p>{{.Description}}</p>{{end}}
+    {{end}}
+    {{with .ArchivedAt}}<p><small>Snapshot taken {{.Format "2 January 2006"}}.</small></p>{{end}}
+</body>
+</html>
+`))
+
+// archiveDestination fetches dest and stores its title, description and
+// status on the mapping, along with its Open Graph preview. Run it in a
+// goroutine after creation.
+func archiveDestination(code, dest string) {
+	archive, preview, err := snapshot(dest)
+	if err != nil {
+		log.Printf("Failed to archive %s for %s: %v", dest, code, err)
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{"archive": archive, "archived_at": now}
+	if preview != nil {
+		set["preview"] = preview
+	}
+	_, err = collection.UpdateOne(context.Background(), bson.M{"code": code}, bson.M{"$set": set})
+	if err != nil {
+		log.Printf("Failed to save archive for %s: %v", code, err)
+		return
+	}
+
+	shortURLs.Update(code, func(m *URLMapping) {
+		m.Archive = archive
+		m.ArchivedAt = &now
+		if preview != nil {
+			m.Preview = preview
+		}
+	})
+}
+
+// snapshot fetches dest. The preview is nil unless the page is HTML with
+// something to show.
+func snapshot(dest string) (*Archive, *LinkPreview, error) {
+	resp, err := fetchClient.Get(dest)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	archive := &Archive{StatusCode: resp.StatusCode}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return archive, nil, nil
+	}
+	meta := pageMetadata(io.LimitReader(resp.Body, archiveMaxBytes))
+	archive.Title, archive.Description = meta.title, meta.description
+
+	preview := &LinkPreview{
+		Title:       meta.ogTitle,
+		Description: meta.description,
+		Image:       absoluteHTTPURL(resp.Request.URL, meta.image),
+		SiteName:    meta.siteName,
+	}
+	if preview.Title == "" {
+		preview.Title = meta.title
+	}
+	if *preview == (LinkPreview{}) {
+		preview = nil
+	}
+	return archive, preview, nil
+}
+
+// absoluteHTTPURL resolves ref against base, returning "" unless the
+// result is an http or https URL.
+func absoluteHTTPURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+type pageMeta struct {
+	title, ogTitle, description, image, siteName string
+}
+
+// pageMetadata extracts the <title>, meta description and Open Graph tags
+// from an HTML document, stopping at the end of <head>.
+func pageMetadata(r io.Reader) (meta pageMeta) {
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "title":
+				inTitle = true
+			case "meta":
+				var name, content string
+				for _, a := range tok.Attr {
+					switch strings.ToLower(a.Key) {
+					case "name", "property":
+						name = strings.ToLower(a.Val)
+					case "content":
+						content = strings.TrimSpace(a.Val)
+					}
+				}
+				switch {
+				case meta.description == "" && (name == "description" || name == "og:description"):
+					meta.description = content
+				case meta.ogTitle == "" && name == "og:title":
+					meta.ogTitle = content
+				case meta.image == "" && (name == "og:image" || name == "og:image:url" || name == "twitter:image"):
+					meta.image = content
+				case meta.siteName == "" && name == "og:site_name":
+					meta.siteName = content
+				}
+			case "body":
+				return
+			}
+		case html.TextToken:
+			if inTitle && meta.title == "" {
+				meta.title = strings.TrimSpace(string(z.Text()))
+			}
+		case html.EndTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "title":
+				inTitle = false
+			case "head":
+				return
+			}
+		}
+	}
+}
+
+var goneProbes sync.Map // code -> goneProbe
+
+type goneProbe struct {
+	gone    bool
+	checked time.Time
+}
+
+// destinationGone reports whether dest currently answers 404 or 410. Results
+// are cached per code so archived links aren't probed on every redirect.
+func destinationGone(code, dest string) bool {
+	if p, ok := goneProbes.Load(code); ok && time.Since(p.(goneProbe).checked) < goneProbeTTL {
+		return p.(goneProbe).gone
+	}
+
+	gone := false
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, dest, nil)
+	if err == nil {
+		if resp, err := fetchClient.Do(req); err == nil {
+			resp.Body.Close()
+			gone = resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone
+		}
+	}
+
+	goneProbes.Store(code, goneProbe{gone: gone, checked: time.Now()})
+	return gone
+}
+
+func renderGone(w http.ResponseWriter, m URLMapping) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	if err := goneTpl.Execute(w, m); err != nil {
+		log.Printf("Error rendering gone page for %s: %v", m.Code, err)
+	}
+}
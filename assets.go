@@ -0,0 +1,69 @@
+package urlshortener
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+)
+
+//go:embed assets
+var assetsFS embed.FS
+
+// assetVersions maps an asset name to a content hash used as a cache-busting
+// query parameter, so assets can be cached forever.
+var assetVersions = func() map[string]string {
+	versions := make(map[string]string)
+	err := fs.WalkDir(assetsFS, "assets", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := assetsFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		versions[path.Base(p)] = hex.EncodeToString(sum[:])[:12]
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return versions
+}()
+
+// assetURL returns the versioned URL of an embedded asset.
+func assetURL(name string) string {
+	return "/assets/" + name + "?v=" + assetVersions[name]
+}
+
+func assetsHandler() http.Handler {
+	files := http.FileServer(http.FS(assetsFS))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Encoding")
+		if v := r.URL.Query().Get("v"); v != "" && v == assetVersions[path.Base(r.URL.Path)] {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// pushAssets proactively pushes the given assets when the client is on
+// HTTP/2. It is a no-op for HTTP/1.x connections.
+func pushAssets(w http.ResponseWriter, names ...string) {
+	pusher, ok := w.(http.Pusher)
+	if !ok {
+		return
+	}
+	for _, name := range names {
+		err := pusher.Push(assetURL(name), &http.PushOptions{
+			Header: http.Header{"Accept-Encoding": {"gzip"}},
+		})
+		if err != nil && err != http.ErrNotSupported {
+			log.Printf("Failed to push %s: %v", name, err)
+		}
+	}
+}
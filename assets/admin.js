@@ -0,0 +1,84 @@
+(function () {
+    "use strict";
+
+    var status = document.getElementById("live-status");
+    var list = document.getElementById("live-events");
+    var stream = new EventSource("/admin/stream");
+
+    function log(text) {
+        var li = document.createElement("li");
+        li.textContent = new Date().toLocaleTimeString() + " " + text;
+        list.insertBefore(li, list.firstChild);
+        while (list.children.length > 20) {
+            list.removeChild(list.lastChild);
+        }
+    }
+    function count(type) {
+        var el = document.getElementById("count-" + type);
+        el.textContent = Number(el.textContent) + 1;
+    }
+
+    stream.onerror = function () { status.textContent = "Disconnected, retrying\u2026"; };
+    stream.addEventListener("heartbeat", function (e) {
+        status.textContent = "Live, last heartbeat " + new Date(JSON.parse(e.data).time).toLocaleTimeString();
+    });
+    stream.addEventListener("created", function (e) {
+        var d = JSON.parse(e.data);
+        count("created");
+        log("Created " + d.code + " \u2192 " + (d.url || d.target_code));
+    });
+    stream.addEventListener("clicked", function (e) {
+        var d = JSON.parse(e.data);
+        count("clicked");
+        log("Click on " + d.code + " \u2192 " + d.url);
+    });
+    stream.addEventListener("rate_limited", function (e) {
+        var d = JSON.parse(e.data);
+        count("rate_limited");
+        log("Rate limited " + d.ip + " on " + d.scope);
+    });
+    stream.addEventListener("breaker", function (e) {
+        var d = JSON.parse(e.data);
+        document.getElementById("breaker").textContent = d.state;
+        log("Circuit breaker " + d.state + " for " + d.uri);
+    });
+})();
+
+
+(function () {
+    "use strict";
+
+    // Each collection's URLs are loaded the first time it is expanded.
+    document.querySelectorAll("nav.collections details").forEach(function (folder) {
+        folder.addEventListener("toggle", function () {
+            if (!folder.open || folder.dataset.loaded) {
+                return;
+            }
+            folder.dataset.loaded = "1";
+            var list = folder.querySelector("ul");
+            fetch("/api/v1/collections/" + folder.dataset.collection + "/urls?per_page=100", {credentials: "same-origin"})
+                .then(function (resp) { return resp.json(); })
+                .then(function (page) {
+                    list.textContent = "";
+                    (page.urls || []).forEach(function (m) {
+                        var li = document.createElement("li");
+                        var a = document.createElement("a");
+                        a.href = m.short_url;
+                        a.textContent = m.code;
+                        a.title = m.url || m.target_code;
+                        li.appendChild(a);
+                        list.appendChild(li);
+                    });
+                    if (page.total > (page.urls || []).length) {
+                        var more = document.createElement("li");
+                        more.textContent = (page.total - page.urls.length) + " more";
+                        list.appendChild(more);
+                    }
+                })
+                .catch(function () {
+                    delete folder.dataset.loaded;
+                    list.textContent = "Failed to load";
+                });
+        });
+    });
+})();
@@ -0,0 +1,61 @@
+(function () {
+    "use strict";
+
+    var centroids = JSON.parse(document.getElementById("centroids").textContent);
+    var fadeMS = 5000;
+    var palette = ["#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4", "#f032e6", "#9a6324"];
+
+    var map = L.map("map", {worldCopyJump: true}).setView([20, 0], 2);
+    L.tileLayer("https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png", {
+        maxZoom: 8,
+        attribution: "&copy; OpenStreetMap contributors"
+    }).addTo(map);
+
+    var filter = document.getElementById("filter");
+    var status = document.getElementById("status");
+    var unlocated = document.getElementById("unlocated");
+    var missed = 0;
+
+    function colorFor(code, codes) {
+        if (codes.length === 0) {
+            return palette[0];
+        }
+        return palette[codes.indexOf(code) % palette.length];
+    }
+
+    // plot adds a dot near the country's centre, jittered so bursts
+    // from one country stay visible, and fades it out over fadeMS.
+    function plot(latlon, color) {
+        var at = [latlon[0] + (Math.random() - 0.5) * 2, latlon[1] + (Math.random() - 0.5) * 2];
+        var dot = L.circleMarker(at, {radius: 6, color: color, fillColor: color, fillOpacity: 0.8, weight: 1}).addTo(map);
+        var start = performance.now();
+        function step(now) {
+            var left = 1 - (now - start) / fadeMS;
+            if (left <= 0) {
+                map.removeLayer(dot);
+                return;
+            }
+            dot.setStyle({opacity: left, fillOpacity: 0.8 * left});
+            requestAnimationFrame(step);
+        }
+        requestAnimationFrame(step);
+    }
+
+    var stream = new EventSource("/admin/stream");
+    stream.onerror = function () { status.textContent = "Disconnected, retrying\u2026"; };
+    stream.addEventListener("heartbeat", function () { status.textContent = "Live"; });
+    stream.addEventListener("clicked", function (e) {
+        var d = JSON.parse(e.data);
+        var codes = filter.value.split(",").map(function (s) { return s.trim(); }).filter(Boolean);
+        if (codes.length > 0 && codes.indexOf(d.code) < 0) {
+            return;
+        }
+        var latlon = centroids[d.country];
+        if (!latlon) {
+            missed++;
+            unlocated.textContent = missed + " clicks without a location";
+            return;
+        }
+        plot(latlon, colorFor(d.code, codes));
+    });
+})();
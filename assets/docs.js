@@ -0,0 +1,28 @@
+(function () {
+    "use strict";
+
+    var root = document.getElementById("swagger-ui");
+
+    fetch(root.dataset.spec)
+        .then(function (res) {
+            if (!res.ok) {
+                throw new Error(res.status + " " + res.statusText);
+            }
+            return res.json();
+        })
+        .then(function (spec) {
+            // The spec's servers entry is relative; point "Try it out"
+            // at the public base URL instead.
+            spec.servers = [{url: root.dataset.server || window.location.origin}];
+            SwaggerUIBundle({
+                spec: spec,
+                domNode: root,
+                deepLinking: true,
+                tryItOutEnabled: true,
+                persistAuthorization: true
+            });
+        })
+        .catch(function (err) {
+            root.textContent = "Failed to load the API spec: " + err.message;
+        });
+})();
@@ -0,0 +1,21 @@
+(function () {
+    "use strict";
+
+    if (!navigator.clipboard) {
+        return;
+    }
+
+    document.querySelectorAll("li > a[href^='/']").forEach(function (link) {
+        var button = document.createElement("button");
+        button.type = "button";
+        button.className = "copy";
+        button.textContent = "Copy";
+        button.addEventListener("click", function () {
+            navigator.clipboard.writeText(link.href).then(function () {
+                button.textContent = "Copied";
+                setTimeout(function () { button.textContent = "Copy"; }, 1500);
+            });
+        });
+        link.after(button);
+    });
+})();
@@ -0,0 +1,14 @@
+(function () {
+    "use strict";
+
+    var button = document.getElementById("copy");
+    if (!button || !navigator.clipboard) {
+        return;
+    }
+    button.addEventListener("click", function () {
+        navigator.clipboard.writeText(button.dataset.url).then(function () {
+            button.textContent = "Copied";
+        });
+    });
+})();
+
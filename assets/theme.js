@@ -0,0 +1,46 @@
+(function () {
+    "use strict";
+
+    // Loaded in <head>, so the stored choice applies before the first
+    // paint; without one the system setting decides, through theme.css.
+    var root = document.documentElement;
+    var key = "theme";
+    var stored = null;
+    try {
+        stored = localStorage.getItem(key);
+    } catch (e) {
+        // Storage can be disabled; the toggle then lasts for the page.
+    }
+    if (stored === "light" || stored === "dark") {
+        root.dataset.theme = stored;
+    }
+
+    function current() {
+        if (root.dataset.theme) {
+            return root.dataset.theme;
+        }
+        return window.matchMedia("(prefers-color-scheme: dark)").matches ? "dark" : "light";
+    }
+
+    document.addEventListener("DOMContentLoaded", function () {
+        var button = document.getElementById("theme-toggle");
+        if (!button) {
+            return;
+        }
+        function label() {
+            button.textContent = current() === "dark" ? "Light mode" : "Dark mode";
+        }
+        button.addEventListener("click", function () {
+            var next = current() === "dark" ? "light" : "dark";
+            root.dataset.theme = next;
+            try {
+                localStorage.setItem(key, next);
+            } catch (e) {
+                // See above.
+            }
+            label();
+        });
+        window.matchMedia("(prefers-color-scheme: dark)").addEventListener("change", label);
+        label();
+    });
+})();
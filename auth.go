@@ -0,0 +1,151 @@
+package urlshortener
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+// authMiddleware restricts a handler to the admin account configured via
+// ADMIN_USER and ADMIN_PASSWORD, or a session started by panelAuth. With no
+// password configured every request is rejected rather than silently
+// leaving admin routes open.
+func authMiddleware(next http.Handler) http.Handler {
+	return requireAdmin(next, false)
+}
+
+// panelAuth is authMiddleware for the browser-facing admin pages: a
+// successful Basic Auth login there also starts a server-side session, so
+// the page's API calls are authorized by cookie. API clients sending Basic
+// Auth on every call don't get a session each time.
+func panelAuth(next http.Handler) http.Handler {
+	return requireAdmin(next, true)
+}
+
+func requireAdmin(next http.Handler, startSessions bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !adminClientAllowed(r) {
+			log.Printf("Refused admin request for %s from %s: not in ADMIN_ALLOWED_CIDRS", r.URL.Path, realIP(r))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		// Impersonating a user grants the admin API only if the user has it.
+		if c, ok := impersonation(r); ok {
+			if !c.Admin {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cfg.AdminPassword != "" && validSession(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, ok := adminBasicAuth(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="urlshortener admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// --dry-run has nowhere to keep sessions.
+		if startSessions && !cfg.DryRun {
+			if err := startSession(r.Context(), w, user, true); err != nil {
+				log.Printf("Failed to start session: %v", err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminBasicAuth returns the user of r's Basic Auth if it is the admin
+// account's.
+func adminBasicAuth(r *http.Request) (string, bool) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || cfg.AdminPassword == "" ||
+		subtle.ConstantTimeCompare([]byte(user), []byte(cfg.AdminUser)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.AdminPassword)) != 1 {
+		return "", false
+	}
+	return user, true
+}
+
+// isAdmin reports whether r would pass requireAdmin, for public handlers
+// that accept some fields from admins only.
+func isAdmin(r *http.Request) bool {
+	if !adminClientAllowed(r) {
+		return false
+	}
+	if c, ok := impersonation(r); ok {
+		return c.Admin
+	}
+	if cfg.AdminPassword != "" && validSession(r) {
+		return true
+	}
+	_, ok := adminBasicAuth(r)
+	return ok
+}
+
+func adminOnly(h http.HandlerFunc) http.Handler {
+	return authMiddleware(h)
+}
+
+// selfOrAdmin is adminOnly for the routes under /api/v1/users/{id} that a
+// user may also call on their own account, with their session or an
+// impersonation token for them.
+func selfOrAdmin(h http.HandlerFunc) http.Handler {
+	admin := authMiddleware(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := requestUser(r); ok && id == r.PathValue("id") {
+			h(w, r)
+			return
+		}
+		admin.ServeHTTP(w, r)
+	})
+}
+
+// requestUser returns the ID of the user r is made as, if it carries a
+// session or an impersonation token.
+func requestUser(r *http.Request) (string, bool) {
+	if c, ok := impersonation(r); ok {
+		return c.Subject, c.Subject != ""
+	}
+	if s, ok := currentSession(r); ok && s.UserID != "" {
+		return s.UserID, true
+	}
+	return "", false
+}
+
+// requireAPIKey restricts a handler to requests carrying one of API_KEYS
+// in the X-API-Key header or the api_key query parameter, the latter for
+// cross-origin callers such as bookmarklets that can't send headers
+// without a preflight. With no keys configured every request is rejected.
+func requireAPIKey(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key = r.URL.Query().Get("api_key")
+		}
+		if !validAPIKey(key) {
+			http.Error(w, "A valid API key is required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	valid := false
+	for _, k := range cfg.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
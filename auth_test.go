@@ -0,0 +1,83 @@
+package urlshortener
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// withAdminAllowlist restricts admin credentials to cidrs for the test,
+// judging clients by their peer address.
+func withAdminAllowlist(t *testing.T, cidrs string) {
+	t.Helper()
+	blocks, err := parseCIDRs(cidrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedCIDRs, savedTrust := cfg.AdminAllowedCIDRs, cfg.TrustProxy
+	cfg.AdminAllowedCIDRs, cfg.TrustProxy = blocks, trustNever
+	t.Cleanup(func() { cfg.AdminAllowedCIDRs, cfg.TrustProxy = savedCIDRs, savedTrust })
+}
+
+var adminAuthTests = []struct {
+	name       string
+	remoteAddr string
+	password   string // "" sends no credentials
+	admin      bool
+	status     int // requireAdmin's
+	meStatus   int
+}{
+	{"allowed, right password", "10.1.2.3:1000", testAdminPassword, true, http.StatusOK, http.StatusOK},
+	{"allowed, wrong password", "10.1.2.3:1000", "wrong", false, http.StatusUnauthorized, http.StatusUnauthorized},
+	{"allowed, no credentials", "10.1.2.3:1000", "", false, http.StatusUnauthorized, http.StatusUnauthorized},
+	{"outside, right password", "203.0.113.5:1000", testAdminPassword, false, http.StatusForbidden, http.StatusForbidden},
+	{"outside, wrong password", "203.0.113.5:1000", "wrong", false, http.StatusForbidden, http.StatusForbidden},
+	{"outside, no credentials", "203.0.113.5:1000", "", false, http.StatusForbidden, http.StatusForbidden},
+}
+
+func adminAuthRequest(remoteAddr, password string) *http.Request {
+	r := httptest.NewRequest("GET", "/api/v1/me", nil)
+	r.RemoteAddr = remoteAddr
+	if password != "" {
+		r.SetBasicAuth(cfg.AdminUser, password)
+	}
+	return r
+}
+
+func TestRequireAdmin(t *testing.T) {
+	withAdminAllowlist(t, "10.0.0.0/8")
+	h := authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range adminAuthTests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, adminAuthRequest(tt.remoteAddr, tt.password))
+			if w.Code != tt.status {
+				t.Errorf("requireAdmin = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	withAdminAllowlist(t, "10.0.0.0/8")
+	for _, tt := range adminAuthTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAdmin(adminAuthRequest(tt.remoteAddr, tt.password)); got != tt.admin {
+				t.Errorf("isAdmin = %v, want %v", got, tt.admin)
+			}
+		})
+	}
+}
+
+func TestMeHandler(t *testing.T) {
+	withAdminAllowlist(t, "10.0.0.0/8")
+	for _, tt := range adminAuthTests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			meHandler(w, adminAuthRequest(tt.remoteAddr, tt.password))
+			if w.Code != tt.meStatus {
+				t.Errorf("meHandler = %d, want %d", w.Code, tt.meStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	bigQueryBatchSize     = 1000
+	bigQueryFlushInterval = 30 * time.Second
+
+	// bigQueryBuffer is how many clicks may wait for export; beyond it
+	// clicks are still stored in MongoDB but not exported.
+	bigQueryBuffer = 10 * bigQueryBatchSize
+)
+
+// clickEventSchema mirrors ClickEvent, one column per bson field.
+var clickEventSchema = bigquery.Schema{
+	{Name: "code", Type: bigquery.StringFieldType, Required: true},
+	{Name: "timestamp", Type: bigquery.TimestampFieldType, Required: true},
+	{Name: "ip", Type: bigquery.StringFieldType},
+	{Name: "user_agent", Type: bigquery.StringFieldType},
+	{Name: "referrer", Type: bigquery.StringFieldType},
+	{Name: "country", Type: bigquery.StringFieldType},
+	{Name: "bot", Type: bigquery.BooleanFieldType},
+	{Name: "chain", Type: bigquery.StringFieldType, Repeated: true},
+	{Name: "redirect_type", Type: bigquery.IntegerFieldType},
+	{Name: "link_index", Type: bigquery.IntegerFieldType},
+	{Name: "source", Type: bigquery.StringFieldType},
+}
+
+// bigQueryEvents buffers recorded clicks for the exporter; nil when
+// BIGQUERY_PROJECT isn't set.
+var bigQueryEvents chan ClickEvent
+
+var bigQueryDropped atomic.Int64
+
+// startBigQueryExport streams click events to BIGQUERY_PROJECT through the
+// Storage Write API's default stream, creating the table, partitioned by
+// day, if it doesn't exist. Credentials come from Application Default
+// Credentials, e.g. GOOGLE_APPLICATION_CREDENTIALS.
+func startBigQueryExport(ctx context.Context) {
+	if cfg.BigQueryProject == "" {
+		return
+	}
+
+	if err := ensureBigQueryTable(ctx); err != nil {
+		log.Printf("BigQuery export disabled: %v", err)
+		return
+	}
+	writer, err := managedwriter.NewClient(ctx, cfg.BigQueryProject)
+	if err != nil {
+		log.Printf("BigQuery export disabled: %v", err)
+		return
+	}
+	storageSchema, err := adapt.BQSchemaToStorageTableSchema(clickEventSchema)
+	if err != nil {
+		log.Printf("BigQuery export disabled: %v", err)
+		return
+	}
+	d, err := adapt.StorageSchemaToProto2Descriptor(storageSchema, "root")
+	if err != nil {
+		log.Printf("BigQuery export disabled: %v", err)
+		return
+	}
+	desc := d.(protoreflect.MessageDescriptor)
+	normalized, err := adapt.NormalizeDescriptor(desc)
+	if err != nil {
+		log.Printf("BigQuery export disabled: %v", err)
+		return
+	}
+	stream, err := writer.NewManagedStream(ctx,
+		managedwriter.WithDestinationTable(managedwriter.TableParentFromParts(cfg.BigQueryProject, cfg.BigQueryDataset, cfg.BigQueryTable)),
+		managedwriter.WithType(managedwriter.DefaultStream),
+		managedwriter.WithSchemaDescriptor(normalized),
+		managedwriter.EnableWriteRetries(true),
+	)
+	if err != nil {
+		log.Printf("BigQuery export disabled: %v", err)
+		return
+	}
+
+	bigQueryEvents = make(chan ClickEvent, bigQueryBuffer)
+	go func() {
+		ticker := time.NewTicker(bigQueryFlushInterval)
+		defer ticker.Stop()
+		batch := make([]ClickEvent, 0, bigQueryBatchSize)
+		flush := func() {
+			if len(batch) > 0 {
+				if err := appendClickEvents(ctx, stream, desc, batch); err != nil {
+					log.Printf("BigQuery export of %d click events failed: %v", len(batch), err)
+				}
+				batch = batch[:0]
+			}
+			if n := bigQueryDropped.Swap(0); n > 0 {
+				log.Printf("BigQuery export buffer full, %d click events not exported", n)
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				flush()
+				stream.Close()
+				return
+			case ev := <-bigQueryEvents:
+				batch = append(batch, ev)
+				if len(batch) == bigQueryBatchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+	log.Printf("Exporting click events to BigQuery %s.%s.%s", cfg.BigQueryProject, cfg.BigQueryDataset, cfg.BigQueryTable)
+}
+
+func ensureBigQueryTable(ctx context.Context) error {
+	bq, err := bigquery.NewClient(ctx, cfg.BigQueryProject)
+	if err != nil {
+		return err
+	}
+	defer bq.Close()
+	err = bq.Dataset(cfg.BigQueryDataset).Table(cfg.BigQueryTable).Create(ctx, &bigquery.TableMetadata{
+		Schema:           clickEventSchema,
+		TimePartitioning: &bigquery.TimePartitioning{Field: "timestamp"},
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+// exportClick queues ev for BigQuery without blocking the click path.
+func exportClick(ev ClickEvent) {
+	if bigQueryEvents == nil {
+		return
+	}
+	select {
+	case bigQueryEvents <- ev:
+	default:
+		bigQueryDropped.Add(1)
+	}
+}
+
+func appendClickEvents(ctx context.Context, stream *managedwriter.ManagedStream, desc protoreflect.MessageDescriptor, batch []ClickEvent) error {
+	rows := make([][]byte, 0, len(batch))
+	for _, ev := range batch {
+		row, err := encodeClickEvent(desc, ev)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	res, err := stream.AppendRows(ctx, rows)
+	if err != nil {
+		return err
+	}
+	_, err = res.GetResult(ctx)
+	return err
+}
+
+// encodeClickEvent builds the row for ev, leaving empty optional columns
+// NULL as they are absent in MongoDB.
+func encodeClickEvent(desc protoreflect.MessageDescriptor, ev ClickEvent) ([]byte, error) {
+	msg := dynamicpb.NewMessage(desc)
+	fields := desc.Fields()
+	set := func(name string, v protoreflect.Value) {
+		msg.Set(fields.ByName(protoreflect.Name(name)), v)
+	}
+	setString := func(name, s string) {
+		if s != "" {
+			set(name, protoreflect.ValueOfString(s))
+		}
+	}
+
+	set("code", protoreflect.ValueOfString(ev.Code))
+	set("timestamp", protoreflect.ValueOfInt64(ev.Timestamp.UnixMicro()))
+	setString("ip", ev.IP)
+	setString("user_agent", ev.UserAgent)
+	setString("referrer", ev.Referrer)
+	setString("country", ev.Country)
+	set("bot", protoreflect.ValueOfBool(ev.Bot))
+	if len(ev.Chain) > 0 {
+		chain := msg.Mutable(fields.ByName("chain")).List()
+		for _, c := range ev.Chain {
+			chain.Append(protoreflect.ValueOfString(c))
+		}
+	}
+	if ev.RedirectType != 0 {
+		set("redirect_type", protoreflect.ValueOfInt64(int64(ev.RedirectType)))
+	}
+	if ev.LinkIndex != nil {
+		set("link_index", protoreflect.ValueOfInt64(int64(*ev.LinkIndex)))
+	}
+	setString("source", ev.Source)
+	return proto.Marshal(msg)
+}
@@ -0,0 +1,218 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/idna"
+)
+
+var blockedDomains *mongo.Collection
+
+var errBlockedDomain = errors.New("destinations on this domain are not allowed")
+
+// BlockedDomain bars short URLs to a domain and its subdomains. Domain is
+// the ASCII form of the hostname, as validateURL stores destinations.
+type BlockedDomain struct {
+	Domain  string    `bson:"_id" json:"domain"`
+	AddedAt time.Time `bson:"added_at" json:"added_at"`
+}
+
+// blocklist is the set of blocked domains. It is read on every shortening
+// and replaced whole, never modified, so readers need no lock.
+var blocklist atomic.Pointer[map[string]struct{}]
+
+// blocklistMu orders the replacements of blocklist, so a reload can't
+// overwrite a newer list with the one it read before an admin's change.
+var blocklistMu sync.Mutex
+
+// loadBlockedDomains replaces blocklist with the contents of the
+// blocked_domains collection.
+func loadBlockedDomains(ctx context.Context) error {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	cur, err := blockedDomains.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var list []BlockedDomain
+	if err := cur.All(ctx, &list); err != nil {
+		return err
+	}
+	domains := make(map[string]struct{}, len(list))
+	for _, d := range list {
+		domains[d.Domain] = struct{}{}
+	}
+	blocklist.Store(&domains)
+	return nil
+}
+
+// watchBlockedDomains reloads blocklist with load periodically, which
+// picks up changes made on other instances.
+func watchBlockedDomains(ctx context.Context, load func(context.Context) error) {
+	ticker := time.NewTicker(domainReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := load(ctx); err != nil {
+				log.Printf("Failed to reload blocked domains: %v", err)
+			}
+		}
+	}
+}
+
+// updateBlocklist swaps in a copy of blocklist changed by edit.
+func updateBlocklist(edit func(map[string]struct{})) {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	domains := make(map[string]struct{})
+	if cur := blocklist.Load(); cur != nil {
+		for d := range *cur {
+			domains[d] = struct{}{}
+		}
+	}
+	edit(domains)
+	blocklist.Store(&domains)
+}
+
+// checkBlockedDomain returns errBlockedDomain if dest, a URL that passed
+// validateURL, is on a blocked domain or one of its subdomains.
+func checkBlockedDomain(dest string) error {
+	domains := blocklist.Load()
+	if domains == nil || len(*domains) == 0 {
+		return nil
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for {
+		if _, ok := (*domains)[host]; ok {
+			return errBlockedDomain
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			return nil
+		}
+		host = parent
+	}
+}
+
+// normalizeDomain turns an admin's spelling of a domain, which may be a
+// URL or Unicode, into the form blocklist holds.
+func normalizeDomain(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", err
+		}
+		raw = u.Hostname()
+	}
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(raw, "."))
+	if err != nil {
+		return "", err
+	}
+	if domain == "" {
+		return "", errors.New("domain is required")
+	}
+	return domain, nil
+}
+
+func apiListBlockedDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	cur, err := blockedDomains.Find(r.Context(), bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		log.Printf("Failed to list blocked domains: %v", err)
+		http.Error(w, "Failed to list blocked domains", http.StatusInternalServerError)
+		return
+	}
+	list := []BlockedDomain{}
+	if err := cur.All(r.Context(), &list); err != nil {
+		log.Printf("Failed to list blocked domains: %v", err)
+		http.Error(w, "Failed to list blocked domains", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// apiBlockDomainHandler blocks new short URLs to a domain and its
+// subdomains, on this instance at once and on the others within
+// domainReloadInterval. Existing short URLs keep redirecting.
+func apiBlockDomainHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	domain, err := normalizeDomain(req.Domain)
+	if err != nil {
+		http.Error(w, "Invalid domain: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d := BlockedDomain{Domain: domain, AddedAt: time.Now()}
+	if _, err := blockedDomains.InsertOne(r.Context(), d); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "Domain is already blocked", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to block domain %s: %v", domain, err)
+		http.Error(w, "Failed to block domain", http.StatusInternalServerError)
+		return
+	}
+	updateBlocklist(func(domains map[string]struct{}) { domains[domain] = struct{}{} })
+	log.Printf("Blocked domain %s", domain)
+	writeJSON(w, http.StatusCreated, d)
+}
+
+func apiUnblockDomainHandler(w http.ResponseWriter, r *http.Request) {
+	domain, err := normalizeDomain(r.PathValue("domain"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	res, err := blockedDomains.DeleteOne(r.Context(), bson.M{"_id": domain})
+	if err != nil {
+		log.Printf("Failed to unblock domain %s: %v", domain, err)
+		http.Error(w, "Failed to unblock domain", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	updateBlocklist(func(domains map[string]struct{}) { delete(domains, domain) })
+	log.Printf("Unblocked domain %s", domain)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateDestinationURL is validateURL for where a short URL sends people,
+// which mustn't be on a blocked domain.
+func validateDestinationURL(raw string) (string, error) {
+	dest, err := validateURL(raw)
+	if err != nil {
+		return "", err
+	}
+	if err := checkBlockedDomain(dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
@@ -0,0 +1,50 @@
+package urlshortener
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateDestinationURL(t *testing.T) {
+	updateBlocklist(func(domains map[string]struct{}) { domains["blocked.example"] = struct{}{} })
+	t.Cleanup(func() {
+		updateBlocklist(func(domains map[string]struct{}) { delete(domains, "blocked.example") })
+	})
+
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{"https://example.com/path?q=1", "https://example.com/path?q=1", nil},
+		{"http://example.com:8080/", "http://example.com:8080/", nil},
+		{"https://bücher.example/", "https://xn--bcher-kva.example/", nil},
+		{"https://пример.испытание/путь", "https://xn--e1afmkfd.xn--80akhbyknj4f/%D0%BF%D1%83%D1%82%D1%8C", nil},
+		{"https://مثال.إختبار/", "https://xn--mgbh0fb.xn--kgbechtv/", nil},
+		{"https://例子.测试/", "https://xn--fsqu00a.xn--0zwm56d/", nil},
+		{"https://例え.テスト/", "https://xn--r8jz45g.xn--zckzah/", nil},
+		{"https://ПРИМЕР.испытание/", "https://xn--e1afmkfd.xn--80akhbyknj4f/", nil},
+		// Cyrillic "а" in place of the Latin one: a different host from apple.com.
+		{"https://аpple.com/", "https://xn--pple-43d.com/", nil},
+		{"https://203.0.113.7/", "https://203.0.113.7/", nil},
+		{"ftp://example.com/", "", errInvalidURL},
+		{"javascript:alert(1)", "", errInvalidURL},
+		{"/relative/path", "", errInvalidURL},
+		{"https://", "", errInvalidURL},
+		{"", "", errInvalidURL},
+		{"https://blocked.example/", "", errBlockedDomain},
+		{"https://sub.BLOCKED.example/", "", errBlockedDomain},
+		{"https://notblocked.example/", "https://notblocked.example/", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := validateDestinationURL(tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("validateDestinationURL(%q) error = %v, want %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("validateDestinationURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	bloomRebuildInterval = time.Hour
+
+	// bloomMinCapacity sizes the filter of a small database, so an hour of
+	// creations doesn't push it past its false-positive rate.
+	bloomMinCapacity = 100000
+)
+
+// BloomFilter is a set of strings that answers "definitely not present" or
+// "probably present". It is safe for concurrent use.
+type BloomFilter struct {
+	mu     sync.RWMutex
+	bits   []uint64
+	hashes uint64
+}
+
+// NewBloomFilter sizes a filter for n strings at false-positive rate p.
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(n)*math.Ln2))
+	return &BloomFilter{bits: make([]uint64, (uint64(m)+63)/64), hashes: uint64(k)}
+}
+
+// positions derives the filter's bit positions for s from two halves of one
+// FNV-1a hash (Kirsch-Mitzenmacher double hashing).
+func (f *BloomFilter) positions(s string, fn func(uint64)) {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	m := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.hashes; i++ {
+		fn((h1 + i*h2) % m)
+	}
+}
+
+func (f *BloomFilter) Add(s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.positions(s, func(bit uint64) { f.bits[bit/64] |= 1 << (bit % 64) })
+}
+
+// MayContain reports false only if s was never added.
+func (f *BloomFilter) MayContain(s string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	found := true
+	f.positions(s, func(bit uint64) {
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			found = false
+		}
+	})
+	return found
+}
+
+// knownURLs holds the content hash of every destination in MongoDB so the
+// home page form can skip the duplicate query for new ones. pending, while a rebuild reads
+// MongoDB, also receives the destinations added meanwhile.
+var knownURLs struct {
+	sync.Mutex
+	filter  *BloomFilter
+	pending *BloomFilter
+}
+
+// rememberURL adds url to knownURLs after it has been saved.
+func rememberURL(url string) {
+	if url == "" {
+		return
+	}
+	knownURLs.Lock()
+	defer knownURLs.Unlock()
+	hash := contentHash(url)
+	if knownURLs.filter != nil {
+		knownURLs.filter.Add(hash)
+	}
+	if knownURLs.pending != nil {
+		knownURLs.pending.Add(hash)
+	}
+}
+
+// mayBeKnownURL reports whether url could already be shortened. Before the
+// first build it must be assumed so.
+func mayBeKnownURL(url string) bool {
+	knownURLs.Lock()
+	f := knownURLs.filter
+	knownURLs.Unlock()
+	return f == nil || f.MayContain(contentHash(url))
+}
+
+// startURLFilter builds knownURLs and rebuilds it hourly, which drops
+// deleted destinations and resizes it as the database grows.
+func startURLFilter(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(bloomRebuildInterval)
+		defer ticker.Stop()
+		for {
+			if err := rebuildURLFilter(ctx); err != nil {
+				log.Printf("Failed to build URL filter: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func rebuildURLFilter(ctx context.Context) error {
+	filter := bson.M{"url": bson.M{"$nin": bson.A{nil, ""}}}
+	n, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	next := NewBloomFilter(max(2*int(n), bloomMinCapacity), cfg.DuplicateFilterFPRate)
+
+	knownURLs.Lock()
+	knownURLs.pending = next
+	knownURLs.Unlock()
+	defer func() {
+		knownURLs.Lock()
+		knownURLs.pending = nil
+		knownURLs.Unlock()
+	}()
+
+	cur, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"url": 1}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var doc struct {
+			URL string `bson:"url"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return err
+		}
+		next.Add(contentHash(doc.URL))
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	knownURLs.Lock()
+	knownURLs.filter = next
+	knownURLs.Unlock()
+	return nil
+}
+
+// existingShortURL finds a live anonymous short URL for dest, or a URL
+// with the same content hash, as the home page form would create, so the form can hand it out again. Other
+// instances' creations reach the filter through the change stream or the
+// next rebuild; until then they may be shortened twice.
+func existingShortURL(ctx context.Context, dest string) (URLMapping, bool, error) {
+	if cfg.DryRun {
+		m, ok := dryRunExisting(dest)
+		return m, ok, nil
+	}
+	if !mayBeKnownURL(dest) {
+		return URLMapping{}, false, nil
+	}
+	filter := liveFilter()
+	filter["content_hash"] = contentHash(dest)
+	filter["owner_id"] = bson.M{"$exists": false}
+	filter["custom_headers"] = bson.M{"$exists": false}
+	filter["requires_signature"] = bson.M{"$ne": true}
+	filter["prefix"] = bson.M{"$exists": false}
+	filter["always_preview"] = bson.M{"$exists": false}
+	filter["app_deep_link"] = bson.M{"$exists": false}
+
+	var m URLMapping
+	err := collection.FindOne(ctx, filter).Decode(&m)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return m, false, nil
+	}
+	return m, err == nil, err
+}
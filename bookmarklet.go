@@ -0,0 +1,189 @@
+package urlshortener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/sync/singleflight"
+)
+
+// bookmarkletCreates collapses concurrent GET shortens of the same URL, so
+// a double click doesn't create two codes.
+var bookmarkletCreates singleflight.Group
+
+// bookmarkletHandler returns a bookmarklet for this server. Plain, it
+// opens the home page in a popup with the current page's URL filled in.
+// With ?api_key= it shortens the page in place through GET
+// /api/v1/shorten and shows the result; the key is then stored in the
+// bookmark, so only hand that form to the key's owner.
+func bookmarkletHandler(w http.ResponseWriter, r *http.Request) {
+	base, _ := json.Marshal(publicBaseURL(r))
+	var js string
+	if key := r.URL.Query().Get("api_key"); key != "" {
+		if !validAPIKey(key) {
+			http.Error(w, "A valid API key is required", http.StatusUnauthorized)
+			return
+		}
+		k, _ := json.Marshal(key)
+		js = fmt.Sprintf(`javascript:(function(){var x=new XMLHttpRequest();`+
+			`x.open("GET",%s+"/api/v1/shorten?api_key="+encodeURIComponent(%s)+"&url="+encodeURIComponent(location.href));`+
+			`x.onload=function(){if(x.status<300){var m=JSON.parse(x.responseText);prompt("Short URL",%s+m.short_url.replace(/^https?:\/\/[^\/]+/,""));}else{alert("Shortening failed: "+(x.responseText||x.status));}};`+
+			`x.send();})();`, base, k, base)
+	} else {
+		js = fmt.Sprintf(`javascript:(function(){window.open(%s+"/?url="+encodeURIComponent(location.href),"urlshortener","width=560,height=640");})();`, base)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(js))
+}
+
+// apiShortenGetHandler shortens ?url= for bookmarklets and, with
+// ?source=extension, browser extensions. It is idempotent: an existing
+// plain, live short URL for the destination is returned (200) instead of
+// creating another (201). With ?code= it is idempotent for that code
+// instead; see shortenGetWithCode. Cross-origin pages may call it, so it
+// sits behind an API key and its own rate limit.
+func apiShortenGetHandler(w http.ResponseWriter, r *http.Request) {
+	defer shortenLatency.since(time.Now())
+	if !requireReady(w) {
+		return
+	}
+	dest, err := validateDestinationURL(r.URL.Query().Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if code := r.URL.Query().Get("code"); code != "" {
+		shortenGetWithCode(w, r, code, dest)
+		return
+	}
+
+	type result struct {
+		mapping URLMapping
+		created bool
+	}
+	v, err, _ := bookmarkletCreates.Do(dest, func() (interface{}, error) {
+		filter := plainShortURLFilter()
+		filter["content_hash"] = contentHash(dest)
+		var existing URLMapping
+		err := collection.FindOne(r.Context(), filter, options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}})).Decode(&existing)
+		if err == nil {
+			return result{mapping: existing}, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		m, err := createShortURL(r.Context(), URLMapping{URL: dest})
+		if err != nil {
+			return nil, err
+		}
+		return result{mapping: m, created: true}, nil
+	})
+	if err != nil {
+		loggerFrom(r.Context()).Error("Failed to shorten for bookmarklet", "url", dest, "error", err.Error())
+		http.Error(w, "Failed to save to database", http.StatusInternalServerError)
+		return
+	}
+
+	if fromExtension(r) {
+		countExtensionShorten(r)
+	}
+	res := v.(result)
+	if !res.created {
+		writeShortened(w, r, http.StatusOK, res.mapping)
+		return
+	}
+	go notifySlack(publicBaseURL(r), res.mapping, realIP(r))
+	writeShortened(w, r, http.StatusCreated, res.mapping)
+}
+
+// plainFields are the fields a short URL handed out again for its
+// destination must not have: they make it more than a plain redirect, or
+// one meant for its owner only.
+var plainFields = []string{"prefix", "target_code", "type", "campaign_id", "owner_id", "custom_headers", "always_preview", "app_deep_link"}
+
+// plainShortURLFilter matches the live, unsigned short URLs without
+// plainFields, which apiShortenGetHandler may return to anyone asking for
+// their destination. A signed one would hand out its signature.
+func plainShortURLFilter() bson.M {
+	filter := liveFilter()
+	filter["requires_signature"] = bson.M{"$ne": true}
+	for _, field := range plainFields {
+		filter[field] = bson.M{"$exists": false}
+	}
+	return filter
+}
+
+// plainShortURL reports whether m would match plainShortURLFilter.
+func plainShortURL(m URLMapping) bool {
+	return !m.Disabled && !m.Expired() && !m.RequiresSignature &&
+		m.Prefix == "" && m.TargetCode == "" && m.Type == "" && m.CampaignID == "" &&
+		m.OwnerID == "" && len(m.CustomHeaders) == 0 && m.AlwaysPreview == nil && m.AppDeepLink == ""
+}
+
+// shortenGetWithCode makes code redirect to dest, so that the same GET
+// can be repeated, or bookmarked, without side effects: it creates the
+// short URL (201), returns it if code already leads to dest as a plain
+// short URL (200), and answers 409 if code is taken otherwise.
+func shortenGetWithCode(w http.ResponseWriter, r *http.Request, code, dest string) {
+	if err := validateCode(code); err != nil {
+		http.Error(w, err.Error(), validateCodeStatus(err))
+		return
+	}
+	code = normalizeCode(code)
+
+	type result struct {
+		mapping URLMapping
+		created bool
+	}
+	// The "code:" prefix keeps these keys apart from the destinations
+	// apiShortenGetHandler collapses on, which are URLs. The destination is
+	// part of the key so that a request for another one isn't handed this
+	// one's result; it loses the race for the code instead.
+	v, err, _ := bookmarkletCreates.Do("code:"+code+" "+dest, func() (interface{}, error) {
+		// A second pass reads the winner of a race with another instance.
+		for attempt := 0; ; attempt++ {
+			var existing URLMapping
+			err := collection.FindOne(r.Context(), codeFilter(code)).Decode(&existing)
+			if err == nil {
+				if existing.URL != dest || !plainShortURL(existing) {
+					return nil, errCodeTaken
+				}
+				return result{mapping: existing}, nil
+			}
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, err
+			}
+			m, err := createShortURL(r.Context(), URLMapping{Code: code, URL: dest})
+			if errors.Is(err, errCodeTaken) && attempt == 0 {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return result{mapping: m, created: true}, nil
+		}
+	})
+	if errors.Is(err, errCodeTaken) {
+		http.Error(w, "Code is already in use for a different URL", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		loggerFrom(withCode(r.Context(), code)).Error("Failed to shorten for bookmarklet", "url", dest, "error", err.Error())
+		http.Error(w, "Failed to save to database", http.StatusInternalServerError)
+		return
+	}
+
+	res := v.(result)
+	if !res.created {
+		writeShortened(w, r, http.StatusOK, res.mapping)
+		return
+	}
+	go notifySlack(publicBaseURL(r), res.mapping, realIP(r))
+	writeShortened(w, r, http.StatusCreated, res.mapping)
+}
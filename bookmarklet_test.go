@@ -0,0 +1,51 @@
+package urlshortener
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlainShortURL(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	no := false
+	tests := []struct {
+		name string
+		m    URLMapping
+		want bool
+	}{
+		{"plain", URLMapping{URL: "https://example.com/"}, true},
+		{"disabled", URLMapping{Disabled: true}, false},
+		{"expired", URLMapping{ExpiresAt: &past}, false},
+		{"signed", URLMapping{RequiresSignature: true}, false},
+		{"prefix", URLMapping{Prefix: "team"}, false},
+		{"target_code", URLMapping{TargetCode: "abc"}, false},
+		{"type", URLMapping{Type: "bio"}, false},
+		{"campaign_id", URLMapping{CampaignID: "summer"}, false},
+		{"owner_id", URLMapping{OwnerID: "user-1"}, false},
+		{"custom_headers", URLMapping{CustomHeaders: map[string]string{"X-Campaign": "a"}}, false},
+		{"always_preview", URLMapping{AlwaysPreview: &no}, false},
+		{"app_deep_link", URLMapping{AppDeepLink: "myapp://x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plainShortURL(tt.m); got != tt.want {
+				t.Errorf("plainShortURL = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Every field plainShortURLFilter excludes has a case above.
+	filter := plainShortURLFilter()
+	for _, field := range append(plainFields, "requires_signature") {
+		if _, ok := filter[field]; !ok {
+			t.Errorf("plainShortURLFilter doesn't constrain %s", field)
+		}
+		found := false
+		for _, tt := range tests {
+			found = found || tt.name == field || (field == "requires_signature" && tt.name == "signed")
+		}
+		if !found {
+			t.Errorf("no plainShortURL case for %s", field)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package urlshortener
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// botSignatures are lowercase substrings of user agents sent by crawlers,
+// link unfurlers and other automated clients.
+var botSignatures = []string{
+	"bot", "crawl", "spider", "slurp", "facebookexternalhit", "embedly",
+	"bingpreview", "whatsapp", "pinterest", "headlesschrome", "lighthouse",
+}
+
+var botRedirectTpl = template.Must(template.New("").Parse(`<!DOCTYPE html><html><head><meta http-equiv="refresh" content="0;url={{.}}"></head></html>
+`))
+
+// isBot reports whether ua looks like an automated client. It is used both to
+// classify click events and to pick the redirect style in redirectHandler.
+func isBot(ua string) bool {
+	ua = strings.ToLower(ua)
+	for _, sig := range botSignatures {
+		if strings.Contains(ua, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// botRedirect answers with a 200 meta-refresh page instead of a 3xx, since
+// many crawlers don't follow redirects but do index the refresh target.
+func botRedirect(w http.ResponseWriter, dest string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := botRedirectTpl.Execute(w, dest); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
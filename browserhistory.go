@@ -0,0 +1,189 @@
+package urlshortener
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	browserHistoryTag = "browser-history"
+
+	// historyMaxBytes caps the upload; a profile's history of several
+	// years stays well below it.
+	historyMaxBytes = 256 << 20
+
+	// historyMinVisits is the visit count a URL must exceed to be imported.
+	historyMinVisits = 3
+
+	// historyMaxURLs caps the short URLs created per import, most visited
+	// first.
+	historyMaxURLs = 1000
+)
+
+const (
+	browserChrome  = "chrome"
+	browserFirefox = "firefox"
+)
+
+var errNotBrowserHistory = errors.New("not a Chrome History or Firefox places.sqlite database")
+
+// historyQueries select the http(s) URLs visited more than
+// historyMinVisits times, most visited first, from each browser's schema.
+var historyQueries = map[string]string{
+	browserChrome: `SELECT url FROM urls
+		WHERE url LIKE 'http://%' OR url LIKE 'https://%'
+		GROUP BY url HAVING SUM(visit_count) > ? ORDER BY SUM(visit_count) DESC LIMIT ?`,
+	browserFirefox: `SELECT url FROM moz_places
+		WHERE url LIKE 'http://%' OR url LIKE 'https://%'
+		GROUP BY url HAVING SUM(visit_count) > ? ORDER BY SUM(visit_count) DESC LIMIT ?`,
+}
+
+type historyImportResult struct {
+	Browser string      `json:"browser"`
+	Created []feedEntry `json:"created"`
+	Skipped []feedEntry `json:"skipped"`
+}
+
+// browserHistoryImportHandler serves POST /api/v1/import/browser-history:
+// a multipart upload, field history, of a copy of Chrome's History or
+// Firefox's places.sqlite. Every URL visited more than historyMinVisits
+// times that isn't shortened yet gets a short URL tagged browser-history.
+func browserHistoryImportHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, historyMaxBytes)
+	file, _, err := r.FormFile("history")
+	if err != nil {
+		http.Error(w, "history file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// SQLite needs a file on disk, which the upload only is when large.
+	tmp, err := os.CreateTemp("", "browser-history-*.sqlite")
+	if err != nil {
+		log.Printf("Failed to spool browser history: %v", err)
+		http.Error(w, "Failed to import browser history", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, file)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("Failed to spool browser history: %v", err)
+		http.Error(w, "Failed to import browser history", http.StatusInternalServerError)
+		return
+	}
+
+	res, err := importBrowserHistory(r.Context(), tmp.Name())
+	if err != nil {
+		if errors.Is(err, errNotBrowserHistory) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to import browser history: %v", err)
+		http.Error(w, "Failed to import browser history", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// importBrowserHistory shortens the frequently visited URLs of the history
+// database at path, skipping those that already have a short URL.
+func importBrowserHistory(ctx context.Context, path string) (historyImportResult, error) {
+	res := historyImportResult{Created: []feedEntry{}, Skipped: []feedEntry{}}
+	links, browser, err := readBrowserHistory(ctx, path)
+	if err != nil {
+		return res, err
+	}
+	res.Browser = browser
+
+	var dests []string
+	for _, link := range links {
+		dest, err := validateDestinationURL(link)
+		if err != nil {
+			res.Skipped = append(res.Skipped, feedEntry{URL: link, Reason: err.Error()})
+			continue
+		}
+		dests = append(dests, dest)
+	}
+
+	existing := map[string]bool{}
+	cur, err := collection.Find(ctx, bson.M{"url": bson.M{"$in": append([]string{}, dests...)}}, options.Find().SetProjection(bson.M{"url": 1}))
+	if err != nil {
+		return res, err
+	}
+	var found []struct {
+		URL string `bson:"url"`
+	}
+	if err := cur.All(ctx, &found); err != nil {
+		return res, err
+	}
+	for _, f := range found {
+		existing[f.URL] = true
+	}
+
+	for _, dest := range dests {
+		if existing[dest] {
+			res.Skipped = append(res.Skipped, feedEntry{URL: dest, Reason: "already shortened"})
+			continue
+		}
+		m, err := createShortURL(ctx, URLMapping{URL: dest, Tags: []string{browserHistoryTag}})
+		if err != nil {
+			log.Printf("Browser history import failed to shorten %s: %v", dest, err)
+			res.Skipped = append(res.Skipped, feedEntry{URL: dest, Reason: "failed to save"})
+			continue
+		}
+		existing[dest] = true
+		res.Created = append(res.Created, feedEntry{URL: dest, Code: m.Code})
+	}
+	return res, nil
+}
+
+// readBrowserHistory opens the database at path read-only, tells Chrome's
+// schema from Firefox's by their tables, and returns its frequently
+// visited URLs.
+func readBrowserHistory(ctx context.Context, path string) ([]string, string, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, "", err
+	}
+	defer db.Close()
+
+	// Anything but an SQLite file fails here too.
+	var table string
+	err = db.QueryRowContext(ctx, `SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name IN ('moz_places', 'urls')
+		ORDER BY name = 'moz_places' DESC LIMIT 1`).Scan(&table)
+	if err != nil {
+		return nil, "", errNotBrowserHistory
+	}
+	browser := browserChrome
+	if table == "moz_places" {
+		browser = browserFirefox
+	}
+
+	rows, err := db.QueryContext(ctx, historyQueries[browser], historyMinVisits, historyMaxURLs)
+	if err != nil {
+		return nil, "", errNotBrowserHistory
+	}
+	defer rows.Close()
+	var links []string
+	for rows.Next() {
+		var link string
+		if err := rows.Scan(&link); err != nil {
+			return nil, "", err
+		}
+		links = append(links, link)
+	}
+	return links, browser, rows.Err()
+}
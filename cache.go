@@ -0,0 +1,176 @@
+package urlshortener
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+const cacheShards = 256
+
+// urlCache is the in-memory code → mapping map. It is split into shards,
+// each with its own lock, so concurrent redirects for different codes
+// rarely contend.
+type urlCache struct {
+	shards [cacheShards]urlShard
+
+	// byURL maps a destination's content hash to one cached code for it,
+	// sharded by the hash. Only the first code set for a hash is kept, so after that one is
+	// removed others with the same destination aren't found until set
+	// again; the duplicate audit can live with that.
+	byURL [cacheShards]urlIndexShard
+
+	hits, misses atomic.Int64
+}
+
+type urlShard struct {
+	sync.RWMutex
+	m map[string]URLMapping
+}
+
+type urlIndexShard struct {
+	sync.Mutex
+	m map[string]string
+}
+
+func newURLCache() *urlCache {
+	c := &urlCache{}
+	for i := range c.shards {
+		c.shards[i].m = make(map[string]URLMapping)
+		c.byURL[i].m = make(map[string]string)
+	}
+	return c
+}
+
+func shardIndex(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % cacheShards
+}
+
+func (c *urlCache) shard(code string) *urlShard {
+	return &c.shards[shardIndex(code)]
+}
+
+func (c *urlCache) index(m URLMapping) {
+	if m.URL == "" {
+		return
+	}
+	hash := contentHash(m.URL)
+	s := &c.byURL[shardIndex(hash)]
+	s.Lock()
+	if _, ok := s.m[hash]; !ok {
+		s.m[hash] = m.Code
+	}
+	s.Unlock()
+}
+
+func (c *urlCache) unindex(m URLMapping) {
+	if m.URL == "" {
+		return
+	}
+	hash := contentHash(m.URL)
+	s := &c.byURL[shardIndex(hash)]
+	s.Lock()
+	if s.m[hash] == m.Code {
+		delete(s.m, hash)
+	}
+	s.Unlock()
+}
+
+// CodeForURL returns a cached code whose destination has url's content
+// hash.
+func (c *urlCache) CodeForURL(url string) (string, bool) {
+	hash := contentHash(url)
+	s := &c.byURL[shardIndex(hash)]
+	s.Lock()
+	defer s.Unlock()
+	code, ok := s.m[hash]
+	return code, ok
+}
+
+func (c *urlCache) Get(code string) (URLMapping, bool) {
+	s := c.shard(code)
+	s.RLock()
+	defer s.RUnlock()
+	m, ok := s.m[code]
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return m, ok
+}
+
+func (c *urlCache) Set(code string, m URLMapping) {
+	s := c.shard(code)
+	s.Lock()
+	old, had := s.m[code]
+	s.m[code] = m
+	s.Unlock()
+	if had && old.URL != m.URL {
+		c.unindex(old)
+	}
+	c.index(m)
+}
+
+func (c *urlCache) Delete(code string) {
+	s := c.shard(code)
+	s.Lock()
+	old, had := s.m[code]
+	delete(s.m, code)
+	s.Unlock()
+	if had {
+		c.unindex(old)
+	}
+}
+
+// All returns a copy of every cached mapping.
+func (c *urlCache) All() []URLMapping {
+	var all []URLMapping
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.RLock()
+		for _, m := range s.m {
+			all = append(all, m)
+		}
+		s.RUnlock()
+	}
+	return all
+}
+
+// Size returns the number of cached mappings and a rough estimate of the
+// memory their codes and destinations take, counting two bytes per
+// character for string and map overhead.
+func (c *urlCache) Size() (entries int, bytes int64) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.RLock()
+		entries += len(s.m)
+		for code, m := range s.m {
+			bytes += int64(len(code)+len(m.URL)) * 2
+		}
+		s.RUnlock()
+	}
+	return entries, bytes
+}
+
+// Update applies fn to the cached mapping for code, if there is one, and
+// returns the result.
+func (c *urlCache) Update(code string, fn func(*URLMapping)) (URLMapping, bool) {
+	s := c.shard(code)
+	s.Lock()
+	defer s.Unlock()
+	m, ok := s.m[code]
+	if !ok {
+		return m, false
+	}
+	old := m
+	fn(&m)
+	s.m[code] = m
+	if old.URL != m.URL {
+		c.unindex(old)
+		c.index(m)
+	}
+	return m, true
+}
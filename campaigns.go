@@ -0,0 +1,306 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// campaignTTL bounds how long a cached campaign is used after it is edited
+// on another instance.
+const campaignTTL = 60 * time.Second
+
+var campaigns *mongo.Collection
+
+var errUnknownCampaign = errors.New("campaign_id does not match a campaign")
+
+// Campaign holds the UTM parameters shared by every short URL created with
+// its ID. They are added to the destination at redirect time, so editing a
+// campaign retags all of its URLs.
+type Campaign struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	UTMSource   string             `bson:"utm_source,omitempty" json:"utm_source,omitempty"`
+	UTMMedium   string             `bson:"utm_medium,omitempty" json:"utm_medium,omitempty"`
+	UTMCampaign string             `bson:"utm_campaign,omitempty" json:"utm_campaign,omitempty"`
+	Tags        []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type campaignRequest struct {
+	Name        string   `json:"name"`
+	UTMSource   string   `json:"utm_source"`
+	UTMMedium   string   `json:"utm_medium"`
+	UTMCampaign string   `json:"utm_campaign"`
+	Tags        []string `json:"tags"`
+}
+
+func (req *campaignRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if req.UTMSource == "" && req.UTMMedium == "" && req.UTMCampaign == "" {
+		return errors.New("at least one of utm_source, utm_medium or utm_campaign is required")
+	}
+	return nil
+}
+
+// apply adds the campaign's UTM parameters to dest, replacing any of the
+// same name already there.
+func (c Campaign) apply(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+	q := u.Query()
+	for name, v := range map[string]string{
+		"utm_source":   c.UTMSource,
+		"utm_medium":   c.UTMMedium,
+		"utm_campaign": c.UTMCampaign,
+	} {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+type cachedCampaign struct {
+	campaign *Campaign // nil once the campaign is deleted
+	expires  time.Time
+}
+
+var campaignCache sync.Map // campaign ID -> cachedCampaign
+
+// lookupCampaign returns the campaign with the given hex ID, or
+// errUnknownCampaign. Lookups are cached for campaignTTL.
+func lookupCampaign(ctx context.Context, id string) (*Campaign, error) {
+	if v, ok := campaignCache.Load(id); ok && time.Now().Before(v.(cachedCampaign).expires) {
+		if c := v.(cachedCampaign).campaign; c != nil {
+			return c, nil
+		}
+		return nil, errUnknownCampaign
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errUnknownCampaign
+	}
+	var c Campaign
+	err = campaigns.FindOne(ctx, bson.M{"_id": oid}).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		campaignCache.Store(id, cachedCampaign{expires: time.Now().Add(campaignTTL)})
+		return nil, errUnknownCampaign
+	}
+	if err != nil {
+		return nil, err
+	}
+	campaignCache.Store(id, cachedCampaign{campaign: &c, expires: time.Now().Add(campaignTTL)})
+	return &c, nil
+}
+
+// applyCampaign adds the UTM parameters of the mapping's campaign to its
+// destination. A lookup failure leaves the destination untagged rather
+// than failing the redirect.
+func applyCampaign(ctx context.Context, m URLMapping) string {
+	if m.CampaignID == "" || m.URL == "" {
+		return m.URL
+	}
+	c, err := lookupCampaign(ctx, m.CampaignID)
+	if err != nil {
+		if !errors.Is(err, errUnknownCampaign) {
+			loggerFrom(ctx).WarnContext(ctx, "Failed to load campaign", "campaign_id", m.CampaignID, "target_code", m.Code, "error", err.Error())
+		}
+		return m.URL
+	}
+	traceRule(ctx, "campaign", c.Name)
+	return c.apply(m.URL)
+}
+
+// campaignHook tags the destination with the mapping's campaign.
+func campaignHook(ctx context.Context, code, url string, r *http.Request) (string, bool, error) {
+	st := redirectStateFrom(ctx)
+	if st == nil {
+		return "", false, nil
+	}
+	m := st.mapping
+	m.URL = url
+	return applyCampaign(ctx, m), false, nil
+}
+
+// mergeTags appends the tags in extra that aren't already in tags.
+func mergeTags(tags, extra []string) []string {
+	for _, t := range extra {
+		found := false
+		for _, have := range tags {
+			if have == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func campaignIDParam(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	oid, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return oid, false
+	}
+	return oid, true
+}
+
+func apiCreateCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req campaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c := Campaign{
+		Name:        req.Name,
+		UTMSource:   req.UTMSource,
+		UTMMedium:   req.UTMMedium,
+		UTMCampaign: req.UTMCampaign,
+		Tags:        req.Tags,
+		UpdatedAt:   time.Now(),
+	}
+	res, err := campaigns.InsertOne(r.Context(), c)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "A campaign with this name already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to save campaign %s: %v", c.Name, err)
+		http.Error(w, "Failed to save campaign", http.StatusInternalServerError)
+		return
+	}
+	c.ID = res.InsertedID.(primitive.ObjectID)
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func apiListCampaignsHandler(w http.ResponseWriter, r *http.Request) {
+	cur, err := campaigns.Find(r.Context(), bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		log.Printf("Failed to list campaigns: %v", err)
+		http.Error(w, "Failed to list campaigns", http.StatusInternalServerError)
+		return
+	}
+	list := []Campaign{}
+	if err := cur.All(r.Context(), &list); err != nil {
+		log.Printf("Failed to list campaigns: %v", err)
+		http.Error(w, "Failed to list campaigns", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func apiGetCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	oid, ok := campaignIDParam(w, r)
+	if !ok {
+		return
+	}
+	var c Campaign
+	if err := campaigns.FindOne(r.Context(), bson.M{"_id": oid}).Decode(&c); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to load campaign %s: %v", oid.Hex(), err)
+		http.Error(w, "Failed to load campaign", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// apiUpdateCampaignHandler replaces a campaign's name, UTM parameters and
+// tags. Redirects of its URLs pick up the new parameters within
+// campaignTTL; the tags only apply to URLs created afterwards.
+func apiUpdateCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	oid, ok := campaignIDParam(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req campaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var c Campaign
+	err := campaigns.FindOneAndUpdate(r.Context(),
+		bson.M{"_id": oid},
+		bson.M{"$set": bson.M{
+			"name":         req.Name,
+			"utm_source":   req.UTMSource,
+			"utm_medium":   req.UTMMedium,
+			"utm_campaign": req.UTMCampaign,
+			"tags":         req.Tags,
+			"updated_at":   time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&c)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "A campaign with this name already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to update campaign %s: %v", oid.Hex(), err)
+		http.Error(w, "Failed to update campaign", http.StatusInternalServerError)
+		return
+	}
+	campaignCache.Delete(oid.Hex())
+	writeJSON(w, http.StatusOK, c)
+}
+
+// apiDeleteCampaignHandler removes a campaign. Its URLs keep redirecting,
+// without the UTM parameters.
+func apiDeleteCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	oid, ok := campaignIDParam(w, r)
+	if !ok {
+		return
+	}
+	res, err := campaigns.DeleteOne(r.Context(), bson.M{"_id": oid})
+	if err != nil {
+		log.Printf("Failed to delete campaign %s: %v", oid.Hex(), err)
+		http.Error(w, "Failed to delete campaign", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	campaignCache.Delete(oid.Hex())
+	w.WriteHeader(http.StatusNoContent)
+}
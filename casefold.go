@@ -0,0 +1,98 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"urlshortener/pkg/store"
+)
+
+// lowerCodeCharset is used for generated codes with --case-insensitive, so
+// that they survive being typed in any case.
+const lowerCodeCharset = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// normalizeCode folds code to lower case when codes are case-insensitive.
+// Apply it wherever a code enters from outside: creation, lookups and URL
+// paths.
+func normalizeCode(code string) string {
+	if cfg.CaseInsensitive {
+		return strings.ToLower(code)
+	}
+	return code
+}
+
+// codeParam is the normalised {code} path value.
+func codeParam(r *http.Request) string {
+	return normalizeCode(r.PathValue("code"))
+}
+
+// migrateLowercaseCodes renames stored codes containing upper-case letters
+// to their lower-case form, along with their click events, link-in-bio
+// profiles and chain links pointing at them. A code whose lower-case form
+// is already taken is left alone and logged; it stays unreachable until
+// renamed by hand.
+func migrateLowercaseCodes(ctx context.Context) {
+	cur, err := collection.Find(ctx, bson.M{"code": bson.M{"$regex": "[A-Z]"}})
+	if err != nil {
+		log.Printf("Lowercase code migration failed: %v", err)
+		return
+	}
+	defer cur.Close(ctx)
+
+	var migrated int
+	for cur.Next(ctx) {
+		var doc struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Code string             `bson:"code"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			log.Printf("Lowercase code migration failed: %v", err)
+			return
+		}
+		lower := strings.ToLower(doc.Code)
+
+		n, err := collection.CountDocuments(ctx, bson.M{"code": lower})
+		if err != nil {
+			log.Printf("Lowercase code migration failed: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("Lowercase code migration: not renaming %s, %s already exists", doc.Code, lower)
+			continue
+		}
+
+		update := bson.M{"$set": bson.M{"code": lower}}
+		if n, ok := store.CodeToInt(lower); ok {
+			update["$set"].(bson.M)["code_int"] = n
+		} else {
+			update["$unset"] = bson.M{"code_int": ""}
+		}
+		// Changing a shard key value needs the current value in the filter.
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": doc.ID, "code": doc.Code}, update); err != nil {
+			log.Printf("Failed to rename %s to %s: %v", doc.Code, lower, err)
+			continue
+		}
+		if _, err := clickEvents.UpdateMany(ctx, bson.M{"code": doc.Code}, bson.M{"$set": bson.M{"code": lower}}); err != nil {
+			log.Printf("Failed to rename click events for %s: %v", doc.Code, err)
+		}
+		if _, err := linkInBio.UpdateOne(ctx, bson.M{"code": doc.Code}, bson.M{"$set": bson.M{"code": lower}}); err != nil {
+			log.Printf("Failed to rename link-in-bio profile for %s: %v", doc.Code, err)
+		}
+		if _, err := collection.UpdateMany(ctx, bson.M{"target_code": doc.Code}, bson.M{"$set": bson.M{"target_code": lower}}); err != nil {
+			log.Printf("Failed to update chain links to %s: %v", doc.Code, err)
+		}
+		shortURLs.Delete(doc.Code)
+		migrated++
+	}
+	if err := cur.Err(); err != nil {
+		log.Printf("Lowercase code migration failed: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("Lowercase code migration renamed %d codes", migrated)
+	}
+}
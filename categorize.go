@@ -0,0 +1,87 @@
+package urlshortener
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// urlCategory suggests Tag for destinations on Host, or on one of its
+// subdomains, whose path starts with PathPrefix.
+type urlCategory struct {
+	Host       string
+	PathPrefix string
+	Tag        string
+}
+
+// urlCategories is checked in order; a destination can match several
+// entries, and gets the tag of each.
+var urlCategories = []urlCategory{
+	{Host: "youtube.com", PathPrefix: "/watch", Tag: "video"},
+	{Host: "youtube.com", PathPrefix: "/shorts/", Tag: "video"},
+	{Host: "youtu.be", Tag: "video"},
+	{Host: "vimeo.com", Tag: "video"},
+	{Host: "twitch.tv", Tag: "video"},
+	{Host: "tiktok.com", Tag: "video"},
+
+	{Host: "github.com", Tag: "code"},
+	{Host: "gitlab.com", Tag: "code"},
+	{Host: "bitbucket.org", Tag: "code"},
+	{Host: "gist.github.com", Tag: "code"},
+	{Host: "pkg.go.dev", Tag: "code"},
+	{Host: "npmjs.com", PathPrefix: "/package/", Tag: "code"},
+	{Host: "stackoverflow.com", PathPrefix: "/questions/", Tag: "code"},
+
+	{Host: "twitter.com", Tag: "social"},
+	{Host: "x.com", Tag: "social"},
+	{Host: "facebook.com", Tag: "social"},
+	{Host: "instagram.com", Tag: "social"},
+	{Host: "linkedin.com", Tag: "social"},
+	{Host: "reddit.com", Tag: "social"},
+	{Host: "mastodon.social", Tag: "social"},
+	{Host: "tiktok.com", Tag: "social"},
+
+	{Host: "open.spotify.com", Tag: "music"},
+	{Host: "soundcloud.com", Tag: "music"},
+	{Host: "music.apple.com", Tag: "music"},
+
+	{Host: "docs.google.com", Tag: "docs"},
+	{Host: "notion.so", Tag: "docs"},
+	{Host: "wikipedia.org", Tag: "docs"},
+
+	{Host: "amazon.com", PathPrefix: "/dp/", Tag: "shopping"},
+	{Host: "ebay.com", PathPrefix: "/itm/", Tag: "shopping"},
+	{Host: "etsy.com", PathPrefix: "/listing/", Tag: "shopping"},
+}
+
+// categorizeURL returns the tags urlCategories suggests for rawURL, each
+// once. Unparseable URLs get none.
+func categorizeURL(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	var tags []string
+	for _, c := range urlCategories {
+		if host != c.Host && !strings.HasSuffix(host, "."+c.Host) {
+			continue
+		}
+		if !strings.HasPrefix(u.Path, c.PathPrefix) {
+			continue
+		}
+		tags = mergeTags(tags, []string{c.Tag})
+	}
+	return tags
+}
+
+// suggestTags is categorizeURL without the tags m already has.
+func suggestTags(m URLMapping) []string {
+	var suggested []string
+	for _, t := range categorizeURL(m.URL) {
+		if !slices.Contains(m.Tags, t) {
+			suggested = append(suggested, t)
+		}
+	}
+	return suggested
+}
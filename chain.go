@@ -0,0 +1,83 @@
+package urlshortener
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	errChainCycle   = errors.New("target_code would create a redirect loop")
+	errChainTooDeep = errors.New("redirect chain is too deep")
+)
+
+// lookupCode returns the mapping for code from the in-memory map, falling
+// back to the regional MongoDB copy and then the primary. The map is
+// bypassed while it is still being preloaded. A missing code yields
+// mongo.ErrNoDocuments. Mappings read from MongoDB are checked against the
+// cache for duplicate destinations.
+func lookupCode(code string) (URLMapping, error) {
+	code = normalizeCode(code)
+	if isReady() {
+		if mapping, ok := shortURLs.Get(code); ok {
+			return mapping, nil
+		}
+	}
+	mapping, err := replicator.findLocal(code)
+	if err != nil {
+		mapping, err = findInMongoDB(code)
+	}
+	if err == nil {
+		go noteDuplicate(mapping)
+	}
+	return mapping, err
+}
+
+// resolveChain follows code→code links starting at m until it reaches a
+// mapping with a real destination, so a funnel like abc123 → def456 →
+// https://final.example.com costs the visitor a single redirect. The codes
+// visited, starting with m.Code, are returned for attribution.
+func resolveChain(m URLMapping) (URLMapping, []string, error) {
+	chain := []string{m.Code}
+	for m.TargetCode != "" {
+		if len(chain) > cfg.MaxChainDepth {
+			return m, chain, errChainTooDeep
+		}
+		next, err := lookupCode(m.TargetCode)
+		if err != nil {
+			return m, chain, err
+		}
+		if next.Expired() || next.Disabled {
+			return m, chain, fmt.Errorf("chain link %s is no longer active", next.Code)
+		}
+		m = next
+		chain = append(chain, m.Code)
+	}
+	return m, chain, nil
+}
+
+// checkChain walks the chain that would start at target and rejects it if it
+// leads back to code or is longer than the configured maximum depth.
+func checkChain(code, target string) error {
+	seen := map[string]bool{code: true}
+	for depth := 1; target != ""; depth++ {
+		if seen[target] {
+			return errChainCycle
+		}
+		if depth > cfg.MaxChainDepth {
+			return errChainTooDeep
+		}
+		seen[target] = true
+
+		m, err := lookupCode(target)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("target_code %s does not exist", target)
+		}
+		if err != nil {
+			return err
+		}
+		target = m.TargetCode
+	}
+	return nil
+}
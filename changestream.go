@@ -0,0 +1,142 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	changeStreamMinBackoff = time.Second
+	changeStreamMaxBackoff = time.Minute
+
+	// Returned by standalone servers and by MongoDB-compatible servers
+	// without change streams.
+	errCodeChangeStreamUnsupported = 40573
+	errCodeNotImplemented          = 238
+)
+
+// cachedIDs maps document _id to code so deletes, which only carry the
+// _id, can be applied when the server has no pre-image to send.
+var cachedIDs sync.Map
+
+type urlChange struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument             *URLMapping `bson:"fullDocument"`
+	FullDocumentBeforeChange *URLMapping `bson:"fullDocumentBeforeChange"`
+}
+
+// watchURLChanges keeps shortURLs in step with writes made by other
+// instances by following a change stream on the urls collection. It
+// resumes after interruptions, backing off exponentially, and reloads the
+// whole cache if the stream can't be resumed where it left off.
+func watchURLChanges(ctx context.Context) {
+	// Pre-images let deletes name their code; MongoDB 6.0+ only.
+	err := collection.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection.Name()},
+		{Key: "changeStreamPreAndPostImages", Value: bson.M{"enabled": true}},
+	}).Err()
+	if err != nil {
+		log.Printf("Change stream pre-images unavailable, using cached ids for deletes: %v", err)
+	}
+
+	// Click counters change on every redirect and health history every
+	// hour; neither is worth a document lookup on every instance.
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"$or": bson.A{
+		bson.M{"operationType": bson.M{"$ne": "update"}},
+		bson.M{
+			"updateDescription.updatedFields.clicks":         bson.M{"$exists": false},
+			"updateDescription.updatedFields.health_history": bson.M{"$exists": false},
+		},
+	}}}}}
+
+	var resumeToken bson.Raw
+	backoff := changeStreamMinBackoff
+	for {
+		opts := options.ChangeStream().
+			SetFullDocument(options.UpdateLookup).
+			SetFullDocumentBeforeChange(options.WhenAvailable)
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+
+		cs, err := collection.Watch(ctx, pipeline, opts)
+		if err != nil {
+			var cmdErr mongo.CommandError
+			if errors.As(err, &cmdErr) && (cmdErr.Code == errCodeChangeStreamUnsupported || cmdErr.Code == errCodeNotImplemented) {
+				log.Printf("MongoDB does not support change streams; other instances' writes won't reach the cache: %v", err)
+				return
+			}
+			if resumeToken != nil {
+				log.Printf("Failed to resume change stream, reloading cache: %v", err)
+				resumeToken = nil
+				if _, err := preloadCache(ctx); err != nil {
+					log.Printf("Failed to reload cache: %v", err)
+				}
+				continue
+			}
+			log.Printf("Failed to open change stream, retrying in %v: %v", backoff, err)
+		} else {
+			backoff = changeStreamMinBackoff
+			for cs.Next(ctx) {
+				var change urlChange
+				if err := cs.Decode(&change); err != nil {
+					log.Printf("Dropping undecodable change event: %v", err)
+				} else {
+					applyURLChange(change)
+				}
+				resumeToken = cs.ResumeToken()
+			}
+			err = cs.Err()
+			cs.Close(context.Background())
+			log.Printf("Change stream interrupted, resuming in %v: %v", backoff, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, changeStreamMaxBackoff)
+	}
+}
+
+func applyURLChange(change urlChange) {
+	switch change.OperationType {
+	case "insert", "update", "replace":
+		// A nil document means it was deleted before the lookup; the
+		// delete event follows.
+		if m := change.FullDocument; m != nil {
+			rememberID(change.DocumentKey.ID, m.Code)
+			shortURLs.Set(m.Code, *m)
+			rememberURL(m.URL)
+		}
+	case "delete":
+		code := ""
+		if m := change.FullDocumentBeforeChange; m != nil {
+			code = m.Code
+		} else if v, ok := cachedIDs.Load(change.DocumentKey.ID); ok {
+			code = v.(string)
+		}
+		cachedIDs.Delete(change.DocumentKey.ID)
+		if code != "" {
+			shortURLs.Delete(code)
+		}
+	}
+}
+
+func rememberID(id primitive.ObjectID, code string) {
+	if !id.IsZero() {
+		cachedIDs.Store(id, code)
+	}
+}
@@ -0,0 +1,113 @@
+package urlshortener
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	trackingCookie = "_ushortener"
+
+	// trackingCookieTTL is how long after the redirect a browser may still
+	// report the click.
+	trackingCookieTTL = 5 * time.Minute
+
+	// beaconMaxBytes caps the body of POST /api/v1/analytics/event.
+	beaconMaxBytes = 4 << 10
+)
+
+// beaconScript reports the click in the tracking cookie to
+// /api/v1/analytics/event and clears it. It is embedded in the preview
+// page with --client-side-analytics.
+const beaconScript = template.JS(`(function () {
+    var m = document.cookie.match(/(?:^|; )_ushortener=([^;]*)/);
+    if (!m || !navigator.sendBeacon) return;
+    var v = decodeURIComponent(m[1]);
+    var body = JSON.stringify({code: v.slice(0, v.lastIndexOf(":")), referrer: document.referrer});
+    navigator.sendBeacon("/api/v1/analytics/event", new Blob([body], {type: "application/json"}));
+    document.cookie = "_ushortener=; Max-Age=0; Path=/; SameSite=Lax";
+})();`)
+
+// setTrackingCookie leaves ev's code and time in a first-party cookie for
+// the beacon on the preview page to report, instead of recording ev on the
+// server.
+func setTrackingCookie(w http.ResponseWriter, ev ClickEvent) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     trackingCookie,
+		Value:    ev.Code + ":" + strconv.FormatInt(ev.Timestamp.Unix(), 10),
+		Path:     "/",
+		MaxAge:   int(trackingCookieTTL / time.Second),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// parseTrackingCookie splits a tracking cookie value into its code and
+// time. Codes may contain colons, so the time is after the last one.
+func parseTrackingCookie(v string) (string, time.Time, bool) {
+	i := strings.LastIndexByte(v, ':')
+	if i <= 0 {
+		return "", time.Time{}, false
+	}
+	ts, err := strconv.ParseInt(v[i+1:], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return v[:i], time.Unix(ts, 0), true
+}
+
+type beaconRequest struct {
+	Code     string `json:"code"`
+	Referrer string `json:"referrer"`
+}
+
+// analyticsEventHandler serves POST /api/v1/analytics/event, where the
+// beacon reports a redirect from its tracking cookie. The event is recorded
+// at the time of the redirect, with the referrer the browser saw.
+func analyticsEventHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(trackingCookie)
+	if err != nil {
+		http.Error(w, "Missing tracking cookie", http.StatusBadRequest)
+		return
+	}
+	code, ts, ok := parseTrackingCookie(c.Value)
+	if age := time.Since(ts); !ok || age < -time.Minute || age > trackingCookieTTL {
+		http.Error(w, "Invalid tracking cookie", http.StatusBadRequest)
+		return
+	}
+
+	var req beaconRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, beaconMaxBytes)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Code != code {
+		http.Error(w, "code does not match the tracking cookie", http.StatusBadRequest)
+		return
+	}
+
+	mapping, err := lookupCode(code)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Failed to resolve code", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Clear the cookie so that the click is reported once.
+	http.SetCookie(w, &http.Cookie{Name: trackingCookie, Path: "/", MaxAge: -1, SameSite: http.SameSiteLaxMode})
+
+	ev := newClickEvent(r, mapping.Code)
+	ev.Timestamp = ts
+	ev.Referrer = req.Referrer
+	go recordClick(ev)
+	w.WriteHeader(http.StatusNoContent)
+}
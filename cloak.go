@@ -0,0 +1,112 @@
+package urlshortener
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var errCloakOnCreate = errors.New("cloak can only be turned on by an admin, with PATCH /api/v1/{code}")
+
+// cloakClient fetches cloaked destinations. It follows redirects itself so
+// the browser never sees the destination's address, and only connects to
+// public addresses.
+var cloakClient = &http.Client{Timeout: 30 * time.Second, Transport: publicTransport()}
+
+// clientTransport lets a ReverseProxy go through an http.Client, and so
+// follow redirects instead of passing them on.
+type clientTransport struct{ *http.Client }
+
+func (t clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.RequestURI = ""
+	return t.Do(req)
+}
+
+// serveCloaked proxies dest's response to the client in place of a redirect.
+// The visitor's cookies and credentials for this site are not forwarded,
+// and the destination's cookies are dropped. Relative links in the page
+// resolve against the short URL, so cloaking suits self-contained pages.
+func serveCloaked(w http.ResponseWriter, r *http.Request, dest string) {
+	target, err := url.Parse(dest)
+	if err != nil {
+		log.Printf("Failed to parse cloaked destination %s: %v", dest, err)
+		notFound(w, r)
+		return
+	}
+	acceptsGzip := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = target
+			pr.Out.Host = target.Host
+			pr.Out.Header.Del("Cookie")
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("Referer")
+			// Let the transport negotiate and decode compression so the
+			// body can be recompressed for the client below.
+			pr.Out.Header.Del("Accept-Encoding")
+		},
+		Transport: clientTransport{cloakClient},
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Del("Set-Cookie")
+			resp.Header.Del("Location")
+			if acceptsGzip && resp.Header.Get("Content-Encoding") == "" && compressible(resp.Header.Get("Content-Type")) {
+				gzipBody(resp)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Failed to fetch cloaked destination %s: %v", dest, err)
+			http.Error(w, "Failed to fetch destination", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+func compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mt, "text/"),
+		mt == "application/json",
+		mt == "application/javascript",
+		mt == "application/xml",
+		mt == "image/svg+xml",
+		strings.HasSuffix(mt, "+json"),
+		strings.HasSuffix(mt, "+xml"):
+		return true
+	}
+	return false
+}
+
+// gzipBody replaces resp.Body with a gzip stream of it. The compressing
+// goroutine stops once the proxy closes the new body.
+func gzipBody(resp *http.Response) {
+	pr, pw := io.Pipe()
+	body := resp.Body
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, body)
+		if err == nil {
+			err = gz.Close()
+		}
+		body.Close()
+		pw.CloseWithError(err)
+	}()
+
+	resp.Body = pr
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	resp.Header.Set("Content-Encoding", "gzip")
+	resp.Header.Add("Vary", "Accept-Encoding")
+}
@@ -0,0 +1,9 @@
+// Command server runs the urlshortener server. See CONFIGURATION.md for
+// its environment variables and flags.
+package main
+
+import "urlshortener"
+
+func main() {
+	urlshortener.Run()
+}
@@ -0,0 +1,246 @@
+// Command urlshortener-cli talks to a running urlshortener server over its
+// JSON API.
+//
+//	urlshortener-cli [flags] shorten <url>
+//	urlshortener-cli [flags] resolve <code>
+//	urlshortener-cli [flags] stats <code>
+//	urlshortener-cli [flags] delete <code>
+//	urlshortener-cli [flags] list [--tag=x] [--limit=20]
+//
+// The server URL and credentials come from --server, --user and --api-key,
+// falling back to ~/.urlshortener, which holds "key = value" lines for
+// server, user and api_key. The API key is the admin password; it is sent
+// with Basic Auth.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type client struct {
+	server string
+	user   string
+	apiKey string
+	http   *http.Client
+}
+
+func main() {
+	conf := loadConfigFile()
+
+	server := flag.String("server", conf["server"], "server base URL")
+	user := flag.String("user", conf["user"], "admin user name")
+	apiKey := flag.String("api-key", conf["api_key"], "admin API key (password)")
+	asJSON := flag.Bool("json", false, "print raw JSON responses")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *server == "" {
+		*server = "http://localhost:4001"
+	}
+	if *user == "" {
+		*user = "admin"
+	}
+	c := &client{
+		server: strings.TrimRight(*server, "/"),
+		user:   *user,
+		apiKey: *apiKey,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	if err := run(c, args[0], args[1:], *asJSON); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: urlshortener-cli [flags] <command> [args]
+
+Commands:
+  shorten <url>                 create a short URL
+  resolve <code>                show where a code points
+  stats <code>                  show click analytics
+  delete <code>                 permanently delete a short URL
+  list [--tag=x] [--limit=20]   list short URLs
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func run(c *client, cmd string, args []string, asJSON bool) error {
+	switch cmd {
+	case "shorten":
+		if len(args) != 1 {
+			return errors.New("usage: shorten <url>")
+		}
+		body, _ := json.Marshal(map[string]string{"url": args[0]})
+		var m struct {
+			Code     string `json:"code"`
+			ShortURL string `json:"short_url"`
+		}
+		return c.do(http.MethodPost, "/api/v1/shorten", body, asJSON, &m, func() {
+			if strings.HasPrefix(m.ShortURL, "/") {
+				m.ShortURL = c.server + m.ShortURL
+			}
+			fmt.Println(m.ShortURL)
+		})
+
+	case "resolve":
+		if len(args) != 1 {
+			return errors.New("usage: resolve <code>")
+		}
+		var res struct {
+			URL       string     `json:"url"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		return c.do(http.MethodGet, "/api/v1/"+url.PathEscape(args[0]), nil, asJSON, &res, func() {
+			fmt.Println(res.URL)
+			if res.ExpiresAt != nil {
+				fmt.Println("expires", res.ExpiresAt.Format(time.RFC3339))
+			}
+		})
+
+	case "stats":
+		if len(args) != 1 {
+			return errors.New("usage: stats <code>")
+		}
+		var a struct {
+			Code   string `json:"code"`
+			URL    string `json:"url"`
+			Clicks int64  `json:"clicks"`
+			Daily  []struct {
+				Date   string `json:"date"`
+				Clicks int64  `json:"clicks"`
+			} `json:"daily"`
+		}
+		return c.do(http.MethodGet, "/api/v1/"+url.PathEscape(args[0])+"/analytics", nil, asJSON, &a, func() {
+			fmt.Printf("%s -> %s\n%d clicks\n", a.Code, a.URL, a.Clicks)
+			for _, d := range a.Daily {
+				fmt.Printf("  %s  %d\n", d.Date, d.Clicks)
+			}
+		})
+
+	case "delete":
+		if len(args) != 1 {
+			return errors.New("usage: delete <code>")
+		}
+		return c.do(http.MethodDelete, "/api/v1/"+url.PathEscape(args[0]), nil, asJSON, nil, func() {
+			fmt.Println("deleted", args[0])
+		})
+
+	case "list":
+		fs := flag.NewFlagSet("list", flag.ContinueOnError)
+		tag := fs.String("tag", "", "only URLs with this tag")
+		limit := fs.Int("limit", 20, "number of URLs to show")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		q := url.Values{"per_page": {strconv.Itoa(*limit)}}
+		if *tag != "" {
+			q.Set("tag", *tag)
+		}
+		var l struct {
+			URLs []struct {
+				Code   string `json:"code"`
+				URL    string `json:"url"`
+				Clicks int64  `json:"clicks"`
+			} `json:"urls"`
+			Total int64 `json:"total"`
+		}
+		return c.do(http.MethodGet, "/api/v1/urls?"+q.Encode(), nil, asJSON, &l, func() {
+			for _, m := range l.URLs {
+				fmt.Printf("%-12s %6d  %s\n", m.Code, m.Clicks, m.URL)
+			}
+			fmt.Printf("%d of %d\n", len(l.URLs), l.Total)
+		})
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+// do sends the request and either prints the raw JSON response or decodes it
+// into out and calls show.
+func (c *client) do(method, path string, body []byte, asJSON bool, out interface{}, show func()) error {
+	req, err := http.NewRequest(method, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.SetBasicAuth(c.user, c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if asJSON {
+		if len(data) == 0 {
+			data = []byte("{}\n")
+		}
+		os.Stdout.Write(data)
+		return nil
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return err
+		}
+	}
+	show()
+	return nil
+}
+
+// loadConfigFile reads ~/.urlshortener. A missing file is not an error.
+func loadConfigFile() map[string]string {
+	conf := map[string]string{}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return conf
+	}
+	f, err := os.Open(filepath.Join(home, ".urlshortener"))
+	if err != nil {
+		return conf
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			conf[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return conf
+}
@@ -0,0 +1,85 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"urlshortener/pkg/shortener"
+	"urlshortener/pkg/store"
+)
+
+const (
+	codeCharset = shortener.Alphabet
+
+	codeIntBatchSize = 500
+)
+
+// codeFilter selects a mapping by its code. It matches the string code
+// only: every update and the shard key go through the unique {code: 1}
+// index, so indexing code_int as well would add to the index footprint
+// rather than shrink it (BenchmarkCodeIndexSize in pkg/store measures the
+// two). code_int is still written and backfilled so lookups can move to it
+// here once nothing else needs the string index.
+func codeFilter(code string) bson.M {
+	return bson.M{"code": code}
+}
+
+// migrateCodeInts backfills code_int on documents written before it
+// existed.
+func migrateCodeInts(ctx context.Context) {
+	cur, err := collection.Find(ctx,
+		bson.M{"code_int": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"code": 1}),
+	)
+	if err != nil {
+		log.Printf("code_int migration failed: %v", err)
+		return
+	}
+	defer cur.Close(ctx)
+
+	var (
+		models   []mongo.WriteModel
+		migrated int64
+	)
+	flush := func() bool {
+		if len(models) == 0 {
+			return true
+		}
+		res, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			log.Printf("code_int migration failed: %v", err)
+			return false
+		}
+		migrated += res.ModifiedCount
+		models = models[:0]
+		return true
+	}
+	for cur.Next(ctx) {
+		var doc struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Code string             `bson:"code"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			log.Printf("code_int migration failed: %v", err)
+			return
+		}
+		n, ok := store.CodeToInt(doc.Code)
+		if !ok {
+			continue
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": doc.ID, "code": doc.Code}).
+			SetUpdate(bson.M{"$set": bson.M{"code_int": n}}))
+		if len(models) == codeIntBatchSize && !flush() {
+			return
+		}
+	}
+	if flush() && migrated > 0 {
+		log.Printf("code_int migration backfilled %d documents", migrated)
+	}
+}
@@ -0,0 +1,141 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultCodeLength   = 6
+	codeLengthSettingID = "code_length"
+	codeLengthInterval  = time.Hour
+)
+
+var settings *mongo.Collection
+
+// codeLength is the length of newly generated codes once raised above
+// the default. It only ever grows, and existing codes of any length keep
+// resolving.
+var codeLength atomic.Int64
+
+func currentCodeLength() int {
+	if n := codeLength.Load(); n > defaultCodeLength {
+		return int(n)
+	}
+	return defaultCodeLength
+}
+
+// codeLengthThreshold switches generated codes to Length once more than
+// URLs mappings are stored.
+type codeLengthThreshold struct {
+	URLs   int64
+	Length int
+}
+
+// parseCodeLengthThresholds reads CODE_LENGTH_THRESHOLDS, a list of
+// "urls:length" pairs such as "10000000:7,600000000:8".
+func parseCodeLengthThresholds(s string) ([]codeLengthThreshold, error) {
+	var out []codeLengthThreshold
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		urls, length, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, errors.New("expected urls:length pairs")
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(urls), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		l, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			return nil, err
+		}
+		if l <= defaultCodeLength || l > 32 {
+			return nil, errors.New("lengths must be between 7 and 32")
+		}
+		out = append(out, codeLengthThreshold{URLs: n, Length: l})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].URLs < out[j].URLs })
+	return out, nil
+}
+
+// startCodeLength loads the stored code length, then re-checks the URL
+// count against the thresholds now and hourly.
+func startCodeLength(ctx context.Context) {
+	var doc struct {
+		Value int `bson:"value"`
+	}
+	err := settings.FindOne(ctx, bson.M{"_id": codeLengthSettingID}).Decode(&doc)
+	switch {
+	case err == nil:
+		if doc.Value > defaultCodeLength {
+			codeLength.Store(int64(doc.Value))
+		}
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		log.Printf("Failed to load code length setting: %v", err)
+	}
+	checkCodeLength(ctx)
+
+	go func() {
+		ticker := time.NewTicker(codeLengthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkCodeLength(ctx)
+			}
+		}
+	}()
+}
+
+// checkCodeLength raises the code length if the stored URL count has
+// passed a threshold, and records the new length in settings so every
+// instance picks it up.
+func checkCodeLength(ctx context.Context) {
+	n, err := collection.EstimatedDocumentCount(ctx)
+	if err != nil {
+		log.Printf("Failed to count URLs for code length: %v", err)
+		return
+	}
+	have := currentCodeLength()
+	want := have
+	for _, t := range cfg.CodeLengthThresholds {
+		if n > t.URLs && t.Length > want {
+			want = t.Length
+		}
+	}
+	if want == have {
+		return
+	}
+
+	// $max keeps a longer length another instance may already have set.
+	var doc struct {
+		Value int `bson:"value"`
+	}
+	err = settings.FindOneAndUpdate(ctx,
+		bson.M{"_id": codeLengthSettingID},
+		bson.M{"$max": bson.M{"value": want}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		log.Printf("Failed to save code length: %v", err)
+		return
+	}
+	codeLength.Store(int64(doc.Value))
+	log.Printf("%d URLs stored; generating %d-character codes", n, doc.Value)
+}
@@ -0,0 +1,265 @@
+package urlshortener
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// urlCollections holds the folders short URLs are organised into. A URL
+// belongs to at most one, named by URLMapping.CollectionID.
+var urlCollections *mongo.Collection
+
+type URLCollection struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	OwnerID     string             `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+
+	// URLCount is filled in by the list endpoint.
+	URLCount int64 `bson:"-" json:"url_count"`
+}
+
+type collectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	OwnerID     string `json:"owner_id"`
+}
+
+func decodeCollectionRequest(w http.ResponseWriter, r *http.Request) (collectionRequest, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req collectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func collectionIDParam(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	oid, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return oid, false
+	}
+	return oid, true
+}
+
+func apiCreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeCollectionRequest(w, r)
+	if !ok {
+		return
+	}
+	c := URLCollection{
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerID:     req.OwnerID,
+		CreatedAt:   time.Now(),
+	}
+	res, err := urlCollections.InsertOne(r.Context(), c)
+	if err != nil {
+		log.Printf("Failed to save collection %s: %v", c.Name, err)
+		http.Error(w, "Failed to save collection", http.StatusInternalServerError)
+		return
+	}
+	c.ID = res.InsertedID.(primitive.ObjectID)
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// apiListCollectionsHandler lists collections by name with the number of
+// URLs in each, optionally only those of ?owner_id=.
+func apiListCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	filter := bson.M{}
+	if owner := r.URL.Query().Get("owner_id"); owner != "" {
+		filter["owner_id"] = owner
+	}
+	list, err := listCollections(r, filter)
+	if err != nil {
+		log.Printf("Failed to list collections: %v", err)
+		http.Error(w, "Failed to list collections", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func listCollections(r *http.Request, filter bson.M) ([]URLCollection, error) {
+	cur, err := urlCollections.Find(r.Context(), filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	list := []URLCollection{}
+	if err := cur.All(r.Context(), &list); err != nil {
+		return nil, err
+	}
+
+	cur, err = collection.Aggregate(r.Context(), bson.A{
+		bson.M{"$match": bson.M{"collection_id": bson.M{"$exists": true}}},
+		bson.M{"$group": bson.M{"_id": "$collection_id", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var counts []CountEntry
+	if err := cur.All(r.Context(), &counts); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		byID[c.Key] = c.Count
+	}
+	for i := range list {
+		list[i].URLCount = byID[list[i].ID.Hex()]
+	}
+	return list, nil
+}
+
+func apiUpdateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	oid, ok := collectionIDParam(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeCollectionRequest(w, r)
+	if !ok {
+		return
+	}
+	var c URLCollection
+	err := urlCollections.FindOneAndUpdate(r.Context(),
+		bson.M{"_id": oid},
+		bson.M{"$set": bson.M{"name": req.Name, "description": req.Description, "owner_id": req.OwnerID}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&c)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to update collection %s: %v", oid.Hex(), err)
+		http.Error(w, "Failed to update collection", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// apiDeleteCollectionHandler removes a collection. Its URLs are kept and
+// become unfiled; other instances' caches follow via the change stream.
+func apiDeleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	oid, ok := collectionIDParam(w, r)
+	if !ok {
+		return
+	}
+	res, err := urlCollections.DeleteOne(r.Context(), bson.M{"_id": oid})
+	if err != nil {
+		log.Printf("Failed to delete collection %s: %v", oid.Hex(), err)
+		http.Error(w, "Failed to delete collection", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	_, err = collection.UpdateMany(r.Context(), bson.M{"collection_id": oid.Hex()}, bson.M{"$unset": bson.M{"collection_id": ""}})
+	if err != nil {
+		log.Printf("Failed to unfile URLs of collection %s: %v", oid.Hex(), err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiCollectionURLsHandler pages through the URLs in a collection, newest
+// first.
+func apiCollectionURLsHandler(w http.ResponseWriter, r *http.Request) {
+	oid, ok := collectionIDParam(w, r)
+	if !ok {
+		return
+	}
+	n, err := urlCollections.CountDocuments(r.Context(), bson.M{"_id": oid})
+	if err != nil {
+		log.Printf("Failed to load collection %s: %v", oid.Hex(), err)
+		http.Error(w, "Failed to list URLs", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeMappingPage(w, r, bson.M{"collection_id": oid.Hex()})
+}
+
+// apiAddToCollectionHandler files the URL {"code": ...} into the
+// collection, moving it out of any other.
+func apiAddToCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	oid, ok := collectionIDParam(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Body must be {\"code\": ...}", http.StatusBadRequest)
+		return
+	}
+
+	n, err := urlCollections.CountDocuments(r.Context(), bson.M{"_id": oid})
+	if err != nil {
+		log.Printf("Failed to load collection %s: %v", oid.Hex(), err)
+		http.Error(w, "Failed to update URL", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	fileURL(w, r, normalizeCode(req.Code), bson.M{"$set": bson.M{"collection_id": oid.Hex()}}, nil)
+}
+
+// apiRemoveFromCollectionHandler unfiles {code} if it is in the
+// collection.
+func apiRemoveFromCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	oid, ok := collectionIDParam(w, r)
+	if !ok {
+		return
+	}
+	fileURL(w, r, codeParam(r), bson.M{"$unset": bson.M{"collection_id": ""}}, bson.M{"collection_id": oid.Hex()})
+}
+
+// fileURL applies update to the mapping with code, further restricted by
+// match, and answers with the updated mapping.
+func fileURL(w http.ResponseWriter, r *http.Request, code string, update, match bson.M) {
+	filter := bson.M{"code": code}
+	for k, v := range match {
+		filter[k] = v
+	}
+
+	var updated URLMapping
+	err := collection.FindOneAndUpdate(r.Context(), filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to update %s: %v", code, err)
+		http.Error(w, "Failed to update URL", http.StatusInternalServerError)
+		return
+	}
+
+	shortURLs.Update(code, func(m *URLMapping) { *m = updated })
+	publishMutation(replicateUpsert, updated)
+	writeJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,177 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collisionStatsInterval = time.Hour
+
+	// collisionTarget is the probability the recommended code length keeps
+	// collisions below, collisionAlert the one that raises an alert.
+	collisionTarget = 0.001
+	collisionAlert  = 0.01
+
+	collisionHistory = 7 * 24 * time.Hour
+)
+
+var collisionStats *mongo.Collection
+
+// CollisionStat is an hourly reading of the birthday-paradox chance that
+// any two of URLs random codes of CodeLength characters coincide. The
+// generator retries on a collision, so a high chance means slower or
+// failing creations, not clashing links. It is keyed by the hour, so
+// every instance records the same document.
+type CollisionStat struct {
+	Hour              time.Time `bson:"_id" json:"-"`
+	Timestamp         time.Time `bson:"timestamp" json:"timestamp"`
+	URLs              int64     `bson:"urls" json:"urls"`
+	CodeLength        int       `bson:"code_length" json:"code_length"`
+	Probability       float64   `bson:"probability" json:"probability"`
+	RecommendedLength int       `bson:"recommended_code_length" json:"recommended_code_length"`
+}
+
+// collisionProbability is 1 - e^(-n²/(2·k^length)) for an alphabet of k
+// characters. Expm1 keeps the tiny values of a sparse keyspace from
+// rounding to zero.
+func collisionProbability(n int64, length int) float64 {
+	space := math.Pow(float64(len(codeCharset)), float64(length))
+	return -math.Expm1(-float64(n) * float64(n) / (2 * space))
+}
+
+// recommendedCodeLength is the shortest code length, from the 3 characters
+// codes need at least, that keeps the collision probability of n codes
+// below collisionTarget.
+func recommendedCodeLength(n int64) int {
+	length := 3
+	for length < 32 && collisionProbability(n, length) >= collisionTarget {
+		length++
+	}
+	return length
+}
+
+func newCollisionStat(n int64, now time.Time) CollisionStat {
+	length := currentCodeLength()
+	return CollisionStat{
+		Hour:              now.Truncate(collisionStatsInterval),
+		Timestamp:         now,
+		URLs:              n,
+		CodeLength:        length,
+		Probability:       collisionProbability(n, length),
+		RecommendedLength: recommendedCodeLength(n),
+	}
+}
+
+// startCollisionStats records a CollisionStat now and hourly.
+func startCollisionStats(ctx context.Context) {
+	go func() {
+		recordCollisionStat(ctx)
+		ticker := time.NewTicker(collisionStatsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				recordCollisionStat(ctx)
+			}
+		}
+	}()
+}
+
+// recordCollisionStat stores this hour's reading unless another instance
+// already has, and the instance that stores it alerts if the probability
+// has just passed collisionAlert.
+func recordCollisionStat(ctx context.Context) {
+	n, err := collection.EstimatedDocumentCount(ctx)
+	if err != nil {
+		log.Printf("Failed to count URLs for collision stats: %v", err)
+		return
+	}
+	stat := newCollisionStat(n, time.Now())
+	res, err := collisionStats.UpdateOne(ctx,
+		bson.M{"_id": stat.Hour},
+		bson.M{"$setOnInsert": stat},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Failed to record collision stats: %v", err)
+		return
+	}
+	if res.UpsertedCount == 0 || stat.Probability <= collisionAlert {
+		return
+	}
+
+	var prev CollisionStat
+	err = collisionStats.FindOne(ctx,
+		bson.M{"_id": bson.M{"$lt": stat.Hour}},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}),
+	).Decode(&prev)
+	if err == nil && prev.Probability > collisionAlert && prev.CodeLength == stat.CodeLength {
+		return
+	}
+	alertCollisionRisk(stat)
+}
+
+// alertCollisionRisk logs stat and posts it to SLACK_WEBHOOK_URL.
+func alertCollisionRisk(stat CollisionStat) {
+	text := fmt.Sprintf("Short code collision probability is %.2f%% for %d URLs with %d-character codes; %d characters would keep it below %.1f%%",
+		stat.Probability*100, stat.URLs, stat.CodeLength, stat.RecommendedLength, collisionTarget*100)
+	log.Print(text)
+	if cfg.SlackWebhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{text})
+	if err == nil {
+		_, err = postSlack(body)
+	}
+	if err != nil {
+		log.Printf("Failed to notify Slack of the collision risk: %v", err)
+	}
+}
+
+type collisionRisk struct {
+	CollisionStat
+	History []CollisionStat `json:"history"`
+}
+
+// collisionRiskHandler serves GET /api/v1/admin/collision-risk: the
+// probability for the current URL count and code length, and the hourly
+// readings of the last week, oldest first.
+func collisionRiskHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := collection.EstimatedDocumentCount(r.Context())
+	if err != nil {
+		log.Printf("Failed to count URLs: %v", err)
+		http.Error(w, "Failed to compute collision risk", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	cur, err := collisionStats.Find(r.Context(),
+		bson.M{"_id": bson.M{"$gte": now.Add(-collisionHistory)}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		log.Printf("Failed to load collision stats: %v", err)
+		http.Error(w, "Failed to load collision stats", http.StatusInternalServerError)
+		return
+	}
+	history := []CollisionStat{}
+	if err := cur.All(r.Context(), &history); err != nil {
+		log.Printf("Failed to decode collision stats: %v", err)
+		http.Error(w, "Failed to load collision stats", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, collisionRisk{CollisionStat: newCollisionStat(n, now), History: history})
+}
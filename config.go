@@ -0,0 +1,396 @@
+package urlshortener
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+//go:generate go run gen_config_docs.go
+
+// Config holds every tunable. The line comment on each field is its
+// documentation in config_docs.yaml; run go generate after changing them.
+type Config struct {
+	MongoURI        string // Comma-separated MongoDB URIs, tried in order
+	TrustProxy      string // When to honour X-Forwarded-For: never, always or private-only
+	AdminUser       string // Basic auth user for the admin API and panel
+	AdminPassword   string // Basic auth password; admin endpoints are disabled when empty
+	S3Bucket        string // Bucket for the hourly click event export; off when empty
+	S3Endpoint      string // S3-compatible endpoint, empty for AWS
+	S3Region        string // Region of the export bucket
+	TLSCertFile     string // Certificate file; serves HTTPS together with TLS_KEY_FILE
+	TLSKeyFile      string // Private key file for TLS_CERT_FILE
+	MaxChainDepth   int    // Maximum number of short URLs a redirect chain may follow
+	NoPreload       bool   // Skip cache warm-up and accept writes immediately
+	DryRun          bool   // Keep short URLs in memory only, without connecting to MongoDB
+	MutationLogPath string // File to append every short URL mutation to, as newline-delimited JSON; off when empty
+	ReplayOnStart   bool   // Load the mutation log into the cache on startup, before the warm-up from MongoDB
+	KubernetesMode  bool   // Drain gracefully on SIGTERM and serve the /prestop hook
+	CaseInsensitive bool   // Fold short codes to lower case on creation and lookup
+	CodeStyle       string // Generated code style: random or nato
+	BaseURL         string // Public base URL of short links; links are bare paths when empty
+	LogLevel        string // Minimum log level: debug, info, warn or error
+
+	StoreBackend string // Database of short URLs: mongo, or sqlite or postgres, which serve only the core API of shortening, resolving, redirecting and deleting
+	SQLitePath   string // SQLite database file of STORE_BACKEND=sqlite, created with its tables if missing
+	PostgresDSN  string // PostgreSQL connection URL or key=value string of STORE_BACKEND=postgres; its tables are created if missing
+
+	APIDeprecationDate string // Date (YYYY-MM-DD) from which v1 endpoints with a v2 counterpart send Deprecation and successor-version Link headers
+
+	DefaultRedirectType int // Redirect status when neither the URL, its namespace nor its owner sets one: 301, 302, 303, 307 or 308
+
+	ValidateOnRedirect bool // Probe the destination before redirecting and warn when it fails
+
+	ClientSideAnalytics bool // Record clicks that show the preview page from a beacon on it, which reports a _ushortener cookie to /api/v1/analytics/event, instead of on the server; other redirects and bot visits are still recorded on the server
+
+	NamespaceMetrics bool // Label counters and histograms with the namespace; adds a series per namespace, so leave it off with many namespaces
+
+	ProxyMode       bool   // Forward paths that aren't short codes to PROXY_BACKEND_URL instead of answering 404
+	ProxyBackendURL string // Web app served behind the shortener in --proxy-mode
+
+	RobotsDisallow []string // Comma-separated paths disallowed in robots.txt
+
+	ReplicationQueue string // Queue for regional replication: none, channel, redis or kafka
+	ReplicationTopic string // Redis stream or Kafka topic that carries mapping changes
+	RedisURL         string // Redis server for the redis replication queue and shared rate limits
+	KafkaBrokers     string // Comma-separated Kafka brokers for the kafka replication queue
+	RegionMongoURI   string // Regional MongoDB that receives replicated mappings
+	Region           string // Name of this region, used in the consumer group
+
+	NATSURL string // NATS server that receives short URL lifecycle events; none are published when empty
+
+	DeadLetterFile string // NDJSON file keeping short URLs MongoDB failed to save until a retry succeeds; failed saves fail the request when empty
+
+	BigQueryProject string // Google Cloud project to stream click events to; BigQuery export is off when empty
+	BigQueryDataset string // BigQuery dataset of the click event table
+	BigQueryTable   string // BigQuery table for click events, created if missing
+
+	SMTPHost  string // SMTP server for alerts; email is off when empty
+	SMTPPort  string // SMTP server port
+	SMTPUser  string // SMTP user, empty for unauthenticated relays
+	SMTPPass  string // SMTP password
+	FromEmail string // Sender address of outgoing email
+
+	InboundEmailEnabled  bool   // Run an SMTP listener that shortens the links in mail to the shorten@ mailbox and replies with the short URLs
+	InboundEmailPort     int    // Port of the inbound email listener
+	InboundEmailAuthServ string // Authserv-id of the Authentication-Results header added by the mail server that relays to the listener; only mail it passed SPF or DKIM for, aligned with the From domain, is shortened
+
+	ClickSamplingThreshold int // Redirects of a URL per minute on one instance above which its clicks are sampled to keep about that many events a minute; 0 never samples automatically
+
+	HandlerTimeoutMS     int // Timeout for write handlers, in milliseconds
+	RedirectTimeoutMS    int // Timeout for redirects, in milliseconds
+	ShortenRateLimit     int // Shorten requests allowed per client per minute
+	BookmarkletRateLimit int // GET /api/v1/shorten requests allowed per client per minute
+
+	FreeRedirectDelayMS    int // Artificial delay, in milliseconds, before redirecting to the destination of a free-tier URL
+	PremiumRedirectDelayMS int // Artificial delay, in milliseconds, before redirecting to the destination of a premium URL
+
+	APIKeys []string // Comma-separated keys accepted by GET /api/v1/shorten
+
+	ExtensionOrigins []string // Comma-separated browser extension origins, e.g. chrome-extension://<id>, allowed to call GET /api/v1/shorten?source=extension
+
+	HMACSecret string // Key that signs short URLs created with requires_signature
+
+	JWTSecret string // Key that signs admin impersonation tokens; impersonation is off when empty
+
+	RateLimitRedis bool // Keep rate limit counters in Redis at REDIS_URL, shared by all instances
+
+	Screenshots bool // Capture destination screenshots with a headless browser
+
+	MergeDuplicateClicks bool // Weekly, move clicks of a newer code onto an older one with the same destination
+
+	AliasMode string // How alias codes redirect: lookup reads the canonical code on every redirect, copy stores its destination when the alias is created
+
+	AlwaysPreview bool // Show a preview page with the destination instead of redirecting, unless a URL's always_preview says otherwise
+
+	HealthCheckWorkers int // Concurrent destination health probes
+
+	ValidateAllWorkers int // Concurrent destination checks of GET /api/v1/admin/validate-all
+
+	SlackWebhookURL string // Incoming webhook notified of new short URLs
+
+	FeedSyncCron string // Cron schedule for syncing subscribed feeds; on demand only when empty
+
+	AnalyticsRetentionDays int    // Days of click analytics to keep
+	AnalyticsRetentionAt   string // Daily time, HH:MM server local, of the retention sweep
+
+	URLInactivityDays int // Days without clicks after which a short URL is disabled, following a notice to its owner; 0 turns this off
+
+	ClickDedupWindowSeconds int // Repeat clicks from one client within this window count once
+
+	DuplicateFilterFPRate float64 // False-positive rate of the Bloom filter that lets the home page form skip duplicate lookups
+
+	CustomHeadersAllowed []string // Comma-separated response headers, besides Cache-Control, Link, Referrer-Policy and Vary, that creators may set on their redirects with custom_headers
+
+	DeepLinkSchemes []string // Comma-separated custom URL schemes, e.g. myapp, that app_deep_link may use besides http and https
+
+	TrackingParams []string // Comma-separated query parameters, with a trailing * as a wildcard, ignored when matching duplicate destinations
+
+	CodeLengthThresholds []codeLengthThreshold // Comma-separated urls:length pairs that lengthen generated codes as the collection grows
+
+	AdminAllowedCIDRs []*net.IPNet // Comma-separated CIDR blocks, e.g. 10.0.0.0/8,192.168.1.0/24, that admin credentials are accepted from; all when empty
+
+	OAuth2GoogleClientID     string   // Google OAuth2 client ID for admin sign-in
+	OAuth2GoogleClientSecret string   // Google OAuth2 client secret
+	OAuth2GitHubClientID     string   // GitHub OAuth2 client ID for admin sign-in
+	OAuth2GitHubClientSecret string   // GitHub OAuth2 client secret
+	OAuth2AdminEmails        []string // Comma-separated emails allowed to sign in as admin
+}
+
+var cfg Config
+
+func loadConfig() Config {
+	c := Config{
+		MongoURI:      getEnv("MONGO_URI", ""),
+		TrustProxy:    getEnv("TRUST_PROXY", trustPrivateOnly),
+		AdminUser:     getEnv("ADMIN_USER", "admin"),
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+		S3Bucket:      getEnv("S3_BUCKET", ""),
+		S3Endpoint:    getEnv("S3_ENDPOINT", ""),
+		S3Region:      getEnv("AWS_REGION", "us-east-1"),
+		TLSCertFile:   getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:    getEnv("TLS_KEY_FILE", ""),
+		MaxChainDepth: getEnvInt("MAX_CHAIN_DEPTH", 5),
+		BaseURL:       strings.TrimRight(getEnv("BASE_URL", ""), "/"),
+	}
+
+	c.ReplicationQueue = getEnv("REPLICATION_QUEUE", queueNone)
+	c.ReplicationTopic = getEnv("REPLICATION_TOPIC", "urlshortener.mappings")
+	c.RedisURL = getEnv("REDIS_URL", "redis://localhost:6379/0")
+	c.ProxyBackendURL = getEnv("PROXY_BACKEND_URL", "")
+	c.KafkaBrokers = getEnv("KAFKA_BROKERS", "localhost:9092")
+	c.RegionMongoURI = getEnv("REGION_MONGO_URI", "")
+	c.Region = getEnv("REGION", "default")
+
+	c.NATSURL = getEnv("NATS_URL", "")
+
+	c.DeadLetterFile = getEnv("DEAD_LETTER_FILE", "dead_letter.log")
+
+	c.BigQueryProject = getEnv("BIGQUERY_PROJECT", "")
+	c.BigQueryDataset = getEnv("BIGQUERY_DATASET", "urlshortener")
+	c.BigQueryTable = getEnv("BIGQUERY_TABLE", "click_events")
+	c.SMTPHost = getEnv("SMTP_HOST", "")
+	c.SMTPPort = getEnv("SMTP_PORT", "587")
+	c.SMTPUser = getEnv("SMTP_USER", "")
+	c.SMTPPass = getEnv("SMTP_PASS", "")
+	c.FromEmail = getEnv("FROM_EMAIL", "noreply@localhost")
+	c.InboundEmailEnabled, _ = strconv.ParseBool(getEnv("INBOUND_EMAIL_ENABLED", "false"))
+	c.InboundEmailPort = getEnvInt("INBOUND_EMAIL_PORT", 2525)
+	c.InboundEmailAuthServ = getEnv("INBOUND_EMAIL_AUTHSERV_ID", "")
+	c.ClickSamplingThreshold = getEnvInt("CLICK_SAMPLING_THRESHOLD", 6000)
+	c.HandlerTimeoutMS = getEnvInt("HANDLER_TIMEOUT_MS", 30000)
+	c.RedirectTimeoutMS = getEnvInt("REDIRECT_TIMEOUT_MS", 5000)
+	c.FreeRedirectDelayMS = getEnvInt("FREE_REDIRECT_DELAY_MS", 500)
+	c.PremiumRedirectDelayMS = getEnvInt("PREMIUM_REDIRECT_DELAY_MS", 0)
+	c.DefaultRedirectType = getEnvInt("DEFAULT_REDIRECT_TYPE", 302)
+	c.ShortenRateLimit = getEnvInt("SHORTEN_RATE_LIMIT", 60)
+	c.BookmarkletRateLimit = getEnvInt("BOOKMARKLET_RATE_LIMIT", 10)
+	c.HMACSecret = getEnv("SHORTURL_HMAC_SECRET", "")
+	c.JWTSecret = getEnv("JWT_SECRET", "")
+	c.AnalyticsRetentionDays = getEnvInt("ANALYTICS_RETENTION_DAYS", 90)
+	c.AnalyticsRetentionAt = getEnv("ANALYTICS_RETENTION_AT", "03:00")
+	c.URLInactivityDays = getEnvInt("URL_INACTIVITY_DAYS", 180)
+	c.ClickDedupWindowSeconds = getEnvInt("CLICK_DEDUP_WINDOW_SECONDS", 30)
+	c.OAuth2GoogleClientID = getEnv("OAUTH2_GOOGLE_CLIENT_ID", "")
+	c.OAuth2GoogleClientSecret = getEnv("OAUTH2_GOOGLE_CLIENT_SECRET", "")
+	c.OAuth2GitHubClientID = getEnv("OAUTH2_GITHUB_CLIENT_ID", "")
+	c.OAuth2GitHubClientSecret = getEnv("OAUTH2_GITHUB_CLIENT_SECRET", "")
+	c.RateLimitRedis, _ = strconv.ParseBool(getEnv("RATE_LIMIT_REDIS", "false"))
+	c.Screenshots, _ = strconv.ParseBool(getEnv("SCREENSHOTS", "false"))
+	c.MergeDuplicateClicks, _ = strconv.ParseBool(getEnv("MERGE_DUPLICATE_CLICKS", "false"))
+	c.AlwaysPreview, _ = strconv.ParseBool(getEnv("ALWAYS_PREVIEW", "false"))
+	c.HealthCheckWorkers = getEnvInt("HEALTH_CHECK_WORKERS", 4)
+	c.ValidateAllWorkers = getEnvInt("VALIDATE_ALL_WORKERS", 10)
+	c.SlackWebhookURL = getEnv("SLACK_WEBHOOK_URL", "")
+	c.FeedSyncCron = getEnv("FEED_SYNC_CRON", "")
+	c.LogLevel = getEnv("LOG_LEVEL", "info")
+	c.AliasMode = getEnv("ALIAS_MODE", aliasModeLookup)
+	c.StoreBackend = getEnv("STORE_BACKEND", storeMongo)
+	c.SQLitePath = getEnv("SQLITE_PATH", "urlshortener.db")
+	c.PostgresDSN = getEnv("POSTGRES_DSN", "")
+	switch c.ReplicationQueue {
+	case queueNone, queueChannel, queueRedis, queueKafka:
+	default:
+		log.Fatalf("Invalid REPLICATION_QUEUE %q: must be %s, %s or %s", c.ReplicationQueue, queueChannel, queueRedis, queueKafka)
+	}
+	switch c.StoreBackend {
+	case storeMongo, storeSQLite:
+	case storePostgres:
+		if c.PostgresDSN == "" {
+			log.Fatalf("STORE_BACKEND=%s requires POSTGRES_DSN", storePostgres)
+		}
+	default:
+		log.Fatalf("Invalid STORE_BACKEND %q: must be %s, %s or %s", c.StoreBackend, storeMongo, storeSQLite, storePostgres)
+	}
+	if c.AliasMode != aliasModeLookup && c.AliasMode != aliasModeCopy {
+		log.Fatalf("Invalid ALIAS_MODE %q: must be %s or %s", c.AliasMode, aliasModeLookup, aliasModeCopy)
+	}
+
+	var err error
+	c.CodeLengthThresholds, err = parseCodeLengthThresholds(getEnv("CODE_LENGTH_THRESHOLDS", "10000000:7,600000000:8"))
+	if err != nil {
+		log.Fatalf("Invalid CODE_LENGTH_THRESHOLDS: %v", err)
+	}
+	c.AdminAllowedCIDRs, err = parseCIDRs(getEnv("ADMIN_ALLOWED_CIDRS", ""))
+	if err != nil {
+		log.Fatalf("Invalid ADMIN_ALLOWED_CIDRS: %v", err)
+	}
+	c.DuplicateFilterFPRate, err = strconv.ParseFloat(getEnv("DUPLICATE_FILTER_FP_RATE", "0.001"), 64)
+	if err != nil || c.DuplicateFilterFPRate <= 0 || c.DuplicateFilterFPRate >= 1 {
+		log.Fatalf("Invalid DUPLICATE_FILTER_FP_RATE: must be between 0 and 1")
+	}
+
+	for _, email := range strings.Split(getEnv("OAUTH2_ADMIN_EMAILS", ""), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			c.OAuth2AdminEmails = append(c.OAuth2AdminEmails, email)
+		}
+	}
+
+	for _, key := range strings.Split(getEnv("API_KEYS", ""), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			c.APIKeys = append(c.APIKeys, key)
+		}
+	}
+
+	for _, origin := range strings.Split(getEnv("EXTENSION_ORIGINS", ""), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			c.ExtensionOrigins = append(c.ExtensionOrigins, origin)
+		}
+	}
+
+	for _, name := range strings.Split(getEnv("CUSTOM_HEADERS_ALLOWED", "X-Campaign,X-Robots-Tag"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			c.CustomHeadersAllowed = append(c.CustomHeadersAllowed, http.CanonicalHeaderKey(name))
+		}
+	}
+
+	for _, scheme := range strings.Split(getEnv("DEEP_LINK_SCHEMES", ""), ",") {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			c.DeepLinkSchemes = append(c.DeepLinkSchemes, scheme)
+		}
+	}
+
+	for _, param := range strings.Split(getEnv("TRACKING_PARAMS", "utm_*,fbclid,gclid,ref"), ",") {
+		if param = strings.ToLower(strings.TrimSpace(param)); param != "" {
+			c.TrackingParams = append(c.TrackingParams, param)
+		}
+	}
+
+	for _, path := range strings.Split(getEnv("ROBOTS_TXT_DISALLOW", "/api/"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			c.RobotsDisallow = append(c.RobotsDisallow, path)
+		}
+	}
+
+	flag.BoolVar(&c.NoPreload, "no-preload", false, "skip cache warm-up and accept writes immediately")
+	flag.BoolVar(&c.DryRun, "dry-run", false, "keep short URLs in memory only, without connecting to MongoDB")
+	flag.StringVar(&c.MutationLogPath, "mutation-log-path", "", "file to append every short URL mutation to, as newline-delimited JSON")
+	flag.BoolVar(&c.ReplayOnStart, "replay-on-start", false, "load the mutation log into the cache on startup")
+	flag.BoolVar(&c.CaseInsensitive, "case-insensitive", false, "fold short codes to lower case on creation and lookup")
+	flag.StringVar(&c.CodeStyle, "code-style", codeStyleRandom, "generated code style: random or nato")
+	flag.StringVar(&c.APIDeprecationDate, "api-deprecation-date", "", "date (YYYY-MM-DD) from which v1 endpoints with a v2 counterpart are marked deprecated")
+	flag.BoolVar(&c.KubernetesMode, "kubernetes-mode", false, "drain gracefully on SIGTERM and serve the /prestop hook")
+	flag.BoolVar(&c.ValidateOnRedirect, "validate-on-redirect", false, "probe the destination before redirecting and warn when it fails")
+	flag.BoolVar(&c.ClientSideAnalytics, "client-side-analytics", false, "record clicks that show the preview page from a browser beacon and a tracking cookie instead of on the server")
+	flag.BoolVar(&c.NamespaceMetrics, "enable-namespace-metrics", false, "label counters and histograms with the request's or URL's namespace")
+	flag.BoolVar(&c.ProxyMode, "proxy-mode", false, "forward paths that aren't short codes to PROXY_BACKEND_URL")
+	flag.Parse()
+
+	if c.CodeStyle != codeStyleRandom && c.CodeStyle != codeStyleNATO {
+		log.Fatalf("Invalid --code-style %q: must be %s or %s", c.CodeStyle, codeStyleRandom, codeStyleNATO)
+	}
+
+	if c.APIDeprecationDate != "" {
+		if _, err := time.Parse(time.DateOnly, c.APIDeprecationDate); err != nil {
+			log.Fatalf("Invalid --api-deprecation-date %q: must be YYYY-MM-DD", c.APIDeprecationDate)
+		}
+	}
+	if c.ProxyMode && c.ProxyBackendURL == "" {
+		log.Fatalf("--proxy-mode requires PROXY_BACKEND_URL")
+	}
+	if c.ReplayOnStart && c.MutationLogPath == "" {
+		log.Fatalf("--replay-on-start requires --mutation-log-path")
+	}
+	if c.DefaultRedirectType == 0 || validateRedirectType(c.DefaultRedirectType) != nil {
+		log.Fatalf("Invalid DEFAULT_REDIRECT_TYPE %d: must be 301, 302, 303, 307 or 308", c.DefaultRedirectType)
+	}
+	for name, ms := range map[string]int{"FREE_REDIRECT_DELAY_MS": c.FreeRedirectDelayMS, "PREMIUM_REDIRECT_DELAY_MS": c.PremiumRedirectDelayMS} {
+		if ms < 0 || ms >= c.RedirectTimeoutMS {
+			log.Fatalf("Invalid %s %d: must be at least 0 and below REDIRECT_TIMEOUT_MS", name, ms)
+		}
+	}
+	if c.ValidateAllWorkers < 1 {
+		log.Fatalf("Invalid VALIDATE_ALL_WORKERS %d: must be at least 1", c.ValidateAllWorkers)
+	}
+	if c.AnalyticsRetentionDays < 1 {
+		log.Fatalf("Invalid ANALYTICS_RETENTION_DAYS %d: must be at least 1", c.AnalyticsRetentionDays)
+	}
+	if c.URLInactivityDays < 0 {
+		log.Fatalf("Invalid URL_INACTIVITY_DAYS %d: must not be negative", c.URLInactivityDays)
+	}
+	if c.InboundEmailPort < 1 || c.InboundEmailPort > 65535 {
+		log.Fatalf("Invalid INBOUND_EMAIL_PORT %d: must be between 1 and 65535", c.InboundEmailPort)
+	}
+	if c.InboundEmailEnabled && c.InboundEmailAuthServ == "" {
+		log.Fatalf("INBOUND_EMAIL_ENABLED requires INBOUND_EMAIL_AUTHSERV_ID, to verify senders")
+	}
+	if c.ClickSamplingThreshold < 0 {
+		log.Fatalf("Invalid CLICK_SAMPLING_THRESHOLD %d: must not be negative", c.ClickSamplingThreshold)
+	}
+	if _, err := time.Parse("15:04", c.AnalyticsRetentionAt); err != nil {
+		log.Fatalf("Invalid ANALYTICS_RETENTION_AT %q: must be HH:MM", c.AnalyticsRetentionAt)
+	}
+	if c.FeedSyncCron != "" {
+		if _, err := cron.ParseStandard(c.FeedSyncCron); err != nil {
+			log.Fatalf("Invalid FEED_SYNC_CRON %q: %v", c.FeedSyncCron, err)
+		}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		log.Fatalf("Invalid LOG_LEVEL %q: must be debug, info, warn or error", c.LogLevel)
+	}
+	slog.SetLogLoggerLevel(level)
+
+	switch c.TrustProxy {
+	case trustNever, trustAlways, trustPrivateOnly:
+	default:
+		log.Fatalf("Invalid TRUST_PROXY %q: must be %s, %s or %s", c.TrustProxy, trustNever, trustAlways, trustPrivateOnly)
+	}
+
+	return c
+}
+
+func getEnvInt(key string, fallback int) int {
+	v := getEnv(key, "")
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", key, v, err)
+	}
+	return n
+}
+
+func getEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// configSchemaHandler serves config_docs.yaml, the reference of every
+// configuration option with its environment variable and default.
+func configSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.Write(configDocs)
+}
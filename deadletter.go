@@ -0,0 +1,249 @@
+package urlshortener
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"urlshortener/pkg/store"
+)
+
+const (
+	deadLetterRetryInterval = 5 * time.Minute
+	deadLetterRetryTimeout  = 10 * time.Second
+
+	// deadLetterAlertSize is the queue length above which every change to
+	// the queue is logged as an alert.
+	deadLetterAlertSize = 100
+)
+
+var deadLetterEntries = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "dead_letter_entries",
+	Help: "Short URLs in DEAD_LETTER_FILE waiting to be saved to MongoDB.",
+})
+
+// deadLetter is a line of DEAD_LETTER_FILE. Mapping is MongoDB extended
+// JSON, so fields hidden from the API, such as manage_token, survive.
+type deadLetter struct {
+	FailedAt time.Time       `json:"failed_at"`
+	Error    string          `json:"error"`
+	Mapping  json.RawMessage `json:"mapping"`
+}
+
+// deadLetterMu serialises access to DEAD_LETTER_FILE within the instance.
+// Instances sharing a file would overwrite each other's retries, so each
+// needs its own.
+var deadLetterMu sync.Mutex
+
+// deadLetterCodes holds the queued codes, which exist only in memory until
+// they are saved.
+var deadLetterCodes sync.Map // code -> struct{}
+
+func awaitingSave(code string) bool {
+	_, ok := deadLetterCodes.Load(code)
+	return ok
+}
+
+// deferSave queues m, which saveToMongoDB failed to save with saveErr, to
+// be saved later. The short URL is created all the same and redirects from
+// the in-memory map meanwhile. It returns saveErr if m couldn't be queued.
+func deferSave(m URLMapping, saveErr error) error {
+	if cfg.DeadLetterFile == "" {
+		return saveErr
+	}
+	if err := queueDeadLetter(m, saveErr); err != nil {
+		log.Printf("Failed to queue %s in %s: %v", m.Code, cfg.DeadLetterFile, err)
+		return saveErr
+	}
+	return nil
+}
+
+// queueDeadLetter appends m to DEAD_LETTER_FILE after saveErr. It fails,
+// and m is lost, only if the file can't be written.
+func queueDeadLetter(m URLMapping, saveErr error) error {
+	doc, err := bson.MarshalExtJSON(m, true, false)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(deadLetter{FailedAt: time.Now(), Error: saveErr.Error(), Mapping: doc})
+	if err != nil {
+		return err
+	}
+
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	f, err := os.OpenFile(cfg.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	deadLetterCodes.Store(m.Code, struct{}{})
+	log.Printf("Queued %s in %s after failing to save it: %v", m.Code, cfg.DeadLetterFile, saveErr)
+	if lines, err := readDeadLetters(); err == nil {
+		setDeadLetterSize(len(lines))
+	}
+	return nil
+}
+
+// readDeadLetters returns the lines of DEAD_LETTER_FILE; a missing file is
+// an empty queue. The caller holds deadLetterMu.
+func readDeadLetters() ([][]byte, error) {
+	data, err := os.ReadFile(cfg.DeadLetterFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lines [][]byte
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, len(data)+1)
+	for s.Scan() {
+		if len(bytes.TrimSpace(s.Bytes())) > 0 {
+			lines = append(lines, bytes.Clone(s.Bytes()))
+		}
+	}
+	return lines, s.Err()
+}
+
+func setDeadLetterSize(n int) {
+	deadLetterEntries.Set(float64(n))
+	if n > deadLetterAlertSize {
+		log.Printf("ALERT: %d short URLs in %s are waiting to be saved to MongoDB", n, cfg.DeadLetterFile)
+	}
+}
+
+func decodeDeadLetter(line []byte) (URLMapping, error) {
+	var dl deadLetter
+	var m URLMapping
+	if err := json.Unmarshal(line, &dl); err != nil {
+		return m, err
+	}
+	err := bson.UnmarshalExtJSON(dl.Mapping, true, &m)
+	return m, err
+}
+
+// startDeadLetters puts the queued short URLs back in the in-memory map,
+// so they keep redirecting across restarts, and retries saving them every
+// deadLetterRetryInterval.
+func startDeadLetters(ctx context.Context) {
+	if cfg.DeadLetterFile == "" {
+		return
+	}
+	deadLetterMu.Lock()
+	lines, err := readDeadLetters()
+	deadLetterMu.Unlock()
+	if err != nil {
+		log.Printf("Failed to read %s: %v", cfg.DeadLetterFile, err)
+	}
+	for _, line := range lines {
+		if m, err := decodeDeadLetter(line); err == nil {
+			deadLetterCodes.Store(m.Code, struct{}{})
+			shortURLs.Set(m.Code, m)
+		}
+	}
+	setDeadLetterSize(len(lines))
+
+	go func() {
+		ticker := time.NewTicker(deadLetterRetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := retryDeadLetters(ctx); err != nil {
+					log.Printf("Dead letter retry failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// retryDeadLetters saves the queued short URLs and removes those that were
+// saved, or turned out to exist already, from DEAD_LETTER_FILE. Entries
+// queued while it runs are left for the next pass.
+func retryDeadLetters(ctx context.Context) error {
+	deadLetterMu.Lock()
+	lines, err := readDeadLetters()
+	deadLetterMu.Unlock()
+	if err != nil || len(lines) == 0 {
+		return err
+	}
+
+	done := make(map[string]bool)
+retry:
+	for _, line := range lines {
+		m, err := decodeDeadLetter(line)
+		if err != nil {
+			log.Printf("Dropping unreadable entry from %s: %v", cfg.DeadLetterFile, err)
+			done[string(line)] = true
+			continue
+		}
+		if n, ok := store.CodeToInt(m.Code); ok {
+			m.CodeInt = &n
+		}
+		insertCtx, cancel := context.WithTimeout(ctx, deadLetterRetryTimeout)
+		_, err = collection.InsertOne(insertCtx, m)
+		cancel()
+		switch {
+		case err == nil:
+			log.Printf("Saved %s from %s", m.Code, cfg.DeadLetterFile)
+		case mongo.IsDuplicateKeyError(err):
+			// Either an attempt reported as failed was applied after all,
+			// or the code was taken meanwhile; the stored mapping wins.
+			log.Printf("Dropping %s from %s: the code exists in MongoDB", m.Code, cfg.DeadLetterFile)
+			shortURLs.Delete(m.Code)
+		default:
+			// MongoDB is likely still unavailable; keep the rest for later.
+			log.Printf("Failed to save %s from %s: %v", m.Code, cfg.DeadLetterFile, err)
+			break retry
+		}
+		deadLetterCodes.Delete(m.Code)
+		done[string(line)] = true
+	}
+	if len(done) == 0 {
+		return nil
+	}
+
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	lines, err = readDeadLetters()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	n := 0
+	for _, line := range lines {
+		if !done[string(line)] {
+			buf.Write(line)
+			buf.WriteByte('\n')
+			n++
+		}
+	}
+	tmp := cfg.DeadLetterFile + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, cfg.DeadLetterFile); err != nil {
+		return err
+	}
+	setDeadLetterSize(n)
+	return nil
+}
@@ -0,0 +1,155 @@
+package urlshortener
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	codegenBenchCodes = 10000
+
+	// codegenBenchSeed makes runs comparable; ?seed= picks another.
+	codegenBenchSeed = 1
+)
+
+type mapStats struct {
+	Entries        int     `json:"entries"`
+	EstimatedBytes int64   `json:"estimated_bytes"`
+	CacheHits      int64   `json:"cache_hits"`
+	CacheMisses    int64   `json:"cache_misses"`
+	HitRatio       float64 `json:"hit_ratio"`
+	GC             gcStats `json:"gc"`
+}
+
+type gcStats struct {
+	NumGC        uint32    `json:"num_gc"`
+	PauseTotalNS uint64    `json:"pause_total_ns"`
+	LastPauseNS  uint64    `json:"last_pause_ns"`
+	LastGC       time.Time `json:"last_gc,omitempty"`
+	HeapAlloc    uint64    `json:"heap_alloc_bytes"`
+	HeapInuse    uint64    `json:"heap_inuse_bytes"`
+	Sys          uint64    `json:"sys_bytes"`
+}
+
+// localOnly restricts a handler to requests whose direct peer is a
+// loopback address. Proxy headers are ignored, so this only holds when
+// no local reverse proxy forwards outside traffic to these paths.
+func localOnly(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := net.ParseIP(remoteIP(r)); ip == nil || !ip.IsLoopback() {
+			http.NotFound(w, r)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// mapStatsHandler reports the size of the in-memory URL map, its hit
+// rate since startup and garbage collector statistics, to help size the
+// cache.
+func mapStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var stats mapStats
+	stats.Entries, stats.EstimatedBytes = shortURLs.Size()
+	stats.CacheHits = shortURLs.hits.Load()
+	stats.CacheMisses = shortURLs.misses.Load()
+	if total := stats.CacheHits + stats.CacheMisses; total > 0 {
+		stats.HitRatio = float64(stats.CacheHits) / float64(total)
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	stats.GC = gcStats{
+		NumGC:        ms.NumGC,
+		PauseTotalNS: ms.PauseTotalNs,
+		HeapAlloc:    ms.HeapAlloc,
+		HeapInuse:    ms.HeapInuse,
+		Sys:          ms.Sys,
+	}
+	if ms.NumGC > 0 {
+		stats.GC.LastPauseNS = ms.PauseNs[(ms.NumGC+255)%256]
+		stats.GC.LastGC = time.Unix(0, int64(ms.LastGC))
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+type codegenBench struct {
+	Codes         int     `json:"codes"`
+	Seed          int64   `json:"seed"`
+	CodeStyle     string  `json:"code_style"`
+	CodeLength    int     `json:"code_length,omitempty"`
+	Collisions    int     `json:"collisions"`
+	CollisionRate float64 `json:"collision_rate"`
+
+	// Generation is the rand.Intn draws, redrawn for profanity; MongoDB is
+	// the uniqueness check.
+	AvgGenerationNS   int64 `json:"avg_generation_ns"`
+	AvgMongoNS        int64 `json:"avg_mongo_ns"`
+	TotalGenerationNS int64 `json:"total_generation_ns"`
+	TotalMongoNS      int64 `json:"total_mongo_ns"`
+
+	// Percentiles of generating and checking one code.
+	P95NS int64 `json:"p95_ns"`
+	P99NS int64 `json:"p99_ns"`
+}
+
+// codegenBenchHandler generates codegenBenchCodes codes the way new short
+// URLs get them, from a fixed seed, and checks each against MongoDB as
+// createShortURL would, to show where generation time goes and how often
+// codes collide at the current length. A code collides if it is stored
+// already or was drawn earlier in the run. Nothing is saved.
+func codegenBenchHandler(w http.ResponseWriter, r *http.Request) {
+	seed := int64(codegenBenchSeed)
+	if s := r.URL.Query().Get("seed"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid seed", http.StatusBadRequest)
+			return
+		}
+		seed = n
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	report := codegenBench{Codes: codegenBenchCodes, Seed: seed, CodeStyle: cfg.CodeStyle}
+	if cfg.CodeStyle != codeStyleNATO {
+		report.CodeLength = currentCodeLength()
+	}
+	drawn := make(map[string]bool, codegenBenchCodes)
+	latencies := make([]time.Duration, 0, codegenBenchCodes)
+	var genTime, mongoTime time.Duration
+	for i := 0; i < codegenBenchCodes; i++ {
+		start := time.Now()
+		code := drawShortCode(rng.Intn)
+		generated := time.Now()
+		n, err := collection.CountDocuments(r.Context(), codeFilter(code))
+		if err != nil {
+			log.Printf("Codegen benchmark failed: %v", err)
+			http.Error(w, "Failed to check codes", http.StatusInternalServerError)
+			return
+		}
+		checked := time.Now()
+
+		genTime += generated.Sub(start)
+		mongoTime += checked.Sub(generated)
+		latencies = append(latencies, checked.Sub(start))
+		if n > 0 || drawn[code] {
+			report.Collisions++
+		}
+		drawn[code] = true
+	}
+
+	report.CollisionRate = float64(report.Collisions) / codegenBenchCodes
+	report.TotalGenerationNS = genTime.Nanoseconds()
+	report.TotalMongoNS = mongoTime.Nanoseconds()
+	report.AvgGenerationNS = report.TotalGenerationNS / codegenBenchCodes
+	report.AvgMongoNS = report.TotalMongoNS / codegenBenchCodes
+	slices.Sort(latencies)
+	report.P95NS = latencies[len(latencies)*95/100].Nanoseconds()
+	report.P99NS = latencies[len(latencies)*99/100].Nanoseconds()
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,55 @@
+package urlshortener
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// recentClicks holds when each "code|ip|link" key last counted a click.
+var recentClicks sync.Map
+
+// duplicateClick reports whether ev repeats a click already counted from
+// the same IP for the same code (and link-in-bio link) within
+// CLICK_DEDUP_WINDOW_SECONDS. The window runs from the counted click, so
+// steady refreshing still counts once per window.
+func duplicateClick(ev ClickEvent) bool {
+	window := time.Duration(cfg.ClickDedupWindowSeconds) * time.Second
+	if window <= 0 {
+		return false
+	}
+	key := ev.Code + "|" + ev.IP
+	if ev.LinkIndex != nil {
+		key += "|" + strconv.Itoa(*ev.LinkIndex)
+	}
+
+	if v, ok := recentClicks.Load(key); ok && ev.Timestamp.Sub(v.(time.Time)) < window {
+		return true
+	}
+	recentClicks.Store(key, ev.Timestamp)
+	return false
+}
+
+// sweepRecentClicks forgets clicks older than the dedup window.
+func sweepRecentClicks(ctx context.Context) {
+	window := time.Duration(cfg.ClickDedupWindowSeconds) * time.Second
+	if window <= 0 {
+		return
+	}
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			recentClicks.Range(func(k, v interface{}) bool {
+				if now.Sub(v.(time.Time)) >= window {
+					recentClicks.Delete(k)
+				}
+				return true
+			})
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	platformIOS     = "ios"
+	platformAndroid = "android"
+)
+
+var errInvalidDeepLink = errors.New("app_deep_link must be an http or https URL, or an app URL with a scheme in DEEP_LINK_SCHEMES, e.g. myapp://product/123")
+
+// Navigating to a custom scheme does nothing visible when no app handles
+// it, so the page falls back after two seconds unless it was hidden in
+// the meantime, which means the app opened.
+var deepLinkTpl = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Opening the app…</title>
+</head>
+<body>
+    <p>Opening the app&hellip; If nothing happens, <a href="{{.Fallback}}">continue in the browser</a>.</p>
+    <script>
+        (function () {
+            var fallback = setTimeout(function () {
+                window.location.replace({{.Fallback}});
+            }, 2000);
+            document.addEventListener("visibilitychange", function () {
+                if (document.hidden) {
+                    clearTimeout(fallback);
+                }
+            });
+            window.location.href = {{.DeepLink}};
+        })();
+    </script>
+</body>
+</html>
+`))
+
+// deviceHook picks the app platform of a mapping with an app deep link
+// from the user agent, which redirectHandler then opens instead of
+// redirecting. Bots, previews, interstitials and cloaked links still get
+// the web destination.
+func deviceHook(ctx context.Context, code, url string, r *http.Request) (string, bool, error) {
+	st := redirectStateFrom(ctx)
+	if st == nil {
+		return "", false, nil
+	}
+	m := st.mapping
+	if m.AppDeepLink == "" || st.bot || st.preview || m.DelaySeconds > 0 || m.Cloak {
+		return "", false, nil
+	}
+	if st.platform = mobilePlatform(r.UserAgent()); st.platform != "" {
+		traceRule(ctx, "device", st.platform)
+	}
+	return "", false, nil
+}
+
+// mobilePlatform tells iOS and Android user agents apart from the rest.
+func mobilePlatform(ua string) string {
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+		return platformIOS
+	case strings.Contains(ua, "Android"):
+		return platformAndroid
+	}
+	return ""
+}
+
+// validateDeepLink accepts an http(s) App Link or Universal Link, which is
+// checked like any destination since visitors are sent there, or an app
+// URL with one of the schemes in DEEP_LINK_SCHEMES.
+func validateDeepLink(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return "", errInvalidDeepLink
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "http" || scheme == "https" {
+		dest, err := validateDestinationURL(raw)
+		if err != nil {
+			return "", fmt.Errorf("app_deep_link: %w", err)
+		}
+		return dest, nil
+	}
+	if !slices.Contains(cfg.DeepLinkSchemes, scheme) {
+		return "", errInvalidDeepLink
+	}
+	return u.String(), nil
+}
+
+// androidIntentURL rewrites a custom-scheme deep link as an intent: URL,
+// with which Chrome opens the fallback itself when no app matches.
+// http(s) App Links are left alone; Android opens them in a verified app.
+func androidIntentURL(deepLink, fallback string) string {
+	u, err := url.Parse(deepLink)
+	if err != nil || u.Scheme == "http" || u.Scheme == "https" {
+		return deepLink
+	}
+	rest := strings.TrimPrefix(deepLink, u.Scheme+":")
+	rest = strings.TrimPrefix(rest, "//")
+	return "intent://" + rest + "#Intent;scheme=" + u.Scheme + ";S.browser_fallback_url=" + url.QueryEscape(fallback) + ";end"
+}
+
+// renderDeepLink opens m's app on iOS and Android, falling back to
+// m.FallbackURL, or the destination, when it isn't installed. The deep
+// link is also announced in a Link header for clients that look for
+// alternates.
+func renderDeepLink(w http.ResponseWriter, m URLMapping, platform string) {
+	fallback := m.FallbackURL
+	if fallback == "" {
+		fallback = m.URL
+	}
+	deepLink := m.AppDeepLink
+	if platform == platformAndroid {
+		deepLink = androidIntentURL(deepLink, fallback)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Add("Link", "<"+m.AppDeepLink+`>; rel="alternate"`)
+	err := deepLinkTpl.Execute(w, struct {
+		DeepLink string
+		Fallback string
+	}{deepLink, fallback})
+	if err != nil {
+		log.Printf("Error rendering deep link page for %s: %v", m.Code, err)
+	}
+}
@@ -0,0 +1,49 @@
+package urlshortener
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateDeepLink(t *testing.T) {
+	savedSchemes := cfg.DeepLinkSchemes
+	cfg.DeepLinkSchemes = []string{"myapp"}
+	updateBlocklist(func(domains map[string]struct{}) { domains["blocked.example"] = struct{}{} })
+	t.Cleanup(func() {
+		cfg.DeepLinkSchemes = savedSchemes
+		updateBlocklist(func(domains map[string]struct{}) { delete(domains, "blocked.example") })
+	})
+
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{"myapp://product/123", "myapp://product/123", nil},
+		{"MyApp://product/123", "myapp://product/123", nil},
+		{"https://example.com/app/product/123", "https://example.com/app/product/123", nil},
+		{"https://bücher.example/app", "https://xn--bcher-kva.example/app", nil},
+		{"https://blocked.example/app", "", errBlockedDomain},
+		{"https://app.blocked.example/", "", errBlockedDomain},
+		{"http://", "", errInvalidURL},
+		{"otherapp://product/123", "", errInvalidDeepLink},
+		{"intent://product#Intent;scheme=myapp;end", "", errInvalidDeepLink},
+		{"blob:https://example.com/uuid", "", errInvalidDeepLink},
+		{"javascript:alert(1)", "", errInvalidDeepLink},
+		{"data:text/html,hi", "", errInvalidDeepLink},
+		{"file:///etc/passwd", "", errInvalidDeepLink},
+		{"ftp://example.com/", "", errInvalidDeepLink},
+		{"product/123", "", errInvalidDeepLink},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := validateDeepLink(tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("validateDeepLink(%q) error = %v, want %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("validateDeepLink(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
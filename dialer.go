@@ -0,0 +1,129 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var errPrivateAddress = errors.New("refusing to connect to a non-public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, private in practice
+// though netip doesn't count it as such.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicDialer connects only to public addresses. The check runs on the
+// address actually dialled, after DNS resolution, so neither a hostname
+// nor a redirect can point a fetch of a user-supplied URL at loopback, the
+// private network or a cloud metadata endpoint.
+var publicDialer = &net.Dialer{
+	Timeout: 10 * time.Second,
+	Control: func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			return err
+		}
+		if !isPublicAddr(ip) {
+			return fmt.Errorf("%w: %s", errPrivateAddress, ip)
+		}
+		return nil
+	},
+}
+
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// publicTransport is an http.Transport that dials through publicDialer. It
+// ignores HTTP_PROXY and friends, whose proxy would be the address dialled.
+func publicTransport() *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return publicDialer.DialContext(ctx, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// startPublicProxy serves an HTTP proxy on a loopback port that forwards
+// through publicDialer, for clients such as headless Chrome that can't be
+// handed a dialer. It returns the proxy's address.
+func startPublicProxy() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	transport := publicTransport()
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			tunnel(w, r)
+			return
+		}
+		if r.URL.Host == "" {
+			http.Error(w, "Not a proxy request", http.StatusBadRequest)
+			return
+		}
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		out.Header.Del("Proxy-Connection")
+		resp, err := transport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for k, vs := range resp.Header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	return ln.Addr().String(), nil
+}
+
+// tunnel serves a CONNECT request, relaying bytes to r.Host once
+// publicDialer has connected to it.
+func tunnel(w http.ResponseWriter, r *http.Request) {
+	dest, err := publicDialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		dest.Close()
+		http.Error(w, "Tunnelling not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		dest.Close()
+		return
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		conn.Close()
+		dest.Close()
+		return
+	}
+	go func() {
+		io.Copy(dest, buf)
+		dest.Close()
+	}()
+	io.Copy(conn, dest)
+	conn.Close()
+}
@@ -0,0 +1,40 @@
+package urlshortener
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// The Swagger UI bundle and stylesheet in assets/ are swagger-ui-dist
+// 5.18.2, copied unchanged so the docs page works without a CDN.
+var docsTpl = template.Must(template.New("").Funcs(template.FuncMap{"asset": assetURL}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>API documentation</title>
+    <link rel="stylesheet" href="{{asset "swagger-ui.css"}}">
+    <style>body { margin: 0; }</style>
+</head>
+<body>
+    <div id="swagger-ui" data-spec="/api/v1/openapi.json" data-server="{{.Server}}"></div>
+    <script src="{{asset "swagger-ui-bundle.js"}}"></script>
+    <script src="{{asset "docs.js"}}"></script>
+</body>
+</html>
+`))
+
+// docsHandler serves Swagger UI for the embedded OpenAPI spec. "Try it
+// out" requests go to BASE_URL, or to this server when it isn't set; the
+// Authorize button takes an API key or the admin credentials.
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := docsTpl.Execute(w, struct {
+		Server string
+	}{cfg.BaseURL})
+	if err != nil {
+		log.Printf("Error rendering API docs: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package urlshortener
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const domainReloadInterval = 30 * time.Second
+
+var customDomains *mongo.Collection
+
+// CustomDomain serves a namespace's short URLs at the root of its own
+// hostname, e.g. go.example.com/launch for the "example" namespace's launch.
+type CustomDomain struct {
+	Domain      string `bson:"domain" json:"domain"`
+	Namespace   string `bson:"namespace" json:"namespace"`
+	TLSCertPath string `bson:"tls_cert_path,omitempty" json:"tls_cert_path,omitempty"`
+	TLSKeyPath  string `bson:"tls_key_path,omitempty" json:"tls_key_path,omitempty"`
+}
+
+var domainTable struct {
+	sync.RWMutex
+	namespaces map[string]string
+	certs      map[string]*tls.Certificate
+}
+
+// loadCustomDomains replaces the domain table with the contents of the
+// custom_domains collection, reading certificates from disk so renewed
+// files are picked up too.
+func loadCustomDomains(ctx context.Context) error {
+	cur, err := customDomains.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	var domains []CustomDomain
+	if err := cur.All(ctx, &domains); err != nil {
+		return err
+	}
+
+	namespaces := make(map[string]string, len(domains))
+	certs := make(map[string]*tls.Certificate)
+	for _, d := range domains {
+		host := strings.ToLower(d.Domain)
+		namespaces[host] = d.Namespace
+		if d.TLSCertPath == "" || d.TLSKeyPath == "" {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(d.TLSCertPath, d.TLSKeyPath)
+		if err != nil {
+			log.Printf("Failed to load certificate for %s: %v", host, err)
+			continue
+		}
+		certs[host] = &cert
+	}
+
+	domainTable.Lock()
+	domainTable.namespaces = namespaces
+	domainTable.certs = certs
+	domainTable.Unlock()
+	return nil
+}
+
+// watchCustomDomains reloads the domain table periodically so domains can be
+// added or re-pointed without a restart.
+func watchCustomDomains(ctx context.Context) {
+	ticker := time.NewTicker(domainReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := loadCustomDomains(ctx); err != nil {
+				log.Printf("Failed to reload custom domains: %v", err)
+			}
+		}
+	}
+}
+
+// namespaceForHost returns the namespace mapped to the request's Host, if
+// any.
+func namespaceForHost(hostport string) (string, bool) {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	domainTable.RLock()
+	defer domainTable.RUnlock()
+	ns, ok := domainTable.namespaces[strings.ToLower(host)]
+	return ns, ok
+}
+
+// certificateForHello picks a custom domain's certificate by SNI. Returning
+// nil falls back to the server's default TLS_CERT_FILE certificate.
+func certificateForHello(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	domainTable.RLock()
+	defer domainTable.RUnlock()
+	return domainTable.certs[strings.ToLower(hello.ServerName)], nil
+}
@@ -0,0 +1,157 @@
+package urlshortener
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const dryRunBanner = `
+************************************************************
+*                                                          *
+*       [DRY-RUN MODE: no data will be persisted]          *
+*                                                          *
+************************************************************`
+
+// startDryRun stands in for connecting to MongoDB under --dry-run. The
+// collections point at a client that is never connected, so code that
+// still reaches for MongoDB fails at once with ErrClientDisconnected
+// instead of waiting for a server; short URLs live in shortURLs alone.
+func startDryRun() {
+	log.Print(dryRunBanner)
+	c, err := mongo.NewClient(options.Client())
+	if err != nil {
+		log.Fatalf("Failed to set up the dry-run client: %v", err)
+	}
+	useClient(c)
+	readyState.Store(stateReady)
+}
+
+// dryRunMappings is the home page listing under --dry-run: the live,
+// unsigned mappings in shortURLs, newest first, as a cursor for
+// streamMappings.
+func dryRunMappings() (*mongo.Cursor, error) {
+	var live []URLMapping
+	for _, m := range shortURLs.All() {
+		if !m.Disabled && !m.Expired() && !m.RequiresSignature {
+			live = append(live, m)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].CreatedAt.After(live[j].CreatedAt) })
+	docs := make([]interface{}, len(live))
+	for i, m := range live {
+		docs[i] = m
+	}
+	return mongo.NewCursorFromDocuments(docs, nil, nil)
+}
+
+// dryRunExisting is existingShortURL under --dry-run, looking through
+// shortURLs for a mapping that the same filter would match.
+func dryRunExisting(dest string) (URLMapping, bool) {
+	hash := contentHash(dest)
+	for _, m := range shortURLs.All() {
+		if m.ContentHash == hash && !m.Disabled && !m.Expired() && m.OwnerID == "" &&
+			len(m.CustomHeaders) == 0 && !m.RequiresSignature && m.Prefix == "" &&
+			m.AlwaysPreview == nil && m.AppDeepLink == "" {
+			return m, true
+		}
+	}
+	return URLMapping{}, false
+}
+
+// dryRunFind is the newest-first page of shortURLs matching filter, and
+// how many match, for writeMappingPage under --dry-run. It understands the
+// filters the listing endpoints build: liveFilter and equality on
+// top-level fields, which for arrays such as tags means containment.
+func dryRunFind(filter bson.M, skip, limit int) ([]URLMapping, int64, error) {
+	var matched []URLMapping
+	for _, m := range shortURLs.All() {
+		ok, err := dryRunMatches(m, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			matched = append(matched, m)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+	total := int64(len(matched))
+	matched = matched[min(skip, len(matched)):]
+	return matched[:min(limit, len(matched))], total, nil
+}
+
+func dryRunMatches(m URLMapping, filter bson.M) (bool, error) {
+	doc, err := mappingDoc(m)
+	if err != nil {
+		return false, err
+	}
+	for k, want := range filter {
+		if _, ok := want.(bson.M); ok {
+			return false, fmt.Errorf("filtering on %s is not supported under --dry-run", k)
+		}
+		switch k {
+		case "disabled", "$or":
+			// liveFilter
+			if m.Disabled || m.Expired() {
+				return false, nil
+			}
+			continue
+		}
+		switch got := doc[k].(type) {
+		case bson.A:
+			if !slices.Contains(got, want) {
+				return false, nil
+			}
+		default:
+			if got != want {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// dryRunUpdate is updateShortURL under --dry-run: it applies set and unset,
+// which may only name top-level fields, to the mapping in shortURLs.
+func dryRunUpdate(code string, set, unset bson.M) (URLMapping, error) {
+	m, ok := shortURLs.Get(code)
+	if !ok {
+		return URLMapping{}, mongo.ErrNoDocuments
+	}
+	doc, err := mappingDoc(m)
+	if err != nil {
+		return m, err
+	}
+	for k, v := range set {
+		doc[k] = v
+	}
+	for k := range unset {
+		delete(doc, k)
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return m, err
+	}
+	var updated URLMapping
+	if err := bson.Unmarshal(raw, &updated); err != nil {
+		return m, err
+	}
+	shortURLs.Set(code, updated)
+	return updated, nil
+}
+
+// mappingDoc is m as the document MongoDB would store.
+func mappingDoc(m URLMapping) (bson.M, error) {
+	raw, err := bson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(raw, &doc)
+	return doc, err
+}
@@ -0,0 +1,200 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const duplicateReviewInterval = 7 * 24 * time.Hour
+
+// urlDuplicates records pairs of codes found to share a destination, by
+// content hash. It is an audit only: both codes keep redirecting as before.
+var urlDuplicates *mongo.Collection
+
+type urlDuplicate struct {
+	Code        string    `bson:"code" json:"code"`
+	DuplicateOf string    `bson:"duplicate_of" json:"duplicate_of"`
+	URL         string    `bson:"url" json:"url"`
+	FirstSeen   time.Time `bson:"first_seen" json:"first_seen"`
+	LastSeen    time.Time `bson:"last_seen" json:"last_seen"`
+
+	// MergedClicks counts the clicks moved from the newer code of the pair
+	// to the older one by the weekly review.
+	MergedClicks int64 `bson:"merged_clicks,omitempty" json:"merged_clicks,omitempty"`
+}
+
+// recordedDuplicates remembers the codes already written this run, since a
+// code missing from the cache misses on every redirect.
+var recordedDuplicates sync.Map
+
+// noteDuplicate records m in url_duplicates if the cache already holds a
+// different code with the same destination. It is called for mappings
+// that had to be read from MongoDB, which is where codes created on other
+// instances, or before the cache was warm, turn up.
+func noteDuplicate(m URLMapping) {
+	if m.URL == "" {
+		return
+	}
+	other, ok := shortURLs.CodeForURL(m.URL)
+	if !ok || other == m.Code {
+		return
+	}
+	if _, seen := recordedDuplicates.LoadOrStore(m.Code, struct{}{}); seen {
+		return
+	}
+
+	_, err := urlDuplicates.UpdateOne(context.Background(),
+		bson.M{"code": m.Code},
+		duplicateUpsert(m, other, time.Now()),
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		recordedDuplicates.Delete(m.Code)
+		log.Printf("Failed to record %s as a duplicate of %s: %v", m.Code, other, err)
+	}
+}
+
+// duplicateUpsert is the url_duplicates update recording m as a duplicate
+// of other at now. Upserting it again moves last_seen and keeps first_seen.
+func duplicateUpsert(m URLMapping, other string, now time.Time) bson.M {
+	return bson.M{
+		"$set":         bson.M{"duplicate_of": other, "url": m.URL, "last_seen": now},
+		"$setOnInsert": bson.M{"first_seen": now},
+	}
+}
+
+// stillDuplicates reports whether a recorded pair still shares a
+// destination.
+func stillDuplicates(a, b URLMapping) bool {
+	return contentHash(a.URL) == contentHash(b.URL)
+}
+
+// mergeOrder returns the pair oldest first, which the newer one's clicks
+// are merged onto. A tie keeps a as the older.
+func mergeOrder(a, b URLMapping) (older, newer URLMapping) {
+	if b.CreatedAt.Before(a.CreatedAt) {
+		return b, a
+	}
+	return a, b
+}
+
+// startDuplicateReview reviews the recorded duplicates weekly.
+func startDuplicateReview(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(duplicateReviewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := reviewDuplicates(ctx); err != nil {
+					log.Printf("Duplicate review failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// reviewDuplicates drops records whose codes are gone or no longer share a
+// destination and logs how many remain. With MERGE_DUPLICATE_CLICKS it also
+// moves each newer code's clicks onto the older one, so the surviving pair
+// reports its combined traffic in one place.
+func reviewDuplicates(ctx context.Context) error {
+	cur, err := urlDuplicates.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	var dups []urlDuplicate
+	if err := cur.All(ctx, &dups); err != nil {
+		return err
+	}
+
+	var kept, dropped int
+	var merged int64
+	for _, d := range dups {
+		a, err := findInMongoDB(d.Code)
+		var b URLMapping
+		if err == nil {
+			b, err = findInMongoDB(d.DuplicateOf)
+		}
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if err != nil || !stillDuplicates(a, b) {
+			if _, err := urlDuplicates.DeleteOne(ctx, bson.M{"code": d.Code}); err != nil {
+				return err
+			}
+			recordedDuplicates.Delete(d.Code)
+			dropped++
+			continue
+		}
+		kept++
+		if !cfg.MergeDuplicateClicks {
+			continue
+		}
+
+		older, newer := mergeOrder(a, b)
+		n, err := mergeClicks(ctx, newer.Code, older.Code)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			merged += n
+			_, err = urlDuplicates.UpdateOne(ctx, bson.M{"code": d.Code}, bson.M{"$inc": bson.M{"merged_clicks": n}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("Duplicate review: %d pairs share a destination, %d stale records dropped, %d clicks merged", kept, dropped, merged)
+	return nil
+}
+
+// mergeClicks moves the click count of from onto to. Caches elsewhere
+// follow through the change stream.
+func mergeClicks(ctx context.Context, from, to string) (int64, error) {
+	var before URLMapping
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"code": from, "clicks": bson.M{"$gt": 0}},
+		bson.M{"$set": bson.M{"clicks": 0}},
+	).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := collection.UpdateOne(ctx, bson.M{"code": to}, bson.M{"$inc": bson.M{"clicks": before.Clicks}}); err != nil {
+		return 0, err
+	}
+	shortURLs.Update(from, func(m *URLMapping) { m.Clicks = 0 })
+	shortURLs.Update(to, func(m *URLMapping) { m.Clicks += before.Clicks })
+	return before.Clicks, nil
+}
+
+// duplicatesHandler lists the recorded duplicates, most recently seen
+// first.
+func duplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	cur, err := urlDuplicates.Find(r.Context(), bson.M{}, options.Find().SetSort(bson.D{{Key: "last_seen", Value: -1}}))
+	if err != nil {
+		log.Printf("Failed to list duplicates: %v", err)
+		http.Error(w, "Failed to list duplicates", http.StatusInternalServerError)
+		return
+	}
+	dups := []urlDuplicate{}
+	if err := cur.All(r.Context(), &dups); err != nil {
+		log.Printf("Failed to list duplicates: %v", err)
+		http.Error(w, "Failed to list duplicates", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, dups)
+}
@@ -0,0 +1,77 @@
+package urlshortener
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDuplicateUpsert(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := duplicateUpsert(URLMapping{Code: "newer", URL: "https://example.com/"}, "older", now)
+	want := bson.M{
+		"$set":         bson.M{"duplicate_of": "older", "url": "https://example.com/", "last_seen": now},
+		"$setOnInsert": bson.M{"first_seen": now},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("duplicateUpsert = %v, want %v", got, want)
+	}
+}
+
+func TestStillDuplicates(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"https://example.com/page", "https://example.com/page", true},
+		{"https://example.com/page?utm_source=a", "https://EXAMPLE.com/page?utm_source=b", true},
+		{"https://example.com/page?b=2&a=1", "https://example.com/page?a=1&b=2", true},
+		{"https://example.com/page", "https://example.com/other", false},
+		{"https://example.com/page?id=1", "https://example.com/page?id=2", false},
+	}
+	for _, tt := range tests {
+		if got := stillDuplicates(URLMapping{URL: tt.a}, URLMapping{URL: tt.b}); got != tt.want {
+			t.Errorf("stillDuplicates(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMergeOrder(t *testing.T) {
+	early := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	tests := []struct {
+		name      string
+		a, b      URLMapping
+		wantOlder string
+	}{
+		{"a older", URLMapping{Code: "a", CreatedAt: early}, URLMapping{Code: "b", CreatedAt: late}, "a"},
+		{"b older", URLMapping{Code: "a", CreatedAt: late}, URLMapping{Code: "b", CreatedAt: early}, "b"},
+		{"tie", URLMapping{Code: "a", CreatedAt: early}, URLMapping{Code: "b", CreatedAt: early}, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			older, newer := mergeOrder(tt.a, tt.b)
+			if older.Code != tt.wantOlder || newer.Code == older.Code {
+				t.Errorf("mergeOrder = %s, %s; want %s older", older.Code, newer.Code, tt.wantOlder)
+			}
+		})
+	}
+}
+
+// TestNoteDuplicateSkips covers the mappings noteDuplicate must not write
+// for; urlDuplicates is nil in dry-run, so a write would panic.
+func TestNoteDuplicateSkips(t *testing.T) {
+	cached := mustCreate(t, URLMapping{URL: "https://example.com/note-duplicate"})
+	for _, m := range []URLMapping{
+		{Code: "nourl"},
+		{Code: "uncached", URL: "https://example.com/nobody-has-this"},
+		cached,
+	} {
+		noteDuplicate(m)
+		if _, ok := recordedDuplicates.Load(m.Code); ok {
+			t.Errorf("noteDuplicate(%+v) recorded it", m)
+		}
+	}
+}
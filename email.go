@@ -0,0 +1,228 @@
+package urlshortener
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	htmltemplate "html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var confirmationTpl = template.Must(template.New("").Parse(`From: {{.From}}
+To: {{.To}}
+Subject: Your short URL {{.ShortURL}}
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+
+Your short URL is ready.
+
+Short URL:   {{.ShortURL}}
+Code:        {{.Code}}
+Destination: {{.Destination}}
+{{- with .ExpiresAt}}
+Expires:     {{.Format "2 January 2006 15:04 MST"}}
+{{- end}}
+
+View analytics: {{.AnalyticsURL}}
+
+If you didn't create this link, or no longer want it, disable it here:
+{{.DisableURL}}
+`))
+
+type confirmationEmail struct {
+	From, To     string
+	Code         string
+	ShortURL     string
+	Destination  string
+	ExpiresAt    *time.Time
+	AnalyticsURL string
+	DisableURL   string
+}
+
+// newManageToken returns a random token authorising the disable link in a
+// confirmation email.
+func newManageToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sendConfirmation emails m's details to addr. It is a no-op, with a log
+// line, when SMTP_HOST is not configured. Run it in a goroutine.
+func sendConfirmation(base, addr string, m URLMapping) {
+	if cfg.SMTPHost == "" {
+		log.Printf("Not sending confirmation for %s: SMTP_HOST is not set", m.Code)
+		return
+	}
+
+	analytics := base + "/app/"
+	if m.PublicStats {
+		analytics = base + "/s/" + url.PathEscape(m.Code) + "/stats"
+		if m.RequiresSignature {
+			analytics += "?sig=" + signCode(m.Code)
+		}
+	}
+	dest := displayURL(m.URL)
+	if m.TargetCode != "" {
+		dest = base + "/" + m.TargetCode
+	}
+
+	var msg bytes.Buffer
+	err := confirmationTpl.Execute(&msg, confirmationEmail{
+		From:         cfg.FromEmail,
+		To:           addr,
+		Code:         m.Code,
+		ShortURL:     base + m.Path(),
+		Destination:  dest,
+		ExpiresAt:    m.ExpiresAt,
+		AnalyticsURL: analytics,
+		DisableURL:   base + "/disable/" + m.Code + "?token=" + m.ManageToken,
+	})
+	if err != nil {
+		log.Printf("Error rendering confirmation for %s: %v", m.Code, err)
+		return
+	}
+	if err := sendMail(addr, msg.Bytes()); err != nil {
+		log.Printf("Failed to send confirmation for %s to %s: %v", m.Code, addr, err)
+	}
+}
+
+var brokenLinkTpl = template.Must(template.New("").Parse(`From: {{.From}}
+To: {{.To}}
+Subject: Your short URL {{.ShortURL}} is broken
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+
+The destination of your short URL stopped responding normally.
+
+Short URL:   {{.ShortURL}}
+Destination: {{.Destination}}
+Checked:     {{.CheckedAt.Format "2 January 2006 15:04 MST"}}
+Result:      {{if .StatusCode}}{{.StatusCode}} {{end}}{{.Reason}}
+`))
+
+// sendBrokenLinkAlert emails m's contact address that its destination
+// failed a health check. Run it in a goroutine.
+func sendBrokenLinkAlert(m URLMapping, check HealthCheck, reason string) {
+	if cfg.SMTPHost == "" {
+		return
+	}
+	var msg bytes.Buffer
+	err := brokenLinkTpl.Execute(&msg, struct {
+		From, To    string
+		ShortURL    string
+		Destination string
+		CheckedAt   time.Time
+		StatusCode  int
+		Reason      string
+	}{cfg.FromEmail, m.ContactEmail, m.ShortURL(), displayURL(m.URL), check.CheckedAt, check.StatusCode, reason})
+	if err != nil {
+		log.Printf("Error rendering broken link alert for %s: %v", m.Code, err)
+		return
+	}
+	if err := sendMail(m.ContactEmail, msg.Bytes()); err != nil {
+		log.Printf("Failed to send broken link alert for %s to %s: %v", m.Code, m.ContactEmail, err)
+	}
+}
+
+// sendMail delivers a rendered message, headers included, through
+// SMTP_HOST.
+func sendMail(to string, msg []byte) error {
+	// SMTP wants CRLF line endings.
+	body := bytes.ReplaceAll(msg, []byte("\n"), []byte("\r\n"))
+
+	var auth smtp.Auth
+	if cfg.SMTPUser != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPass, cfg.SMTPHost)
+	}
+	smtpAddr := net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort)
+	return smtp.SendMail(smtpAddr, auth, cfg.FromEmail, []string{to}, body)
+}
+
+var disableTpl = htmltemplate.Must(htmltemplate.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Disable short URL</title>
+</head>
+<body>
+    {{if .Done}}
+    <p>The short URL <code>{{.Path}}</code> has been disabled.</p>
+    {{else}}
+    <p>Disable the short URL <code>{{.Path}}</code>? It will stop redirecting immediately.</p>
+    <form method="post">
+        <input type="hidden" name="token" value="{{.Token}}">
+        <button type="submit">Disable</button>
+    </form>
+    {{end}}
+</body>
+</html>
+`))
+
+// disableHandler backs the link in confirmation emails. GET shows a
+// confirmation form, so link scanners that prefetch URLs don't disable
+// anything; POST with the mapping's manage token disables it.
+func disableHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+	token := r.FormValue("token")
+
+	mapping, err := findInMongoDB(code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			notFound(w, r)
+			return
+		}
+		http.Error(w, "Failed to load URL", http.StatusInternalServerError)
+		return
+	}
+	if mapping.ManageToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(mapping.ManageToken)) != 1 {
+		http.Error(w, "Invalid or expired link", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := disableCode(r.Context(), code); err != nil {
+			log.Printf("Failed to disable %s: %v", code, err)
+			http.Error(w, "Failed to disable URL", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = disableTpl.Execute(w, struct {
+		Path, Token string
+		Done        bool
+	}{mapping.Path(), token, r.Method == http.MethodPost})
+	if err != nil {
+		log.Printf("Error rendering disable page for %s: %v", code, err)
+	}
+}
+
+func disableCode(ctx context.Context, code string) error {
+	now := time.Now()
+	_, err := collection.UpdateOne(ctx, bson.M{"code": code}, bson.M{"$set": bson.M{"disabled": true, "disabled_at": now}})
+	if err != nil {
+		return err
+	}
+	if m, ok := shortURLs.Update(code, func(m *URLMapping) { m.Disabled, m.DisabledAt = true, &now }); ok {
+		publishMutation(replicateUpsert, m)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package urlshortener
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	streamHeartbeat  = 5 * time.Second
+	streamBufferSize = 64
+)
+
+// Event types sent on /admin/stream.
+const (
+	eventHeartbeat   = "heartbeat"
+	eventCreated     = "created"
+	eventClicked     = "clicked"
+	eventRateLimited = "rate_limited"
+	eventBreaker     = "breaker"
+)
+
+type adminEvent struct {
+	Type string
+	Data interface{}
+}
+
+// eventBroker fans events out to every subscribed admin stream. Publish
+// never blocks: a subscriber whose buffer is full misses the event.
+type eventBroker struct {
+	subs sync.Map // chan adminEvent -> struct{}
+}
+
+var adminEvents = &eventBroker{}
+
+func (b *eventBroker) Subscribe() chan adminEvent {
+	ch := make(chan adminEvent, streamBufferSize)
+	b.subs.Store(ch, struct{}{})
+	return ch
+}
+
+// Unsubscribe stops delivery to ch. The channel is left open since a
+// concurrent Publish may still hold it.
+func (b *eventBroker) Unsubscribe(ch chan adminEvent) {
+	b.subs.Delete(ch)
+}
+
+func (b *eventBroker) Publish(typ string, data interface{}) {
+	ev := adminEvent{Type: typ, Data: data}
+	b.subs.Range(func(k, _ interface{}) bool {
+		select {
+		case k.(chan adminEvent) <- ev:
+		default:
+		}
+		return true
+	})
+}
+
+// adminStreamHandler streams live events to the admin dashboard as
+// server-sent events, with a heartbeat so the page can tell the stream is
+// alive. It must not run behind timeoutMiddleware, which buffers.
+func adminStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := adminEvents.Subscribe()
+	defer adminEvents.Unsubscribe(events)
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		var ev adminEvent
+		select {
+		case <-r.Context().Done():
+			return
+		case <-shuttingDown:
+			return
+		case now := <-ticker.C:
+			ev = adminEvent{Type: eventHeartbeat, Data: map[string]time.Time{"time": now}}
+		case ev = <-events:
+		}
+
+		data, err := json.Marshal(ev.Data)
+		if err != nil {
+			log.Printf("Failed to encode %s event: %v", ev.Type, err)
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
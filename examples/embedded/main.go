@@ -0,0 +1,64 @@
+// Command embedded shows package shortener used as a library, without the
+// urlshortener server: it shortens the URLs given as arguments in memory,
+// resolves each code back and prints the click counts.
+//
+//	go run ./examples/embedded https://example.com https://go.dev
+//
+// With MONGO_URI set, links go to the server's "urls" collection and
+// visits to "click_events" instead, so the server redirects the codes and
+// reports their analytics.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"urlshortener/pkg/analytics"
+	"urlshortener/pkg/shortener"
+	"urlshortener/pkg/store"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("usage: embedded <url>...")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var st store.Store = store.NewMemory()
+	var opts shortener.Options
+	if uri := os.Getenv("MONGO_URI"); uri != "" {
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			log.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+		defer client.Disconnect(context.Background())
+		db := client.Database("urlshortener")
+		st = store.NewMongo(db.Collection("urls"))
+		opts.Recorder = analytics.NewMongo(db.Collection("click_events"))
+	}
+	s := shortener.New(st, opts)
+
+	for _, raw := range os.Args[1:] {
+		l, err := s.Create(ctx, raw, "")
+		if err != nil {
+			log.Fatalf("Failed to shorten %s: %v", raw, err)
+		}
+		code := l.Code
+		dest, err := s.Resolve(ctx, code)
+		if err != nil {
+			log.Fatalf("Failed to resolve %s: %v", code, err)
+		}
+		l, err = s.Get(ctx, code)
+		if err != nil {
+			log.Fatalf("Failed to look up %s: %v", code, err)
+		}
+		fmt.Printf("%s -> %s, clicks: %d\n", l.Code, dest, l.Clicks)
+	}
+}
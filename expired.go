@@ -0,0 +1,39 @@
+package urlshortener
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+var expiredTpl = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Link expired</title>
+</head>
+<body>
+    <h1>This link has expired</h1>
+    {{with .URL}}<p>It used to point to <code>{{.}}</code>.</p>{{end}}
+    {{with .ExpiresAt}}<p>It expired on {{.Format "2 January 2006 at 15:04 MST"}}.</p>{{end}}
+    {{with .ContactEmail}}<p><a href="mailto:{{.}}?subject=Expired%20link">Contact the creator</a></p>{{end}}
+</body>
+</html>
+`))
+
+// expiredHandler answers 410 Gone for an expired mapping, preferring a
+// custom 410 status page when one has been set.
+func expiredHandler(w http.ResponseWriter, r *http.Request, m URLMapping) {
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if serveStatusPage(w, r, http.StatusGone) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	if err := expiredTpl.Execute(w, m); err != nil {
+		log.Printf("Error rendering expired page for %s: %v", m.Code, err)
+	}
+}
@@ -0,0 +1,133 @@
+package urlshortener
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	expiryNoticeInterval = time.Hour
+
+	// maxNotifyBeforeDeleteHours caps notify_before_delete_hours at 30 days.
+	maxNotifyBeforeDeleteHours = 30 * 24
+)
+
+var expiryNoticeTpl = template.Must(template.New("").Parse(`From: {{.From}}
+To: {{.To}}
+Subject: Your short URL {{.ShortURL}} expires soon
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+
+Your short URL stops redirecting soon.
+
+Short URL:   {{.ShortURL}}
+Destination: {{.Destination}}
+Expires:     {{.ExpiresAt.Format "2 January 2006 15:04 MST"}}
+`))
+
+var errNotifyBeforeDelete = fmt.Errorf("notify_before_delete_hours must be between 0 and %d", maxNotifyBeforeDeleteHours)
+
+func validateNotifyBeforeDelete(hours int) error {
+	if hours < 0 || hours > maxNotifyBeforeDeleteHours {
+		return errNotifyBeforeDelete
+	}
+	return nil
+}
+
+// startExpiryNotices warns hourly about short URLs that expire within their
+// notify_before_delete_hours.
+func startExpiryNotices(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(expiryNoticeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := sendExpiryNotices(ctx); err != nil {
+					log.Printf("Expiry notices failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// sendExpiryNotices notifies the contact email and the Slack webhook of
+// each URL expiring within its notice period. The window reaches an hour
+// past the period, as the job runs hourly, and includes URLs whose notice
+// is overdue, e.g. because they were created with an expiry sooner than
+// it. pre_delete_notified_at keeps a URL from being notified twice.
+func sendExpiryNotices(ctx context.Context) error {
+	now := time.Now()
+	window := bson.M{"$add": bson.A{now, bson.M{"$multiply": bson.A{
+		bson.M{"$add": bson.A{"$notify_before_delete_hours", 1}},
+		time.Hour.Milliseconds(),
+	}}}}
+	cur, err := collection.Find(ctx, bson.M{
+		"notify_before_delete_hours": bson.M{"$gt": 0},
+		"pre_delete_notified_at":     bson.M{"$exists": false},
+		"disabled":                   bson.M{"$ne": true},
+		"expires_at":                 bson.M{"$gt": now},
+		"$expr":                      bson.M{"$lte": bson.A{"$expires_at", window}},
+	})
+	if err != nil {
+		return err
+	}
+	var due []URLMapping
+	if err := cur.All(ctx, &due); err != nil {
+		return err
+	}
+
+	for _, m := range due {
+		notifyExpiry(m)
+		_, err := collection.UpdateOne(ctx, bson.M{"code": m.Code}, bson.M{"$set": bson.M{"pre_delete_notified_at": time.Now()}})
+		if err != nil {
+			return err
+		}
+	}
+	if len(due) > 0 {
+		log.Printf("Sent expiry notices for %d short URLs", len(due))
+	}
+	return nil
+}
+
+func notifyExpiry(m URLMapping) {
+	base := cfg.BaseURL
+	if m.ContactEmail != "" && cfg.SMTPHost != "" {
+		var msg bytes.Buffer
+		err := expiryNoticeTpl.Execute(&msg, struct {
+			From, To    string
+			ShortURL    string
+			Destination string
+			ExpiresAt   time.Time
+		}{cfg.FromEmail, m.ContactEmail, base + m.Path(), displayURL(m.URL), *m.ExpiresAt})
+		if err != nil {
+			log.Printf("Error rendering expiry notice for %s: %v", m.Code, err)
+		} else if err := sendMail(m.ContactEmail, msg.Bytes()); err != nil {
+			log.Printf("Failed to send expiry notice for %s to %s: %v", m.Code, m.ContactEmail, err)
+		}
+	}
+
+	if cfg.SlackWebhookURL != "" {
+		text := fmt.Sprintf("Short URL %s expires %s", slackEscape.Replace(base+m.Path()), m.ExpiresAt.Format("2 January 2006 15:04 MST"))
+		body, err := json.Marshal(struct {
+			Text string `json:"text"`
+		}{text})
+		if err == nil {
+			_, err = postSlack(body)
+		}
+		if err != nil {
+			log.Printf("Failed to notify Slack of the expiry of %s: %v", m.Code, err)
+			return
+		}
+		recordAudit(m.Code, auditWebhookSent, "", "Slack expiry notice")
+	}
+}
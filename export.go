@@ -0,0 +1,135 @@
+package urlshortener
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	s3ExportInterval = time.Hour
+	s3ExportJobID    = "s3_export"
+)
+
+var jobs *mongo.Collection
+
+type jobState struct {
+	ID             string    `bson:"_id"`
+	LastExportedAt time.Time `bson:"last_exported_at"`
+}
+
+// startS3Export runs the hourly click event export when S3_BUCKET is set.
+// Credentials come from the standard AWS environment variables.
+func startS3Export(ctx context.Context) {
+	if cfg.S3Bucket == "" {
+		return
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.S3Region))
+	if err != nil {
+		log.Printf("S3 export disabled: %v", err)
+		return
+	}
+	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	go func() {
+		ticker := time.NewTicker(s3ExportInterval)
+		defer ticker.Stop()
+		for {
+			if err := exportClickEvents(ctx, s3Client); err != nil {
+				log.Printf("S3 export failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// exportClickEvents uploads every click event since the last successful
+// export as one gzipped NDJSON object, then advances the watermark.
+func exportClickEvents(ctx context.Context, s3Client *s3.Client) error {
+	to := time.Now().UTC()
+	from := to.Add(-s3ExportInterval)
+
+	var state jobState
+	err := jobs.FindOne(ctx, bson.M{"_id": s3ExportJobID}).Decode(&state)
+	switch {
+	case err == nil:
+		from = state.LastExportedAt
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return err
+	}
+
+	cur, err := clickEvents.Find(ctx,
+		bson.M{"timestamp": bson.M{"$gte": from, "$lt": to}},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	count := 0
+	for cur.Next(ctx) {
+		var ev ClickEvent
+		if err := cur.Decode(&ev); err != nil {
+			return err
+		}
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	if count > 0 {
+		key := fmt.Sprintf("click_events/%s/%s-%s.ndjson.gz",
+			to.Format("2006/01/02"), from.Format("20060102T150405Z"), to.Format("20060102T150405Z"))
+		_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:          aws.String(cfg.S3Bucket),
+			Key:             aws.String(key),
+			Body:            bytes.NewReader(buf.Bytes()),
+			ContentType:     aws.String("application/x-ndjson"),
+			ContentEncoding: aws.String("gzip"),
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Exported %d click events to s3://%s/%s", count, cfg.S3Bucket, key)
+	}
+
+	_, err = jobs.UpdateOne(ctx,
+		bson.M{"_id": s3ExportJobID},
+		bson.M{"$set": bson.M{"last_exported_at": to}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
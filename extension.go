@@ -0,0 +1,88 @@
+package urlshortener
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var extensionShortensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "extension_shortens_total",
+	Help: "GET /api/v1/shorten calls from browser extensions, by Extension-Version.",
+}, []string{"version", namespaceLabel})
+
+// fromExtension reports whether a GET shorten comes from a browser
+// extension, as it says with ?source=extension.
+func fromExtension(r *http.Request) bool {
+	return r.URL.Query().Get("source") == "extension"
+}
+
+// shortenGetCORS lets pages call GET /api/v1/shorten from any origin, as
+// bookmarklets run on whatever page is open. Extension calls are only
+// allowed from EXTENSION_ORIGINS, since they may send the API key in a
+// header. Headers are set before the API key check so that a browser lets
+// the caller read a 401, and the rate limit headers are exposed so that
+// it can back off.
+func shortenGetCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fromExtension(r) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else if origin := r.Header.Get("Origin"); slices.Contains(cfg.ExtensionOrigins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		w.Header().Set("Access-Control-Expose-Headers", "X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// extensionPreflightHandler answers the CORS preflight an extension's
+// X-API-Key and Extension-Version headers trigger.
+func extensionPreflightHandler(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); fromExtension(r) && slices.Contains(cfg.ExtensionOrigins, origin) {
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Methods", "GET")
+		h.Set("Access-Control-Allow-Headers", "X-API-Key, Extension-Version")
+		h.Set("Access-Control-Max-Age", "86400")
+		h.Add("Vary", "Origin")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// countExtensionShorten records the Extension-Version of an extension
+// call; malformed versions are counted as "unknown" to bound the labels.
+func countExtensionShorten(r *http.Request) {
+	version := r.Header.Get("Extension-Version")
+	if !sourcePattern.MatchString(version) {
+		version = "unknown"
+	}
+	extensionShortensTotal.WithLabelValues(version, requestNamespace(r)).Inc()
+}
+
+// wantsPlainText reports whether the client asked for text/plain, as
+// extensions do to copy the result straight to the clipboard.
+func wantsPlainText(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if strings.TrimSpace(mediaType) == "text/plain" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeShortened answers a GET shorten with m, as JSON or, if asked, as
+// the bare short URL.
+func writeShortened(w http.ResponseWriter, r *http.Request, status int, m URLMapping) {
+	if !wantsPlainText(r) {
+		writeJSON(w, status, m)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(publicBaseURL(r) + m.Path()))
+}
@@ -0,0 +1,335 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	feedSyncTag  = "feed-sync"
+	feedMaxBytes = 5 << 20
+)
+
+var feeds *mongo.Collection
+
+// feedClient fetches subscribed feeds from the server's network, so it only
+// connects to public addresses, redirects included.
+var feedClient = &http.Client{Timeout: 30 * time.Second, Transport: publicTransport()}
+
+// Feed is a subscription synced on demand and on the FEED_SYNC_CRON
+// schedule.
+type Feed struct {
+	URL          string     `bson:"_id" json:"feed_url"`
+	Tag          string     `bson:"tag" json:"tag"`
+	CreatedAt    time.Time  `bson:"created_at" json:"created_at"`
+	LastSyncedAt *time.Time `bson:"last_synced_at,omitempty" json:"last_synced_at,omitempty"`
+	LastCreated  int        `bson:"last_created" json:"last_created"`
+	LastSkipped  int        `bson:"last_skipped" json:"last_skipped"`
+	LastError    string     `bson:"last_error,omitempty" json:"last_error,omitempty"`
+}
+
+type feedEntry struct {
+	URL    string `json:"url"`
+	Code   string `json:"code,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type feedSyncResult struct {
+	FeedURL string      `json:"feed_url"`
+	Created []feedEntry `json:"created"`
+	Skipped []feedEntry `json:"skipped"`
+}
+
+type feedSyncRequest struct {
+	FeedURL string `json:"feed_url"`
+	Tag     string `json:"tag"`
+}
+
+// feedLink covers both RSS <link>text</link> and Atom <link href rel/>.
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type feedItem struct {
+	Links []feedLink `xml:"link"`
+}
+
+func (it feedItem) link() string {
+	for _, l := range it.Links {
+		if t := strings.TrimSpace(l.Text); t != "" {
+			return t
+		}
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// feedDoc matches RSS 2.0 (channel/item), RSS 1.0 (item at the root) and
+// Atom (entry) documents.
+type feedDoc struct {
+	Channel struct {
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []feedItem `xml:"item"`
+	Entries []feedItem `xml:"entry"`
+}
+
+// parseFeed returns the item links in r, resolved against base.
+func parseFeed(base *url.URL, r io.Reader) ([]string, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charset.NewReaderLabel
+	var doc feedDoc
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var links []string
+	for _, items := range [][]feedItem{doc.Channel.Items, doc.Items, doc.Entries} {
+		for _, it := range items {
+			if link := absoluteHTTPURL(base, it.link()); link != "" {
+				links = append(links, link)
+			}
+		}
+	}
+	return links, nil
+}
+
+func fetchFeed(ctx context.Context, feedURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed answered %s", resp.Status)
+	}
+	return parseFeed(resp.Request.URL, io.LimitReader(resp.Body, feedMaxBytes))
+}
+
+// syncFeed creates short URLs for the feed's items that don't already have
+// one, tagged with tag and feed-sync, and records the outcome on the
+// subscription.
+func syncFeed(ctx context.Context, feedURL, tag string) (feedSyncResult, error) {
+	res := feedSyncResult{FeedURL: feedURL, Created: []feedEntry{}, Skipped: []feedEntry{}}
+	links, err := fetchFeed(ctx, feedURL)
+	if err != nil {
+		recordFeedSync(ctx, res, err)
+		return res, err
+	}
+
+	var dests []string
+	for _, link := range links {
+		dest, err := validateDestinationURL(link)
+		if err != nil {
+			res.Skipped = append(res.Skipped, feedEntry{URL: link, Reason: err.Error()})
+			continue
+		}
+		dests = append(dests, dest)
+	}
+
+	existing := map[string]bool{}
+	cur, err := collection.Find(ctx, bson.M{"url": bson.M{"$in": append([]string{}, dests...)}}, options.Find().SetProjection(bson.M{"url": 1}))
+	if err != nil {
+		recordFeedSync(ctx, res, err)
+		return res, err
+	}
+	var found []struct {
+		URL string `bson:"url"`
+	}
+	if err := cur.All(ctx, &found); err != nil {
+		recordFeedSync(ctx, res, err)
+		return res, err
+	}
+	for _, f := range found {
+		existing[f.URL] = true
+	}
+
+	tags := []string{tag, feedSyncTag}
+	if tag == feedSyncTag {
+		tags = tags[:1]
+	}
+	for _, dest := range dests {
+		if existing[dest] {
+			res.Skipped = append(res.Skipped, feedEntry{URL: dest, Reason: "already shortened"})
+			continue
+		}
+		m, err := createShortURL(ctx, URLMapping{URL: dest, Tags: tags})
+		if err != nil {
+			log.Printf("Feed sync of %s failed to shorten %s: %v", feedURL, dest, err)
+			res.Skipped = append(res.Skipped, feedEntry{URL: dest, Reason: "failed to save"})
+			continue
+		}
+		existing[dest] = true
+		res.Created = append(res.Created, feedEntry{URL: dest, Code: m.Code})
+	}
+
+	recordFeedSync(ctx, res, nil)
+	return res, nil
+}
+
+func recordFeedSync(ctx context.Context, res feedSyncResult, syncErr error) {
+	set := bson.M{
+		"last_synced_at": time.Now(),
+		"last_created":   len(res.Created),
+		"last_skipped":   len(res.Skipped),
+	}
+	update := bson.M{"$set": set}
+	if syncErr != nil {
+		set["last_error"] = syncErr.Error()
+	} else {
+		update["$unset"] = bson.M{"last_error": ""}
+	}
+	if _, err := feeds.UpdateOne(ctx, bson.M{"_id": res.FeedURL}, update); err != nil {
+		log.Printf("Failed to record sync of feed %s: %v", res.FeedURL, err)
+	}
+}
+
+// startFeedSync syncs every subscribed feed on the FEED_SYNC_CRON
+// schedule, server local time. Without a schedule feeds only sync on
+// demand.
+func startFeedSync(ctx context.Context) {
+	if cfg.FeedSyncCron == "" {
+		return
+	}
+	sched, _ := cron.ParseStandard(cfg.FeedSyncCron)
+
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(sched.Next(time.Now())))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				syncAllFeeds(ctx)
+			}
+		}
+	}()
+}
+
+func syncAllFeeds(ctx context.Context) {
+	cur, err := feeds.Find(ctx, bson.M{})
+	if err != nil {
+		log.Printf("Failed to load feeds: %v", err)
+		return
+	}
+	var subs []Feed
+	if err := cur.All(ctx, &subs); err != nil {
+		log.Printf("Failed to load feeds: %v", err)
+		return
+	}
+	for _, f := range subs {
+		res, err := syncFeed(ctx, f.URL, f.Tag)
+		if err != nil {
+			log.Printf("Failed to sync feed %s: %v", f.URL, err)
+			continue
+		}
+		log.Printf("Synced feed %s: %d created, %d skipped", f.URL, len(res.Created), len(res.Skipped))
+	}
+}
+
+// feedSyncHandler subscribes to a feed, replacing the tag of an existing
+// subscription, and syncs it immediately.
+func feedSyncHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req feedSyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	feedURL, err := validateURL(req.FeedURL)
+	if err != nil {
+		http.Error(w, "feed_url: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	tag := strings.TrimSpace(req.Tag)
+	if tag == "" {
+		http.Error(w, "tag is required", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := feeds.UpdateOne(r.Context(),
+		bson.M{"_id": feedURL},
+		bson.M{"$set": bson.M{"tag": tag}, "$setOnInsert": bson.M{"created_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Failed to save feed %s: %v", feedURL, err)
+		http.Error(w, "Failed to save feed", http.StatusInternalServerError)
+		return
+	}
+
+	res, err := syncFeed(r.Context(), feedURL, tag)
+	if err != nil {
+		// Don't keep retrying a new subscription that never worked.
+		if saved.UpsertedCount > 0 {
+			if _, err := feeds.DeleteOne(context.Background(), bson.M{"_id": feedURL}); err != nil {
+				log.Printf("Failed to drop feed %s: %v", feedURL, err)
+			}
+		}
+		log.Printf("Failed to sync feed %s: %v", feedURL, err)
+		http.Error(w, "Failed to sync feed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func listFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	cur, err := feeds.Find(r.Context(), bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		log.Printf("Failed to list feeds: %v", err)
+		http.Error(w, "Failed to list feeds", http.StatusInternalServerError)
+		return
+	}
+	subs := []Feed{}
+	if err := cur.All(r.Context(), &subs); err != nil {
+		log.Printf("Failed to list feeds: %v", err)
+		http.Error(w, "Failed to list feeds", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+// deleteFeedHandler unsubscribes from ?feed_url=. Short URLs already
+// created from the feed are kept.
+func deleteFeedHandler(w http.ResponseWriter, r *http.Request) {
+	feedURL, err := validateURL(r.URL.Query().Get("feed_url"))
+	if err != nil {
+		http.Error(w, "feed_url: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := feeds.DeleteOne(r.Context(), bson.M{"_id": feedURL})
+	if err != nil {
+		log.Printf("Failed to delete feed %s: %v", feedURL, err)
+		http.Error(w, "Failed to delete feed", http.StatusInternalServerError)
+		return
+	}
+	if res.DeletedCount == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,33 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchFeedRefusesPrivateAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("feed fetch reached the internal server: %s %s", r.Method, r.URL)
+	}))
+	t.Cleanup(internal.Close)
+
+	tests := []struct {
+		name string
+		feed string
+	}{
+		{"loopback", internal.URL + "/feed.xml"},
+		{"localhost", strings.Replace(internal.URL, "127.0.0.1", "localhost", 1)},
+		{"metadata", "http://169.254.169.254/latest/meta-data/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := fetchFeed(context.Background(), tt.feed); !errors.Is(err, errPrivateAddress) {
+				t.Errorf("fetchFeed(%q) error = %v, want %v", tt.feed, err, errPrivateAddress)
+			}
+		})
+	}
+}
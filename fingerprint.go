@@ -0,0 +1,78 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"urlshortener/pkg/store"
+)
+
+const contentHashBatchSize = 500
+
+// contentHash fingerprints a destination with the TRACKING_PARAMS
+// ignored; see store.ContentHash. It expects a URL that passed validateURL.
+// Stored content hashes follow the TRACKING_PARAMS of the time they were
+// computed.
+func contentHash(raw string) string {
+	return store.ContentHash(raw, cfg.TrackingParams)
+}
+
+// migrateContentHashes sets content_hash on mappings saved before it
+// existed, so that fingerprint lookups find them.
+func migrateContentHashes(ctx context.Context) {
+	cur, err := collection.Find(ctx,
+		bson.M{"content_hash": bson.M{"$exists": false}, "url": bson.M{"$nin": bson.A{nil, ""}}},
+		options.Find().SetProjection(bson.M{"url": 1}),
+	)
+	if err != nil {
+		log.Printf("content_hash migration failed: %v", err)
+		return
+	}
+	defer cur.Close(ctx)
+
+	var (
+		models   []mongo.WriteModel
+		migrated int64
+	)
+	flush := func() bool {
+		if len(models) == 0 {
+			return true
+		}
+		res, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			log.Printf("content_hash migration failed: %v", err)
+			return false
+		}
+		migrated += res.ModifiedCount
+		models = models[:0]
+		return true
+	}
+	for cur.Next(ctx) {
+		var doc struct {
+			ID  primitive.ObjectID `bson:"_id"`
+			URL string             `bson:"url"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			log.Printf("content_hash migration failed: %v", err)
+			return
+		}
+		hash := contentHash(doc.URL)
+		if hash == "" {
+			continue
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": doc.ID, "url": doc.URL}).
+			SetUpdate(bson.M{"$set": bson.M{"content_hash": hash}}))
+		if len(models) == contentHashBatchSize && !flush() {
+			return
+		}
+	}
+	if flush() && migrated > 0 {
+		log.Printf("content_hash migration backfilled %d documents", migrated)
+	}
+}
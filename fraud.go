@@ -0,0 +1,205 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// An IP following more than fraudDistinctCodes different codes within
+	// fraudWindow is taken for a scanner and blocked for fraudBlockFor.
+	fraudWindow        = time.Minute
+	fraudDistinctCodes = 50
+	fraudBlockFor      = time.Hour
+
+	fraudEventsLimit = 500
+)
+
+// fraudEvents records each detected scanner, with the clicks that gave it
+// away.
+var fraudEvents *mongo.Collection
+
+type fraudClick struct {
+	Code      string    `bson:"code" json:"code"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+type FraudEvent struct {
+	IP            string       `bson:"ip" json:"ip"`
+	DetectedAt    time.Time    `bson:"detected_at" json:"detected_at"`
+	BlockedUntil  time.Time    `bson:"blocked_until" json:"blocked_until"`
+	Clicks        []fraudClick `bson:"clicks" json:"clicks"`
+	RemovedClicks int64        `bson:"removed_clicks" json:"removed_clicks"`
+}
+
+// ipCodes is an IP's sliding window: the last time it followed each code.
+// Keeping one entry per code bounds it at fraudDistinctCodes+1 entries.
+type ipCodes struct {
+	mu    sync.Mutex
+	codes map[string]time.Time
+}
+
+var (
+	clickVelocity sync.Map // ip -> *ipCodes
+	blockedIPs    sync.Map // ip -> time.Time the block ends
+)
+
+// startFraudDetection drops idle windows and lapsed blocks. Windows and
+// blocks are per instance.
+func startFraudDetection(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(fraudWindow)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				clickVelocity.Range(func(k, v interface{}) bool {
+					c := v.(*ipCodes)
+					c.mu.Lock()
+					for code, t := range c.codes {
+						if now.Sub(t) >= fraudWindow {
+							delete(c.codes, code)
+						}
+					}
+					if len(c.codes) == 0 {
+						clickVelocity.Delete(k)
+					}
+					c.mu.Unlock()
+					return true
+				})
+				blockedIPs.Range(func(k, v interface{}) bool {
+					if now.After(v.(time.Time)) {
+						blockedIPs.Delete(k)
+					}
+					return true
+				})
+			}
+		}
+	}()
+}
+
+// allowClicker answers 403 and reports false for a blocked IP. Otherwise
+// it adds code to the IP's window and, once the IP is over the limit,
+// blocks it and answers 403 as well. Declared bots are exempt: crawlers
+// and link unfurlers follow many codes legitimately, and their clicks
+// are already marked as bot traffic.
+func allowClicker(w http.ResponseWriter, r *http.Request, code string) bool {
+	ip := realIP(r)
+	now := time.Now()
+	if until, ok := blockedIPs.Load(ip); ok && now.Before(until.(time.Time)) {
+		writeError(w, r, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	if isBot(r.UserAgent()) {
+		return true
+	}
+
+	v, _ := clickVelocity.LoadOrStore(ip, &ipCodes{codes: make(map[string]time.Time)})
+	c := v.(*ipCodes)
+	c.mu.Lock()
+	c.codes[code] = now
+	var clicks []fraudClick
+	for code, t := range c.codes {
+		if now.Sub(t) < fraudWindow {
+			clicks = append(clicks, fraudClick{Code: code, Timestamp: t})
+		}
+	}
+	flagged := len(clicks) > fraudDistinctCodes
+	if flagged {
+		c.codes = make(map[string]time.Time)
+	}
+	c.mu.Unlock()
+
+	if !flagged {
+		return true
+	}
+	until := now.Add(fraudBlockFor)
+	blockedIPs.Store(ip, until)
+	sort.Slice(clicks, func(i, j int) bool { return clicks[i].Timestamp.Before(clicks[j].Timestamp) })
+	go recordFraud(FraudEvent{IP: ip, DetectedAt: now, BlockedUntil: until, Clicks: clicks})
+	writeError(w, r, "Forbidden", http.StatusForbidden)
+	return false
+}
+
+// recordFraud removes ev's clicks from analytics, both the click events
+// and the click counters, and logs ev to fraud_events. Clicks still being
+// recorded when it runs are missed.
+func recordFraud(ev FraudEvent) {
+	log.Printf("Blocking %s until %s: followed %d short codes within %s", ev.IP, ev.BlockedUntil.Format(time.RFC3339), len(ev.Clicks), fraudWindow)
+	ctx := context.Background()
+
+	if n, err := removeClicks(ctx, ev); err != nil {
+		log.Printf("Failed to remove clicks of %s from analytics: %v", ev.IP, err)
+	} else {
+		ev.RemovedClicks = n
+	}
+
+	if _, err := fraudEvents.InsertOne(ctx, ev); err != nil {
+		log.Printf("Failed to record fraud event for %s: %v", ev.IP, err)
+	}
+}
+
+func removeClicks(ctx context.Context, ev FraudEvent) (int64, error) {
+	cur, err := clickEvents.Find(ctx,
+		bson.M{"ip": ev.IP, "timestamp": bson.M{"$gte": ev.Clicks[0].Timestamp}},
+		options.Find().SetProjection(bson.M{"_id": 1, "code": 1}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var found []struct {
+		ID   interface{} `bson:"_id"`
+		Code string      `bson:"code"`
+	}
+	if err := cur.All(ctx, &found); err != nil {
+		return 0, err
+	}
+	if len(found) == 0 {
+		return 0, nil
+	}
+
+	ids := make(bson.A, 0, len(found))
+	perCode := make(map[string]int64)
+	for _, f := range found {
+		ids = append(ids, f.ID)
+		perCode[f.Code]++
+	}
+	res, err := clickEvents.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	for code, n := range perCode {
+		if _, err := collection.UpdateOne(ctx, bson.M{"code": code}, bson.M{"$inc": bson.M{"clicks": -n}}); err != nil {
+			log.Printf("Error decrementing clicks for %s: %v", code, err)
+		}
+	}
+	return res.DeletedCount, nil
+}
+
+// fraudEventsHandler lists the most recent fraud events, newest first.
+func fraudEventsHandler(w http.ResponseWriter, r *http.Request) {
+	cur, err := fraudEvents.Find(r.Context(), bson.M{},
+		options.Find().SetSort(bson.D{{Key: "detected_at", Value: -1}}).SetLimit(fraudEventsLimit))
+	if err != nil {
+		log.Printf("Failed to list fraud events: %v", err)
+		http.Error(w, "Failed to list fraud events", http.StatusInternalServerError)
+		return
+	}
+	list := []FraudEvent{}
+	if err := cur.All(r.Context(), &list); err != nil {
+		log.Printf("Failed to list fraud events: %v", err)
+		http.Error(w, "Failed to list fraud events", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,171 @@
+(function () {
+    "use strict";
+
+    const perPage = 20;
+    let page = 1;
+    let chart = null;
+
+    async function api(method, path, body) {
+        const opts = { method, headers: {} };
+        if (body !== undefined) {
+            opts.headers["Content-Type"] = "application/json";
+            opts.body = JSON.stringify(body);
+        }
+        const res = await fetch(path, opts);
+        if (!res.ok) {
+            throw new Error((await res.text()).trim() || res.statusText);
+        }
+        return res.status === 204 ? null : res.json();
+    }
+
+    function cell(text) {
+        const td = document.createElement("td");
+        td.textContent = text;
+        return td;
+    }
+
+    async function loadPage() {
+        const data = await api("GET", `/api/v1/urls?page=${page}&per_page=${perPage}`);
+        const rows = document.getElementById("url-rows");
+        rows.replaceChildren();
+
+        for (const m of data.urls) {
+            const tr = document.createElement("tr");
+
+            const preview = document.createElement("td");
+            const thumb = document.createElement("img");
+            thumb.className = "thumb";
+            thumb.loading = "lazy";
+            thumb.alt = "";
+            thumb.src = "/screenshot/" + encodeURIComponent(m.code);
+            thumb.addEventListener("error", () => thumb.remove());
+            preview.appendChild(thumb);
+            tr.appendChild(preview);
+
+            const code = document.createElement("td");
+            const link = document.createElement("a");
+            link.href = "/" + encodeURIComponent(m.code);
+            link.target = "_blank";
+            link.textContent = m.code;
+            code.appendChild(link);
+            tr.appendChild(code);
+
+            const dest = cell(m.url);
+            dest.className = "dest";
+            dest.title = "Click to edit";
+            dest.addEventListener("click", () => editDestination(dest, m));
+            tr.appendChild(dest);
+
+            tr.appendChild(cell(m.clicks));
+            tr.appendChild(cell((m.tags || []).join(", ")));
+            tr.appendChild(cell(new Date(m.created_at).toLocaleString()));
+
+            const actions = document.createElement("td");
+            const stats = document.createElement("button");
+            stats.type = "button";
+            stats.textContent = "Stats";
+            stats.addEventListener("click", () => showStats(m.code));
+            actions.appendChild(stats);
+            tr.appendChild(actions);
+
+            rows.appendChild(tr);
+        }
+
+        const pages = Math.max(1, Math.ceil(data.total / perPage));
+        document.getElementById("page-info").textContent = `Page ${page} of ${pages}`;
+        document.getElementById("prev-page").disabled = page <= 1;
+        document.getElementById("next-page").disabled = page >= pages;
+    }
+
+    function editDestination(td, mapping) {
+        if (td.querySelector("input")) {
+            return;
+        }
+        const input = document.createElement("input");
+        input.type = "url";
+        input.value = mapping.url;
+        td.replaceChildren(input);
+        input.focus();
+
+        const finish = async (save) => {
+            if (save && input.value !== mapping.url) {
+                try {
+                    const updated = await api("PATCH", "/api/v1/" + encodeURIComponent(mapping.code), { url: input.value });
+                    mapping.url = updated.url;
+                } catch (err) {
+                    alert("Update failed: " + err.message);
+                }
+            }
+            td.textContent = mapping.url;
+        };
+        input.addEventListener("keydown", (e) => {
+            if (e.key === "Enter") {
+                finish(true);
+            } else if (e.key === "Escape") {
+                finish(false);
+            }
+        });
+        input.addEventListener("blur", () => finish(true));
+    }
+
+    async function showStats(code) {
+        const data = await api("GET", "/api/v1/" + encodeURIComponent(code) + "/analytics");
+        document.getElementById("stats").hidden = false;
+        document.getElementById("stats-code").textContent = code;
+
+        if (chart) {
+            chart.destroy();
+        }
+        chart = new Chart(document.getElementById("stats-chart"), {
+            type: "bar",
+            data: {
+                labels: data.daily.map((d) => d.date),
+                datasets: [{ label: "Clicks", data: data.daily.map((d) => d.clicks) }],
+            },
+            options: { scales: { y: { beginAtZero: true, ticks: { precision: 0 } } } },
+        });
+    }
+
+    document.getElementById("create-form").addEventListener("submit", async (e) => {
+        e.preventDefault();
+        const form = e.target;
+        const result = document.getElementById("create-result");
+        const body = { url: form.url.value };
+        if (form.code.value) {
+            body.code = form.code.value;
+        }
+        if (form.expires_at.value) {
+            body.expires_at = new Date(form.expires_at.value).toISOString();
+        }
+        const tags = form.tags.value.split(",").map((t) => t.trim()).filter(Boolean);
+        if (tags.length) {
+            body.tags = tags;
+        }
+
+        try {
+            const created = await api("POST", "/api/v1/shorten", body);
+            const short = new URL(created.short_url, window.location.origin).href;
+            result.className = "";
+            result.textContent = "Created " + short;
+            form.reset();
+            page = 1;
+            await loadPage();
+        } catch (err) {
+            result.className = "error";
+            result.textContent = err.message;
+        }
+    });
+
+    document.getElementById("prev-page").addEventListener("click", () => {
+        page--;
+        loadPage();
+    });
+    document.getElementById("next-page").addEventListener("click", () => {
+        page++;
+        loadPage();
+    });
+
+    loadPage().catch((err) => {
+        document.getElementById("page-info").textContent = "Failed to load: " + err.message;
+    });
+})();
@@ -0,0 +1,208 @@
+package urlshortener
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	users    *mongo.Collection
+	auditLog *mongo.Collection
+
+	// complianceLog records erasures. It is append-only and never erased.
+	complianceLog *mongo.Collection
+)
+
+type erasureSummary struct {
+	UserID      string    `bson:"user_id" json:"user_id"`
+	URLs        int64     `bson:"urls" json:"urls"`
+	ClickEvents int64     `bson:"click_events" json:"click_events"`
+	AuditLog    int64     `bson:"audit_log" json:"audit_log"`
+	UserDeleted bool      `bson:"user_deleted" json:"user_deleted"`
+	Sessions    int64     `bson:"sessions" json:"sessions"`
+	ErasedAt    time.Time `bson:"erased_at" json:"erased_at"`
+}
+
+// eraseUserHandler implements the GDPR right to erasure for one user.
+func eraseUserHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	summary, codes, err := eraseUser(r.Context(), id)
+	if err != nil {
+		log.Printf("Failed to erase user %s: %v", id, err)
+		http.Error(w, "Failed to erase user", http.StatusInternalServerError)
+		return
+	}
+
+	for _, code := range codes {
+		shortURLs.Delete(code)
+		publishMutation(replicateDelete, URLMapping{Code: code})
+		lifecycle.PublishDeleted(code)
+	}
+
+	entry := struct {
+		Event          string `bson:"event"`
+		Actor          string `bson:"actor"`
+		erasureSummary `bson:",inline"`
+	}{"user_erased", cfg.AdminUser, summary}
+	if _, err := complianceLog.InsertOne(r.Context(), entry); err != nil {
+		// The data is already gone; failing the request would only invite a
+		// retry that finds nothing.
+		log.Printf("Failed to record erasure of user %s in compliance log: %v", id, err)
+	}
+	log.Printf("Erased user %s: %d URLs, %d click events", id, summary.URLs, summary.ClickEvents)
+
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// eraseUser deletes everything owned by or about the user in one
+// transaction and returns what was removed along with the erased codes.
+func eraseUser(ctx context.Context, id string) (erasureSummary, []string, error) {
+	summary := erasureSummary{UserID: id, ErasedAt: time.Now()}
+	var codes []string
+
+	session, err := client.StartSession()
+	if err != nil {
+		return summary, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		summary = erasureSummary{UserID: id, ErasedAt: summary.ErasedAt}
+		codes = nil
+
+		cur, err := collection.Find(sc, bson.M{"owner_id": id}, options.Find().SetProjection(bson.M{"code": 1}))
+		if err != nil {
+			return nil, err
+		}
+		var owned []struct {
+			Code string `bson:"code"`
+		}
+		if err := cur.All(sc, &owned); err != nil {
+			return nil, err
+		}
+		for _, o := range owned {
+			codes = append(codes, o.Code)
+		}
+
+		byCode := bson.M{"code": bson.M{"$in": codes}}
+		res, err := collection.DeleteMany(sc, bson.M{"owner_id": id})
+		if err != nil {
+			return nil, err
+		}
+		summary.URLs = res.DeletedCount
+		if len(codes) > 0 {
+			if res, err = clickEvents.DeleteMany(sc, byCode); err != nil {
+				return nil, err
+			}
+			summary.ClickEvents = res.DeletedCount
+			if res, err = auditLog.DeleteMany(sc, byCode); err != nil {
+				return nil, err
+			}
+			summary.AuditLog = res.DeletedCount
+		}
+		if res, err = users.DeleteOne(sc, bson.M{"_id": id}); err != nil {
+			return nil, err
+		}
+		summary.UserDeleted = res.DeletedCount > 0
+		if res, err = sessions.DeleteMany(sc, bson.M{"user_id": id}); err != nil {
+			return nil, err
+		}
+		summary.Sessions = res.DeletedCount
+		return nil, nil
+	})
+	return summary, codes, err
+}
+
+// exportUserHandler answers a GDPR subject access request with a ZIP of the
+// user's URLs, the click events on them and their account record. Click
+// events are exported without IP addresses or user agents, since those
+// identify the visitors rather than the user.
+func exportUserHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ctx := r.Context()
+
+	urls := []URLMapping{}
+	cur, err := collection.Find(ctx, bson.M{"owner_id": id}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err == nil {
+		err = cur.All(ctx, &urls)
+	}
+	if err != nil {
+		log.Printf("Failed to export URLs for user %s: %v", id, err)
+		http.Error(w, "Failed to export user data", http.StatusInternalServerError)
+		return
+	}
+
+	codes := make([]string, 0, len(urls))
+	for _, m := range urls {
+		codes = append(codes, m.Code)
+	}
+	events := []ClickEvent{}
+	if len(codes) > 0 {
+		cur, err = clickEvents.Find(ctx,
+			bson.M{"code": bson.M{"$in": codes}},
+			options.Find().SetProjection(bson.M{"ip": 0, "user_agent": 0}).SetSort(bson.D{{Key: "timestamp", Value: 1}}),
+		)
+		if err == nil {
+			err = cur.All(ctx, &events)
+		}
+		if err != nil {
+			log.Printf("Failed to export click events for user %s: %v", id, err)
+			http.Error(w, "Failed to export user data", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	account := bson.M{"user_id": id}
+	var user bson.M
+	switch err := users.FindOne(ctx, bson.M{"_id": id}).Decode(&user); {
+	case err == nil:
+		account["user"] = user
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		log.Printf("Failed to export account for user %s: %v", id, err)
+		http.Error(w, "Failed to export user data", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, v := range map[string]interface{}{
+		"urls.json":         urls,
+		"click_events.json": events,
+		"account.json":      account,
+	} {
+		f, err := zw.Create(name)
+		if err == nil {
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(v)
+		}
+		if err != nil {
+			log.Printf("Failed to build export for user %s: %v", id, err)
+			http.Error(w, "Failed to export user data", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Failed to build export for user %s: %v", id, err)
+		http.Error(w, "Failed to export user data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "data-export-" + id + ".zip"}))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
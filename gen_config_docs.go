@@ -0,0 +1,303 @@
+//go:build ignore
+
+// gen_config_docs writes config_docs.yaml, documenting every Config field,
+// config_docs.go, which embeds it, and the same reference as
+// CONFIGURATION.md. Run it with go generate; make check-generated fails
+// when the committed files are out of date.
+//
+// The field list, types and descriptions come from the Config struct and
+// its line comments; the environment variables, flags and defaults from the
+// getEnv, getEnvInt and flag calls in loadConfig. It works on the parsed
+// source rather than through reflection because the line comments and the
+// defaults only exist there.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type option struct {
+	Field       string
+	Env         string
+	Flag        string
+	Type        string
+	Default     string
+	Description string
+}
+
+const goFile = `// Code generated by gen_config_docs.go; DO NOT EDIT.
+
+package urlshortener
+
+import _ "embed"
+
+// configDocs is the YAML reference of every configuration option.
+//
+//go:embed config_docs.yaml
+var configDocs []byte
+`
+
+func main() {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && filepath.Base(fi.Name()) != "gen_config_docs.go"
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg, ok := pkgs["urlshortener"]
+	if !ok {
+		log.Fatal("package urlshortener not found")
+	}
+
+	consts := stringConsts(pkg)
+	var fields *ast.StructType
+	var load *ast.FuncDecl
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == "Config" {
+						fields = ts.Type.(*ast.StructType)
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Name.Name == "loadConfig" && d.Recv == nil {
+					load = d
+				}
+			}
+		}
+	}
+	if fields == nil || load == nil {
+		log.Fatal("Config or loadConfig not found")
+	}
+
+	var opts []*option
+	byField := map[string]*option{}
+	for _, f := range fields.Fields.List {
+		desc := strings.TrimSpace(f.Comment.Text())
+		for _, name := range f.Names {
+			o := &option{Field: name.Name, Type: typeName(f.Type), Description: desc}
+			if desc == "" {
+				log.Fatalf("Config.%s has no line comment", name.Name)
+			}
+			opts = append(opts, o)
+			byField[name.Name] = o
+		}
+	}
+
+	for _, stmt := range load.Body.List {
+		sources(stmt, byField, consts)
+	}
+	for _, o := range opts {
+		if o.Env == "" && o.Flag == "" {
+			log.Fatalf("Config.%s is not set from the environment or a flag in loadConfig", o.Field)
+		}
+	}
+
+	if err := os.WriteFile("config_docs.yaml", render(opts), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("config_docs.go", []byte(goFile), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("CONFIGURATION.md", renderMarkdown(opts), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// sources records the environment variables and flags stmt reads into
+// Config fields. The field is the one named in the same statement, so a
+// variable read in a loop must be appended to the field inside it.
+func sources(stmt ast.Stmt, byField map[string]*option, consts map[string]string) {
+	if cl := compositeConfig(stmt); cl != nil {
+		for _, elt := range cl.Elts {
+			kv := elt.(*ast.KeyValueExpr)
+			if o := byField[kv.Key.(*ast.Ident).Name]; o != nil {
+				readEnv(kv.Value, o, consts)
+			}
+		}
+		return
+	}
+
+	var o *option
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && o == nil {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == "c" {
+				o = byField[sel.Sel.Name]
+			}
+		}
+		return true
+	})
+	if o == nil {
+		return
+	}
+	readEnv(stmt, o, consts)
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 4 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "flag" {
+			return true
+		}
+		o.Flag = "--" + literal(call.Args[1], consts)
+		o.Default = literal(call.Args[2], consts)
+		return false
+	})
+}
+
+func compositeConfig(stmt ast.Stmt) *ast.CompositeLit {
+	as, ok := stmt.(*ast.AssignStmt)
+	if !ok || len(as.Rhs) != 1 {
+		return nil
+	}
+	cl, ok := as.Rhs[0].(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	if id, ok := cl.Type.(*ast.Ident); !ok || id.Name != "Config" {
+		return nil
+	}
+	return cl
+}
+
+func readEnv(n ast.Node, o *option, consts map[string]string) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		fn, ok := call.Fun.(*ast.Ident)
+		if !ok || (fn.Name != "getEnv" && fn.Name != "getEnvInt") {
+			return true
+		}
+		o.Env = literal(call.Args[0], consts)
+		o.Default = literal(call.Args[1], consts)
+		return false
+	})
+}
+
+// literal is the value of a basic literal or a string constant.
+func literal(e ast.Expr, consts map[string]string) string {
+	switch v := e.(type) {
+	case *ast.BasicLit:
+		if v.Kind == token.STRING {
+			s, err := strconv.Unquote(v.Value)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return s
+		}
+		return v.Value
+	case *ast.Ident:
+		if v.Name == "true" || v.Name == "false" {
+			return v.Name
+		}
+		if s, ok := consts[v.Name]; ok {
+			return s
+		}
+	}
+	log.Fatalf("Can't resolve default %s", types.ExprString(e))
+	return ""
+}
+
+func stringConsts(pkg *ast.Package) map[string]string {
+	consts := map[string]string{}
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range d.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if i >= len(vs.Values) {
+						continue
+					}
+					if lit, ok := vs.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						consts[name.Name], _ = strconv.Unquote(lit.Value)
+					}
+				}
+			}
+		}
+	}
+	return consts
+}
+
+// typeName is the type an operator supplies: lists are comma-separated
+// strings whatever their element type.
+func typeName(e ast.Expr) string {
+	if _, ok := e.(*ast.ArrayType); ok {
+		return "list"
+	}
+	return types.ExprString(e)
+}
+
+func render(opts []*option) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Code generated by gen_config_docs.go; DO NOT EDIT.\n")
+	b.WriteString("# Every urlshortener configuration option with its default.\n")
+	b.WriteString("options:\n")
+	for _, o := range opts {
+		fmt.Fprintf(&b, "  - name: %s\n", o.Field)
+		if o.Env != "" {
+			fmt.Fprintf(&b, "    env: %s\n", o.Env)
+		}
+		if o.Flag != "" {
+			fmt.Fprintf(&b, "    flag: %s\n", o.Flag)
+		}
+		fmt.Fprintf(&b, "    type: %s\n", o.Type)
+		if o.Type == "string" || o.Type == "list" {
+			fmt.Fprintf(&b, "    default: %s\n", strconv.Quote(o.Default))
+		} else {
+			fmt.Fprintf(&b, "    default: %s\n", o.Default)
+		}
+		fmt.Fprintf(&b, "    description: %s\n", strconv.Quote(o.Description))
+	}
+	return b.Bytes()
+}
+
+var markdownCell = strings.NewReplacer("|", `\|`, "`", "")
+
+func renderMarkdown(opts []*option) []byte {
+	var b bytes.Buffer
+	b.WriteString("<!-- Code generated by gen_config_docs.go; DO NOT EDIT. -->\n\n")
+	b.WriteString("# Configuration\n\n")
+	b.WriteString("urlshortener is configured through environment variables and a few\n")
+	b.WriteString("command-line flags. `GET /api/v1/config-schema` serves the same reference\n")
+	b.WriteString("as YAML.\n\n")
+	b.WriteString("| Option | Environment variable | Flag | Type | Default | Description |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	code := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "`" + markdownCell.Replace(s) + "`"
+	}
+	for _, o := range opts {
+		def := code(o.Default)
+		if o.Default == "" {
+			def = "*empty*"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+			o.Field, code(o.Env), code(o.Flag), o.Type, def, markdownCell.Replace(o.Description))
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,621 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// graphQLDefaultRange is the analytics window when from is omitted.
+	graphQLDefaultRange = 30 * 24 * time.Hour
+	graphQLMaxEvents    = 100
+
+	// graphQLActor names GraphQL mutations in audit_log; the caller is only
+	// known by its API key.
+	graphQLActor = "API key"
+)
+
+// batchLoader is the DataLoader pattern for graphql-go: a resolver asks for
+// a key and returns a thunk, graphql-go resolves the whole level of the
+// query before calling any thunk, and the first thunk called loads every
+// key asked for so far with one call to fetch. Results are kept for the
+// rest of the request. A key fetch doesn't return resolves to null.
+type batchLoader struct {
+	fetch func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+	mu      sync.Mutex
+	pending []string
+	results map[string]interface{}
+	errs    map[string]error
+}
+
+func newBatchLoader(fetch func(context.Context, []string) (map[string]interface{}, error)) *batchLoader {
+	return &batchLoader{fetch: fetch, results: map[string]interface{}{}, errs: map[string]error{}}
+}
+
+func (l *batchLoader) load(ctx context.Context, key string) func() (interface{}, error) {
+	l.mu.Lock()
+	l.pending = append(l.pending, key)
+	l.mu.Unlock()
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+			got, err := l.fetch(ctx, keys)
+			for _, k := range keys {
+				if err != nil {
+					l.errs[k] = err
+				} else if v, ok := got[k]; ok {
+					l.results[k] = v
+				}
+			}
+		}
+		if err := l.errs[key]; err != nil {
+			return nil, err
+		}
+		return l.results[key], nil
+	}
+}
+
+// graphQLLoaders are the batch loaders of one request.
+type graphQLLoaders struct {
+	targets *batchLoader
+
+	mu        sync.Mutex
+	analytics map[[2]time.Time]*batchLoader // by analytics window
+}
+
+type graphQLLoadersKey struct{}
+
+func loadersFrom(ctx context.Context) *graphQLLoaders {
+	return ctx.Value(graphQLLoadersKey{}).(*graphQLLoaders)
+}
+
+func newGraphQLLoaders() *graphQLLoaders {
+	return &graphQLLoaders{
+		targets: newBatchLoader(func(ctx context.Context, codes []string) (map[string]interface{}, error) {
+			found, err := fetchMappings(ctx, codes)
+			if err != nil {
+				return nil, graphQLInternal("load short URLs", err)
+			}
+			return found, nil
+		}),
+		analytics: map[[2]time.Time]*batchLoader{},
+	}
+}
+
+// analyticsLoader returns the loader for the window [from, to), so that
+// ShortURL.analytics fields asking for the same window share a query.
+func (ls *graphQLLoaders) analyticsLoader(from, to time.Time) *batchLoader {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	key := [2]time.Time{from, to}
+	l, ok := ls.analytics[key]
+	if !ok {
+		l = newBatchLoader(func(ctx context.Context, codes []string) (map[string]interface{}, error) {
+			found, err := fetchAnalytics(ctx, codes, from, to)
+			if err != nil {
+				return nil, graphQLInternal("load analytics", err)
+			}
+			return found, nil
+		})
+		ls.analytics[key] = l
+	}
+	return l
+}
+
+func fetchMappings(ctx context.Context, codes []string) (map[string]interface{}, error) {
+	cur, err := collection.Find(ctx, bson.M{"code": bson.M{"$in": codes}})
+	if err != nil {
+		return nil, err
+	}
+	var list []URLMapping
+	if err := cur.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	found := make(map[string]interface{}, len(list))
+	for _, m := range list {
+		found[m.Code] = m
+	}
+	return found, nil
+}
+
+// analyticsSummary is an AnalyticsSummary: the clicks of a code between
+// from and to.
+type analyticsSummary struct {
+	code     string
+	from, to time.Time
+	daily    []DailyClicks
+}
+
+// fetchAnalytics counts the clicks of every code in codes per day between
+// from and to with one aggregation. Every code gets a summary, if empty.
+func fetchAnalytics(ctx context.Context, codes []string, from, to time.Time) (map[string]interface{}, error) {
+	cur, err := clickEvents.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"code": bson.M{"$in": codes}, "timestamp": bson.M{"$gte": from, "$lt": to}}},
+		bson.M{"$group": bson.M{
+			"_id": bson.M{
+				"code": "$code",
+				"date": bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$timestamp"}},
+			},
+			"clicks": clickCount,
+		}},
+		bson.M{"$sort": bson.D{{Key: "_id.code", Value: 1}, {Key: "_id.date", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ID struct {
+			Code string `bson:"code"`
+			Date string `bson:"date"`
+		} `bson:"_id"`
+		Clicks int64 `bson:"clicks"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	summaries := make(map[string]*analyticsSummary, len(codes))
+	for _, code := range codes {
+		summaries[code] = &analyticsSummary{code: code, from: from, to: to, daily: []DailyClicks{}}
+	}
+	for _, row := range rows {
+		if s, ok := summaries[row.ID.Code]; ok {
+			s.daily = append(s.daily, DailyClicks{Date: row.ID.Date, Clicks: row.Clicks})
+		}
+	}
+	found := make(map[string]interface{}, len(summaries))
+	for code, s := range summaries {
+		found[code] = s
+	}
+	return found, nil
+}
+
+// parseGraphQLTime accepts an RFC 3339 timestamp or a date, which is taken
+// as midnight UTC.
+func parseGraphQLTime(name string, v interface{}) (time.Time, bool, error) {
+	s, _ := v.(string)
+	if s == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", name)
+}
+
+// analyticsWindow reads the from and to arguments: by default the last
+// graphQLDefaultRange. Minutes are truncated so that fields asking for the
+// default window share a loader.
+func analyticsWindow(args map[string]interface{}) (time.Time, time.Time, error) {
+	to, ok, err := parseGraphQLTime("to", args["to"])
+	if err != nil {
+		return to, to, err
+	}
+	if !ok {
+		to = time.Now().UTC().Truncate(time.Minute).Add(time.Minute)
+	}
+	from, ok, err := parseGraphQLTime("from", args["from"])
+	if err != nil {
+		return from, to, err
+	}
+	if !ok {
+		from = to.Add(-graphQLDefaultRange)
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+func graphQLInternal(what string, err error) error {
+	log.Printf("GraphQL: failed to %s: %v", what, err)
+	return errors.New("failed to " + what)
+}
+
+var analyticsArgs = graphql.FieldConfigArgument{
+	"from": &graphql.ArgumentConfig{Type: graphql.String, Description: "RFC 3339 timestamp or YYYY-MM-DD; defaults to 30 days before to."},
+	"to":   &graphql.ArgumentConfig{Type: graphql.String, Description: "RFC 3339 timestamp or YYYY-MM-DD, exclusive; defaults to now."},
+}
+
+var clickEventType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ClickEvent",
+	Fields: graphql.Fields{
+		"timestamp":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: clickField(func(e ClickEvent) interface{} { return e.Timestamp })},
+		"referrer":     &graphql.Field{Type: graphql.String, Resolve: clickField(func(e ClickEvent) interface{} { return e.Referrer })},
+		"userAgent":    &graphql.Field{Type: graphql.String, Description: "Requires admin credentials.", Resolve: adminOnlyField(clickField(func(e ClickEvent) interface{} { return e.UserAgent }))},
+		"country":      &graphql.Field{Type: graphql.String, Resolve: clickField(func(e ClickEvent) interface{} { return e.Country })},
+		"bot":          &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Resolve: clickField(func(e ClickEvent) interface{} { return e.Bot })},
+		"source":       &graphql.Field{Type: graphql.String, Resolve: clickField(func(e ClickEvent) interface{} { return e.Source })},
+		"redirectType": &graphql.Field{Type: graphql.Int, Resolve: clickField(func(e ClickEvent) interface{} { return e.RedirectType })},
+	},
+})
+
+var errGraphQLAdmin = errors.New("admin credentials are required")
+
+type graphQLAdminKey struct{}
+
+// adminOnlyField restricts resolve to requests that carry admin
+// credentials besides the API key, which graphQLHandler records in the
+// context.
+func adminOnlyField(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if admin, _ := p.Context.Value(graphQLAdminKey{}).(bool); !admin {
+			return nil, errGraphQLAdmin
+		}
+		return resolve(p)
+	}
+}
+
+func clickField(f func(ClickEvent) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return f(p.Source.(ClickEvent)), nil
+	}
+}
+
+var dailyClicksType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "DailyClicks",
+	Fields: graphql.Fields{
+		"date":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"clicks": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var analyticsSummaryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AnalyticsSummary",
+	Fields: graphql.Fields{
+		"code": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(*analyticsSummary).code, nil
+		}},
+		"from": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(*analyticsSummary).from, nil
+		}},
+		"to": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(*analyticsSummary).to, nil
+		}},
+		"clicks": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "Clicks within the window.", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			var n int64
+			for _, d := range p.Source.(*analyticsSummary).daily {
+				n += d.Clicks
+			}
+			return n, nil
+		}},
+		"daily": &graphql.Field{Type: graphql.NewList(dailyClicksType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(*analyticsSummary).daily, nil
+		}},
+		// Events are loaded per summary, so ask for them on one code rather
+		// than across a list.
+		"events": &graphql.Field{
+			Type:        graphql.NewList(clickEventType),
+			Description: "The latest clicks within the window, newest first.",
+			Args: graphql.FieldConfigArgument{
+				"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				s := p.Source.(*analyticsSummary)
+				limit, _ := p.Args["limit"].(int)
+				if limit < 1 || limit > graphQLMaxEvents {
+					return nil, fmt.Errorf("limit must be between 1 and %d", graphQLMaxEvents)
+				}
+				cur, err := clickEvents.Find(p.Context,
+					bson.M{"code": s.code, "timestamp": bson.M{"$gte": s.from, "$lt": s.to}},
+					options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(int64(limit)),
+				)
+				if err != nil {
+					return nil, graphQLInternal("load click events", err)
+				}
+				events := []ClickEvent{}
+				if err := cur.All(p.Context, &events); err != nil {
+					return nil, graphQLInternal("load click events", err)
+				}
+				return events, nil
+			},
+		},
+	},
+})
+
+var shortURLType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ShortURL",
+	Fields: graphql.Fields{
+		"code":        &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: mappingField(func(m URLMapping) interface{} { return m.Code })},
+		"url":         &graphql.Field{Type: graphql.String, Resolve: mappingField(func(m URLMapping) interface{} { return m.URL })},
+		"targetCode":  &graphql.Field{Type: graphql.String, Resolve: mappingField(func(m URLMapping) interface{} { return m.TargetCode })},
+		"tags":        &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: mappingField(func(m URLMapping) interface{} { return m.Tags })},
+		"clicks":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: mappingField(func(m URLMapping) interface{} { return m.Clicks })},
+		"impressions": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: mappingField(func(m URLMapping) interface{} { return m.Impressions })},
+		"createdAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: mappingField(func(m URLMapping) interface{} { return m.CreatedAt })},
+		"expiresAt":   &graphql.Field{Type: graphql.DateTime, Resolve: mappingField(func(m URLMapping) interface{} { return m.ExpiresAt })},
+		"disabled":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Resolve: mappingField(func(m URLMapping) interface{} { return m.Disabled })},
+		"publicStats": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Resolve: mappingField(func(m URLMapping) interface{} { return m.PublicStats })},
+		"analytics": &graphql.Field{
+			Type: analyticsSummaryType,
+			Args: analyticsArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				from, to, err := analyticsWindow(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				return loadersFrom(p.Context).analyticsLoader(from, to).load(p.Context, p.Source.(URLMapping).Code), nil
+			},
+		},
+	},
+})
+
+// ShortURL.target refers back to ShortURL, so it is added once the type
+// exists.
+func init() {
+	shortURLType.AddFieldConfig("target", &graphql.Field{
+		Type:        shortURLType,
+		Description: "The short URL that targetCode points to.",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			m := p.Source.(URLMapping)
+			if m.TargetCode == "" {
+				return nil, nil
+			}
+			return loadersFrom(p.Context).targets.load(p.Context, m.TargetCode), nil
+		},
+	})
+}
+
+func mappingField(f func(URLMapping) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return f(p.Source.(URLMapping)), nil
+	}
+}
+
+// lookupMapping resolves a code argument to its mapping, or to null.
+func lookupMapping(p graphql.ResolveParams) (interface{}, error) {
+	code := normalizeCode(p.Args["code"].(string))
+	var m URLMapping
+	err := collection.FindOne(p.Context, codeFilter(code)).Decode(&m)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphQLInternal("load short URL", err)
+	}
+	return m, nil
+}
+
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		if s, ok := s.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+var graphQLQuery = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"shortURL": &graphql.Field{
+			Type:    shortURLType,
+			Args:    graphql.FieldConfigArgument{"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: lookupMapping,
+		},
+		"shortURLs": &graphql.Field{
+			Type:        graphql.NewList(shortURLType),
+			Description: "Short URLs with all of tags, newest first.",
+			Args: graphql.FieldConfigArgument{
+				"tags":    &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)},
+				"page":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+				"perPage": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPerPage},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				page, _ := p.Args["page"].(int)
+				if page < 1 {
+					page = 1
+				}
+				perPage, _ := p.Args["perPage"].(int)
+				if perPage < 1 || perPage > maxPerPage {
+					perPage = defaultPerPage
+				}
+				filter := bson.M{}
+				if tags := stringList(p.Args["tags"]); len(tags) > 0 {
+					filter["tags"] = bson.M{"$all": tags}
+				}
+				cur, err := collection.Find(p.Context, filter, options.Find().
+					SetSort(bson.D{{Key: "created_at", Value: -1}}).
+					SetSkip(int64((page-1)*perPage)).
+					SetLimit(int64(perPage)))
+				if err != nil {
+					return nil, graphQLInternal("list short URLs", err)
+				}
+				urls := []URLMapping{}
+				if err := cur.All(p.Context, &urls); err != nil {
+					return nil, graphQLInternal("list short URLs", err)
+				}
+				return urls, nil
+			},
+		},
+		"analytics": &graphql.Field{
+			Type: analyticsSummaryType,
+			Args: graphql.FieldConfigArgument{
+				"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"from": analyticsArgs["from"],
+				"to":   analyticsArgs["to"],
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				from, to, err := analyticsWindow(p.Args)
+				if err != nil {
+					return nil, err
+				}
+				m, err := lookupMapping(p)
+				if m == nil || err != nil {
+					return nil, err
+				}
+				return loadersFrom(p.Context).analyticsLoader(from, to).load(p.Context, m.(URLMapping).Code), nil
+			},
+		},
+	},
+})
+
+var graphQLMutation = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"createShortURL": &graphql.Field{
+			Type: shortURLType,
+			Args: graphql.FieldConfigArgument{
+				"url":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"code":      &graphql.ArgumentConfig{Type: graphql.String},
+				"tags":      &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)},
+				"expiresAt": &graphql.ArgumentConfig{Type: graphql.String, Description: "RFC 3339 timestamp."},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if !isReady() {
+					return nil, errors.New("server is starting up, try again shortly")
+				}
+				dest, err := validateDestinationURL(p.Args["url"].(string))
+				if err != nil {
+					return nil, err
+				}
+				m := URLMapping{URL: dest, Tags: mergeTags(nil, stringList(p.Args["tags"]))}
+				if code, _ := p.Args["code"].(string); code != "" {
+					if err := validateCode(code); err != nil {
+						return nil, err
+					}
+					m.Code = code
+				}
+				if v, ok := p.Args["expiresAt"].(string); ok {
+					t, err := time.Parse(time.RFC3339, v)
+					if err != nil || !t.After(time.Now()) {
+						return nil, errors.New("expiresAt must be an RFC 3339 timestamp in the future")
+					}
+					m.ExpiresAt = &t
+				}
+				created, err := createShortURL(p.Context, m)
+				if err != nil {
+					if errors.Is(err, errCodeTaken) {
+						return nil, err
+					}
+					return nil, graphQLInternal("save short URL", err)
+				}
+				return created, nil
+			},
+		},
+		"updateShortURL": &graphql.Field{
+			Type:        shortURLType,
+			Description: "Changes the given fields; an empty tags list removes the tags. Returns null for an unknown code. Requires admin credentials.",
+			Args: graphql.FieldConfigArgument{
+				"code":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"url":      &graphql.ArgumentConfig{Type: graphql.String},
+				"tags":     &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)},
+				"disabled": &graphql.ArgumentConfig{Type: graphql.Boolean},
+			},
+			Resolve: adminOnlyField(func(p graphql.ResolveParams) (interface{}, error) {
+				set := bson.M{}
+				unset := bson.M{}
+				if v, ok := p.Args["url"].(string); ok {
+					dest, err := validateDestinationURL(v)
+					if err != nil {
+						return nil, err
+					}
+					set["url"] = dest
+					set["content_hash"] = contentHash(dest)
+					unset["target_code"] = ""
+					unset["health_history"] = ""
+				}
+				if _, ok := p.Args["tags"]; ok {
+					if tags := stringList(p.Args["tags"]); len(tags) == 0 {
+						unset["tags"] = ""
+					} else {
+						set["tags"] = mergeTags(nil, tags)
+					}
+				}
+				if v, ok := p.Args["disabled"].(bool); ok {
+					set["disabled"] = v
+				}
+				if len(set) == 0 && len(unset) == 0 {
+					return nil, errors.New("nothing to update")
+				}
+				code := normalizeCode(p.Args["code"].(string))
+				updated, err := updateShortURL(p.Context, code, graphQLActor, set, unset)
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, graphQLInternal("update short URL", err)
+				}
+				return updated, nil
+			}),
+		},
+		"deleteShortURL": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "Deletes a short URL and its click events for good; false if there was none. Requires admin credentials.",
+			Args:        graphql.FieldConfigArgument{"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+			Resolve: adminOnlyField(func(p graphql.ResolveParams) (interface{}, error) {
+				found, err := deleteShortURL(p.Context, normalizeCode(p.Args["code"].(string)))
+				if err != nil {
+					return nil, graphQLInternal("delete short URL", err)
+				}
+				return found, nil
+			}),
+		},
+	},
+})
+
+var graphQLSchema = func() graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: graphQLQuery, Mutation: graphQLMutation})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}()
+
+// graphQLHandler serves POST /graphql with a JSON body of query, variables
+// and operationName. The endpoint sits behind an
+// API key for queries as well as mutations, since it exposes analytics
+// that the REST API keeps behind admin auth. Changing or deleting existing
+// URLs and reading visitors' user agents also take admin credentials, as
+// on the REST API. Errors are reported in the response body with status
+// 200, as GraphQL clients expect.
+func graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query         string                 `json:"query"`
+		Variables     map[string]interface{} `json:"variables"`
+		OperationName string                 `json:"operationName"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Query == "" {
+		http.Error(w, "query is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphQLLoadersKey{}, newGraphQLLoaders())
+	ctx = context.WithValue(ctx, graphQLAdminKey{}, isAdmin(r))
+	res := graphql.Do(graphql.Params{
+		Schema:         graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,87 @@
+package urlshortener
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+const maxCustomHeaders = 20
+
+// allowedCustomHeaders are the standard headers creators may set on
+// redirect responses, in addition to CUSTOM_HEADERS_ALLOWED. Other
+// headers aren't allowed since proxies in front of the server may act on
+// them, like X-Accel-Redirect or X-Forwarded-For.
+var allowedCustomHeaders = map[string]bool{
+	"Cache-Control":   true,
+	"Link":            true,
+	"Referrer-Policy": true,
+	"Vary":            true,
+}
+
+// customHeaderAllowed reports whether creators may set name, which is in
+// canonical form. Location, Set-Cookie and the Content- headers stay under
+// the redirect logic's control even if configured.
+func customHeaderAllowed(name string) bool {
+	if name == "Location" || name == "Set-Cookie" || strings.HasPrefix(name, "Content-") {
+		return false
+	}
+	return allowedCustomHeaders[name] || slices.Contains(cfg.CustomHeadersAllowed, name)
+}
+
+// validateCustomHeaders checks h against the allowlist and returns it with
+// canonical header names. An empty map comes back as nil.
+func validateCustomHeaders(h map[string]string) (map[string]string, error) {
+	if len(h) == 0 {
+		return nil, nil
+	}
+	if len(h) > maxCustomHeaders {
+		return nil, fmt.Errorf("at most %d custom headers are allowed", maxCustomHeaders)
+	}
+	out := make(map[string]string, len(h))
+	for name, value := range h {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if !customHeaderAllowed(name) {
+			return nil, fmt.Errorf("header %s cannot be customised", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("invalid value for header %s", name)
+		}
+		out[name] = value
+	}
+	return out, nil
+}
+
+// parseHeaderLines reads "Name: value" lines from the HTML form.
+func parseHeaderLines(s string) (map[string]string, error) {
+	h := map[string]string{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, errors.New("custom headers must be one \"Name: value\" per line")
+		}
+		h[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return validateCustomHeaders(h)
+}
+
+// applyCustomHeaders sets h on a redirect response, skipping headers that
+// were allowed when they were saved but no longer are.
+func applyCustomHeaders(w http.ResponseWriter, h map[string]string) {
+	for name, value := range h {
+		if customHeaderAllowed(name) {
+			w.Header().Set(name, value)
+		}
+	}
+}
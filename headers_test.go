@@ -0,0 +1,59 @@
+package urlshortener
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateCustomHeaders(t *testing.T) {
+	saved := cfg.CustomHeadersAllowed
+	cfg.CustomHeadersAllowed = []string{"X-Campaign", "X-Robots-Tag", "Location"}
+	t.Cleanup(func() { cfg.CustomHeadersAllowed = saved })
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   string // canonical name, "" when refused
+	}{
+		{"standard", "cache-control", "no-store", "Cache-Control"},
+		{"link", "Link", `</a>; rel="preload"`, "Link"},
+		{"configured", "x-campaign", "summer", "X-Campaign"},
+		{"configured robots", "X-Robots-Tag", "noindex", "X-Robots-Tag"},
+		{"unlisted X-", "X-Custom", "a", ""},
+		{"X-Accel-Redirect", "X-Accel-Redirect", "/internal/secret", ""},
+		{"X-Sendfile", "X-Sendfile", "/etc/passwd", ""},
+		{"X-Forwarded-For", "X-Forwarded-For", "127.0.0.1", ""},
+		{"X-Frame-Options", "X-Frame-Options", "ALLOWALL", ""},
+		{"configured Location", "Location", "https://evil.example/", ""},
+		{"Set-Cookie", "Set-Cookie", "a=b", ""},
+		{"Content-Type", "Content-Type", "text/html", ""},
+		{"invalid name", "Bad Header", "a", ""},
+		{"invalid value", "X-Campaign", "a\r\nSet-Cookie: a=b", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateCustomHeaders(map[string]string{tt.header: tt.value})
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("validateCustomHeaders(%q: %q) = %v, want refused", tt.header, tt.value, got)
+				}
+				return
+			}
+			if err != nil || got[tt.want] != tt.value {
+				t.Errorf("validateCustomHeaders(%q: %q) = %v, %v; want %s set", tt.header, tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCustomHeadersSkipsDisallowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	applyCustomHeaders(w, map[string]string{"Cache-Control": "no-store", "X-Accel-Redirect": "/internal/secret"})
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	if got := w.Header().Get("X-Accel-Redirect"); got != "" {
+		t.Errorf("X-Accel-Redirect = %q, want it skipped", got)
+	}
+}
@@ -0,0 +1,57 @@
+package urlshortener
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+type healthResponse struct {
+	Status       string     `json:"status"`
+	MongoDB      string     `json:"mongodb"`
+	LastVacuumAt *time.Time `json:"last_vacuum_at,omitempty"`
+	IndexHealth  string     `json:"index_health,omitempty"`
+}
+
+// healthHandler reports readiness and MongoDB reachability. It answers 503
+// while the server is warming up or the database is unreachable, but not
+// for index problems, which only slow it down.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{Status: readyState.Load().(string), MongoDB: "ok", IndexHealth: indexHealth()}
+	if t := lastVacuumAt(); !t.IsZero() {
+		resp.LastVacuumAt = &t
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if cfg.DryRun {
+		// Nothing is stored there to be unreachable.
+		resp.MongoDB = "dry-run"
+	} else if err := client.Ping(ctx, nil); err != nil {
+		resp.MongoDB = err.Error()
+	}
+
+	status := http.StatusOK
+	if resp.Status != stateReady || (resp.MongoDB != "ok" && !cfg.DryRun) {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// pingMiddleware answers GET /ping itself, ahead of the router and every
+// middleware but recovery, for load balancer probes that only need to know
+// the server accepts connections. Unlike /healthz it checks nothing, so it
+// answers 200 during warm-up and while MongoDB is down.
+func pingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ping" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Set("Content-Type", "text/plain")
+		h.Set("Cache-Control", "no-store")
+		io.WriteString(w, "pong")
+	})
+}
@@ -0,0 +1,119 @@
+package urlshortener
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// RedirectHook lets custom code rewrite or pin the destination of a redirect
+// without touching redirectHandler. Register hooks from an init function in
+// a file compiled into the binary, such as one next to cmd/server's main:
+//
+//	func init() { urlshortener.AddRedirectHook(myHook{}) }
+//
+// Hooks run in registration order, after the built-in traffic split,
+// campaign and device routing hooks. Each receives the destination produced
+// by the previous hook; returning abort stops the chain with newURL as the
+// final destination. A hook that returns an error is logged and skipped.
+type RedirectHook interface {
+	BeforeRedirect(ctx context.Context, code, url string, r *http.Request) (newURL string, abort bool, err error)
+}
+
+// RedirectHookFunc adapts a plain function to the RedirectHook interface.
+type RedirectHookFunc func(ctx context.Context, code, url string, r *http.Request) (string, bool, error)
+
+func (f RedirectHookFunc) BeforeRedirect(ctx context.Context, code, url string, r *http.Request) (string, bool, error) {
+	return f(ctx, code, url, r)
+}
+
+type Server struct {
+	mu    sync.RWMutex
+	hooks []RedirectHook
+}
+
+var server = &Server{hooks: []RedirectHook{
+	builtinHook{"split", splitHook},
+	builtinHook{"campaign", campaignHook},
+	builtinHook{"device", deviceHook},
+}}
+
+// AddRedirectHook adds hook to the end of the server's redirect chain.
+func AddRedirectHook(hook RedirectHook) {
+	server.AddRedirectHook(hook)
+}
+
+func (s *Server) AddRedirectHook(hook RedirectHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *Server) runRedirectHooks(ctx context.Context, code, url string, r *http.Request) string {
+	s.mu.RLock()
+	hooks := s.hooks
+	s.mu.RUnlock()
+
+	for _, hook := range hooks {
+		before := traceLen(ctx)
+		newURL, abort, err := hook.BeforeRedirect(ctx, code, url, r)
+		explained := traceLen(ctx) > before
+
+		if err != nil {
+			loggerFrom(ctx).WarnContext(ctx, "Redirect hook failed", "hook", hookName(hook), "error", err.Error())
+			if !explained {
+				traceRule(ctx, hookName(hook), "error")
+			}
+			continue
+		}
+		if !explained {
+			if newURL != "" && newURL != url {
+				traceRule(ctx, hookName(hook), newURL)
+			} else {
+				traceRule(ctx, hookName(hook), "skip")
+			}
+		}
+		if newURL != "" {
+			url = newURL
+		}
+		if abort {
+			break
+		}
+	}
+	return url
+}
+
+// builtinHook is one of the server's own rules on the hook chain, traced
+// under its name.
+type builtinHook struct {
+	name string
+	RedirectHookFunc
+}
+
+func (h builtinHook) Name() string { return h.name }
+
+// redirectState is what redirectHandler hands the built-in hooks about the
+// redirect in progress, and what they decide besides the destination.
+type redirectState struct {
+	mapping URLMapping
+	split   *TrafficSplit
+	// bot and preview rule out opening an app.
+	bot     bool
+	preview bool
+
+	splitSide string
+	platform  string
+}
+
+type redirectStateKey struct{}
+
+func withRedirectState(ctx context.Context, st *redirectState) context.Context {
+	return context.WithValue(ctx, redirectStateKey{}, st)
+}
+
+// redirectStateFrom returns the state redirectHandler attached to ctx, or
+// nil when the hooks run outside of it.
+func redirectStateFrom(ctx context.Context) *redirectState {
+	st, _ := ctx.Value(redirectStateKey{}).(*redirectState)
+	return st
+}
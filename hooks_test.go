@@ -0,0 +1,128 @@
+package urlshortener
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// withRedirectHooks registers hooks for the test, removing them again when
+// it finishes.
+func withRedirectHooks(t *testing.T, hooks ...RedirectHook) {
+	t.Helper()
+	server.mu.RLock()
+	saved := server.hooks
+	server.mu.RUnlock()
+	t.Cleanup(func() {
+		server.mu.Lock()
+		server.hooks = saved
+		server.mu.Unlock()
+	})
+	for _, hook := range hooks {
+		AddRedirectHook(hook)
+	}
+}
+
+func TestRedirectHooks(t *testing.T) {
+	srv := newTestServer(t)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	split := mustCreate(t, URLMapping{
+		URL:          "https://example.com/unsplit",
+		TrafficSplit: &TrafficSplit{URLA: "https://a.example/", URLB: "https://b.example/", PctA: 100},
+	})
+	aborted := mustCreate(t, URLMapping{URL: "https://example.com/aborted"})
+	app := mustCreate(t, URLMapping{URL: "https://example.com/app", AppDeepLink: "https://app.example/open"})
+
+	var seen []string
+	withRedirectHooks(t,
+		RedirectHookFunc(func(ctx context.Context, code, url string, r *http.Request) (string, bool, error) {
+			seen = append(seen, url)
+			if code == aborted.Code {
+				return "https://example.com/pinned", true, nil
+			}
+			return url + "?hooked=1", false, nil
+		}),
+		RedirectHookFunc(func(ctx context.Context, code, url string, r *http.Request) (string, bool, error) {
+			if code == aborted.Code {
+				t.Errorf("hook after an abort ran for %s", code)
+			}
+			return "", false, nil
+		}),
+	)
+
+	tests := []struct {
+		name         string
+		code         string
+		userAgent    string
+		wantSeen     string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"split before custom hooks", split.Code, "", "https://a.example/", http.StatusFound, "https://a.example/?hooked=1"},
+		{"abort stops the chain", aborted.Code, "", "https://example.com/aborted", http.StatusFound, "https://example.com/pinned"},
+		{"desktop gets the web destination", app.Code, "Mozilla/5.0 (X11; Linux x86_64)", "https://example.com/app", http.StatusFound, "https://example.com/app?hooked=1"},
+		{"device routing opens the app", app.Code, "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", "https://example.com/app", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/"+tt.code, nil)
+			if tt.userAgent != "" {
+				req.Header.Set("User-Agent", tt.userAgent)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if len(seen) != 1 || seen[0] != tt.wantSeen {
+				t.Errorf("custom hook saw %q, want [%q]", seen, tt.wantSeen)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(string(body), "https://app.example/open") {
+				t.Errorf("deep link page doesn't open the app:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestDeviceHook(t *testing.T) {
+	const iPhone = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"
+	app := URLMapping{URL: "https://example.com/", AppDeepLink: "myapp://open"}
+	tests := []struct {
+		name      string
+		st        redirectState
+		userAgent string
+		want      string
+	}{
+		{"iPhone", redirectState{mapping: app}, iPhone, platformIOS},
+		{"desktop", redirectState{mapping: app}, "Mozilla/5.0 (X11; Linux x86_64)", ""},
+		{"no deep link", redirectState{mapping: URLMapping{URL: "https://example.com/"}}, iPhone, ""},
+		{"bot", redirectState{mapping: app, bot: true}, iPhone, ""},
+		{"preview", redirectState{mapping: app, preview: true}, iPhone, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			st := tt.st
+			url, abort, err := deviceHook(withRedirectState(context.Background(), &st), "code", app.URL, r)
+			if url != "" || abort || err != nil {
+				t.Errorf("deviceHook = %q, %v, %v; want it to leave the destination alone", url, abort, err)
+			}
+			if st.platform != tt.want {
+				t.Errorf("platform = %q, want %q", st.platform, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	impersonationTTL = 15 * time.Minute
+
+	// auditImpersonated is the audit_log event of every request made with
+	// an impersonation token.
+	auditImpersonated = "impersonated_request"
+)
+
+// impersonationClaims are carried by the tokens that
+// POST /api/v1/admin/impersonate/{user_id} issues. Subject is the user;
+// Admin is whether the user may use the admin API, as on their own login.
+type impersonationClaims struct {
+	ImpersonatedBy string `json:"impersonated_by"`
+	Admin          bool   `json:"admin,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type impersonationKey struct{}
+
+// impersonation returns the claims of r's impersonation token, if it was
+// made with one.
+func impersonation(r *http.Request) (*impersonationClaims, bool) {
+	c, ok := r.Context().Value(impersonationKey{}).(*impersonationClaims)
+	return c, ok
+}
+
+// requestActor names who is making r, for audit_log: the impersonated user
+// and the admin behind them, or else the admin account.
+func requestActor(r *http.Request) string {
+	if c, ok := impersonation(r); ok {
+		return fmt.Sprintf("%s (impersonated by %s)", c.Subject, c.ImpersonatedBy)
+	}
+	return cfg.AdminUser
+}
+
+// impersonationMiddleware authenticates requests carrying an impersonation
+// token as a bearer token and records each in audit_log, under the code
+// it concerns if the path names one. A token that doesn't verify is
+// answered with 401 rather than ignored, so a client doesn't silently
+// fall back to acting as nobody.
+func impersonationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, err := parseImpersonationToken(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+
+		rec := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(context.WithValue(r.Context(), impersonationKey{}, claims))
+		next.ServeHTTP(rec, r)
+		recordAudit(r.PathValue("code"), auditImpersonated, requestActor(r),
+			fmt.Sprintf("%s %s: %d", r.Method, r.URL.Path, rec.status))
+	})
+}
+
+// statusWriter remembers the status of the response for the audit entry.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming handlers working behind the middleware.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func parseImpersonationToken(token string) (*impersonationClaims, error) {
+	if cfg.JWTSecret == "" {
+		return nil, errors.New("impersonation is disabled")
+	}
+	claims := &impersonationClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ImpersonatedBy == "" {
+		return nil, errors.New("not an impersonation token")
+	}
+	return claims, nil
+}
+
+// adminIdentity names the admin authorised for r by requireAdmin: the
+// user of their session, or else the Basic Auth account.
+func adminIdentity(r *http.Request) string {
+	if s, ok := currentSession(r); ok && s.Admin {
+		return s.UserID
+	}
+	return cfg.AdminUser
+}
+
+// impersonateHandler issues a token that acts as {user_id} for
+// impersonationTTL. Impersonated sessions can't impersonate in turn.
+func impersonateHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.JWTSecret == "" {
+		http.Error(w, "Impersonation is disabled: JWT_SECRET is not set", http.StatusServiceUnavailable)
+		return
+	}
+	if _, ok := impersonation(r); ok {
+		http.Error(w, "An impersonated session can't impersonate", http.StatusForbidden)
+		return
+	}
+
+	var user User
+	err := users.FindOne(r.Context(), bson.M{"_id": r.PathValue("user_id")}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to load user %s: %v", r.PathValue("user_id"), err)
+		http.Error(w, "Failed to impersonate user", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	admin := adminIdentity(r)
+	claims := impersonationClaims{
+		ImpersonatedBy: admin,
+		Admin:          isOAuthAdmin(user.Email),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(impersonationTTL)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSecret))
+	if err != nil {
+		log.Printf("Failed to sign impersonation token: %v", err)
+		http.Error(w, "Failed to impersonate user", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("%s is impersonating user %s until %s", admin, user.ID, claims.ExpiresAt.Format(time.RFC3339))
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_at": claims.ExpiresAt.Time,
+	})
+}
+
+type meResponse struct {
+	UserID         string     `json:"user_id"`
+	Admin          bool       `json:"admin"`
+	Impersonated   bool       `json:"impersonated"`
+	ImpersonatedBy string     `json:"impersonated_by,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// meHandler describes the caller: an impersonation token, a session, or
+// the Basic Auth admin account. Admin is only reported, and admin
+// credentials only checked, for clients in ADMIN_ALLOWED_CIDRS, as
+// isAdmin does, so the route can't be used to guess the password from
+// elsewhere.
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	allowed := adminClientAllowed(r)
+	if c, ok := impersonation(r); ok {
+		writeJSON(w, http.StatusOK, meResponse{
+			UserID:         c.Subject,
+			Admin:          c.Admin && allowed,
+			Impersonated:   true,
+			ImpersonatedBy: c.ImpersonatedBy,
+			ExpiresAt:      &c.ExpiresAt.Time,
+		})
+		return
+	}
+	if s, ok := currentSession(r); ok {
+		writeJSON(w, http.StatusOK, meResponse{UserID: s.UserID, Admin: s.Admin && allowed, ExpiresAt: &s.ExpiresAt})
+		return
+	}
+	if !allowed {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if user, ok := adminBasicAuth(r); ok {
+		writeJSON(w, http.StatusOK, meResponse{UserID: user, Admin: true})
+		return
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
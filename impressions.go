@@ -0,0 +1,49 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// impressionBatchSize bounds the $in list of one impressions update, so a
+// long home page listing doesn't build a single huge query.
+const impressionBatchSize = 1000
+
+// recordImpressions counts one impression for each code, meaning the short
+// URL was shown in the home page listing or an API list response. Like
+// clicks, the counter only lives in MongoDB.
+func recordImpressions(codes []string) {
+	if cfg.DryRun {
+		for _, code := range codes {
+			shortURLs.Update(code, func(m *URLMapping) { m.Impressions++ })
+		}
+		return
+	}
+	for len(codes) > 0 {
+		batch := codes
+		if len(batch) > impressionBatchSize {
+			batch = batch[:impressionBatchSize]
+		}
+		codes = codes[len(batch):]
+
+		_, err := collection.UpdateMany(context.Background(),
+			bson.M{"code": bson.M{"$in": batch}},
+			bson.M{"$inc": bson.M{"impressions": 1}},
+		)
+		if err != nil {
+			log.Printf("Error recording impressions for %d URLs: %v", len(batch), err)
+			return
+		}
+	}
+}
+
+// clickThroughRate is clicks per impression, or 0 before the first
+// impression.
+func clickThroughRate(clicks, impressions int64) float64 {
+	if impressions == 0 {
+		return 0
+	}
+	return float64(clicks) / float64(impressions)
+}
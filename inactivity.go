@@ -0,0 +1,276 @@
+package urlshortener
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	inactivitySweepInterval = 24 * time.Hour
+
+	// inactivityGrace is how long an owner has to answer the notice
+	// before the URL is disabled.
+	inactivityGrace = 7 * 24 * time.Hour
+)
+
+var inactivityNoticeTpl = template.Must(template.New("").Parse(`From: {{.From}}
+To: {{.To}}
+Subject: Keep your short URL {{.ShortURL}}?
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+
+Your short URL hasn't been visited in {{.Days}} days.
+
+Short URL:   {{.ShortURL}}
+Destination: {{.Destination}}
+
+It will be disabled on {{.DisableAt.Format "2 January 2006"}} unless you keep it:
+{{.KeepURL}}
+
+A disabled short URL stops redirecting but isn't deleted.
+`))
+
+// startInactivitySweep runs sweepInactiveURLs daily when
+// URL_INACTIVITY_DAYS is set.
+func startInactivitySweep(ctx context.Context) {
+	if cfg.URLInactivityDays == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(inactivitySweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := sweepInactiveURLs(ctx); err != nil {
+					log.Printf("Inactivity sweep failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// sweepInactiveURLs finds short URLs that have had no clicks for
+// URL_INACTIVITY_DAYS since they were created or last kept. Those with an
+// owner or contact email are sent a notice with a link to keep them, and
+// disabled inactivityGrace later if it isn't followed; anonymous ones have
+// nobody to ask and are disabled at once. Disabling frees their cache slot.
+// A URL the sweep disabled is left alone if an admin re-enables it.
+func sweepInactiveURLs(ctx context.Context) error {
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -cfg.URLInactivityDays)
+	cur, err := collection.Find(ctx, bson.M{
+		"clicks":               0,
+		"disabled":             bson.M{"$ne": true},
+		"inactive_disabled_at": bson.M{"$exists": false},
+		"created_at":           bson.M{"$lt": cutoff},
+		"$or": bson.A{
+			bson.M{"kept_active_at": bson.M{"$exists": false}},
+			bson.M{"kept_active_at": bson.M{"$lt": cutoff}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var notified, disabled int
+	for cur.Next(ctx) {
+		var m URLMapping
+		if err := cur.Decode(&m); err != nil {
+			return err
+		}
+		switch {
+		case m.InactivityNoticeAt != nil:
+			if now.Sub(*m.InactivityNoticeAt) < inactivityGrace {
+				continue
+			}
+		case m.OwnerID != "" || m.ContactEmail != "":
+			if err := sendInactivityNotice(ctx, m, now); err != nil {
+				return err
+			}
+			notified++
+			continue
+		}
+		ok, err := disableInactive(ctx, m, now)
+		if err != nil {
+			return err
+		}
+		if ok {
+			disabled++
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	if notified > 0 || disabled > 0 {
+		log.Printf("Inactivity sweep: notified %d owners, disabled %d short URLs", notified, disabled)
+	}
+	return nil
+}
+
+// sendInactivityNotice records that m's owner was asked to keep it and
+// emails them the keep link. The grace period starts either way; without
+// SMTP_HOST or an address, only a click in the meantime keeps the URL.
+func sendInactivityNotice(ctx context.Context, m URLMapping, now time.Time) error {
+	set := bson.M{"inactivity_notice_at": now}
+	if m.ManageToken == "" {
+		token, err := newManageToken()
+		if err != nil {
+			return err
+		}
+		m.ManageToken = token
+		set["manage_token"] = token
+	}
+	if _, err := collection.UpdateOne(ctx, bson.M{"code": m.Code}, bson.M{"$set": set}); err != nil {
+		return err
+	}
+
+	addr := m.ContactEmail
+	if m.OwnerID != "" {
+		var u User
+		err := users.FindOne(ctx, bson.M{"_id": m.OwnerID}).Decode(&u)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Failed to look up the owner of %s: %v", m.Code, err)
+		}
+		if u.Email != "" {
+			addr = u.Email
+		}
+	}
+	if addr == "" || cfg.SMTPHost == "" {
+		log.Printf("Not sending inactivity notice for %s: no address or SMTP_HOST", m.Code)
+		return nil
+	}
+
+	base := cfg.BaseURL
+	var msg bytes.Buffer
+	err := inactivityNoticeTpl.Execute(&msg, struct {
+		From, To    string
+		ShortURL    string
+		Destination string
+		Days        int
+		DisableAt   time.Time
+		KeepURL     string
+	}{cfg.FromEmail, addr, base + m.Path(), displayURL(m.URL), cfg.URLInactivityDays, now.Add(inactivityGrace), base + "/keep/" + m.Code + "?token=" + m.ManageToken})
+	if err != nil {
+		log.Printf("Error rendering inactivity notice for %s: %v", m.Code, err)
+		return nil
+	}
+	go func() {
+		if err := sendMail(addr, msg.Bytes()); err != nil {
+			log.Printf("Failed to send inactivity notice for %s to %s: %v", m.Code, addr, err)
+		}
+	}()
+	return nil
+}
+
+// disableInactive disables m unless it was clicked or kept since the sweep
+// read it, and evicts it from the cache.
+func disableInactive(ctx context.Context, m URLMapping, now time.Time) (bool, error) {
+	filter := bson.M{"code": m.Code, "clicks": 0, "disabled": bson.M{"$ne": true}}
+	if m.InactivityNoticeAt != nil {
+		filter["inactivity_notice_at"] = *m.InactivityNoticeAt
+	}
+	res, err := collection.UpdateOne(ctx, filter, bson.M{
+		"$set":   bson.M{"disabled": true, "inactive_disabled_at": now},
+		"$unset": bson.M{"inactivity_notice_at": ""},
+	})
+	if err != nil || res.ModifiedCount == 0 {
+		return false, err
+	}
+	shortURLs.Delete(m.Code)
+	m.Disabled = true
+	publishMutation(replicateUpsert, m)
+	recordAudit(m.Code, auditDisabled, "inactivity sweep", "")
+	return true, nil
+}
+
+var keepTpl = htmltemplate.Must(htmltemplate.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Keep short URL</title>
+</head>
+<body>
+    {{if .Done}}
+    <p>The short URL <code>{{.Path}}</code> will be kept active.</p>
+    {{else}}
+    <p>Keep the short URL <code>{{.Path}}</code> active? It won't be disabled for inactivity for another {{.Days}} days.</p>
+    <form method="post">
+        <input type="hidden" name="token" value="{{.Token}}">
+        <button type="submit">Keep</button>
+    </form>
+    {{end}}
+</body>
+</html>
+`))
+
+// keepHandler backs the link in inactivity notices. Like disableHandler,
+// GET only shows a form, and POST with the manage token keeps the URL:
+// the inactivity period starts again, and a URL the sweep has disabled
+// already is re-enabled.
+func keepHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+	token := r.FormValue("token")
+
+	mapping, err := findInMongoDB(code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			notFound(w, r)
+			return
+		}
+		http.Error(w, "Failed to load URL", http.StatusInternalServerError)
+		return
+	}
+	if mapping.ManageToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(mapping.ManageToken)) != 1 {
+		http.Error(w, "Invalid or expired link", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		set := bson.M{"kept_active_at": time.Now()}
+		reenable := mapping.InactiveDisabledAt != nil && mapping.Disabled
+		if reenable {
+			set["disabled"] = false
+		}
+		_, err := collection.UpdateOne(r.Context(), bson.M{"code": code}, bson.M{
+			"$set":   set,
+			"$unset": bson.M{"inactivity_notice_at": "", "inactive_disabled_at": ""},
+		})
+		if err != nil {
+			log.Printf("Failed to keep %s: %v", code, err)
+			http.Error(w, "Failed to keep URL", http.StatusInternalServerError)
+			return
+		}
+		if reenable {
+			mapping.Disabled = false
+			publishMutation(replicateUpsert, mapping)
+			recordAudit(code, auditReenabled, "owner", "kept after the inactivity sweep")
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = keepTpl.Execute(w, struct {
+		Path, Token string
+		Days        int
+		Done        bool
+	}{mapping.Path(), token, cfg.URLInactivityDays, r.Method == http.MethodPost})
+	if err != nil {
+		log.Printf("Error rendering keep page for %s: %v", code, err)
+	}
+}
@@ -0,0 +1,424 @@
+package urlshortener
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// inboundMailbox is the local part mail must be addressed to, as in
+	// shorten@example.com; the domain is whatever MX points here.
+	inboundMailbox = "shorten"
+
+	inboundMaxBytes   = 1 << 20
+	inboundMaxURLs    = 20
+	inboundQueueSize  = 64
+	inboundCmdTimeout = 5 * time.Minute
+)
+
+var inboundURLPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// inboundEmail is a message accepted by the SMTP listener, waiting for
+// processInboundEmails.
+type inboundEmail struct {
+	envelopeFrom string
+	data         []byte
+}
+
+var inboundEmails = make(chan inboundEmail, inboundQueueSize)
+
+var inboundReplyTpl = template.Must(template.New("").Parse(`From: {{.From}}
+To: {{.To}}
+Subject: {{.Subject}}
+{{- with .InReplyTo}}
+In-Reply-To: {{.}}
+{{- end}}
+Auto-Submitted: auto-replied
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+
+{{if .Results -}}
+{{range .Results}}{{.URL}}
+    {{if .Err}}not shortened: {{.Err}}{{else}}{{.ShortURL}}{{end}}
+{{end}}
+{{- else -}}
+No links were found in your message. Send the http or https URLs to shorten
+in the body, up to {{.Max}} per message.
+{{end}}`))
+
+type inboundResult struct {
+	URL, ShortURL, Err string
+}
+
+// startInboundEmail listens on INBOUND_EMAIL_PORT for mail to the shorten
+// mailbox when INBOUND_EMAIL_ENABLED is set. The listener only accepts
+// messages; processInboundEmails shortens their links in the background
+// and replies to the sender.
+func startInboundEmail(ctx context.Context) {
+	if !cfg.InboundEmailEnabled {
+		return
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.InboundEmailPort))
+	if err != nil {
+		log.Fatalf("Failed to listen for inbound email: %v", err)
+	}
+	log.Printf("Accepting mail to %s@ on port %d", inboundMailbox, cfg.InboundEmailPort)
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Inbound email listener stopped: %v", err)
+				}
+				return
+			}
+			go serveSMTP(conn)
+		}
+	}()
+	go processInboundEmails(ctx)
+}
+
+// serveSMTP speaks just enough SMTP to receive mail for inboundMailbox:
+// every other recipient is refused, so the listener can't be used as a
+// relay.
+func serveSMTP(conn net.Conn) {
+	defer conn.Close()
+	c := textproto.NewConn(conn)
+	host, _ := os.Hostname()
+
+	reply := func(format string, args ...any) bool {
+		conn.SetDeadline(time.Now().Add(inboundCmdTimeout))
+		return c.PrintfLine(format, args...) == nil
+	}
+	if !reply("220 %s ESMTP urlshortener", host) {
+		return
+	}
+
+	var from string
+	var haveFrom, haveRcpt bool
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		var ok bool
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			ok = reply("250 %s", host)
+		case "EHLO":
+			ok = reply("250-%s", host) && reply("250-SIZE %d", inboundMaxBytes) && reply("250 8BITMIME")
+		case "MAIL":
+			addr, found := smtpPath(arg, "FROM:")
+			if !found {
+				ok = reply("501 5.5.4 Syntax: MAIL FROM:<address>")
+				break
+			}
+			from, haveFrom, haveRcpt = addr, true, false
+			ok = reply("250 2.1.0 OK")
+		case "RCPT":
+			addr, found := smtpPath(arg, "TO:")
+			switch {
+			case !haveFrom:
+				ok = reply("503 5.5.1 MAIL first")
+			case !found:
+				ok = reply("501 5.5.4 Syntax: RCPT TO:<address>")
+			case !isInboundMailbox(addr):
+				ok = reply("550 5.1.1 No such mailbox")
+			default:
+				haveRcpt = true
+				ok = reply("250 2.1.5 OK")
+			}
+		case "DATA":
+			if !haveRcpt {
+				ok = reply("503 5.5.1 RCPT first")
+				break
+			}
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(c.DotReader(), inboundMaxBytes+1))
+			if err != nil {
+				return
+			}
+			if len(data) > inboundMaxBytes {
+				// Drain the rest so the connection stays in sync.
+				if _, err := io.Copy(io.Discard, c.DotReader()); err != nil {
+					return
+				}
+				ok = reply("552 5.3.4 Message too big")
+			} else {
+				select {
+				case inboundEmails <- inboundEmail{envelopeFrom: from, data: data}:
+					ok = reply("250 2.0.0 Queued")
+				default:
+					ok = reply("451 4.3.1 Too busy, try again later")
+				}
+			}
+			haveFrom, haveRcpt = false, false
+		case "RSET":
+			haveFrom, haveRcpt = false, false
+			ok = reply("250 2.0.0 OK")
+		case "NOOP":
+			ok = reply("250 2.0.0 OK")
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			ok = reply("502 5.5.2 Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// smtpPath extracts the address from a MAIL FROM:<a> or RCPT TO:<a>
+// argument, ignoring any parameters after it. The null path <> is reported
+// as "".
+func smtpPath(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(path, "<") {
+		return "", false
+	}
+	addr, _, found := strings.Cut(path[1:], ">")
+	return addr, found
+}
+
+func isInboundMailbox(addr string) bool {
+	local, _, found := strings.Cut(addr, "@")
+	return found && strings.EqualFold(local, inboundMailbox)
+}
+
+// processInboundEmails handles accepted messages one at a time.
+func processInboundEmails(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-inboundEmails:
+			if err := processInboundEmail(ctx, msg); err != nil {
+				log.Printf("Failed to process inbound email from %s: %v", msg.envelopeFrom, err)
+			}
+		}
+	}
+}
+
+// processInboundEmail shortens the links in the body of msg, owned by the
+// user with the sender's address, and replies with the short URLs. Mail
+// whose sender isn't verified by inboundSenderVerified is dropped without
+// a reply, since the reply would go to whoever the From was forged as;
+// so are bounces and other automatic mail, so two robots can't keep
+// replying to each other.
+func processInboundEmail(ctx context.Context, msg inboundEmail) error {
+	m, err := mail.ReadMessage(bytes.NewReader(msg.data))
+	if err != nil {
+		return err
+	}
+	if msg.envelopeFrom == "" || isAutomatedMail(m.Header) {
+		return nil
+	}
+	from, err := mail.ParseAddress(m.Header.Get("From"))
+	if err != nil {
+		return nil
+	}
+	addr := from.Address
+	if !inboundSenderVerified(m.Header, addr) {
+		log.Printf("Dropped inbound email from %s: no SPF or DKIM pass for its domain from %s", addr, cfg.InboundEmailAuthServ)
+		return nil
+	}
+
+	text, err := inboundText(textproto.MIMEHeader(m.Header), m.Body)
+	if err != nil {
+		return err
+	}
+	urls := extractURLs(text)
+
+	var results []inboundResult
+	if len(urls) > 0 {
+		owner, err := inboundOwner(ctx, addr)
+		if err != nil {
+			return err
+		}
+		for _, raw := range urls {
+			res := inboundResult{URL: raw}
+			dest, err := validateDestinationURL(raw)
+			if err != nil {
+				res.Err = err.Error()
+				results = append(results, res)
+				continue
+			}
+			created, err := createShortURL(ctx, URLMapping{URL: dest, OwnerID: owner})
+			if err != nil {
+				log.Printf("Failed to shorten %s from %s: %v", raw, addr, err)
+				res.Err = "failed to save, try again later"
+			} else {
+				res.ShortURL = cfg.BaseURL + created.Path()
+			}
+			results = append(results, res)
+		}
+	}
+
+	if cfg.SMTPHost == "" {
+		log.Printf("Not replying to inbound email from %s: SMTP_HOST is not set", addr)
+		return nil
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		subject = ""
+	}
+	if subject == "" {
+		subject = "Your short URLs"
+	} else if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	var body bytes.Buffer
+	err = inboundReplyTpl.Execute(&body, struct {
+		From, To, Subject, InReplyTo string
+		Results                      []inboundResult
+		Max                          int
+	}{cfg.FromEmail, addr, mime.QEncoding.Encode("UTF-8", subject), m.Header.Get("Message-Id"), results, inboundMaxURLs})
+	if err != nil {
+		return err
+	}
+	return sendMail(addr, body.Bytes())
+}
+
+// isAutomatedMail reports whether a message was sent by a program, per
+// RFC 3834 or the older Precedence header.
+func isAutomatedMail(h mail.Header) bool {
+	if v := h.Get("Auto-Submitted"); v != "" && !strings.EqualFold(v, "no") {
+		return true
+	}
+	switch strings.ToLower(h.Get("Precedence")) {
+	case "bulk", "list", "junk":
+		return true
+	}
+	return false
+}
+
+// inboundText is the decoded text of a message body: the first text/plain
+// part of a multipart message, or its first text/html part if it has no
+// plain one.
+func inboundText(h textproto.MIMEHeader, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	switch enc := strings.ToLower(h.Get("Content-Transfer-Encoding")); enc {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		if !strings.HasPrefix(mediaType, "text/") {
+			return "", nil
+		}
+		b, err := io.ReadAll(body)
+		return string(b), err
+	}
+
+	var html string
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return html, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		text, err := inboundText(part.Header, part)
+		if err != nil {
+			return "", err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch {
+		case text == "":
+		case strings.HasPrefix(partType, "multipart/"), partType == "text/plain", partType == "":
+			return text, nil
+		case html == "":
+			html = text
+		}
+	}
+}
+
+// extractURLs returns the distinct http(s) URLs in text, in order, without
+// trailing punctuation, at most inboundMaxURLs of them.
+func extractURLs(text string) []string {
+	var urls []string
+	seen := map[string]bool{}
+	for _, u := range inboundURLPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?")
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+		if len(urls) == inboundMaxURLs {
+			break
+		}
+	}
+	return urls
+}
+
+// inboundOwner returns the ID of the user with email addr, creating a
+// user for an address not seen before. addr must be verified, since it
+// may be an OAuth user's. Signing in with OAuth later doesn't link to a
+// user created here: OAuth users are found by identity, not email, and a
+// user without identities is left out of their unique index.
+func inboundOwner(ctx context.Context, addr string) (string, error) {
+	addr = strings.ToLower(addr)
+	var u User
+	err := users.FindOne(ctx, bson.M{"email": addr}).Decode(&u)
+	if err == nil {
+		return u.ID, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", err
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	u = User{
+		ID:        hex.EncodeToString(b),
+		Email:     addr,
+		CreatedAt: time.Now(),
+	}
+	if _, err := users.InsertOne(ctx, u); err != nil {
+		return "", err
+	}
+	return u.ID, nil
+}
@@ -0,0 +1,102 @@
+package urlshortener
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// inboundSenderVerified reports whether the mail server relaying to the
+// listener vouched for the From domain of h: an Authentication-Results
+// header (RFC 8601) with INBOUND_EMAIL_AUTHSERV_ID records a DMARC, DKIM
+// or SPF pass aligned with it. That server must strip headers claiming
+// its authserv-id from the mail it receives, or they could be forged.
+func inboundSenderVerified(h mail.Header, from string) bool {
+	_, domain, found := strings.Cut(strings.ToLower(from), "@")
+	if !found || domain == "" {
+		return false
+	}
+	for _, v := range h["Authentication-Results"] {
+		authserv, results := parseAuthResults(v)
+		if !strings.EqualFold(authserv, cfg.InboundEmailAuthServ) {
+			continue
+		}
+		for _, res := range results {
+			if res.result != "pass" {
+				continue
+			}
+			var d string
+			switch res.method {
+			case "dmarc":
+				d = res.props["header.from"]
+			case "dkim":
+				d = res.props["header.d"]
+				if d == "" {
+					d = res.props["header.i"]
+				}
+			case "spf":
+				d = res.props["smtp.mailfrom"]
+			}
+			if _, after, ok := strings.Cut(d, "@"); ok {
+				d = after
+			}
+			if d != "" && (domain == d || strings.HasSuffix(domain, "."+d)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authResult is one method's result in an Authentication-Results header,
+// with its properties such as header.d, lower-cased.
+type authResult struct {
+	method, result string
+	props          map[string]string
+}
+
+// parseAuthResults splits an Authentication-Results header value into its
+// authserv-id and results. Comments are dropped; a result this doesn't
+// understand is skipped rather than failing the header.
+func parseAuthResults(v string) (string, []authResult) {
+	parts := strings.Split(stripComments(v), ";")
+	fields := strings.Fields(parts[0])
+	if len(fields) == 0 {
+		return "", nil
+	}
+	var results []authResult
+	for _, part := range parts[1:] {
+		fields := strings.Fields(strings.ToLower(part))
+		if len(fields) == 0 {
+			continue
+		}
+		method, result, ok := strings.Cut(fields[0], "=")
+		if !ok {
+			continue
+		}
+		res := authResult{method: method, result: result, props: map[string]string{}}
+		for _, f := range fields[1:] {
+			if k, v, ok := strings.Cut(f, "="); ok {
+				res.props[k] = strings.Trim(v, `"`)
+			}
+		}
+		results = append(results, res)
+	}
+	return fields[0], results
+}
+
+// stripComments removes RFC 5322 comments, which may nest.
+func stripComments(s string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package urlshortener
+
+import (
+	"net/mail"
+	"reflect"
+	"testing"
+)
+
+func TestParseAuthResults(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		wantAuthserv string
+		want         []authResult
+	}{
+		{
+			"dkim and spf",
+			`mx.example.org; dkim=pass header.d=Example.com header.s=sel; spf=fail smtp.mailfrom="user@example.com"`,
+			"mx.example.org",
+			[]authResult{
+				{"dkim", "pass", map[string]string{"header.d": "example.com", "header.s": "sel"}},
+				{"spf", "fail", map[string]string{"smtp.mailfrom": "user@example.com"}},
+			},
+		},
+		{
+			"version and comments",
+			`mx.example.org 1 (relay (nested)); dmarc=pass (p=none) header.from=example.com`,
+			"mx.example.org",
+			[]authResult{{"dmarc", "pass", map[string]string{"header.from": "example.com"}}},
+		},
+		{
+			"unparseable results skipped",
+			`mx.example.org; none; ; dkim=pass header.d=example.com`,
+			"mx.example.org",
+			[]authResult{{"dkim", "pass", map[string]string{"header.d": "example.com"}}},
+		},
+		{"empty", "", "", nil},
+		{"only a comment", "(nothing)", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authserv, got := parseAuthResults(tt.header)
+			if authserv != tt.wantAuthserv || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAuthResults(%q) = %q, %v; want %q, %v", tt.header, authserv, got, tt.wantAuthserv, tt.want)
+			}
+		})
+	}
+}
+
+func TestInboundSenderVerified(t *testing.T) {
+	saved := cfg.InboundEmailAuthServ
+	cfg.InboundEmailAuthServ = "mx.example.org"
+	t.Cleanup(func() { cfg.InboundEmailAuthServ = saved })
+
+	tests := []struct {
+		name    string
+		results []string
+		from    string
+		want    bool
+	}{
+		{"dmarc pass", []string{"mx.example.org; dmarc=pass header.from=example.com"}, "user@example.com", true},
+		{"dkim pass", []string{"mx.example.org; dkim=pass header.d=example.com"}, "User@Example.com", true},
+		{"dkim identity", []string{"mx.example.org; dkim=pass header.i=@example.com"}, "user@example.com", true},
+		{"spf pass", []string{"mx.example.org; spf=pass smtp.mailfrom=bounce@example.com"}, "user@example.com", true},
+		{"subdomain sender", []string{"mx.example.org; dkim=pass header.d=example.com"}, "user@mail.example.com", true},
+		{"authserv-id case", []string{"MX.Example.org; dkim=pass header.d=example.com"}, "user@example.com", true},
+		{"second header", []string{"other.example; dkim=fail header.d=example.com", "mx.example.org; dkim=pass header.d=example.com"}, "user@example.com", true},
+		{"fail", []string{"mx.example.org; dkim=fail header.d=example.com"}, "user@example.com", false},
+		{"unaligned domain", []string{"mx.example.org; dkim=pass header.d=attacker.example"}, "user@example.com", false},
+		{"suffix without dot", []string{"mx.example.org; dkim=pass header.d=ample.com"}, "user@example.com", false},
+		{"parent of sender only", []string{"mx.example.org; dkim=pass header.d=mail.example.com"}, "user@example.com", false},
+		{"other authserv-id", []string{"forged.example; dmarc=pass header.from=example.com"}, "user@example.com", false},
+		{"unknown method", []string{"mx.example.org; arc=pass header.d=example.com"}, "user@example.com", false},
+		{"no header", nil, "user@example.com", false},
+		{"no from domain", []string{"mx.example.org; dkim=pass header.d=example.com"}, "user", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mail.Header{}
+			if tt.results != nil {
+				h["Authentication-Results"] = tt.results
+			}
+			if got := inboundSenderVerified(h, tt.from); got != tt.want {
+				t.Errorf("inboundSenderVerified(%q, %q) = %v, want %v", tt.results, tt.from, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	indexCheckInterval = 24 * time.Hour
+	indexCheckTimeout  = time.Minute
+)
+
+// lastIndexCheck is the outcome of the latest checkIndexes, reported by
+// the health endpoint as index_health.
+var lastIndexCheck struct {
+	sync.Mutex
+	result string
+}
+
+func indexHealth() string {
+	lastIndexCheck.Lock()
+	defer lastIndexCheck.Unlock()
+	return lastIndexCheck.result
+}
+
+// startIndexCheck runs checkIndexes daily in the background; the first
+// check runs once ensureIndexes has succeeded at startup.
+func startIndexCheck(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(indexCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkIndexes(ctx)
+			}
+		}
+	}()
+}
+
+// checkIndexes compares the indexes of every collection against
+// expectedIndexes. Missing indexes are created; extra ones, which slow
+// down writes for nothing, and ones whose options differ are logged for an
+// operator to drop, since one may have been added by hand on purpose.
+func checkIndexes(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, indexCheckTimeout)
+	defer cancel()
+
+	var problems []string
+	for _, spec := range expectedIndexes() {
+		problems = append(problems, checkCollectionIndexes(ctx, spec)...)
+	}
+	result := "ok"
+	if len(problems) > 0 {
+		result = strings.Join(problems, "; ")
+	}
+	lastIndexCheck.Lock()
+	lastIndexCheck.result = result
+	lastIndexCheck.Unlock()
+}
+
+// listedIndex is an entry of listIndexes.
+type listedIndex struct {
+	Name               string `bson:"name"`
+	Key                bson.D `bson:"key"`
+	Unique             bool   `bson:"unique"`
+	ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+}
+
+func checkCollectionIndexes(ctx context.Context, spec indexSpec) []string {
+	name := spec.coll.Name()
+	listed, err := listIndexes(ctx, spec.coll)
+	if err != nil {
+		log.Printf("Failed to list indexes of %s: %v", name, err)
+		return []string{fmt.Sprintf("%s: %v", name, err)}
+	}
+	existing := make(map[string]listedIndex, len(listed))
+	for _, idx := range listed {
+		existing[indexKey(idx.Key)] = idx
+	}
+
+	var problems []string
+	expected := make(map[string]bool, len(spec.models)+1)
+	expected[indexKey(bson.D{{Key: "_id", Value: 1}})] = true
+	for _, model := range spec.models {
+		key := indexKey(model.Keys.(bson.D))
+		expected[key] = true
+		idx, ok := existing[key]
+		if !ok {
+			log.Printf("WARNING: index %s is missing on %s, creating it", key, name)
+			if _, err := spec.coll.Indexes().CreateOne(ctx, model); err != nil {
+				log.Printf("Failed to create index %s on %s: %v", key, name, err)
+				problems = append(problems, fmt.Sprintf("%s: missing index %s", name, key))
+			}
+			continue
+		}
+		if diff := indexOptionsDiff(model, idx); diff != "" {
+			log.Printf("ERROR: index %s on %s %s", idx.Name, name, diff)
+			problems = append(problems, fmt.Sprintf("%s: index %s %s", name, idx.Name, diff))
+		}
+	}
+	for _, idx := range listed {
+		if !expected[indexKey(idx.Key)] {
+			log.Printf("ERROR: unexpected index %s on %s slows down writes; drop it unless it's needed", idx.Name, name)
+			problems = append(problems, fmt.Sprintf("%s: unexpected index %s", name, idx.Name))
+		}
+	}
+	return problems
+}
+
+// listIndexes lists the indexes of coll. A collection that doesn't exist
+// yet has none; creating its indexes creates it.
+func listIndexes(ctx context.Context, coll *mongo.Collection) ([]listedIndex, error) {
+	cur, err := coll.Indexes().List(ctx)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceNotFound" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var listed []listedIndex
+	err = cur.All(ctx, &listed)
+	return listed, err
+}
+
+// indexKey renders an index key specification the way MongoDB names the
+// index, e.g. code_1_timestamp_1, whatever numeric type the server
+// returned the directions in.
+func indexKey(keys bson.D) string {
+	parts := make([]string, 0, 2*len(keys))
+	for _, e := range keys {
+		v := e.Value
+		switch n := v.(type) {
+		case int32:
+			v = int64(n)
+		case float64:
+			v = int64(n)
+		}
+		parts = append(parts, e.Key, fmt.Sprint(v))
+	}
+	return strings.Join(parts, "_")
+}
+
+// indexOptionsDiff describes how idx differs from model in the options
+// that change behaviour: uniqueness and TTL.
+func indexOptionsDiff(model mongo.IndexModel, idx listedIndex) string {
+	var unique bool
+	var ttl *int32
+	if model.Options != nil {
+		unique = model.Options.Unique != nil && *model.Options.Unique
+		ttl = model.Options.ExpireAfterSeconds
+	}
+	switch {
+	case unique && !idx.Unique:
+		return "should be unique"
+	case !unique && idx.Unique:
+		return "shouldn't be unique"
+	case ttl == nil && idx.ExpireAfterSeconds != nil:
+		return "shouldn't expire documents"
+	case ttl != nil && (idx.ExpireAfterSeconds == nil || *idx.ExpireAfterSeconds != *ttl):
+		return fmt.Sprintf("should expire documents after %ds", *ttl)
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package urlshortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	instanceHeartbeat = 30 * time.Second
+
+	// instanceTTL is how long an instance counts as running after its
+	// last heartbeat; the TTL index removes it later.
+	instanceTTL = 2 * time.Minute
+)
+
+// instances holds a heartbeat document per running server, so one
+// instance can tell it isn't alone.
+var instances *mongo.Collection
+
+// instanceID names this process: host and PID are unique among live ones.
+var instanceID = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// startInstanceHeartbeat records this instance every instanceHeartbeat and
+// warns, once, when others are running while rate limits are still kept
+// in memory, since each instance then allows the full limit on its own.
+func startInstanceHeartbeat(ctx context.Context) {
+	go func() {
+		warned := false
+		ticker := time.NewTicker(instanceHeartbeat)
+		defer ticker.Stop()
+		for {
+			now := time.Now()
+			_, err := instances.UpdateOne(ctx,
+				bson.M{"_id": instanceID},
+				bson.M{"$set": bson.M{"last_seen": now}},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				log.Printf("Failed to record instance heartbeat: %v", err)
+			} else if !warned && rateLimiter == nil {
+				others, err := instances.CountDocuments(ctx, bson.M{
+					"_id":       bson.M{"$ne": instanceID},
+					"last_seen": bson.M{"$gt": now.Add(-instanceTTL)},
+				})
+				if err == nil && others > 0 {
+					log.Printf("Warning: %d other instances are running but rate limits are per instance; set RATE_LIMIT_REDIS to share them", others)
+					warned = true
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,60 @@
+package urlshortener
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+const maxDelaySeconds = 60
+
+var interstitialTpl = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta http-equiv="refresh" content="{{.Delay}};url={{.URL}}">
+    <meta name="robots" content="noindex, follow">
+    <title>Redirecting…</title>
+</head>
+<body>
+    <p>You will be redirected to <a href="{{.URL}}">{{.URL}}</a> in <span id="countdown">{{.Delay}}</span> seconds.</p>
+    {{with .AdHTML}}<div class="ad">{{.}}</div>{{end}}
+    <script>
+        (function () {
+            var el = document.getElementById("countdown");
+            var left = {{.Delay}};
+            var timer = setInterval(function () {
+                left--;
+                el.textContent = Math.max(left, 0);
+                if (left <= 0) {
+                    clearInterval(timer);
+                }
+            }, 1000);
+        })();
+    </script>
+</body>
+</html>
+`))
+
+type InterstitialPageVariables struct {
+	URL    string
+	Delay  int
+	AdHTML template.HTML
+}
+
+// renderInterstitial serves the countdown page for mappings with a redirect
+// delay. AdHTML can only be set by an admin, so it is trusted as-is.
+func renderInterstitial(w http.ResponseWriter, m URLMapping) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := interstitialTpl.Execute(w, InterstitialPageVariables{
+		URL:    m.URL,
+		Delay:  m.DelaySeconds,
+		AdHTML: template.HTML(m.AdHTML),
+	})
+	if err != nil {
+		log.Printf("Error rendering interstitial for %s: %v", m.Code, err)
+	}
+}
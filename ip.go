@@ -0,0 +1,79 @@
+package urlshortener
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Trust levels for the X-Forwarded-For and X-Real-IP headers.
+const (
+	trustNever       = "never"
+	trustAlways      = "always"
+	trustPrivateOnly = "private-only"
+)
+
+// realIP returns the most accurate client IP for r. Proxy headers are only
+// honoured as far as cfg.TrustProxy allows: with private-only they are used
+// when the direct peer is on a private network, and public hops listed in
+// X-Forwarded-For are never skipped over.
+func realIP(r *http.Request) string {
+	peer := remoteIP(r)
+
+	switch cfg.TrustProxy {
+	case trustNever:
+		return peer
+	case trustPrivateOnly:
+		if !isPrivateIP(peer) {
+			return peer
+		}
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		if cfg.TrustProxy == trustAlways {
+			if ip := parseIP(hops[0]); ip != "" {
+				return ip
+			}
+		} else {
+			// Walk right to left past our own private proxies; the first
+			// public address is the client as seen by the outermost proxy.
+			for i := len(hops) - 1; i >= 0; i-- {
+				ip := parseIP(hops[i])
+				if ip == "" {
+					break
+				}
+				if !isPrivateIP(ip) || i == 0 {
+					return ip
+				}
+			}
+		}
+	}
+
+	if ip := parseIP(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+
+	return peer
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func parseIP(s string) string {
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+func isPrivateIP(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast())
+}
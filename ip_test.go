@@ -0,0 +1,49 @@
+package urlshortener
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trust      string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		want       string
+	}{
+		{"never ignores headers", trustNever, "10.0.0.1:80", "203.0.113.7", "203.0.113.8", "10.0.0.1"},
+		{"private-only from public peer", trustPrivateOnly, "198.51.100.1:80", "203.0.113.7", "", "198.51.100.1"},
+		{"private-only from private peer", trustPrivateOnly, "10.0.0.1:80", "203.0.113.7", "", "203.0.113.7"},
+		{"private-only skips private hops", trustPrivateOnly, "10.0.0.1:80", "203.0.113.7, 10.0.0.2, 192.168.1.1", "", "203.0.113.7"},
+		{"private-only stops at public hop", trustPrivateOnly, "10.0.0.1:80", "203.0.113.7, 198.51.100.9", "", "198.51.100.9"},
+		{"private-only all private", trustPrivateOnly, "127.0.0.1:80", "10.0.0.2, 10.0.0.3", "", "10.0.0.2"},
+		{"private-only bad hop", trustPrivateOnly, "10.0.0.1:80", "203.0.113.7, garbage", "", "10.0.0.1"},
+		{"private-only X-Real-IP", trustPrivateOnly, "10.0.0.1:80", "", "203.0.113.8", "203.0.113.8"},
+		{"always takes first hop", trustAlways, "198.51.100.1:80", "203.0.113.7, 10.0.0.2", "", "203.0.113.7"},
+		{"always falls back to X-Real-IP", trustAlways, "198.51.100.1:80", "garbage", "203.0.113.8", "203.0.113.8"},
+		{"always without headers", trustAlways, "198.51.100.1:80", "", "", "198.51.100.1"},
+		{"IPv6 peer", trustPrivateOnly, "[::1]:80", "2001:db8::1", "", "2001:db8::1"},
+		{"peer without port", trustNever, "198.51.100.1", "", "", "198.51.100.1"},
+	}
+	saved := cfg.TrustProxy
+	t.Cleanup(func() { cfg.TrustProxy = saved })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.TrustProxy = tt.trust
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				r.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			if got := realIP(r); got != tt.want {
+				t.Errorf("realIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
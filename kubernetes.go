@@ -0,0 +1,96 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// With --kubernetes-mode the pod drains in two steps. The preStop hook
+// calls /prestop, which fails the /ready probe and holds for
+// preStopDelay while the endpoint is removed from its Services. The
+// SIGTERM that follows closes the listener and gives in-flight requests
+// up to shutdownGrace to finish. Set terminationGracePeriodSeconds to at
+// least 40, the sum of both plus headroom, so the kubelet doesn't kill
+// the pod mid-drain:
+//
+//	terminationGracePeriodSeconds: 40
+//	lifecycle:
+//	  preStop:
+//	    exec:
+//	      command: ["wget", "-qO-", "http://127.0.0.1:4001/prestop"]
+//	readinessProbe:
+//	  httpGet: {path: /ready, port: 4001}
+const (
+	preStopDelay  = 5 * time.Second
+	shutdownGrace = 30 * time.Second
+)
+
+// draining is set once the pod is being stopped; /ready then answers 503
+// while requests keep being served.
+var draining atomic.Bool
+
+// shuttingDown is closed when the server stops accepting connections, so
+// long-lived streams can end instead of holding up the drain.
+var shuttingDown = make(chan struct{})
+
+// readyHandler is the readiness probe: 200 once warm-up is done, 503
+// while starting or draining.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case draining.Load():
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+	case !isReady():
+		http.Error(w, stateStarting, http.StatusServiceUnavailable)
+	default:
+		w.Write([]byte(stateReady))
+	}
+}
+
+// preStopHandler starts the drain and returns after preStopDelay. It is
+// meant for the pod's preStop hook; Kubernetes sends SIGTERM once it
+// returns.
+func preStopHandler(w http.ResponseWriter, r *http.Request) {
+	if !draining.Swap(true) {
+		log.Printf("preStop hook called, failing readiness for %s", preStopDelay)
+	}
+	select {
+	case <-r.Context().Done():
+		return
+	case <-time.After(preStopDelay):
+	}
+	w.Write([]byte("draining"))
+}
+
+// serveUntilTerminated runs listen until SIGTERM or an interrupt, then
+// shuts srv down gracefully: no new connections are accepted and in-flight
+// requests get shutdownGrace to complete.
+func serveUntilTerminated(srv *http.Server, listen func() error) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	srv.RegisterOnShutdown(func() { close(shuttingDown) })
+	errc := make(chan error, 1)
+	go func() { errc <- listen() }()
+
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	draining.Store(true)
+	log.Printf("Received termination signal, draining requests for up to %s", shutdownGrace)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown grace period over, dropping requests in flight: %v", err)
+		return
+	}
+	log.Printf("Drained, exiting")
+}
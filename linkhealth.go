@@ -0,0 +1,320 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	linkHealthInterval    = time.Hour
+	linkHealthTimeout     = 10 * time.Second
+	linkHealthHistory     = 10
+	linkHealthJobID       = "link_health"
+	linkHealthBrokenLimit = 100
+)
+
+// HealthCheck is one probe of a mapping's destination. StatusCode is 0
+// when the request failed outright.
+type HealthCheck struct {
+	CheckedAt  time.Time     `bson:"checked_at" json:"checked_at"`
+	StatusCode int           `bson:"status_code" json:"status_code"`
+	Latency    time.Duration `bson:"latency" json:"latency"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// Healthy reports whether the destination answered with a success or
+// redirect status.
+func (c HealthCheck) Healthy() bool {
+	return c.StatusCode >= 200 && c.StatusCode < 400
+}
+
+type linkHealthStats struct {
+	Monitored    int64      `json:"monitored"`
+	Checked      int64      `json:"checked"`
+	Healthy      int64      `json:"healthy"`
+	Broken       int64      `json:"broken"`
+	AvgLatencyMS float64    `json:"avg_latency_ms"`
+	BrokenCodes  []string   `json:"broken_codes"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+}
+
+// linkHealthClient probes the destinations of anonymous links, and the
+// result is mailed to their creators, so it only connects to public
+// addresses, redirects included.
+var linkHealthClient = &http.Client{Timeout: linkHealthTimeout, Transport: publicTransport()}
+
+var linksBrokenTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "links_broken_total",
+	Help: "Destinations that went from healthy to broken in a health check.",
+}, []string{namespaceLabel})
+
+var lastLinkHealth struct {
+	sync.Mutex
+	at time.Time
+}
+
+// monitoredFilter matches the mappings worth probing: live redirects that
+// are neither disabled nor expired.
+func monitoredFilter(now time.Time) bson.M {
+	return bson.M{
+		"url":      bson.M{"$nin": bson.A{nil, ""}},
+		"disabled": bson.M{"$ne": true},
+		"$or": bson.A{
+			bson.M{"expires_at": nil},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		},
+	}
+}
+
+// startLinkHealth probes every monitored destination at startup and then
+// hourly, using HEALTH_CHECK_WORKERS concurrent requests. Zero workers
+// turns monitoring off.
+func startLinkHealth(ctx context.Context) {
+	if cfg.HealthCheckWorkers <= 0 {
+		return
+	}
+	var state struct {
+		RanAt time.Time `bson:"ran_at"`
+	}
+	if err := jobs.FindOne(ctx, bson.M{"_id": linkHealthJobID}).Decode(&state); err == nil {
+		setLastLinkHealth(state.RanAt)
+	}
+
+	go func() {
+		ticker := time.NewTicker(linkHealthInterval)
+		defer ticker.Stop()
+		for {
+			if err := checkLinkHealth(ctx); err != nil {
+				log.Printf("Link health check failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+type healthTarget struct {
+	Code    string        `bson:"code"`
+	Prefix  string        `bson:"prefix"`
+	URL     string        `bson:"url"`
+	History []HealthCheck `bson:"health_history"`
+}
+
+// checkLinkHealth probes every monitored destination once and appends the
+// result to its history.
+func checkLinkHealth(ctx context.Context) error {
+	ranAt := time.Now()
+	cur, err := collection.Find(ctx, monitoredFilter(ranAt),
+		options.Find().SetProjection(bson.M{"code": 1, "prefix": 1, "url": 1, "health_history": 1}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	targets := make(chan healthTarget)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.HealthCheckWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range targets {
+				recordHealthCheck(ctx, t, probeDestination(ctx, t.URL))
+			}
+		}()
+	}
+
+	for cur.Next(ctx) {
+		var t healthTarget
+		if err := cur.Decode(&t); err != nil {
+			log.Printf("Failed to decode health check target: %v", err)
+			continue
+		}
+		targets <- t
+	}
+	close(targets)
+	wg.Wait()
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	_, err = jobs.UpdateOne(ctx,
+		bson.M{"_id": linkHealthJobID},
+		bson.M{"$set": bson.M{"ran_at": ranAt}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Failed to record link health run: %v", err)
+	}
+	setLastLinkHealth(ranAt)
+	return nil
+}
+
+// probeDestination sends a HEAD request to dest, retrying with GET for
+// servers that don't implement HEAD.
+func probeDestination(ctx context.Context, dest string) HealthCheck {
+	check := HealthCheck{CheckedAt: time.Now()}
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, dest, nil)
+		if err != nil {
+			check.Error = err.Error()
+			return check
+		}
+		start := time.Now()
+		resp, err := linkHealthClient.Do(req)
+		check.Latency = time.Since(start)
+		if err != nil {
+			check.Error = err.Error()
+			return check
+		}
+		resp.Body.Close()
+		check.StatusCode = resp.StatusCode
+		if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+			break
+		}
+	}
+	return check
+}
+
+// recordHealthCheck keeps the last linkHealthHistory checks on the mapping
+// and alerts when a healthy destination starts failing.
+func recordHealthCheck(ctx context.Context, t healthTarget, check HealthCheck) {
+	history := append(t.History, check)
+	if len(history) > linkHealthHistory {
+		history = history[len(history)-linkHealthHistory:]
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{"code": t.Code}, bson.M{"$set": bson.M{"health_history": history}})
+	if err != nil {
+		log.Printf("Failed to save health check for %s: %v", t.Code, err)
+		return
+	}
+	shortURLs.Update(t.Code, func(m *URLMapping) { m.HealthHistory = history })
+
+	if len(t.History) > 0 && t.History[len(t.History)-1].Healthy() && !check.Healthy() {
+		alertBrokenLink(t, check)
+	}
+}
+
+// alertBrokenLink logs the transition and, when the mapping has a contact
+// address and SMTP is configured, emails it.
+func alertBrokenLink(t healthTarget, check HealthCheck) {
+	linksBrokenTotal.WithLabelValues(metricNamespace(t.Prefix)).Inc()
+	reason := http.StatusText(check.StatusCode)
+	if check.StatusCode == 0 {
+		reason = check.Error
+		log.Printf("Destination for %s is broken: %s", t.Code, reason)
+	} else {
+		log.Printf("Destination for %s is broken: %s answered %d %s", t.Code, t.URL, check.StatusCode, reason)
+	}
+
+	m, ok := shortURLs.Get(t.Code)
+	if !ok {
+		var err error
+		if m, err = findInMongoDB(t.Code); err != nil {
+			return
+		}
+	}
+	if m.ContactEmail != "" {
+		go sendBrokenLinkAlert(m, check, reason)
+	}
+}
+
+func linkHealthHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := computeLinkHealth(r.Context())
+	if err != nil {
+		log.Printf("Failed to compute link health: %v", err)
+		http.Error(w, "Failed to compute link health", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func computeLinkHealth(ctx context.Context) (*linkHealthStats, error) {
+	stats := &linkHealthStats{BrokenCodes: []string{}}
+	if at := lastLinkHealthAt(); !at.IsZero() {
+		stats.LastRunAt = &at
+	}
+
+	healthy := bson.M{"$and": bson.A{
+		bson.M{"$gte": bson.A{"$last.status_code", 200}},
+		bson.M{"$lt": bson.A{"$last.status_code", 400}},
+	}}
+	cur, err := collection.Aggregate(ctx, bson.A{
+		bson.M{"$match": monitoredFilter(time.Now())},
+		bson.M{"$project": bson.M{
+			"code": 1,
+			"last": bson.M{"$arrayElemAt": bson.A{"$health_history", -1}},
+		}},
+		bson.M{"$facet": bson.M{
+			"totals": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":       nil,
+					"monitored": bson.M{"$sum": 1},
+					"checked":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$last", nil}}, 1, 0}}},
+					"healthy":   bson.M{"$sum": bson.M{"$cond": bson.A{healthy, 1, 0}}},
+					"latency":   bson.M{"$avg": "$last.latency"},
+				}},
+			},
+			"broken": bson.A{
+				bson.M{"$match": bson.M{"last": bson.M{"$ne": nil}}},
+				bson.M{"$match": bson.M{"$expr": bson.M{"$not": bson.A{healthy}}}},
+				bson.M{"$limit": linkHealthBrokenLimit},
+				bson.M{"$project": bson.M{"_id": 0, "code": 1}},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var facets []struct {
+		Totals []struct {
+			Monitored int64   `bson:"monitored"`
+			Checked   int64   `bson:"checked"`
+			Healthy   int64   `bson:"healthy"`
+			Latency   float64 `bson:"latency"`
+		} `bson:"totals"`
+		Broken []struct {
+			Code string `bson:"code"`
+		} `bson:"broken"`
+	}
+	if err := cur.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+	if len(facets) == 0 {
+		return stats, nil
+	}
+	if len(facets[0].Totals) > 0 {
+		t := facets[0].Totals[0]
+		stats.Monitored = t.Monitored
+		stats.Checked = t.Checked
+		stats.Healthy = t.Healthy
+		stats.Broken = t.Checked - t.Healthy
+		stats.AvgLatencyMS = t.Latency / float64(time.Millisecond)
+	}
+	for _, b := range facets[0].Broken {
+		stats.BrokenCodes = append(stats.BrokenCodes, b.Code)
+	}
+	return stats, nil
+}
+
+func setLastLinkHealth(t time.Time) {
+	lastLinkHealth.Lock()
+	lastLinkHealth.at = t
+	lastLinkHealth.Unlock()
+}
+
+func lastLinkHealthAt() time.Time {
+	lastLinkHealth.Lock()
+	defer lastLinkHealth.Unlock()
+	return lastLinkHealth.at
+}
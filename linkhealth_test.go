@@ -0,0 +1,33 @@
+package urlshortener
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeDestinationRefusesPrivateAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("probe reached the internal server: %s %s", r.Method, r.URL)
+	}))
+	t.Cleanup(internal.Close)
+
+	tests := []struct {
+		name string
+		dest string
+	}{
+		{"loopback", internal.URL + "/admin"},
+		{"localhost", strings.Replace(internal.URL, "127.0.0.1", "localhost", 1)},
+		{"metadata", "http://169.254.169.254/latest/meta-data/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := probeDestination(context.Background(), tt.dest)
+			if check.StatusCode != 0 || !strings.Contains(check.Error, errPrivateAddress.Error()) {
+				t.Errorf("probeDestination(%q) = status %d, error %q; want refused", tt.dest, check.StatusCode, check.Error)
+			}
+		})
+	}
+}
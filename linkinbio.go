@@ -0,0 +1,267 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mappingLinkInBio marks short codes that render a profile page of links
+// instead of redirecting.
+const mappingLinkInBio = "link_in_bio"
+
+const maxBioLinks = 50
+
+var linkInBio *mongo.Collection
+
+type BioLink struct {
+	Label string `bson:"label" json:"label"`
+	URL   string `bson:"url" json:"url"`
+	Icon  string `bson:"icon,omitempty" json:"icon,omitempty"`
+}
+
+type LinkInBio struct {
+	Code      string    `bson:"code" json:"code"`
+	Title     string    `bson:"title" json:"title"`
+	AvatarURL string    `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+	Links     []BioLink `bson:"links" json:"links"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type bioRequest struct {
+	Code      string    `json:"code"`
+	Prefix    string    `json:"prefix"`
+	Title     string    `json:"title"`
+	AvatarURL string    `json:"avatar_url"`
+	Links     []BioLink `json:"links"`
+}
+
+var linkInBioTpl = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    <style>
+        body { font-family: system-ui, sans-serif; max-width: 480px; margin: 2rem auto; padding: 0 1rem; text-align: center; }
+        img.avatar { width: 96px; height: 96px; border-radius: 50%; object-fit: cover; }
+        ul { list-style: none; padding: 0; }
+        li a { display: block; margin: 0.75rem 0; padding: 0.75rem; border: 1px solid #ccc; border-radius: 8px; text-decoration: none; color: inherit; }
+        li img { width: 20px; height: 20px; vertical-align: middle; margin-right: 0.5rem; }
+    </style>
+</head>
+<body>
+    {{with .AvatarURL}}<img class="avatar" src="{{.}}" alt="">{{end}}
+    <h1>{{.Title}}</h1>
+    <ul>
+        {{range $i, $l := .Links}}
+        <li><a href="/b/{{$i}}/{{$.Code}}">{{with $l.Icon}}<img src="{{.}}" alt="">{{end}}{{$l.Label}}</a></li>
+        {{end}}
+    </ul>
+</body>
+</html>
+`))
+
+// validate normalises the profile's URLs in place.
+func (req *bioRequest) validate() error {
+	if req.Title == "" {
+		return errors.New("title is required")
+	}
+	if len(req.Links) > maxBioLinks {
+		return errors.New("at most 50 links are allowed")
+	}
+	if req.AvatarURL != "" {
+		u, err := validateURL(req.AvatarURL)
+		if err != nil {
+			return errors.New("avatar_url: " + err.Error())
+		}
+		req.AvatarURL = u
+	}
+	for i := range req.Links {
+		l := &req.Links[i]
+		if l.Label == "" {
+			return errors.New("every link needs a label")
+		}
+		u, err := validateDestinationURL(l.URL)
+		if err != nil {
+			return errors.New(l.Label + ": " + err.Error())
+		}
+		l.URL = u
+		if l.Icon != "" {
+			if l.Icon, err = validateURL(l.Icon); err != nil {
+				return errors.New(l.Label + " icon: " + err.Error())
+			}
+		}
+	}
+	if req.Links == nil {
+		req.Links = []BioLink{}
+	}
+	return nil
+}
+
+func findLinkInBio(ctx context.Context, code string) (LinkInBio, error) {
+	var p LinkInBio
+	err := linkInBio.FindOne(ctx, bson.M{"code": code}).Decode(&p)
+	return p, err
+}
+
+// apiCreateBioHandler reserves a short code of type link_in_bio and stores
+// its profile.
+func apiCreateBioHandler(w http.ResponseWriter, r *http.Request) {
+	var req bioRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Code != "" {
+		if err := validateCode(req.Code); err != nil {
+			http.Error(w, err.Error(), validateCodeStatus(err))
+			return
+		}
+	}
+	if req.Prefix != "" && !codePattern.MatchString(req.Prefix) {
+		http.Error(w, "prefix must be 3-32 letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+
+	mapping, err := createShortURL(r.Context(), URLMapping{Code: req.Code, Prefix: req.Prefix, Type: mappingLinkInBio})
+	if err != nil {
+		if errors.Is(err, errCodeTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to save to database: %v", err)
+		http.Error(w, "Failed to save to database", http.StatusInternalServerError)
+		return
+	}
+
+	profile := LinkInBio{
+		Code:      mapping.Code,
+		Title:     req.Title,
+		AvatarURL: req.AvatarURL,
+		Links:     req.Links,
+		UpdatedAt: time.Now(),
+	}
+	if _, err := linkInBio.InsertOne(r.Context(), profile); err != nil {
+		log.Printf("Failed to save link-in-bio profile for %s: %v", mapping.Code, err)
+		if _, err := collection.DeleteOne(context.Background(), bson.M{"code": mapping.Code}); err != nil {
+			log.Printf("Failed to roll back %s: %v", mapping.Code, err)
+		}
+		shortURLs.Delete(mapping.Code)
+		publishMutation(replicateDelete, URLMapping{Code: mapping.Code})
+		lifecycle.PublishDeleted(mapping.Code)
+		http.Error(w, "Failed to save to database", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, profile)
+}
+
+func apiGetBioHandler(w http.ResponseWriter, r *http.Request) {
+	profile, err := findLinkInBio(r.Context(), codeParam(r))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to load link-in-bio profile: %v", err)
+		http.Error(w, "Failed to load profile", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// apiUpdateBioHandler replaces a profile's title, avatar and links. The
+// code itself cannot change.
+func apiUpdateBioHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	var req bioRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var profile LinkInBio
+	err := linkInBio.FindOneAndUpdate(r.Context(),
+		bson.M{"code": code},
+		bson.M{"$set": bson.M{
+			"title":      req.Title,
+			"avatar_url": req.AvatarURL,
+			"links":      req.Links,
+			"updated_at": time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&profile)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to update link-in-bio profile for %s: %v", code, err)
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// serveLinkInBio renders the profile page for m and records the view.
+func serveLinkInBio(w http.ResponseWriter, r *http.Request, m URLMapping) {
+	profile, err := findLinkInBio(r.Context(), m.Code)
+	if err != nil {
+		log.Printf("Failed to load link-in-bio profile for %s: %v", m.Code, err)
+		notFound(w, r)
+		return
+	}
+
+	ev := newClickEvent(r, m.Code)
+	ev.RedirectType = http.StatusOK
+	go recordClick(ev)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := linkInBioTpl.Execute(w, profile); err != nil {
+		log.Printf("Error rendering link-in-bio page for %s: %v", m.Code, err)
+	}
+}
+
+// bioLinkHandler redirects to one link of a profile, recording the click
+// with its link_index.
+func bioLinkHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+
+	profile, err := findLinkInBio(r.Context(), code)
+	if err != nil || index < 0 || index >= len(profile.Links) {
+		notFound(w, r)
+		return
+	}
+
+	ev := newClickEvent(r, code)
+	ev.LinkIndex = &index
+	ev.RedirectType = http.StatusSeeOther
+	go recordClick(ev)
+
+	http.Redirect(w, r, profile.Links[index].URL, http.StatusSeeOther)
+}
@@ -0,0 +1,74 @@
+package urlshortener
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// supportedLanguages are the home page's languages, English first as the
+// fallback for everything else.
+var supportedLanguages = []language.Tag{language.English, language.Spanish, language.French, language.German}
+
+var (
+	translators     = loadTranslators()
+	languageMatcher = language.NewMatcher(supportedLanguages)
+)
+
+// Translator looks up the home page's UI strings in one language. Keys
+// missing from its locale file fall back to English, then to the key.
+type Translator struct {
+	lang     string
+	messages map[string]string
+	fallback map[string]string
+}
+
+// T returns the string for key.
+func (t Translator) T(key string) string {
+	if s, ok := t.messages[key]; ok {
+		return s
+	}
+	if s, ok := t.fallback[key]; ok {
+		return s
+	}
+	return key
+}
+
+// Lang is the BCP 47 tag of t's language, for the page's lang attribute.
+func (t Translator) Lang() string {
+	return t.lang
+}
+
+// loadTranslators parses locales/{lang}.json for each supported language,
+// in the order of supportedLanguages. A missing or malformed file is a
+// build mistake, so it panics like template.Must.
+func loadTranslators() []Translator {
+	ts := make([]Translator, len(supportedLanguages))
+	for i, tag := range supportedLanguages {
+		base, _ := tag.Base()
+		data, err := localeFiles.ReadFile("locales/" + base.String() + ".json")
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("locales/%s.json: %v", base, err))
+		}
+		ts[i] = Translator{lang: base.String(), messages: messages, fallback: ts[0].messages}
+	}
+	return ts
+}
+
+// translatorFor picks the supported language that best matches r's
+// Accept-Language, or English.
+func translatorFor(r *http.Request) Translator {
+	tags, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	_, i, _ := languageMatcher.Match(tags...)
+	return translators[i]
+}
@@ -0,0 +1,41 @@
+package urlshortener
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+type ctxKey string
+
+// ctxCode carries the short code a request resolved or names in its path,
+// so code further down can log it without being handed it.
+const ctxCode ctxKey = "short_code"
+
+func withCode(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, ctxCode, code)
+}
+
+// codeFromContext returns the short code of the request ctx belongs to, or
+// "" outside a redirect.
+func codeFromContext(ctx context.Context) string {
+	code, _ := ctx.Value(ctxCode).(string)
+	return code
+}
+
+// loggerFrom is the default logger with the short_code of the request ctx
+// belongs to, if it has one, on every line.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if code := codeFromContext(ctx); code != "" {
+		return slog.Default().With("short_code", code)
+	}
+	return slog.Default()
+}
+
+// codeContext puts the {code} of a route's path in the request context, for
+// loggerFrom.
+func codeContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withCode(r.Context(), codeParam(r))))
+	})
+}
@@ -1,103 +1,652 @@
-package main
+// Package urlshortener is the urlshortener server. cmd/server runs it; the
+// packages under pkg/ hold its core for embedding in other programs.
+package urlshortener
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
-	"sync"
+	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/sync/singleflight"
+
+	"urlshortener/pkg/shortener"
+	"urlshortener/pkg/store"
 )
 
 var (
-	mu         sync.Mutex
-	shortURLs  = make(map[string]string)
+	shortURLs  = newURLCache()
 	client     *mongo.Client
 	collection *mongo.Collection
 )
 
-var tpl = template.Must(template.New("").Parse(`
+var tpl = template.Must(template.New("").Funcs(template.FuncMap{"asset": assetURL, "share": shareLinks, "qr": qrPath}).Parse(`
 <!DOCTYPE html>
-<html lang="en">
+<html lang="{{.Translator.Lang}}">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>URL Shortener</title>
+    <title>{{.Translator.T "title"}}</title>
+    <link rel="stylesheet" href="{{asset "home.css"}}">
+    <link rel="sitemap" type="application/xml" href="/sitemap_index.xml">
 </head>
 <body>
-    <h1>URL Shortener</h1>
+    <h1>{{.Translator.T "title"}}</h1>
     <form method="post" action="/shorten">
-        <label for="url">URL to Shorten:</label>
-        <input type="url" name="url" required>
-        <button type="submit">Shorten</button>
+        <label for="url">{{.Translator.T "url_label"}}</label>
+        <input type="url" name="url" required value="{{.PrefillURL}}">
+        <details>
+            <summary>{{.Translator.T "advanced_options"}}</summary>
+            <label for="custom_headers">{{.Translator.T "custom_headers_label"}}</label><br>
+            <textarea name="custom_headers" id="custom_headers" rows="3" cols="40" placeholder="X-Campaign: summer2024"></textarea>
+        </details>
+        <button type="submit">{{.Translator.T "shorten"}}</button>
     </form>
+    {{with .Created}}
+    <p class="created">{{$.Translator.T "created"}} <a href="{{.Path}}" target="_blank">{{.ShortURL}}</a><br>
+        <img src="{{qr .}}" alt="{{printf ($.Translator.T "qr_alt") .ShortURL}}" width="256" height="256"></p>
+    {{end}}
     <br>
-    <h2>Shortened URLs:</h2>
+    <h2>{{.Translator.T "shortened_urls"}}</h2>
     <ul>
-        {{range $code, $url := .ShortURLs}}
-            <li><a href="/{{$code}}" target="_blank">{{$url}}</a></li>
+        {{range $m := .ShortURLs}}
+            <li><a href="{{$m.Path}}" target="_blank">{{$m.ShortURL}}</a> → {{if $m.TargetCode}}/{{$m.TargetCode}}{{else}}{{$m.DisplayURL}}{{end}}
+                {{with share $.BaseURL $m}}<span class="share"><a href="{{.Twitter}}" target="_blank" rel="noopener">{{$.Translator.T "share_x"}}</a> <a href="{{.LinkedIn}}" target="_blank" rel="noopener">{{$.Translator.T "share_linkedin"}}</a></span>{{end}}</li>
         {{end}}
     </ul>
+    <script src="{{asset "home.js"}}"></script>
 </body>
 </html>
 `))
 
 type PageVariables struct {
-	ShortURLs map[string]string
+	// ShortURLs streams mappings from a MongoDB cursor as the template
+	// ranges over it, so the page never holds every URL in memory.
+	ShortURLs <-chan URLMapping
+	BaseURL   string
+
+	// PrefillURL fills the form from ?url=, as the bookmarklet opens it.
+	PrefillURL string
+
+	// Created is the URL just shortened, named by ?created=, shown with its
+	// QR code.
+	Created *URLMapping
+
+	// Translator holds the UI strings in the visitor's Accept-Language.
+	Translator Translator
 }
 
 type URLMapping struct {
-	Code string `bson:"code"`
-	URL  string `bson:"url"`
+	Code        string     `bson:"code" json:"code"`
+	CodeInt     *int64     `bson:"code_int,omitempty" json:"-"`
+	URL         string     `bson:"url,omitempty" json:"url,omitempty"`
+	TargetCode  string     `bson:"target_code,omitempty" json:"target_code,omitempty"`
+	Prefix      string     `bson:"prefix,omitempty" json:"prefix,omitempty"`
+	Type        string     `bson:"type,omitempty" json:"type,omitempty"`
+	Clicks      int64      `bson:"clicks" json:"clicks"`
+	Impressions int64      `bson:"impressions,omitempty" json:"impressions,omitempty"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt   *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Tags        []string   `bson:"tags,omitempty" json:"tags,omitempty"`
+	PublicStats bool       `bson:"public_stats" json:"public_stats"`
+	Archive     *Archive   `bson:"archive,omitempty" json:"archive,omitempty"`
+	ArchivedAt  *time.Time `bson:"archived_at,omitempty" json:"archived_at,omitempty"`
+
+	// Preview is the destination's Open Graph data, served at /og/{code}.
+	Preview *LinkPreview `bson:"preview,omitempty" json:"preview,omitempty"`
+
+	DelaySeconds int    `bson:"delay_seconds,omitempty" json:"delay_seconds,omitempty"`
+	AdHTML       string `bson:"ad_html,omitempty" json:"ad_html,omitempty"`
+
+	ShadowURL string `bson:"shadow_url,omitempty" json:"shadow_url,omitempty"`
+	Disabled  bool   `bson:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// DisabledAt is when Disabled was last turned on; vacuum deletes the
+	// URL vacuumRetention later.
+	DisabledAt *time.Time `bson:"disabled_at,omitempty" json:"disabled_at,omitempty"`
+
+	// TrafficSplit, when set, chooses the destination of each redirect
+	// instead of URL.
+	TrafficSplit *TrafficSplit `bson:"traffic_split,omitempty" json:"traffic_split,omitempty"`
+
+	// SamplingRate is the share of redirects whose click event is recorded;
+	// unset records all of them. Clicks and analytics counts are scaled
+	// back up to estimated totals.
+	SamplingRate float64 `bson:"sampling_rate,omitempty" json:"sampling_rate,omitempty"`
+
+	// CollectionID names the URLCollection the URL is filed in.
+	CollectionID string `bson:"collection_id,omitempty" json:"collection_id,omitempty"`
+
+	// CampaignID names the Campaign whose UTM parameters are added to the
+	// destination on redirect.
+	CampaignID string `bson:"campaign_id,omitempty" json:"campaign_id,omitempty"`
+
+	// RequiresSignature refuses redirects without the ?sig= that Path
+	// appends, so the code can't be found by guessing.
+	RequiresSignature bool `bson:"requires_signature,omitempty" json:"requires_signature,omitempty"`
+
+	// RedirectType is the status to redirect with, overriding the
+	// namespace, owner and global defaults; see redirectStatus.
+	RedirectType int `bson:"redirect_type,omitempty" json:"redirect_type,omitempty"`
+
+	// Cloak serves the destination from the short URL instead of
+	// redirecting to it.
+	Cloak bool `bson:"cloak,omitempty" json:"cloak,omitempty"`
+
+	// HealthHistory holds the most recent destination health checks,
+	// oldest first.
+	HealthHistory []HealthCheck `bson:"health_history,omitempty" json:"health_history,omitempty"`
+
+	// ManageToken authorises the disable link sent in confirmation emails.
+	ManageToken string `bson:"manage_token,omitempty" json:"-"`
+
+	OwnerID string `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+
+	// VerifySSLPin refuses to redirect unless the destination presents a
+	// certificate whose SPKI hash is in ExpectedPins; see checkPins.
+	VerifySSLPin bool     `bson:"verify_ssl_pin,omitempty" json:"verify_ssl_pin,omitempty"`
+	ExpectedPins []string `bson:"expected_pins,omitempty" json:"expected_pins,omitempty"`
+
+	// ContentHash fingerprints URL for duplicate detection; see
+	// contentHash.
+	ContentHash string `bson:"content_hash,omitempty" json:"content_hash,omitempty"`
+
+	// AliasOf is the canonical code an alias redirects for; see
+	// aliasModeLookup.
+	AliasOf string `bson:"alias_of,omitempty" json:"alias_of,omitempty"`
+
+	// AppDeepLink is opened instead of the destination on iOS and Android,
+	// with FallbackURL, or the destination, for when the app isn't
+	// installed; see renderDeepLink.
+	AppDeepLink string `bson:"app_deep_link,omitempty" json:"app_deep_link,omitempty"`
+	FallbackURL string `bson:"fallback_url,omitempty" json:"fallback_url,omitempty"`
+
+	// AlwaysPreview, when set, overrides ALWAYS_PREVIEW for the URL.
+	AlwaysPreview *bool `bson:"always_preview,omitempty" json:"always_preview,omitempty"`
+
+	// Priority is the URL's service tier, "premium" or "free" (the default
+	// when empty); see lookupRedirect.
+	Priority string `bson:"priority,omitempty" json:"priority,omitempty"`
+
+	// ContactEmail is shown on the expiry page.
+	ContactEmail string `bson:"contact_email,omitempty" json:"contact_email,omitempty"`
+
+	// CustomHeaders are added to redirect responses.
+	CustomHeaders map[string]string `bson:"custom_headers,omitempty" json:"custom_headers,omitempty"`
+
+	// NotifyBeforeDeleteHours asks for a notice, to ContactEmail and the
+	// Slack webhook, this many hours before ExpiresAt; PreDeleteNotifiedAt
+	// records that it was sent.
+	NotifyBeforeDeleteHours int        `bson:"notify_before_delete_hours,omitempty" json:"notify_before_delete_hours,omitempty"`
+	PreDeleteNotifiedAt     *time.Time `bson:"pre_delete_notified_at,omitempty" json:"pre_delete_notified_at,omitempty"`
+
+	// InactivityNoticeAt records that the owner was asked to keep the URL
+	// after URL_INACTIVITY_DAYS without clicks, KeptActiveAt that they did,
+	// and InactiveDisabledAt that they didn't; see sweepInactiveURLs.
+	InactivityNoticeAt *time.Time `bson:"inactivity_notice_at,omitempty" json:"inactivity_notice_at,omitempty"`
+	KeptActiveAt       *time.Time `bson:"kept_active_at,omitempty" json:"kept_active_at,omitempty"`
+	InactiveDisabledAt *time.Time `bson:"inactive_disabled_at,omitempty" json:"inactive_disabled_at,omitempty"`
+
+	// ExpiredPublishedAt records that the expiry was announced on NATS.
+	ExpiredPublishedAt *time.Time `bson:"expired_published_at,omitempty" json:"-"`
+
+	// DryRun marks API responses for mappings that were validated but not
+	// saved.
+	DryRun bool `bson:"-" json:"dry_run,omitempty"`
+
+	// SuggestedTags, in shorten responses, are the tags categorizeURL
+	// proposes for the destination. They aren't applied; a PATCH with
+	// tags confirms them.
+	SuggestedTags []string `bson:"-" json:"suggested_tags,omitempty"`
+}
+
+// Path is the public path of the short URL, signed if it requires a
+// signature. Namespaced codes are stored as "prefix/code" and served under
+// /p/.
+func (m URLMapping) Path() string {
+	path := "/" + m.Code
+	if m.Prefix != "" {
+		path = "/p/" + m.Code
+	}
+	if m.RequiresSignature {
+		path += "?sig=" + signCode(m.Code)
+	}
+	return path
 }
 
-func main() {
+// ShortURL is the full public short URL. Without BASE_URL configured it is
+// just the path.
+func (m URLMapping) ShortURL() string {
+	return cfg.BaseURL + m.Path()
+}
+
+// MarshalJSON adds the computed short_url to API responses.
+func (m URLMapping) MarshalJSON() ([]byte, error) {
+	type plain URLMapping
+	return json.Marshal(struct {
+		plain
+		ShortURL string `json:"short_url"`
+	}{plain(m), m.ShortURL()})
+}
+
+// DisplayURL is the destination with its hostname in Unicode form.
+func (m URLMapping) DisplayURL() string {
+	return displayURL(m.URL)
+}
+
+func (m URLMapping) Expired() bool {
+	return m.ExpiresAt != nil && time.Now().After(*m.ExpiresAt)
+}
+
+// liveFilter matches mappings that are neither disabled nor expired.
+func liveFilter() bson.M {
+	return bson.M{"disabled": bson.M{"$ne": true}, "$or": bson.A{
+		bson.M{"expires_at": bson.M{"$exists": false}},
+		bson.M{"expires_at": nil},
+		bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+	}}
+}
+
+// Run configures the server from the environment and flags and serves on
+// :4001 until it fails or, in Kubernetes mode, is terminated.
+func Run() {
 	rand.Seed(time.Now().UnixNano())
+	cfg = loadConfig()
+	slog.Debug("Configuration options:\n" + string(configDocs))
+	if cfg.StoreBackend != storeMongo {
+		runSQLStore()
+		return
+	}
+	configureOAuth()
+	// Registering the routes reserves their codes, before anything can
+	// create a short URL.
+	r := routes()
+	replayMutationLog()
+	openMutationLog()
 
-	// Connect to MongoDB
-	clientOptions := options.Client().ApplyURI("")
-	client, err := mongo.Connect(context.Background(), clientOptions)
-	if err != nil {
-		log.Fatal(err)
+	if cfg.DryRun {
+		startDryRun()
+	} else {
+		// Connect to MongoDB, trying each configured URI in turn
+		if err := connectMongo(context.Background(), splitMongoURIs(cfg.MongoURI)); err != nil {
+			log.Fatal(err)
+		}
+		defer func() { client.Disconnect(context.Background()) }()
+		startServices(context.Background())
 	}
-	defer client.Disconnect(context.Background())
+	go sweepRateBuckets(context.Background())
+	startRedisRateLimiter()
+	go sweepRecentClicks(context.Background())
+	startProxy()
+
+	r.warnMissingSuccessors()
 
-	// Select the database and collection
-	database := client.Database("urlshortener")
-	collection = database.Collection("urls")
+	serve(impersonationMiddleware(r))
+}
 
-	// Initialize HTTP server
-	r := http.NewServeMux()
-	r.HandleFunc("/", homeHandler)
-	r.HandleFunc("/shorten", shortenHandler)
-	r.HandleFunc("/{code}", redirectHandler)
+// serve serves h on :4001, with HTTPS if TLS is configured, until it fails
+// or, in Kubernetes mode, is terminated.
+func serve(h http.Handler) {
+	srv := &http.Server{Addr: ":4001", Handler: recoveryMiddleware(pingMiddleware(h))}
+	listen := srv.ListenAndServe
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+		srv.TLSConfig = &tls.Config{GetCertificate: certificateForHello}
+		listen = func() error { return srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile) }
+	} else {
+		log.Printf("Warning: TLS is not configured; browsers will use HTTP/1.1 and asset push is disabled")
+	}
 
-	log.Fatal(http.ListenAndServe(":4001", r))
+	if cfg.KubernetesMode {
+		serveUntilTerminated(srv, listen)
+		return
+	}
+	log.Fatal(listen())
 }
 
-func homeHandler(w http.ResponseWriter, r *http.Request) {
-	mu.Lock()
-	defer mu.Unlock()
+// routes builds the router with every route. Timeouts and rate limits are
+// set per route: redirects keep a tight deadline since a person is waiting,
+// writes get the longer handler timeout.
+func routes() *Router {
+	writeTimeout := time.Duration(cfg.HandlerTimeoutMS) * time.Millisecond
+	redirectTimeout := time.Duration(cfg.RedirectTimeoutMS) * time.Millisecond
 
-	pageVariables := PageVariables{
-		ShortURLs: shortURLs,
+	r := newRouter()
+	r.Handle(RouteConfig{Path: "/"}, proxyOr(securityHeadersMiddleware(http.HandlerFunc(homeHandler))))
+	r.HandleFunc(RouteConfig{Path: "/shorten", Timeout: writeTimeout, RateLimit: cfg.ShortenRateLimit}, shortenHandler)
+	r.HandleFunc(RouteConfig{Path: "/{code}", Timeout: redirectTimeout}, redirectHandler)
+	r.HandleFunc(RouteConfig{Path: "/p/{prefix}/{code}", Timeout: redirectTimeout}, redirectHandler)
+	r.HandleFunc(RouteConfig{Method: "POST", Path: "/api/v1/shorten", Timeout: writeTimeout, RateLimit: cfg.ShortenRateLimit}, apiShortenHandler)
+	r.Handle(RouteConfig{Method: "POST", Path: "/graphql", Timeout: writeTimeout, RateLimit: cfg.ShortenRateLimit}, requireAPIKey(graphQLHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/shorten", Timeout: writeTimeout, RateLimit: cfg.BookmarkletRateLimit}, shortenGetCORS(requireAPIKey(apiShortenGetHandler)))
+	r.HandleFunc(RouteConfig{Method: "OPTIONS", Path: "/api/v1/shorten"}, extensionPreflightHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/bookmarklet"}, bookmarkletHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/api/v1/openapi.json"}, openAPIHandler)
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/docs"}, securityHeadersMiddleware(http.HandlerFunc(docsHandler)))
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/api/v1/{code}"}, apiResolveHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/api/v1/{code}/share"}, apiShareHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/api/v1/{code}/preview"}, apiPreviewHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/api/v1/{code}/qr"}, qrHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/t/{code...}"}, trackHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/og/{code...}"}, ogHandler)
+	if cfg.ClientSideAnalytics {
+		r.HandleFunc(RouteConfig{Method: "POST", Path: "/api/v1/analytics/event"}, analyticsEventHandler)
+	}
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/urls"}, adminOnly(apiListHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/reverse"}, adminOnly(apiReverseHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/config-schema"}, adminOnly(configSchemaHandler))
+	r.Handle(RouteConfig{Method: "PATCH", Path: "/api/v1/{code}", Timeout: writeTimeout}, adminOnly(apiUpdateHandler))
+	r.Handle(RouteConfig{Method: "DELETE", Path: "/api/v1/{code}", Timeout: writeTimeout}, adminOnly(apiDeleteHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/{code}/analytics"}, adminOnly(apiAnalyticsHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/{code}/activity"}, adminOnly(apiActivityHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/{code}/analytics/reset", Timeout: writeTimeout}, adminOnly(analyticsResetHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/{code}/aliases", Timeout: writeTimeout}, adminOnly(apiCreateAliasHandler))
+	r.Handle(RouteConfig{Method: "PUT", Path: "/api/v1/admin/splits/{code...}", Timeout: writeTimeout}, adminOnly(putSplitHandler))
+	r.Handle(RouteConfig{Method: "PATCH", Path: "/api/v1/admin/splits/{code...}", Timeout: writeTimeout}, adminOnly(patchSplitHandler))
+	r.Handle(RouteConfig{Method: "DELETE", Path: "/api/v1/admin/splits/{code...}", Timeout: writeTimeout}, adminOnly(deleteSplitHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/analytics/summary"}, adminOnly(analyticsSummaryHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/namespaces/{ns}/analytics"}, adminOnly(namespaceAnalyticsHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/namespaces/{ns}"}, adminOnly(getNamespaceSettingsHandler))
+	r.Handle(RouteConfig{Method: "PUT", Path: "/api/v1/admin/namespaces/{ns}", Timeout: writeTimeout}, adminOnly(putNamespaceSettingsHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/stats/batch"}, adminOnly(apiBatchStatsHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/status-pages/{status}"}, adminOnly(getStatusPageHandler))
+	r.Handle(RouteConfig{Method: "PUT", Path: "/api/v1/admin/status-pages/{status}", Timeout: writeTimeout}, adminOnly(putStatusPageHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/vacuum"}, adminOnly(vacuumHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/duplicates"}, adminOnly(duplicatesHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/retention"}, adminOnly(retentionHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/collision-risk"}, adminOnly(collisionRiskHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/fraud-events"}, adminOnly(fraudEventsHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/health/urls"}, adminOnly(linkHealthHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/validate-all"}, adminOnly(validateAllHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/admin/impersonate/{user_id}"}, adminOnly(impersonateHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/blocked-domains"}, adminOnly(apiListBlockedDomainsHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/admin/blocked-domains", Timeout: writeTimeout}, adminOnly(apiBlockDomainHandler))
+	r.Handle(RouteConfig{Method: "DELETE", Path: "/api/v1/admin/blocked-domains/{domain}", Timeout: writeTimeout}, adminOnly(apiUnblockDomainHandler))
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/api/v1/me"}, meHandler)
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/feed-sync", Timeout: writeTimeout}, adminOnly(feedSyncHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/feed-sync"}, adminOnly(listFeedsHandler))
+	r.Handle(RouteConfig{Method: "DELETE", Path: "/api/v1/feed-sync", Timeout: writeTimeout}, adminOnly(deleteFeedHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/import/browser-history", Timeout: writeTimeout}, adminOnly(browserHistoryImportHandler))
+	r.Handle(RouteConfig{Method: "PATCH", Path: "/api/v1/users/{id}", Timeout: writeTimeout}, adminOnly(apiUpdateUserHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/users/{id}/erase"}, adminOnly(eraseUserHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/users/{id}/export"}, selfOrAdmin(exportUserHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/admin"}, securityHeadersMiddleware(panelAuth(http.HandlerFunc(adminHandler))))
+	r.Handle(RouteConfig{Method: "GET", Path: "/admin/stream"}, panelAuth(http.HandlerFunc(adminStreamHandler)))
+	r.Handle(RouteConfig{Method: "GET", Path: "/admin/click-map"}, securityHeaders(clickMapCSP)(panelAuth(http.HandlerFunc(clickMapHandler))))
+	r.HandleFunc(RouteConfig{Method: "POST", Path: "/admin/logout"}, logoutHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/auth/login"}, oauthLoginHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/auth/callback"}, oauthCallbackHandler)
+	r.Handle(RouteConfig{Method: "GET", Path: "/app/"}, securityHeaders(appCSP)(panelAuth(spaHandler())))
+	r.Handle(RouteConfig{Method: "GET", Path: "/app"}, http.RedirectHandler("/app/", http.StatusMovedPermanently))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/admin/bio", Timeout: writeTimeout}, adminOnly(apiCreateBioHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/bio/{code...}"}, adminOnly(apiGetBioHandler))
+	r.Handle(RouteConfig{Method: "PUT", Path: "/api/v1/admin/bio/{code...}", Timeout: writeTimeout}, adminOnly(apiUpdateBioHandler))
+	r.Handle(RouteConfig{Method: "DELETE", Path: "/api/v1/admin/bio/{code...}", Timeout: writeTimeout}, adminOnly(apiDeleteHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/collections", Timeout: writeTimeout}, adminOnly(apiCreateCollectionHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/collections"}, adminOnly(apiListCollectionsHandler))
+	r.Handle(RouteConfig{Method: "PUT", Path: "/api/v1/collections/{id}", Timeout: writeTimeout}, adminOnly(apiUpdateCollectionHandler))
+	r.Handle(RouteConfig{Method: "DELETE", Path: "/api/v1/collections/{id}", Timeout: writeTimeout}, adminOnly(apiDeleteCollectionHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/collections/{id}/urls"}, adminOnly(apiCollectionURLsHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/collections/{id}/urls", Timeout: writeTimeout}, adminOnly(apiAddToCollectionHandler))
+	r.Handle(RouteConfig{Method: "DELETE", Path: "/api/v1/collections/{id}/urls/{code...}", Timeout: writeTimeout}, adminOnly(apiRemoveFromCollectionHandler))
+	r.Handle(RouteConfig{Method: "POST", Path: "/api/v1/admin/campaigns", Timeout: writeTimeout}, adminOnly(apiCreateCampaignHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/campaigns"}, adminOnly(apiListCampaignsHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/api/v1/admin/campaigns/{id}"}, adminOnly(apiGetCampaignHandler))
+	r.Handle(RouteConfig{Method: "PUT", Path: "/api/v1/admin/campaigns/{id}", Timeout: writeTimeout}, adminOnly(apiUpdateCampaignHandler))
+	r.Handle(RouteConfig{Method: "DELETE", Path: "/api/v1/admin/campaigns/{id}", Timeout: writeTimeout}, adminOnly(apiDeleteCampaignHandler))
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/b/{index}/{code...}", Timeout: redirectTimeout}, bioLinkHandler)
+	r.Handle(RouteConfig{Method: "GET", Path: "/s/{code}/stats"}, securityHeadersMiddleware(http.HandlerFunc(publicStatsHandler)))
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/screenshot/{code...}"}, screenshotHandler)
+	r.HandleFunc(RouteConfig{Path: "/disable/{code...}", Timeout: writeTimeout}, disableHandler)
+	r.HandleFunc(RouteConfig{Path: "/keep/{code...}", Timeout: writeTimeout}, keepHandler)
+	r.Handle(RouteConfig{Method: "GET", Path: "/assets/"}, assetsHandler())
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/sitemap.xml"}, sitemapHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/robots.txt"}, robotsHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/healthz"}, healthHandler)
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/status"}, statusHandler)
+	r.Handle(RouteConfig{Method: "GET", Path: "/metrics"}, promhttp.Handler())
+	r.Handle(RouteConfig{Method: "GET", Path: "/debug/map-stats"}, localOnly(mapStatsHandler))
+	r.Handle(RouteConfig{Method: "GET", Path: "/debug/codegen-bench"}, localOnly(codegenBenchHandler))
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/sitemap_index.xml"}, sitemapIndexHandler)
+
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/ready"}, readyHandler)
+	if cfg.KubernetesMode {
+		r.Handle(RouteConfig{Method: "GET", Path: "/prestop"}, localOnly(preStopHandler))
+	}
+	return r
+}
+
+// startServices loads the state kept in MongoDB, warms the cache and starts
+// the background jobs, all of which --dry-run goes without.
+func startServices(ctx context.Context) {
+	go watchMongo(ctx)
+
+	if err := loadCustomDomains(ctx); err != nil {
+		log.Printf("Failed to load custom domains: %v", err)
+	}
+	go watchCustomDomains(ctx)
+	if err := loadBlockedDomains(ctx); err != nil {
+		log.Printf("Failed to load blocked domains: %v", err)
+	}
+	go watchBlockedDomains(ctx, loadBlockedDomains)
+	startReplication(ctx)
+	startNATS(ctx)
+	startDeadLetters(ctx)
+	go watchURLChanges(ctx)
+	startCodeLength(ctx)
+	startCollisionStats(ctx)
+	checkSharding(ctx)
+
+	if cfg.NoPreload {
+		if err := ensureIndexes(ctx); err != nil {
+			log.Printf("Failed to create indexes: %v", err)
+		} else {
+			checkIndexes(ctx)
+		}
+		selfTest(ctx)
+		readyState.Store(stateReady)
+	} else {
+		go warmUp(ctx)
+	}
+	startIndexCheck(ctx)
+
+	startS3Export(ctx)
+	startBigQueryExport(ctx)
+	startVacuum(ctx)
+	startDuplicateReview(ctx)
+	startExpiryNotices(ctx)
+	startInactivitySweep(ctx)
+	startInboundEmail(ctx)
+	startClickSampling(ctx)
+	startURLFilter(ctx)
+	startFraudDetection(ctx)
+	startRetention(ctx)
+	go func() {
+		if cfg.CaseInsensitive {
+			migrateLowercaseCodes(ctx)
+		}
+		migrateCodeInts(ctx)
+		migrateContentHashes(ctx)
+	}()
+	startInstanceHeartbeat(ctx)
+	startHealthChecks(ctx)
+	startScreenshots(ctx)
+	startLinkHealth(ctx)
+	startFeedSync(ctx)
+}
+
+// indexSpec lists the indexes a collection should have besides _id.
+type indexSpec struct {
+	coll   *mongo.Collection
+	models []mongo.IndexModel
+}
+
+// expectedIndexes is the index schema that ensureIndexes creates and
+// checkIndexes verifies.
+func expectedIndexes() []indexSpec {
+	return []indexSpec{
+		{collection, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "created_at", Value: -1}}},
+			// Feed sync and reverse lookups find mappings by destination.
+			{Keys: bson.D{{Key: "url", Value: 1}}},
+			{Keys: bson.D{{Key: "content_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "prefix", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "notify_before_delete_hours", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "collection_id", Value: 1}, {Key: "created_at", Value: -1}}, Options: options.Index().SetSparse(true)},
+		}},
+		{clickEvents, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "code", Value: 1}, {Key: "timestamp", Value: 1}}},
+		}},
+		{users, []mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.provider_user_id", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"identities": bson.M{"$exists": true}}),
+			},
+		}},
+		{sessions, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "session_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		}},
+		{campaigns, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		}},
+		{urlDuplicates, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+		}},
+		{auditLog, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "code", Value: 1}, {Key: "timestamp", Value: 1}}},
+		}},
+		{fraudEvents, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "detected_at", Value: -1}}},
+		}},
+		{instances, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "last_seen", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(instanceTTL.Seconds()))},
+		}},
+		{healthChecks, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "timestamp", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(healthCheckTTL.Seconds()))},
+		}},
 	}
+}
 
-	err := tpl.Execute(w, pageVariables)
+func ensureIndexes(ctx context.Context) error {
+	for _, spec := range expectedIndexes() {
+		if _, err := spec.coll.Indexes().CreateMany(ctx, spec.models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// homeFilter matches the mappings listed on the home page. Like the
+// sitemap it leaves out signed URLs, whose listed Path would carry the
+// signature.
+func homeFilter() bson.M {
+	filter := liveFilter()
+	filter["requires_signature"] = bson.M{"$ne": true}
+	return filter
+}
+
+func homeHandler(w http.ResponseWriter, r *http.Request) {
+	// Cancelling stops the cursor goroutine if rendering ends early.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var cur *mongo.Cursor
+	var err error
+	if cfg.DryRun {
+		cur, err = dryRunMappings()
+	} else {
+		cur, err = collection.Find(ctx, homeFilter(), options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to list URLs: %v", err)
+		http.Error(w, "Failed to list URLs", http.StatusInternalServerError)
 		return
 	}
+
+	pageVariables := PageVariables{
+		ShortURLs:  streamMappings(ctx, cur),
+		BaseURL:    publicBaseURL(r),
+		PrefillURL: r.URL.Query().Get("url"),
+		Translator: translatorFor(r),
+	}
+
+	// The form never creates signed URLs, so ?created= doesn't show them:
+	// their Path would give the signature to anyone who knows the code.
+	if code := r.URL.Query().Get("created"); code != "" {
+		if m, err := lookupCode(normalizeCode(code)); err == nil && !m.RequiresSignature {
+			pageVariables.Created = &m
+		}
+	}
+
+	w.Header().Set("Content-Language", pageVariables.Translator.Lang())
+	w.Header().Add("Vary", "Accept-Language")
+	pushAssets(w, "home.css", "home.js")
+
+	// The page is streamed, so a failure part-way can only be logged.
+	if err := tpl.Execute(w, pageVariables); err != nil {
+		log.Printf("Error rendering home page: %v", err)
+	}
+}
+
+// streamMappings decodes cur into a channel that is closed when the cursor
+// is exhausted, fails or ctx is cancelled. Each mapping handed to the page
+// counts as an impression.
+func streamMappings(ctx context.Context, cur *mongo.Cursor) <-chan URLMapping {
+	ch := make(chan URLMapping, 64)
+	go func() {
+		var shown []string
+		defer func() { go recordImpressions(shown) }()
+		defer close(ch)
+		defer cur.Close(context.Background())
+		for cur.Next(ctx) {
+			var m URLMapping
+			if err := cur.Decode(&m); err != nil {
+				log.Printf("Error decoding URL for home page: %v", err)
+				return
+			}
+			select {
+			case ch <- m:
+				shown = append(shown, m.Code)
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := cur.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Error reading URLs for home page: %v", err)
+		}
+	}()
+	return ch
 }
 
 func shortenHandler(w http.ResponseWriter, r *http.Request) {
-	mu.Lock()
-	defer mu.Unlock()
+	defer shortenLatency.since(time.Now())
+	if !requireReady(w) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		if isBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid form body", http.StatusBadRequest)
+		return
+	}
 
 	url := r.FormValue("url")
 	if url == "" {
@@ -105,65 +654,429 @@ func shortenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	shortCode := generateShortCode()
-	shortURLs[shortCode] = url
+	url, err := validateDestinationURL(url)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	headers, err := parseHeaderLines(r.FormValue("custom_headers"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Save to MongoDB
-	if err := saveToMongoDB(shortCode, url); err != nil {
-		log.Printf("Failed to save to database: %v", err)
+	// The form hands out the existing short URL for a destination it has
+	// already shortened, unless response headers make it a different link.
+	if headers == nil {
+		existing, ok, err := existingShortURL(r.Context(), url)
+		if err != nil {
+			loggerFrom(r.Context()).Error("Failed to look up existing short URL", "error", err.Error())
+		}
+		if ok {
+			pushQR(w, existing)
+			http.Redirect(w, r, "/?created="+existing.Code, http.StatusSeeOther)
+			return
+		}
+	}
+
+	mapping, err := createShortURL(r.Context(), URLMapping{URL: url, CustomHeaders: headers})
+	if err != nil {
+		loggerFrom(r.Context()).Error("Failed to save to database", "error", err.Error())
 		http.Error(w, "Failed to save to database", http.StatusInternalServerError)
 		return
 	}
+	go notifySlack(publicBaseURL(r), mapping, realIP(r))
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	pushQR(w, mapping)
+	http.Redirect(w, r, "/?created="+mapping.Code, http.StatusSeeOther)
 }
 
 func redirectHandler(w http.ResponseWriter, r *http.Request) {
-	mu.Lock()
-	defer mu.Unlock()
+	start := time.Now()
+	urlTier := priorityFree
+	var throttled time.Duration
+	defer func() {
+		d := time.Since(start)
+		redirectDuration.WithLabelValues(urlTier, requestNamespace(r)).Observe(d.Seconds())
+		// /status reports the service's own latency, not the tier's.
+		redirectLatency.observe(d - throttled)
+	}()
 
-	shortCode := r.URL.Path[1:]
-	if originalURL, ok := shortURLs[shortCode]; ok {
-		http.Redirect(w, r, originalURL, http.StatusSeeOther)
+	shortCode := r.PathValue("code")
+	if prefix := r.PathValue("prefix"); prefix != "" {
+		shortCode = prefix + "/" + shortCode
+	} else if ns, ok := namespaceForHost(r.Host); ok {
+		shortCode = ns + "/" + shortCode
+	}
+	shortCode = normalizeCode(shortCode)
+	r = r.WithContext(withCode(r.Context(), shortCode))
+	if !allowRedirect(w, r, shortCode) || !allowClicker(w, r, shortCode) {
 		return
 	}
 
-	// If not found in the map, try to find in MongoDB
-	url, err := findInMongoDB(shortCode)
-	if err == nil && url != "" {
-		http.Redirect(w, r, url, http.StatusSeeOther)
+	// A cache miss still asks MongoDB, so a code that was never created
+	// (404) can be told apart from one that is disabled or expired (410).
+	mapping, err := lookupRedirect(shortCode)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		unknownPath(w, r)
+		return
+	}
+	if err != nil {
+		writeError(w, r, "Failed to resolve code", http.StatusInternalServerError)
+		return
+	}
+	urlTier = tier(mapping)
+	if !checkSignature(w, r, mapping) {
+		return
+	}
+	if mapping.Disabled {
+		gone(w, r)
+		return
+	}
+	if mapping.Expired() {
+		expiredHandler(w, r, mapping)
+		return
+	}
+	if mapping.AliasOf != "" {
+		var ok bool
+		if mapping, shortCode, ok = resolveAlias(w, r, mapping); !ok {
+			return
+		}
+	}
+	if mapping.Type == mappingLinkInBio {
+		serveLinkInBio(w, r, mapping)
 		return
 	}
 
-	http.NotFound(w, r)
+	// The campaign and redirect type of the link that was followed win over
+	// the target's.
+	campaignID := mapping.CampaignID
+	split := mapping.TrafficSplit
+	samplingRate := mapping.samplingRate()
+	status := redirectStatus(r.Context(), mapping)
+	preview := wantsPreview(mapping)
+	mapping, chain, err := resolveChain(mapping)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Failed to resolve chain", "error", err.Error())
+		notFound(w, r)
+		return
+	}
+	if campaignID != "" {
+		mapping.CampaignID = campaignID
+	}
+
+	ctx := r.Context()
+	if wantsTrace(r) {
+		var trace *redirectTrace
+		ctx, trace = withRedirectTrace(ctx)
+		w = &traceWriter{ResponseWriter: w, trace: trace}
+	}
+
+	ev := newClickEvent(r, shortCode)
+	// With a signing key the preview's Continue link comes back here with
+	// a skip_preview token, and that visit counts as the click. Without
+	// one it leads straight to the destination, so the preview counts.
+	preview = preview && !ev.Bot && mapping.DelaySeconds == 0 && !skipPreview(r, shortCode)
+
+	st := &redirectState{mapping: mapping, split: split, bot: ev.Bot, preview: preview}
+	mapping.URL = server.runRedirectHooks(withRedirectState(ctx, st), shortCode, mapping.URL, r)
+
+	if mapping.VerifySSLPin {
+		if err := checkPins(shortCode, mapping.URL, mapping.ExpectedPins); err != nil {
+			loggerFrom(ctx).Warn("Refusing to redirect", "error", err.Error())
+			traceRule(ctx, "pin", "mismatch")
+			writeError(w, r, errCertificateMismatch.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	if mapping.Archive != nil && destinationGone(shortCode, mapping.URL) {
+		traceRule(ctx, "archive", "gone")
+		if !serveStatusPage(w, r, http.StatusGone) {
+			renderGone(w, mapping)
+		}
+		return
+	}
+
+	ev.Source = clickSource(r)
+	ev.Split = st.splitSide
+	if len(chain) > 1 {
+		ev.Chain = chain
+		traceRule(ctx, "chain", strings.Join(chain, ">"))
+	}
+	if preview && cfg.HMACSecret != "" {
+		traceRule(ctx, "preview", "always_preview")
+		renderPreview(w, mapping, skipPreviewURL(r, shortCode), false)
+		return
+	}
+	platform := st.platform
+	if mapping.AppDeepLink != "" {
+		w.Header().Add("Vary", "User-Agent")
+	}
+	var unreachable string
+	if cfg.ValidateOnRedirect && !ev.Bot && !preview && mapping.DelaySeconds == 0 && !mapping.Cloak && platform == "" {
+		unreachable = validateDestination(shortCode, mapping.URL)
+	}
+	ev.RedirectType = status
+	if ev.Bot || preview || mapping.DelaySeconds > 0 || mapping.Cloak || platform != "" || unreachable != "" {
+		ev.RedirectType = http.StatusOK
+	}
+	// Only the preview page below runs the beacon, so only it gets the
+	// tracking cookie; other redirects and bots are still recorded here.
+	beacon := cfg.ClientSideAnalytics && preview && !mapping.Cloak
+	weight, record := sampleClick(shortCode, samplingRate)
+	switch {
+	case beacon:
+		setTrackingCookie(w, ev)
+	case record:
+		if weight > 1 {
+			ev.Weight = weight
+		}
+		go recordClick(ev)
+	}
+	adminEvents.Publish(eventClicked, map[string]string{"code": shortCode, "url": mapping.URL, "country": ev.Country})
+
+	applyCustomHeaders(w, mapping.CustomHeaders)
+
+	if mapping.ShadowURL != "" {
+		if target, err := shadowURLFor(mapping.ShadowURL, r); err == nil {
+			go sendShadowRequest(shortCode, target)
+		}
+	}
+
+	if mapping.Cloak {
+		traceRule(ctx, "cloak", "proxy")
+		serveCloaked(w, r, mapping.URL)
+		return
+	}
+	if ev.Bot {
+		traceRule(ctx, "bot", "meta-refresh")
+		botRedirect(w, mapping.URL)
+		return
+	}
+	if preview {
+		traceRule(ctx, "preview", "always_preview")
+		renderPreview(w, mapping, mapping.URL, beacon)
+		return
+	}
+	if mapping.DelaySeconds > 0 {
+		traceRule(ctx, fmt.Sprintf("delay:%ds", mapping.DelaySeconds), "interstitial")
+		renderInterstitial(w, mapping)
+		return
+	}
+	if platform != "" {
+		traceRule(ctx, "deep_link", platform)
+		renderDeepLink(w, mapping, platform)
+		return
+	}
+	if unreachable != "" {
+		traceRule(ctx, "validate", unreachable)
+		renderUnreachable(w, mapping, unreachable)
+		return
+	}
+	throttled = throttleRedirect(r, urlTier)
+	http.Redirect(w, r, mapping.URL, ev.RedirectType)
+}
+
+// createShortURL persists m, generating a code when none was requested, and
+// adds it to the in-memory map once MongoDB, or failing that
+// DEAD_LETTER_FILE, has accepted it. Codes are unique within m.Prefix; the
+// stored code is qualified as "prefix/code".
+func createShortURL(ctx context.Context, m URLMapping) (URLMapping, error) {
+	m.CreatedAt = time.Now()
+	m.Prefix = normalizeCode(m.Prefix)
+	m.TargetCode = normalizeCode(m.TargetCode)
+	m.ContentHash = contentHash(m.URL)
+
+	if m.Code != "" {
+		m.Code = normalizeCode(qualifyCode(m.Prefix, m.Code))
+		if err := saveToMongoDB(m); err != nil {
+			if errors.Is(err, errCodeTaken) {
+				return m, err
+			}
+			if err := deferSave(m, err); err != nil {
+				return m, err
+			}
+		}
+	} else {
+		const maxAttempts = 5
+		for attempt := 1; ; attempt++ {
+			m.Code = qualifyCode(m.Prefix, generateShortCode())
+			err := saveToMongoDB(m)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, errCodeTaken) {
+				if err := deferSave(m, err); err != nil {
+					return m, err
+				}
+				break
+			}
+			if attempt == maxAttempts {
+				return m, fmt.Errorf("no free code after %d attempts", maxAttempts)
+			}
+		}
+	}
+
+	shortURLs.Set(m.Code, m)
+	rememberURL(m.URL)
+	publishMutation(replicateUpsert, m)
+	lifecycle.Publish(subjectCreated, m)
+	adminEvents.Publish(eventCreated, map[string]string{"code": m.Code, "url": m.URL, "target_code": m.TargetCode})
+
+	if m.URL != "" {
+		go archiveDestination(m.Code, m.URL)
+		enqueueScreenshot(m.Code, m.URL)
+	}
+
+	return m, nil
+}
+
+// previewShortURL runs the same collision checks as createShortURL, picking
+// a free generated code if none was requested, without saving anything. A
+// generated code is not reserved, so a later real creation may get another.
+func previewShortURL(ctx context.Context, m URLMapping) (URLMapping, error) {
+	m.CreatedAt = time.Now()
+	m.DryRun = true
+	m.Prefix = normalizeCode(m.Prefix)
+
+	const maxAttempts = 5
+	custom := m.Code
+	for attempt := 1; ; attempt++ {
+		code := custom
+		if code == "" {
+			code = generateShortCode()
+		}
+		code = normalizeCode(qualifyCode(m.Prefix, code))
+		exists, err := codeExists(ctx, code)
+		if err != nil {
+			return m, err
+		}
+		if !exists {
+			m.Code = code
+			return m, nil
+		}
+		if custom != "" {
+			return m, errCodeTaken
+		}
+		if attempt == maxAttempts {
+			return m, errors.New("no free short code found")
+		}
+	}
+}
+
+func qualifyCode(prefix, code string) string {
+	if prefix != "" {
+		return prefix + "/" + code
+	}
+	return code
+}
+
+func codeExists(ctx context.Context, code string) (bool, error) {
+	if _, ok := shortURLs.Get(code); ok {
+		return true, nil
+	}
+	if cfg.DryRun {
+		return false, nil
+	}
+
+	n, err := collection.CountDocuments(ctx, codeFilter(code), options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
 }
 
+// generateShortCode returns a new code in the configured style, drawing
+// again whenever one spells out a word from profanity.txt.
 func generateShortCode() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	codeLength := 6
+	return drawShortCode(rand.Intn)
+}
+
+// drawShortCode is generateShortCode with intn as the random source.
+func drawShortCode(intn func(n int) int) string {
+	for {
+		code := randomShortCode(intn)
+		if !isProfane(code) {
+			return code
+		}
+	}
+}
 
-	b := make([]byte, codeLength)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+func randomShortCode(intn func(n int) int) string {
+	if cfg.CodeStyle == codeStyleNATO {
+		return natoCode(intn)
 	}
 
-	return string(b)
+	charset := codeCharset
+	if cfg.CaseInsensitive {
+		charset = lowerCodeCharset
+	}
+	return shortener.RandomCode(intn, charset, currentCodeLength())
 }
 
-func saveToMongoDB(code, url string) error {
-	_, err := collection.InsertOne(context.Background(), URLMapping{Code: code, URL: url})
-	if err != nil {
-		log.Printf("Error saving to MongoDB: %v", err)
+// saveToMongoDB inserts m, or fails with errCodeTaken if its code exists.
+// Checking and inserting are one atomic operation, an upsert that only
+// sets fields on insert and returns the document it found, so instances
+// racing for a code can't both get it. Codes only held in memory, with
+// --dry-run or while queued in DEAD_LETTER_FILE, are checked in memory.
+func saveToMongoDB(m URLMapping) error {
+	if awaitingSave(m.Code) {
+		return errCodeTaken
 	}
+	if cfg.DryRun {
+		if _, ok := shortURLs.Get(m.Code); ok {
+			return errCodeTaken
+		}
+		return nil
+	}
+	if n, ok := store.CodeToInt(m.Code); ok {
+		m.CodeInt = &n
+	}
+	err := collection.FindOneAndUpdate(context.Background(),
+		bson.M{"code": m.Code},
+		bson.M{"$setOnInsert": m},
+		options.FindOneAndUpdate().SetUpsert(true),
+	).Err()
+	switch {
+	case err == nil:
+		return errCodeTaken
+	case errors.Is(err, mongo.ErrNoDocuments):
+		// Nothing was found before the upsert, so m was inserted.
+		return nil
+	case mongo.IsDuplicateKeyError(err):
+		// Concurrent upserts can still collide on the unique index where
+		// the server doesn't retry them.
+		return errCodeTaken
+	}
+	log.Printf("Error saving to MongoDB: %v", err)
 	return err
 }
 
-func findInMongoDB(code string) (string, error) {
-	var result URLMapping
-	err := collection.FindOne(context.Background(), bson.M{"code": code}).Decode(&result)
-	if err != nil {
-		log.Printf("Error finding URL in MongoDB: %v", err)
-		return "", err
+// mongoLookups collapses concurrent cache misses for the same code into a
+// single MongoDB query whose result every waiting caller shares.
+var mongoLookups singleflight.Group
+
+func findInMongoDB(code string) (URLMapping, error) {
+	if cfg.DryRun {
+		if m, ok := shortURLs.Get(code); ok {
+			return m, nil
+		}
+		return URLMapping{}, mongo.ErrNoDocuments
 	}
-	return result.URL, nil
+	v, err, _ := mongoLookups.Do(code, func() (interface{}, error) {
+		var result URLMapping
+		err := collection.FindOne(context.Background(), codeFilter(code)).Decode(&result)
+		if err != nil {
+			log.Printf("Error finding URL in MongoDB: %v", err)
+			return URLMapping{}, err
+		}
+		return result, nil
+	})
+	return v.(URLMapping), err
 }
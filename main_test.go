@@ -0,0 +1,112 @@
+package urlshortener
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testAdminPassword = "test-admin-password"
+	testHMACSecret    = "test-hmac-secret"
+)
+
+// testHandler is the server's handler, built once since routes() registers
+// metrics and reserves codes.
+var testHandler http.Handler
+
+// TestMain runs the tests against a --dry-run server, which keeps short
+// URLs in memory, so that they need no MongoDB.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "urlshortener-test-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("ADMIN_PASSWORD", testAdminPassword)
+	os.Setenv("SHORTURL_HMAC_SECRET", testHMACSecret)
+	os.Setenv("DEAD_LETTER_FILE", filepath.Join(dir, "dead_letter.log"))
+	cfg = loadConfig()
+	cfg.DryRun = true
+	startDryRun()
+	testHandler = pingMiddleware(impersonationMiddleware(routes()))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(testHandler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// mustCreate saves m as createShortURL does for the API.
+func mustCreate(t *testing.T, m URLMapping) URLMapping {
+	t.Helper()
+	m, err := createShortURL(context.Background(), m)
+	if err != nil {
+		t.Fatalf("createShortURL(%q): %v", m.URL, err)
+	}
+	return m
+}
+
+func TestHomeHidesSignedURLs(t *testing.T) {
+	srv := newTestServer(t)
+	plain := mustCreate(t, URLMapping{URL: "https://example.com/home-plain"})
+	signed := mustCreate(t, URLMapping{URL: "https://example.com/home-signed", RequiresSignature: true})
+
+	tests := []struct {
+		name   string
+		path   string
+		want   []string
+		absent []string
+	}{
+		{"listing", "/", []string{"/" + plain.Code}, []string{signed.Code, signCode(signed.Code)}},
+		{"created plain", "/?created=" + plain.Code, []string{qrPath(plain)}, nil},
+		{"created signed", "/?created=" + signed.Code, nil, []string{signed.Code, signCode(signed.Code)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("GET %s doesn't contain %q", tt.path, s)
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(string(body), s) {
+					t.Errorf("GET %s contains %q", tt.path, s)
+				}
+			}
+		})
+	}
+}
+
+func TestQRPathSigned(t *testing.T) {
+	srv := newTestServer(t)
+	signed := mustCreate(t, URLMapping{URL: "https://example.com/qr-signed", RequiresSignature: true})
+
+	resp, err := http.Get(srv.URL + qrPath(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET %s = %d, want 200", qrPath(signed), resp.StatusCode)
+	}
+}
@@ -0,0 +1,42 @@
+package urlshortener
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespaceLabel is on every counter and histogram. With
+// --enable-namespace-metrics it holds the namespace that the request's
+// Host maps to, or the URL's for background work, so a multi-tenant
+// deployment can scrape and alert per tenant. Every namespace multiplies
+// each metric's series, and a histogram has one per bucket, so with many
+// namespaces this can slow Prometheus down; only custom domains add
+// request namespaces, but link health counts every namespace with a
+// broken link. Without the flag the label is always empty, which
+// Prometheus stores as no label at all, and the series are the same as
+// before it existed.
+const namespaceLabel = "namespace"
+
+// metricNamespace is the namespace label value for ns.
+func metricNamespace(ns string) string {
+	if !cfg.NamespaceMetrics {
+		return ""
+	}
+	return ns
+}
+
+// requestNamespace is the namespace label value for r, from its Host.
+func requestNamespace(r *http.Request) string {
+	if !cfg.NamespaceMetrics {
+		return ""
+	}
+	ns, _ := namespaceForHost(r.Host)
+	return ns
+}
+
+var panicsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "panics_total",
+	Help: "Handler panics caught by recoveryMiddleware.",
+}, []string{namespaceLabel})
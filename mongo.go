@@ -0,0 +1,219 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	mongoPingTimeout      = 5 * time.Second
+	mongoProbeInterval    = 10 * time.Second
+	mongoFailureThreshold = 3
+)
+
+// Circuit breaker states, as published in eventBreaker.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// mongoFailover tracks which of the configured URIs is in use. After
+// mongoFailureThreshold failed health probes in a row the breaker opens;
+// probeOpenMongo decides when it closes again.
+var mongoFailover = struct {
+	sync.Mutex
+	uris     []string
+	active   int
+	failures int
+	state    string
+	openedAt time.Time
+}{state: breakerClosed}
+
+// splitMongoURIs splits a comma-separated MONGO_URI into individual URIs.
+// Commas inside a single URI (a host list) are preserved: a new URI only
+// starts where the next element carries a mongodb scheme.
+func splitMongoURIs(s string) []string {
+	var uris []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(uris) == 0 || strings.HasPrefix(part, "mongodb://") || strings.HasPrefix(part, "mongodb+srv://") {
+			uris = append(uris, part)
+		} else {
+			uris[len(uris)-1] += "," + part
+		}
+	}
+	return uris
+}
+
+// redactURI hides credentials so URIs can be logged.
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "<invalid URI>"
+	}
+	return u.Redacted()
+}
+
+func dial(ctx context.Context, uri string) (*mongo.Client, error) {
+	c, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, mongoPingTimeout)
+	defer cancel()
+	if err := c.Ping(pingCtx, nil); err != nil {
+		c.Disconnect(context.Background())
+		return nil, err
+	}
+	return c, nil
+}
+
+// connectMongo tries each URI in order and activates the first one that
+// answers a ping.
+func connectMongo(ctx context.Context, uris []string) error {
+	mongoFailover.uris = uris
+	var lastErr error
+	for i, uri := range uris {
+		c, err := dial(ctx, uri)
+		if err != nil {
+			log.Printf("MongoDB %s unavailable: %v", redactURI(uri), err)
+			lastErr = err
+			continue
+		}
+		mongoFailover.active = i
+		useClient(c)
+		log.Printf("Using MongoDB %s", redactURI(uri))
+		return nil
+	}
+	return lastErr
+}
+
+// useClient points the package-level collections at c.
+func useClient(c *mongo.Client) {
+	client = c
+	database := client.Database("urlshortener")
+	collection = database.Collection("urls")
+	clickEvents = database.Collection("click_events")
+	jobs = database.Collection("jobs")
+	statusPages = database.Collection("status_pages")
+	shadowEvents = database.Collection("shadow_events")
+	customDomains = database.Collection("custom_domains")
+	sessions = database.Collection("sessions")
+	users = database.Collection("users")
+	auditLog = database.Collection("audit_log")
+	complianceLog = database.Collection("compliance_log")
+	linkInBio = database.Collection("link_in_bio")
+	settings = database.Collection("settings")
+	feeds = database.Collection("feeds")
+	campaigns = database.Collection("campaigns")
+	urlCollections = database.Collection("collections")
+	urlDuplicates = database.Collection("url_duplicates")
+	instances = database.Collection("instances")
+	namespaceSettings = database.Collection("namespaces")
+	fraudEvents = database.Collection("fraud_events")
+	blockedDomains = database.Collection("blocked_domains")
+	healthChecks = database.Collection("health_checks")
+	collisionStats = database.Collection("collision_stats")
+}
+
+// watchMongo probes the active client and fails over to the next URI once
+// the breaker opens. With a single URI the driver reconnects on its own;
+// the breaker only tracks when it has.
+func watchMongo(ctx context.Context) {
+	ticker := time.NewTicker(mongoProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			probeMongo(ctx)
+		}
+	}
+}
+
+func probeMongo(ctx context.Context) {
+	mongoFailover.Lock()
+	defer mongoFailover.Unlock()
+
+	if mongoFailover.state == breakerOpen {
+		probeOpenMongo(ctx)
+		return
+	}
+
+	uri := redactURI(mongoFailover.uris[mongoFailover.active])
+	pingCtx, cancel := context.WithTimeout(ctx, mongoPingTimeout)
+	defer cancel()
+	if err := client.Ping(pingCtx, nil); err != nil {
+		mongoFailover.failures++
+		log.Printf("MongoDB %s health probe failed (%d/%d): %v", uri, mongoFailover.failures, mongoFailureThreshold, err)
+		if mongoFailover.failures >= mongoFailureThreshold {
+			mongoFailover.state = breakerOpen
+			mongoFailover.openedAt = time.Now()
+			slog.Warn("MongoDB connection lost", "uri", uri, "failures", mongoFailover.failures, "error", err.Error())
+			adminEvents.Publish(eventBreaker, map[string]string{"state": breakerOpen, "uri": uri})
+		}
+		return
+	}
+	mongoFailover.failures = 0
+}
+
+// probeOpenMongo tries to close an open breaker. The connection, or the
+// next URI's when there are several, must first answer a ping; the breaker
+// then goes half-open and lets one real query through, closing only if
+// that succeeds too. A ping can pass while queries still fail, e.g. while
+// a replica set has no primary.
+func probeOpenMongo(ctx context.Context) {
+	if len(mongoFailover.uris) > 1 {
+		next := (mongoFailover.active + 1) % len(mongoFailover.uris)
+		uri := mongoFailover.uris[next]
+		mongoFailover.active = next
+		c, err := dial(ctx, uri)
+		if err != nil {
+			log.Printf("Failover to MongoDB %s failed: %v", redactURI(uri), err)
+			return
+		}
+		old := client
+		useClient(c)
+		log.Printf("Failed over to MongoDB %s", redactURI(uri))
+		// Give in-flight requests on the old client time to finish.
+		time.AfterFunc(time.Minute, func() { old.Disconnect(context.Background()) })
+	} else {
+		pingCtx, cancel := context.WithTimeout(ctx, mongoPingTimeout)
+		defer cancel()
+		if err := client.Ping(pingCtx, nil); err != nil {
+			log.Printf("MongoDB %s still unavailable: %v", redactURI(mongoFailover.uris[mongoFailover.active]), err)
+			return
+		}
+	}
+
+	uri := redactURI(mongoFailover.uris[mongoFailover.active])
+	mongoFailover.state = breakerHalfOpen
+	adminEvents.Publish(eventBreaker, map[string]string{"state": breakerHalfOpen, "uri": uri})
+
+	trialCtx, cancel := context.WithTimeout(ctx, mongoPingTimeout)
+	defer cancel()
+	err := collection.FindOne(trialCtx, bson.M{}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		mongoFailover.state = breakerOpen
+		log.Printf("MongoDB %s answered a ping but failed a query, keeping the breaker open: %v", uri, err)
+		adminEvents.Publish(eventBreaker, map[string]string{"state": breakerOpen, "uri": uri})
+		return
+	}
+
+	mongoFailover.state = breakerClosed
+	mongoFailover.failures = 0
+	slog.Info("MongoDB connection recovered", "uri", uri, "outage", time.Since(mongoFailover.openedAt).Round(time.Second).String())
+	adminEvents.Publish(eventBreaker, map[string]string{"state": breakerClosed, "uri": uri})
+}
@@ -0,0 +1,154 @@
+package urlshortener
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxMutationLine bounds a line of the mutation log when replaying; a
+// mapping with an archived page and a preview stays well below it.
+const maxMutationLine = 4 << 20
+
+// mutationRecord is a line of the mutation log. Mapping is the mapping in
+// MongoDB's relaxed Extended JSON, which keeps the fields the API hides,
+// such as manage_token, so a replay restores it exactly. Deletes carry
+// only the code.
+type mutationRecord struct {
+	Time    time.Time       `json:"time"`
+	Op      string          `json:"op"`
+	Code    string          `json:"code"`
+	Mapping json.RawMessage `json:"mapping,omitempty"`
+}
+
+// MutationLog appends a mutationRecord per URL mapping write to w, one
+// JSON object per line, for tailing, shipping to a log aggregator, or
+// replaying with replayMutationLog.
+type MutationLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// mutationLog is nil without --mutation-log-path; its methods are no-ops
+// then.
+var mutationLog *MutationLog
+
+func NewMutationLog(w io.Writer) *MutationLog {
+	return &MutationLog{w: w}
+}
+
+// openMutationLog opens --mutation-log-path for appending.
+func openMutationLog() {
+	if cfg.MutationLogPath == "" {
+		return
+	}
+	f, err := os.OpenFile(cfg.MutationLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Fatalf("Failed to open mutation log: %v", err)
+	}
+	mutationLog = NewMutationLog(f)
+	log.Printf("Appending URL mutations to %s", cfg.MutationLogPath)
+}
+
+// Append records op, replicateUpsert or replicateDelete, of m. A record is
+// written with a single Write, so lines from concurrent writers don't
+// interleave.
+func (l *MutationLog) Append(op string, m URLMapping) {
+	if l == nil {
+		return
+	}
+	rec := mutationRecord{Time: time.Now().UTC(), Op: op, Code: m.Code}
+	if op != replicateDelete {
+		b, err := bson.MarshalExtJSON(m, false, false)
+		if err != nil {
+			log.Printf("Failed to encode mutation of %s: %v", m.Code, err)
+			return
+		}
+		rec.Mapping = b
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("Failed to encode mutation of %s: %v", m.Code, err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to append mutation of %s: %v", m.Code, err)
+	}
+}
+
+// publishMutation announces a write of m, already made in MongoDB, to the
+// other regions and the mutation log.
+func publishMutation(op string, m URLMapping) {
+	replicator.Publish(op, m)
+	mutationLog.Append(op, m)
+}
+
+// replayMutationLog hydrates shortURLs from --mutation-log-path with
+// --replay-on-start. The warm-up from MongoDB still follows and wins; the
+// replay serves redirects until then, and is all there is with --dry-run.
+// A missing file is an empty log.
+func replayMutationLog() {
+	if !cfg.ReplayOnStart {
+		return
+	}
+	f, err := os.Open(cfg.MutationLogPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to open mutation log: %v", err)
+	}
+	defer f.Close()
+	n, err := replayMutations(f)
+	if err != nil {
+		log.Fatalf("Failed to replay mutation log: %v", err)
+	}
+	log.Printf("Replayed %d mutations from %s", n, cfg.MutationLogPath)
+}
+
+// replayMutations applies the records in r to shortURLs in order. A torn
+// last line, left by a crash mid-write, is skipped.
+func replayMutations(r io.Reader) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), maxMutationLine)
+	n, line := 0, 0
+	var torn error
+	for sc.Scan() {
+		line++
+		if torn != nil {
+			return n, torn
+		}
+		var rec mutationRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			torn = fmt.Errorf("line %d: %w", line, err)
+			continue
+		}
+		switch rec.Op {
+		case replicateUpsert:
+			var m URLMapping
+			if err := bson.UnmarshalExtJSON(rec.Mapping, false, &m); err != nil {
+				return n, fmt.Errorf("line %d: %w", line, err)
+			}
+			shortURLs.Set(m.Code, m)
+		case replicateDelete:
+			shortURLs.Delete(rec.Code)
+		default:
+			return n, fmt.Errorf("line %d: unknown op %q", line, rec.Op)
+		}
+		n++
+	}
+	if torn != nil {
+		log.Printf("Skipping torn last line of the mutation log: %v", torn)
+	}
+	return n, sc.Err()
+}
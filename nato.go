@@ -0,0 +1,30 @@
+package urlshortener
+
+import "strings"
+
+// Values for --code-style.
+const (
+	codeStyleRandom = "random"
+	codeStyleNATO   = "nato"
+)
+
+// natoAlphabet is the phonetic alphabet used for --code-style=nato codes.
+const natoAlphabet = "Alpha Bravo Charlie Delta Echo Foxtrot Golf Hotel India Juliet Kilo Lima Mike " +
+	"November Oscar Papa Quebec Romeo Sierra Tango Uniform Victor Whiskey Xray Yankee Zulu"
+
+// natoCodeWords is the number of words in a generated NATO code, giving
+// 26^3 = 17,576 codes.
+const natoCodeWords = 3
+
+var natoWords = strings.Fields(natoAlphabet)
+
+// natoCode returns a code such as "Alpha-Bravo-Charlie" that can be read
+// out over the phone without spelling. With --case-insensitive it is
+// lower-cased like any other code.
+func natoCode(intn func(n int) int) string {
+	words := make([]string, natoCodeWords)
+	for i := range words {
+		words[i] = natoWords[intn(len(natoWords))]
+	}
+	return normalizeCode(strings.Join(words, "-"))
+}
@@ -0,0 +1,134 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Lifecycle subjects. created and expired carry a URLMapping, clicked a
+// ClickEvent and deleted a deletedEvent, all as JSON.
+const (
+	subjectCreated = "urlshortener.created"
+	subjectClicked = "urlshortener.clicked"
+	subjectDeleted = "urlshortener.deleted"
+	subjectExpired = "urlshortener.expired"
+)
+
+const (
+	expirySweepInterval = time.Minute
+
+	// expirySweepLookback bounds how far back the sweep looks, so enabling
+	// NATS doesn't announce every URL that ever expired.
+	expirySweepLookback = 24 * time.Hour
+)
+
+type deletedEvent struct {
+	Code      string    `json:"code"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type natsPublisher struct {
+	conn *nats.Conn
+}
+
+// lifecycle is nil when NATS_URL is unset; its methods are no-ops then.
+var lifecycle *natsPublisher
+
+// startNATS connects to NATS_URL and starts announcing expiries. The
+// connection is retried in the background, and the client buffers
+// events published meanwhile.
+func startNATS(ctx context.Context) {
+	if cfg.NATSURL == "" {
+		return
+	}
+	conn, err := nats.Connect(cfg.NATSURL,
+		nats.Name("urlshortener"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("NATS disconnected: %v", err)
+			}
+		}),
+	)
+	if err != nil {
+		log.Fatalf("Invalid NATS_URL: %v", err)
+	}
+	lifecycle = &natsPublisher{conn: conn}
+	go func() {
+		<-ctx.Done()
+		conn.Drain()
+	}()
+
+	go func() {
+		ticker := time.NewTicker(expirySweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := publishExpired(ctx); err != nil {
+					log.Printf("Expiry sweep failed: %v", err)
+				}
+			}
+		}
+	}()
+	log.Printf("Publishing lifecycle events to NATS")
+}
+
+// Publish sends v on subject without blocking the caller.
+func (p *natsPublisher) Publish(subject string, v interface{}) {
+	if p == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", subject, err)
+		return
+	}
+	if err := p.conn.Publish(subject, data); err != nil {
+		log.Printf("Failed to publish %s event: %v", subject, err)
+	}
+}
+
+func (p *natsPublisher) PublishDeleted(code string) {
+	p.Publish(subjectDeleted, deletedEvent{Code: code, DeletedAt: time.Now()})
+}
+
+// publishExpired announces URLs whose expires_at has passed. Expiry is
+// only a timestamp, so it is found by polling; expired_published_at is
+// claimed first so that one instance announces each URL once.
+func publishExpired(ctx context.Context) error {
+	now := time.Now()
+	cur, err := collection.Find(ctx, bson.M{
+		"expires_at":           bson.M{"$lte": now, "$gt": now.Add(-expirySweepLookback)},
+		"expired_published_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var m URLMapping
+		if err := cur.Decode(&m); err != nil {
+			return err
+		}
+		res, err := collection.UpdateOne(ctx,
+			bson.M{"code": m.Code, "expired_published_at": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"expired_published_at": now}},
+		)
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 1 {
+			lifecycle.Publish(subjectExpired, m)
+		}
+	}
+	return cur.Err()
+}
@@ -0,0 +1,316 @@
+package urlshortener
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+var errIdentityLinked = errors.New("this login is already linked to another account")
+
+// Identity is one provider login linked to a user.
+type Identity struct {
+	Provider       string `bson:"provider" json:"provider"`
+	ProviderUserID string `bson:"provider_user_id" json:"provider_user_id"`
+	Email          string `bson:"email,omitempty" json:"email,omitempty"`
+}
+
+type User struct {
+	ID         string     `bson:"_id" json:"id"`
+	Name       string     `bson:"name,omitempty" json:"name,omitempty"`
+	Email      string     `bson:"email,omitempty" json:"email,omitempty"`
+	Identities []Identity `bson:"identities,omitempty" json:"identities"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+
+	// DefaultRedirectType applies to URLs the user owns that set none
+	// themselves; see redirectStatus.
+	DefaultRedirectType int `bson:"default_redirect_type,omitempty" json:"default_redirect_type,omitempty"`
+}
+
+// oauthProfile is what a provider tells us about the user. Email is only
+// set when the provider has verified it.
+type oauthProfile struct {
+	ID, Name, Email string
+}
+
+type oauthProvider struct {
+	endpoint oauth2.Endpoint
+	scopes   []string
+	clientID string
+	secret   string
+	profile  func(ctx context.Context, c *http.Client) (oauthProfile, error)
+}
+
+var oauthProviders = map[string]*oauthProvider{
+	"google": {endpoint: endpoints.Google, scopes: []string{"openid", "email", "profile"}, profile: googleProfile},
+	"github": {endpoint: endpoints.GitHub, scopes: []string{"read:user", "user:email"}, profile: githubProfile},
+}
+
+// configureOAuth fills in client credentials from cfg. Providers without a
+// client ID stay disabled.
+func configureOAuth() {
+	oauthProviders["google"].clientID = cfg.OAuth2GoogleClientID
+	oauthProviders["google"].secret = cfg.OAuth2GoogleClientSecret
+	oauthProviders["github"].clientID = cfg.OAuth2GitHubClientID
+	oauthProviders["github"].secret = cfg.OAuth2GitHubClientSecret
+}
+
+func (p *oauthProvider) config(r *http.Request) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.clientID,
+		ClientSecret: p.secret,
+		Endpoint:     p.endpoint,
+		Scopes:       p.scopes,
+		RedirectURL:  publicBaseURL(r) + "/auth/callback",
+	}
+}
+
+func enabledProvider(name string) (*oauthProvider, bool) {
+	p, ok := oauthProviders[name]
+	return p, ok && p.clientID != ""
+}
+
+// oauthLoginHandler sends the browser to ?provider=google|github. The state
+// parameter names the provider and is checked against a cookie on return.
+func oauthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("provider")
+	p, ok := enabledProvider(name)
+	if !ok {
+		http.Error(w, "Unknown or disabled login provider", http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	state := name + "." + hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/auth/",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		// Lax, not Strict: the provider sends the browser back with a
+		// cross-site navigation.
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, p.config(r).AuthCodeURL(state), http.StatusFound)
+}
+
+// oauthCallbackHandler finishes a login: it exchanges the code, finds or
+// creates the user for the provider identity and starts a session. A user
+// who is already logged in gets the identity linked to their account.
+func oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	c, err := r.Cookie(oauthStateCookie)
+	if err != nil || state == "" || c.Value != state {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/auth/", MaxAge: -1, HttpOnly: true, Secure: true})
+
+	name, _, _ := strings.Cut(state, ".")
+	p, ok := enabledProvider(name)
+	if !ok {
+		http.Error(w, "Unknown or disabled login provider", http.StatusBadRequest)
+		return
+	}
+	if e := r.URL.Query().Get("error"); e != "" {
+		http.Error(w, "Login was not completed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	conf := p.config(r)
+	token, err := conf.Exchange(ctx, r.URL.Query().Get("code"))
+	if err != nil {
+		log.Printf("Failed to exchange %s login code: %v", name, err)
+		http.Error(w, "Failed to complete login", http.StatusBadGateway)
+		return
+	}
+	profile, err := p.profile(ctx, conf.Client(ctx, token))
+	if err != nil {
+		log.Printf("Failed to fetch %s profile: %v", name, err)
+		http.Error(w, "Failed to complete login", http.StatusBadGateway)
+		return
+	}
+
+	var current string
+	if s, ok := currentSession(r); ok {
+		current = s.UserID
+	}
+	user, err := upsertOAuthUser(ctx, current, Identity{Provider: name, ProviderUserID: profile.ID, Email: profile.Email}, profile)
+	if err != nil {
+		if errors.Is(err, errIdentityLinked) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to save %s user: %v", name, err)
+		http.Error(w, "Failed to complete login", http.StatusInternalServerError)
+		return
+	}
+
+	if err := startSession(ctx, w, user.ID, isOAuthAdmin(profile.Email)); err != nil {
+		log.Printf("Failed to start session: %v", err)
+		http.Error(w, "Failed to complete login", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/app", http.StatusSeeOther)
+}
+
+// upsertOAuthUser returns the user owning id, creating one if the identity
+// is new. With linkTo set the identity is added to that user instead.
+func upsertOAuthUser(ctx context.Context, linkTo string, id Identity, profile oauthProfile) (User, error) {
+	match := bson.M{"identities": bson.M{"$elemMatch": bson.M{
+		"provider":         id.Provider,
+		"provider_user_id": id.ProviderUserID,
+	}}}
+
+	var existing User
+	err := users.FindOne(ctx, match).Decode(&existing)
+	switch {
+	case err == nil:
+		if linkTo != "" && existing.ID != linkTo {
+			return User{}, errIdentityLinked
+		}
+		return existing, nil
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return User{}, err
+	}
+
+	if linkTo != "" {
+		var linked User
+		err := users.FindOneAndUpdate(ctx,
+			bson.M{"_id": linkTo},
+			bson.M{"$push": bson.M{"identities": id}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&linked)
+		if err == nil || !errors.Is(err, mongo.ErrNoDocuments) {
+			return linked, err
+		}
+		// No such user: the session belongs to the Basic Auth admin or
+		// to an erased account, so create a new one.
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return User{}, err
+	}
+	user := User{
+		ID:         hex.EncodeToString(b),
+		Name:       profile.Name,
+		Email:      profile.Email,
+		Identities: []Identity{id},
+		CreatedAt:  time.Now(),
+	}
+	if _, err := users.InsertOne(ctx, user); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// isOAuthAdmin reports whether a verified email is listed in
+// OAUTH2_ADMIN_EMAILS. Other users get a session but no admin access.
+func isOAuthAdmin(email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, a := range cfg.OAuth2AdminEmails {
+		if strings.EqualFold(a, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func getJSON(ctx context.Context, c *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func googleProfile(ctx context.Context, c *http.Client) (oauthProfile, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Name          string `json:"name"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := getJSON(ctx, c, "https://openidconnect.googleapis.com/v1/userinfo", &info); err != nil {
+		return oauthProfile{}, err
+	}
+	if info.Sub == "" {
+		return oauthProfile{}, errors.New("userinfo has no subject")
+	}
+	p := oauthProfile{ID: info.Sub, Name: info.Name}
+	if info.EmailVerified {
+		p.Email = info.Email
+	}
+	return p, nil
+}
+
+func githubProfile(ctx context.Context, c *http.Client) (oauthProfile, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, c, "https://api.github.com/user", &user); err != nil {
+		return oauthProfile{}, err
+	}
+	if user.ID == 0 {
+		return oauthProfile{}, errors.New("user has no id")
+	}
+	p := oauthProfile{ID: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if p.Name == "" {
+		p.Name = user.Login
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, c, "https://api.github.com/user/emails", &emails); err != nil {
+		return oauthProfile{}, err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			p.Email = e.Email
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,141 @@
+package urlshortener
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LinkPreview is the Open Graph data scraped from a destination when the
+// short URL is created.
+type LinkPreview struct {
+	Title       string `bson:"title,omitempty" json:"title,omitempty"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	Image       string `bson:"image,omitempty" json:"image,omitempty"`
+	SiteName    string `bson:"site_name,omitempty" json:"site_name,omitempty"`
+}
+
+var ogTpl = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta http-equiv="refresh" content="0; url={{.Path}}">
+    <meta property="og:type" content="website">
+    <meta property="og:url" content="{{.ShareURL}}">
+    {{with .Preview}}
+    {{if .Title}}<meta property="og:title" content="{{.Title}}">
+    <meta name="twitter:title" content="{{.Title}}">{{end}}
+    {{if .Description}}<meta property="og:description" content="{{.Description}}">
+    <meta name="twitter:description" content="{{.Description}}">{{end}}
+    {{if .SiteName}}<meta property="og:site_name" content="{{.SiteName}}">{{end}}
+    {{if .Image}}<meta property="og:image" content="{{.Image}}">
+    <meta name="twitter:image" content="{{.Image}}">
+    <meta name="twitter:card" content="summary_large_image">{{else}}<meta name="twitter:card" content="summary">{{end}}
+    {{end}}
+</head>
+</html>
+`))
+
+// ogPath is the sharing URL path for m, whose page carries the
+// destination's preview for social media crawlers.
+func ogPath(m URLMapping) string {
+	return "/og/" + m.Code
+}
+
+// ogHandler serves only the Open Graph meta tags for a short URL, so link
+// unfurlers show the destination's title and image. Browsers that land
+// here are sent on to the short URL itself.
+func ogHandler(w http.ResponseWriter, r *http.Request) {
+	mapping, err := lookupCode(codeParam(r))
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Failed to resolve code", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || mapping.Expired() || mapping.Disabled {
+		notFound(w, r)
+		return
+	}
+	if !checkSignature(w, r, mapping) {
+		return
+	}
+
+	preview := linkPreview(mapping)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	err = ogTpl.Execute(w, struct {
+		Path     string
+		ShareURL string
+		Preview  *LinkPreview
+	}{mapping.Path(), publicBaseURL(r) + ogPath(mapping), &preview})
+	if err != nil {
+		log.Printf("Error rendering Open Graph page for %s: %v", mapping.Code, err)
+	}
+}
+
+// linkPreview is m's Open Graph data, or the title and description of its
+// archive snapshot when the destination had none.
+func linkPreview(m URLMapping) LinkPreview {
+	switch {
+	case m.Preview != nil:
+		return *m.Preview
+	case m.Archive != nil:
+		return LinkPreview{Title: m.Archive.Title, Description: m.Archive.Description}
+	}
+	return LinkPreview{}
+}
+
+// PreviewMetadata is what GET /api/v1/{code}/preview tells link unfurlers
+// about a short URL.
+type PreviewMetadata struct {
+	Code           string    `json:"code"`
+	ShortURL       string    `json:"short_url"`
+	DestinationURL string    `json:"destination_url"`
+	Title          string    `json:"title,omitempty"`
+	Description    string    `json:"description,omitempty"`
+	ImageURL       string    `json:"image_url,omitempty"`
+	Clicks         int64     `json:"clicks"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// apiPreviewHandler is the JSON counterpart of the Open Graph page, for
+// unfurlers that would rather not follow the redirect.
+func apiPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	// The preview, not the API response, is what should be indexed.
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	mapping, err := lookupCode(codeParam(r))
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Failed to resolve code", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || mapping.Expired() || mapping.Disabled {
+		http.NotFound(w, r)
+		return
+	}
+	if !checkSignature(w, r, mapping) {
+		return
+	}
+	final, err := resolveDestination(mapping)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	preview := linkPreview(mapping)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, PreviewMetadata{
+		Code:           mapping.Code,
+		ShortURL:       publicBaseURL(r) + mapping.Path(),
+		DestinationURL: final.URL,
+		Title:          preview.Title,
+		Description:    preview.Description,
+		ImageURL:       preview.Image,
+		Clicks:         mapping.Clicks,
+		CreatedAt:      mapping.CreatedAt,
+	})
+}
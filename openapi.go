@@ -0,0 +1,17 @@
+package urlshortener
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes the JSON API. It is also the input for the client
+// SDKs generated by `make generate-sdk`.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,117 @@
+package urlshortener
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pinCheckTimeout = 2 * time.Second
+
+	// pinTTL is how long a matching pin is trusted before the destination
+	// is dialled again. It is short: a swapped certificate should be
+	// caught within a minute.
+	pinTTL = time.Minute
+)
+
+// errCertificateMismatch is the body of the 502 answered instead of a
+// redirect whose destination fails its pin check.
+var errCertificateMismatch = errors.New("CERTIFICATE_MISMATCH")
+
+var errInvalidPin = errors.New("expected_pins must be base64 SHA-256 hashes of a SubjectPublicKeyInfo, optionally prefixed with sha256/")
+
+// spkiHash is the pin of cert: the base64 SHA-256 of its
+// SubjectPublicKeyInfo, as in HPKP and `openssl x509 -pubkey | openssl
+// pkey -pubin -outform der | openssl dgst -sha256 -binary | base64`.
+func spkiHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// normalizePins validates pins and strips their sha256/ prefix.
+func normalizePins(pins []string) ([]string, error) {
+	out := make([]string, 0, len(pins))
+	for _, p := range pins {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "sha256/")
+		if b, err := base64.StdEncoding.DecodeString(p); err != nil || len(b) != sha256.Size {
+			return nil, errInvalidPin
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// validatePinning checks the pinning fields of a new URL.
+func validatePinning(dest string, verify bool, pins []string) ([]string, error) {
+	pins, err := normalizePins(pins)
+	if err != nil {
+		return nil, err
+	}
+	if verify {
+		if len(pins) == 0 {
+			return nil, errors.New("verify_ssl_pin needs at least one expected_pins entry")
+		}
+		if !strings.HasPrefix(dest, "https://") {
+			return nil, errors.New("verify_ssl_pin needs an https destination")
+		}
+	}
+	return pins, nil
+}
+
+var verifiedPins sync.Map // code -> verifiedPin
+
+type verifiedPin struct {
+	url     string
+	checked time.Time
+}
+
+// checkPins dials dest and succeeds if a certificate in the chain it
+// presents has one of pins; pinning a CA or intermediate survives leaf
+// renewals. The chain must also pass normal verification, so an expired
+// or misissued certificate fails even with a matching key.
+func checkPins(code, dest string, pins []string) error {
+	if v, ok := verifiedPins.Load(code); ok {
+		if v := v.(verifiedPin); v.url == dest && time.Since(v.checked) < pinTTL {
+			return nil
+		}
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "https" {
+		return fmt.Errorf("%s is not an https URL", dest)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pinCheckTimeout)
+	defer cancel()
+	// Pins come with anonymous creates, so the host must be public like
+	// any other destination the server connects to.
+	dialer := &tls.Dialer{NetDialer: publicDialer, Config: &tls.Config{ServerName: u.Hostname()}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, cert := range conn.(*tls.Conn).ConnectionState().PeerCertificates {
+		if slices.Contains(pins, spkiHash(cert)) {
+			verifiedPins.Store(code, verifiedPin{url: dest, checked: time.Now()})
+			return nil
+		}
+	}
+	return fmt.Errorf("no certificate presented by %s matches its pins", u.Host)
+}
@@ -0,0 +1,32 @@
+package urlshortener
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckPinsRefusesPrivateAddresses(t *testing.T) {
+	internal := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(internal.Close)
+	pin := spkiHash(internal.Certificate())
+
+	tests := []struct {
+		name string
+		dest string
+	}{
+		{"loopback", internal.URL + "/"},
+		{"localhost", strings.Replace(internal.URL, "127.0.0.1", "localhost", 1) + "/"},
+		{"private", "https://10.0.0.1:8443/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPins("pin-test-"+tt.name, tt.dest, []string{pin})
+			if !errors.Is(err, errPrivateAddress) {
+				t.Errorf("checkPins(%q) = %v, want %v", tt.dest, err, errPrivateAddress)
+			}
+		})
+	}
+}
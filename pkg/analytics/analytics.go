@@ -0,0 +1,49 @@
+// Package analytics records the visits of short URLs. Mongo writes them as
+// the server's click_events documents, so its analytics endpoints count
+// visits recorded by an embedding application too.
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Click is a visit of a short URL. The bson names are the server's, see
+// ClickEvent.
+type Click struct {
+	Code      string    `bson:"code" json:"code"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+	IP        string    `bson:"ip" json:"ip"`
+	UserAgent string    `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Referrer  string    `bson:"referrer,omitempty" json:"referrer,omitempty"`
+	Bot       bool      `bson:"bot" json:"bot"`
+}
+
+// Recorder records clicks. Implementations must be safe for concurrent use.
+type Recorder interface {
+	Record(ctx context.Context, c Click) error
+}
+
+// RecorderFunc adapts a function to a Recorder.
+type RecorderFunc func(ctx context.Context, c Click) error
+
+func (f RecorderFunc) Record(ctx context.Context, c Click) error {
+	return f(ctx, c)
+}
+
+// Mongo is a Recorder inserting into a collection, such as the server's
+// "click_events".
+type Mongo struct {
+	coll *mongo.Collection
+}
+
+func NewMongo(coll *mongo.Collection) *Mongo {
+	return &Mongo{coll: coll}
+}
+
+func (m *Mongo) Record(ctx context.Context, c Click) error {
+	_, err := m.coll.InsertOne(ctx, c)
+	return err
+}
@@ -0,0 +1,28 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SQL is a Recorder inserting into the click_events table that the SQL
+// stores of package store create.
+type SQL struct {
+	db     *sql.DB
+	insert string
+}
+
+// NewSQLite records to the database of a store.SQLite.
+func NewSQLite(db *sql.DB) *SQL {
+	return &SQL{db: db, insert: `INSERT INTO click_events (code, timestamp, ip, user_agent, referrer, bot) VALUES (?, ?, ?, ?, ?, ?)`}
+}
+
+func (s *SQL) Record(ctx context.Context, c Click) error {
+	_, err := s.db.ExecContext(ctx, s.insert, c.Code, c.Timestamp.UTC(), c.IP, c.UserAgent, c.Referrer, c.Bot)
+	return err
+}
+
+// NewPostgres records to the database of a store.Postgres.
+func NewPostgres(db *sql.DB) *SQL {
+	return &SQL{db: db, insert: `INSERT INTO click_events (code, timestamp, ip, user_agent, referrer, bot) VALUES ($1, $2, $3, $4, $5, $6)`}
+}
@@ -0,0 +1,205 @@
+// Package api serves a shortener.Shortener over HTTP with the core routes
+// of the urlshortener server, in the same shapes: POST /api/v1/shorten,
+// GET and DELETE /api/v1/{code} and the redirect GET /{code}. The server
+// mounts it for the SQL store backends, which don't support the rest of
+// its API.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"urlshortener/pkg/analytics"
+	"urlshortener/pkg/shortener"
+	"urlshortener/pkg/store"
+)
+
+// maxBodyBytes caps the body of POST /api/v1/shorten.
+const maxBodyBytes = 1 << 20
+
+// Options configure Handler. The zero value is usable, but refuses deletes.
+type Options struct {
+	// BaseURL prefixes codes in short_url, which are bare paths without it.
+	BaseURL string
+	// ClientIP returns the visitor's address recorded with a click, the
+	// peer address by default.
+	ClientIP func(r *http.Request) string
+	// Admin wraps the routes that need admin credentials, DELETE
+	// /api/v1/{code}. They answer 403 without it.
+	Admin func(h http.Handler) http.Handler
+	// OnError is called with the errors that fail a request with a 500.
+	// They are logged by default.
+	OnError func(r *http.Request, err error)
+	// ValidateURL checks and normalises the url of POST /api/v1/shorten,
+	// shortener.ValidateURL by default. Its errors answer 400.
+	ValidateURL func(raw string) (string, error)
+	// ValidateCode, if set, further checks custom codes, e.g. for reserved
+	// words. Its errors answer CodeStatus(err), 400 by default.
+	ValidateCode func(code string) error
+	CodeStatus   func(err error) int
+	// ShortenLimit wraps POST /api/v1/shorten, e.g. in a rate limit.
+	ShortenLimit func(h http.Handler) http.Handler
+}
+
+type handler struct {
+	s    *shortener.Shortener
+	opts Options
+}
+
+// Handler returns the routes serving s.
+func Handler(s *shortener.Shortener, opts Options) http.Handler {
+	if opts.ClientIP == nil {
+		opts.ClientIP = peerIP
+	}
+	if opts.Admin == nil {
+		opts.Admin = func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+			})
+		}
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(r *http.Request, err error) { log.Printf("%s %s: %v", r.Method, r.URL.Path, err) }
+	}
+	if opts.ValidateURL == nil {
+		opts.ValidateURL = shortener.ValidateURL
+	}
+	if opts.CodeStatus == nil {
+		opts.CodeStatus = func(error) int { return http.StatusBadRequest }
+	}
+	if opts.ShortenLimit == nil {
+		opts.ShortenLimit = func(h http.Handler) http.Handler { return h }
+	}
+	h := &handler{s: s, opts: opts}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/shorten", opts.ShortenLimit(http.HandlerFunc(h.shorten)))
+	mux.HandleFunc("GET /api/v1/{code}", h.resolve)
+	mux.Handle("DELETE /api/v1/{code}", opts.Admin(http.HandlerFunc(h.delete)))
+	mux.HandleFunc("GET /{code}", h.redirect)
+	return mux
+}
+
+type shortenRequest struct {
+	URL  string `json:"url"`
+	Code string `json:"code"`
+}
+
+type linkResponse struct {
+	store.Link
+	ShortURL string `json:"short_url"`
+}
+
+type resolveResponse struct {
+	Code      string     `json:"code"`
+	ShortURL  string     `json:"short_url"`
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (h *handler) shorten(w http.ResponseWriter, r *http.Request) {
+	var req shortenRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	dest, err := h.opts.ValidateURL(req.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Code != "" && h.opts.ValidateCode != nil {
+		if err := h.opts.ValidateCode(req.Code); err != nil {
+			http.Error(w, err.Error(), h.opts.CodeStatus(err))
+			return
+		}
+	}
+	l, err := h.s.Create(r.Context(), dest, req.Code)
+	switch {
+	case errors.Is(err, shortener.ErrInvalidURL), errors.Is(err, shortener.ErrInvalidCode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, store.ErrDuplicate) && req.Code != "":
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		h.fail(w, r, "Failed to save to database", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, linkResponse{Link: l, ShortURL: h.shortURL(l.Code)})
+}
+
+func (h *handler) resolve(w http.ResponseWriter, r *http.Request) {
+	l, err := h.s.Get(r.Context(), r.PathValue("code"))
+	if errors.Is(err, store.ErrNotFound) || (err == nil && l.Expired(time.Now())) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.fail(w, r, "Failed to resolve code", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resolveResponse{Code: l.Code, ShortURL: h.shortURL(l.Code), URL: l.URL, ExpiresAt: l.ExpiresAt})
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request) {
+	err := h.s.Delete(r.Context(), r.PathValue("code"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.fail(w, r, "Failed to delete short URL", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) redirect(w http.ResponseWriter, r *http.Request) {
+	dest, err := h.s.ResolveClick(r.Context(), analytics.Click{
+		Code:      r.PathValue("code"),
+		IP:        h.opts.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, shortener.ErrExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+	case err != nil:
+		h.fail(w, r, "Failed to resolve code", err)
+	default:
+		http.Redirect(w, r, dest, http.StatusFound)
+	}
+}
+
+func (h *handler) fail(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	h.opts.OnError(r, err)
+	http.Error(w, msg, http.StatusInternalServerError)
+}
+
+func (h *handler) shortURL(code string) string {
+	return strings.TrimSuffix(h.opts.BaseURL, "/") + "/" + code
+}
+
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
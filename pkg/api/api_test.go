@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"urlshortener/pkg/shortener"
+	"urlshortener/pkg/store"
+)
+
+var errReserved = errors.New("code is reserved")
+
+func newTestServer(t *testing.T, opts Options) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(Handler(shortener.New(store.NewMemory(), shortener.Options{}), opts))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestHandler(t *testing.T) {
+	srv := newTestServer(t, Options{
+		Admin: func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Test-Admin") == "" {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				h.ServeHTTP(w, r)
+			})
+		},
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		status   int
+		location string
+	}{
+		{"shorten", "POST", "/api/v1/shorten", `{"url": "https://example.com/a", "code": "abc"}`, http.StatusCreated, ""},
+		{"taken code", "POST", "/api/v1/shorten", `{"url": "https://example.com/b", "code": "abc"}`, http.StatusConflict, ""},
+		{"generated code", "POST", "/api/v1/shorten", `{"url": "https://example.com/c"}`, http.StatusCreated, ""},
+		{"invalid url", "POST", "/api/v1/shorten", `{"url": "ftp://example.com/"}`, http.StatusBadRequest, ""},
+		{"invalid code", "POST", "/api/v1/shorten", `{"url": "https://example.com/", "code": "a"}`, http.StatusBadRequest, ""},
+		{"invalid json", "POST", "/api/v1/shorten", `{`, http.StatusBadRequest, ""},
+		{"resolve", "GET", "/api/v1/abc", "", http.StatusOK, ""},
+		{"resolve unknown", "GET", "/api/v1/nope", "", http.StatusNotFound, ""},
+		{"redirect", "GET", "/abc", "", http.StatusFound, "https://example.com/a"},
+		{"redirect unknown", "GET", "/nope", "", http.StatusNotFound, ""},
+		{"delete without admin", "DELETE", "/api/v1/abc", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(t, tt.method, srv.URL+tt.path, tt.body)
+			if resp.StatusCode != tt.status {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get("Location"); got != tt.location {
+				t.Errorf("%s %s: Location = %q, want %q", tt.method, tt.path, got, tt.location)
+			}
+		})
+	}
+}
+
+func TestHandlerDefaultAdminRefuses(t *testing.T) {
+	srv := newTestServer(t, Options{})
+	do(t, "POST", srv.URL+"/api/v1/shorten", `{"url": "https://example.com/", "code": "keep"}`)
+	if resp := do(t, "DELETE", srv.URL+"/api/v1/keep", ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("DELETE without Options.Admin = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestHandlerHooks(t *testing.T) {
+	limited := 0
+	srv := newTestServer(t, Options{
+		ValidateURL: func(raw string) (string, error) {
+			dest, err := shortener.ValidateURL(raw)
+			if err == nil && strings.Contains(dest, "blocked.example") {
+				return "", errors.New("blocked")
+			}
+			return dest, err
+		},
+		ValidateCode: func(code string) error {
+			if code == "admin" {
+				return errReserved
+			}
+			return nil
+		},
+		CodeStatus: func(err error) int {
+			if errors.Is(err, errReserved) {
+				return http.StatusUnprocessableEntity
+			}
+			return http.StatusBadRequest
+		},
+		ShortenLimit: func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if limited++; limited > 4 {
+					http.Error(w, "Too many requests", http.StatusTooManyRequests)
+					return
+				}
+				h.ServeHTTP(w, r)
+			})
+		},
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"allowed", `{"url": "https://example.com/", "code": "fine"}`, http.StatusCreated},
+		{"blocked url", `{"url": "https://blocked.example/"}`, http.StatusBadRequest},
+		{"reserved code", `{"url": "https://example.com/", "code": "admin"}`, http.StatusUnprocessableEntity},
+		{"invalid url before code", `{"url": "nope", "code": "admin"}`, http.StatusBadRequest},
+		{"limited", `{"url": "https://example.com/"}`, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := do(t, "POST", srv.URL+"/api/v1/shorten", tt.body); resp.StatusCode != tt.status {
+				t.Errorf("POST %s = %d, want %d", tt.body, resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
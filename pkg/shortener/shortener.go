@@ -0,0 +1,178 @@
+// Package shortener is the core of the urlshortener server without its
+// HTTP layer: creating, resolving and deleting short URLs in a store.Store,
+// optionally recording visits with an analytics.Recorder. It is meant to be
+// embedded in other Go programs; see examples/embedded.
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+
+	"golang.org/x/net/idna"
+
+	"urlshortener/pkg/analytics"
+	"urlshortener/pkg/store"
+)
+
+// Alphabet is what generated codes are drawn from, the one code_int packs.
+const Alphabet = store.Alphabet
+
+const (
+	defaultCodeLength  = 6
+	defaultMaxAttempts = 5
+)
+
+var (
+	ErrInvalidURL  = errors.New("URL must be an absolute http or https URL")
+	ErrInvalidCode = errors.New("code must be 3-32 letters, digits, '-' or '_'")
+	ErrExpired     = errors.New("short URL has expired")
+
+	// CodePattern is what custom codes must match.
+	CodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+)
+
+// Options configure a Shortener. The zero value is usable.
+type Options struct {
+	// CodeLength is the length of generated codes, 6 by default.
+	CodeLength int
+	// Alphabet is what generated codes are drawn from, Alphabet by default.
+	Alphabet string
+	// MaxAttempts bounds the codes drawn for one Create before giving up
+	// on collisions, 5 by default.
+	MaxAttempts int
+	// Intn is the random source for codes, math/rand.Intn by default.
+	Intn func(n int) int
+	// Recorder, if set, records every resolved visit.
+	Recorder analytics.Recorder
+	// OnError is called with errors that don't fail the operation, such as
+	// a click that couldn't be recorded. They are logged by default.
+	OnError func(error)
+}
+
+// Shortener creates and resolves short URLs. It is safe for concurrent use
+// if its store and recorder are.
+type Shortener struct {
+	store store.Store
+	opts  Options
+}
+
+func New(s store.Store, opts Options) *Shortener {
+	if opts.CodeLength <= 0 {
+		opts.CodeLength = defaultCodeLength
+	}
+	if opts.Alphabet == "" {
+		opts.Alphabet = Alphabet
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Intn == nil {
+		opts.Intn = rand.Intn
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) { log.Printf("shortener: %v", err) }
+	}
+	return &Shortener{store: s, opts: opts}
+}
+
+// Create shortens rawURL under code, or under a generated code if code is
+// empty. A taken custom code returns store.ErrDuplicate.
+func (s *Shortener) Create(ctx context.Context, rawURL, code string) (store.Link, error) {
+	dest, err := ValidateURL(rawURL)
+	if err != nil {
+		return store.Link{}, err
+	}
+	l := store.Link{Code: code, URL: dest, CreatedAt: time.Now()}
+	if code != "" {
+		if !CodePattern.MatchString(code) {
+			return store.Link{}, ErrInvalidCode
+		}
+		return l, s.store.Insert(ctx, l)
+	}
+
+	for attempt := 0; attempt < s.opts.MaxAttempts; attempt++ {
+		l.Code = RandomCode(s.opts.Intn, s.opts.Alphabet, s.opts.CodeLength)
+		err = s.store.Insert(ctx, l)
+		if !errors.Is(err, store.ErrDuplicate) {
+			return l, err
+		}
+	}
+	return store.Link{}, fmt.Errorf("no free code after %d attempts: %w", s.opts.MaxAttempts, err)
+}
+
+// Resolve returns the destination of code and counts the visit.
+func (s *Shortener) Resolve(ctx context.Context, code string) (string, error) {
+	return s.ResolveClick(ctx, analytics.Click{Code: code})
+}
+
+// ResolveClick is Resolve for c.Code, recording c, so that callers serving
+// HTTP can pass on the visitor's IP, user agent and referrer. A zero
+// c.Timestamp is set to now.
+func (s *Shortener) ResolveClick(ctx context.Context, c analytics.Click) (string, error) {
+	l, err := s.store.Get(ctx, c.Code)
+	if err != nil {
+		return "", err
+	}
+	if l.Expired(time.Now()) {
+		return "", ErrExpired
+	}
+	if err := s.store.IncrementClicks(ctx, c.Code); err != nil {
+		s.opts.OnError(fmt.Errorf("count click of %s: %w", c.Code, err))
+	}
+	if s.opts.Recorder != nil {
+		if c.Timestamp.IsZero() {
+			c.Timestamp = time.Now()
+		}
+		if err := s.opts.Recorder.Record(ctx, c); err != nil {
+			s.opts.OnError(fmt.Errorf("record click of %s: %w", c.Code, err))
+		}
+	}
+	return l.URL, nil
+}
+
+// Get returns the link of code without counting a visit.
+func (s *Shortener) Get(ctx context.Context, code string) (store.Link, error) {
+	return s.store.Get(ctx, code)
+}
+
+// Delete removes the link of code, or returns store.ErrNotFound.
+func (s *Shortener) Delete(ctx context.Context, code string) error {
+	return s.store.Delete(ctx, code)
+}
+
+// ValidateURL checks that raw is an absolute http(s) URL and returns it
+// with an internationalized hostname in its ACE (Punycode) form, so that
+// lookups and comparisons see a single canonical spelling.
+func ValidateURL(raw string) (string, error) {
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", ErrInvalidURL
+	}
+	if host := u.Hostname(); net.ParseIP(host) == nil {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", ErrInvalidURL
+		}
+		if port := u.Port(); port != "" {
+			ascii += ":" + port
+		}
+		u.Host = ascii
+	}
+	return u.String(), nil
+}
+
+// RandomCode draws length characters of charset with intn.
+func RandomCode(intn func(n int) int, charset string, length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = charset[intn(len(charset))]
+	}
+	return string(b)
+}
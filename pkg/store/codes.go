@@ -0,0 +1,87 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// Alphabet is what generated codes are drawn from, in the digit order of
+// CodeToInt.
+const Alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// maxIntCodeLength is the longest code CodeToInt packs: 62^10 still fits
+// in an int64.
+const maxIntCodeLength = 10
+
+// DefaultTrackingParams are the server's default TRACKING_PARAMS.
+var DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "ref"}
+
+// CodeToInt packs a base-62 code into an int64 for the compact code_int
+// index. The numbering is bijective (digits run 1..62), so "a" and "aa"
+// stay distinct. Codes with other characters, such as '-' or a namespace
+// slash, or longer than 10 characters report false.
+func CodeToInt(code string) (int64, bool) {
+	if code == "" || len(code) > maxIntCodeLength {
+		return 0, false
+	}
+	var n int64
+	for i := 0; i < len(code); i++ {
+		d := strings.IndexByte(Alphabet, code[i])
+		if d < 0 {
+			return 0, false
+		}
+		n = n*int64(len(Alphabet)) + int64(d+1)
+	}
+	return n, true
+}
+
+// ContentHash fingerprints a destination for the content_hash index, so
+// that URLs differing only in the query parameters trackingParams names,
+// parameter order, or the case of scheme and host match: e.g.
+// https://example.com/?utm_source=a and https://EXAMPLE.com/?utm_source=b.
+// A trailing '*' in trackingParams matches any suffix. It returns "" for a
+// URL that doesn't parse.
+func ContentHash(raw string, trackingParams []string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	q := u.Query()
+	for name := range q {
+		if isTrackingParam(trackingParams, name) {
+			delete(q, name)
+		}
+	}
+	// Encode sorts by name.
+	u.RawQuery = q.Encode()
+
+	sum := sha256.Sum256([]byte(u.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func isTrackingParam(params []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range params {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCodeToInt(t *testing.T) {
+	tests := []struct {
+		code   string
+		want   int64
+		wantOK bool
+	}{
+		{"a", 1, true},
+		{"b", 2, true},
+		{"A", 27, true},
+		{"9", 62, true},
+		{"aa", 63, true},
+		{"ba", 2*62 + 1, true},
+		{"abc", (1*62+2)*62 + 3, true},
+		{"9999999999", 853058371866181866, true},
+		{"", 0, false},
+		{"a-b", 0, false},
+		{"ns/code", 0, false},
+		{"ü", 0, false},
+		{"aaaaaaaaaaa", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, ok := CodeToInt(tt.code)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("CodeToInt(%q) = %d, %v; want %d, %v", tt.code, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	base := ContentHash("https://example.com/page?id=1&lang=en", DefaultTrackingParams)
+	tests := []struct {
+		raw  string
+		same bool
+	}{
+		{"https://EXAMPLE.com/page?lang=en&id=1", true},
+		{"HTTPS://example.com:443/page?id=1&lang=en&utm_source=a", true},
+		{"https://example.com/page?id=1&lang=en&fbclid=x&UTM_Medium=b", true},
+		{"https://example.com/page?id=2&lang=en", false},
+		{"http://example.com/page?id=1&lang=en", false},
+		{"https://example.com/Page?id=1&lang=en", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := ContentHash(tt.raw, DefaultTrackingParams) == base; got != tt.same {
+				t.Errorf("ContentHash(%q) matching = %v, want %v", tt.raw, got, tt.same)
+			}
+		})
+	}
+	if got := ContentHash("", DefaultTrackingParams); got != "" {
+		t.Errorf(`ContentHash("") = %q, want ""`, got)
+	}
+}
+
+// benchCorpusSize is how many mappings BenchmarkCodeIndexSize indexes.
+const benchCorpusSize = 1_000_000
+
+// BenchmarkCodeIndexSize fills a scratch collection in the database of
+// MONGO_BENCH_URI with 1M six-character codes and reports the size of the
+// {code: 1} and {code_int: 1} indexes over them. It is skipped without one;
+// run it once with
+//
+//	MONGO_BENCH_URI=mongodb://localhost:27017 go test ./pkg/store -run '^$' -bench CodeIndexSize -benchtime 1x
+func BenchmarkCodeIndexSize(b *testing.B) {
+	uri := os.Getenv("MONGO_BENCH_URI")
+	if uri == "" {
+		b.Skip("MONGO_BENCH_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	b.Cleanup(func() { client.Disconnect(ctx) })
+	db := client.Database("urlshortener_bench")
+
+	for i := 0; i < b.N; i++ {
+		coll := db.Collection(fmt.Sprintf("code_index_size_%d", i))
+		coll.Drop(ctx)
+		b.Cleanup(func() { coll.Drop(ctx) })
+
+		docs := make([]any, 0, 10000)
+		for n := 0; n < benchCorpusSize; n++ {
+			code := benchCode(n)
+			packed, _ := CodeToInt(code)
+			docs = append(docs, bson.M{"code": code, "code_int": packed})
+			if len(docs) == cap(docs) {
+				if _, err := coll.InsertMany(ctx, docs); err != nil {
+					b.Fatalf("insert: %v", err)
+				}
+				docs = docs[:0]
+			}
+		}
+		_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "code_int", Value: 1}}, Options: options.Index().SetUnique(true)},
+		})
+		if err != nil {
+			b.Fatalf("create indexes: %v", err)
+		}
+
+		var stats struct {
+			IndexSizes map[string]int64 `bson:"indexSizes"`
+		}
+		if err := db.RunCommand(ctx, bson.D{{Key: "collStats", Value: coll.Name()}}).Decode(&stats); err != nil {
+			b.Fatalf("collStats: %v", err)
+		}
+		b.ReportMetric(float64(stats.IndexSizes["code_1"]), "code-index-bytes")
+		b.ReportMetric(float64(stats.IndexSizes["code_int_1"]), "code_int-index-bytes")
+	}
+}
+
+// benchCode returns the n'th of benchCorpusSize distinct six-character
+// codes, spread over the code space rather than in insertion order, the way
+// generated codes arrive.
+func benchCode(n int) string {
+	const space = 62 * 62 * 62 * 62 * 62 * 62
+	// 1e9+7 is coprime with 62, so this permutes the code space.
+	v := int64(n) * 1_000_000_007 % space
+	code := make([]byte, 6)
+	for i := len(code) - 1; i >= 0; i-- {
+		code[i] = Alphabet[v%62]
+		v /= 62
+	}
+	return string(code)
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a Store in a map. Its links are lost when the process exits.
+type Memory struct {
+	mu    sync.RWMutex
+	links map[string]Link
+}
+
+func NewMemory() *Memory {
+	return &Memory{links: make(map[string]Link)}
+}
+
+func (m *Memory) Insert(_ context.Context, l Link) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.links[l.Code]; ok {
+		return ErrDuplicate
+	}
+	m.links[l.Code] = l
+	return nil
+}
+
+func (m *Memory) Get(_ context.Context, code string) (Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	l, ok := m.links[code]
+	if !ok {
+		return Link{}, ErrNotFound
+	}
+	return l, nil
+}
+
+func (m *Memory) Delete(_ context.Context, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.links[code]; !ok {
+		return ErrNotFound
+	}
+	delete(m.links, code)
+	return nil
+}
+
+func (m *Memory) IncrementClicks(_ context.Context, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.links[code]
+	if !ok {
+		return ErrNotFound
+	}
+	l.Clicks++
+	m.links[code] = l
+	return nil
+}
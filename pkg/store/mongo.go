@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Mongo is a Store in a MongoDB collection, such as the server's "urls".
+// It relies on a unique index on code, which the server creates; call
+// EnsureIndexes when the collection isn't shared with one.
+type Mongo struct {
+	coll *mongo.Collection
+
+	// TrackingParams are the query parameters the content_hash of inserted
+	// links ignores, DefaultTrackingParams by default. Set them to the
+	// server's TRACKING_PARAMS when sharing its collection.
+	TrackingParams []string
+}
+
+func NewMongo(coll *mongo.Collection) *Mongo {
+	return &Mongo{coll: coll, TrackingParams: DefaultTrackingParams}
+}
+
+// mongoLink is a Link with the server's lookup fields, which it finds
+// codes and duplicate destinations by.
+type mongoLink struct {
+	Link        `bson:",inline"`
+	CodeInt     *int64 `bson:"code_int,omitempty"`
+	ContentHash string `bson:"content_hash,omitempty"`
+}
+
+// EnsureIndexes creates the unique index on code that Insert depends on to
+// reject taken codes.
+func (m *Mongo) EnsureIndexes(ctx context.Context) error {
+	_, err := m.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (m *Mongo) Insert(ctx context.Context, l Link) error {
+	doc := mongoLink{Link: l, ContentHash: ContentHash(l.URL, m.TrackingParams)}
+	if n, ok := CodeToInt(l.Code); ok {
+		doc.CodeInt = &n
+	}
+	_, err := m.coll.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (m *Mongo) Get(ctx context.Context, code string) (Link, error) {
+	var l Link
+	err := m.coll.FindOne(ctx, bson.M{"code": code}).Decode(&l)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Link{}, ErrNotFound
+	}
+	return l, err
+}
+
+func (m *Mongo) Delete(ctx context.Context, code string) error {
+	res, err := m.coll.DeleteOne(ctx, bson.M{"code": code})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *Mongo) IncrementClicks(ctx context.Context, code string) error {
+	res, err := m.coll.UpdateOne(ctx, bson.M{"code": code}, bson.M{"$inc": bson.M{"clicks": 1}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresSchema creates the urls table, the click_events table that
+// analytics.NewPostgres records to and the blocked_domains table.
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS urls (
+		code TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		clicks INT8 NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ,
+		disabled BOOL NOT NULL DEFAULT FALSE
+	)`,
+	`CREATE TABLE IF NOT EXISTS click_events (
+		id BIGSERIAL PRIMARY KEY,
+		code TEXT NOT NULL,
+		timestamp TIMESTAMPTZ NOT NULL,
+		ip TEXT NOT NULL,
+		user_agent TEXT,
+		referrer TEXT,
+		bot BOOL NOT NULL DEFAULT FALSE
+	)`,
+	`CREATE INDEX IF NOT EXISTS click_events_code_timestamp ON click_events (code, timestamp)`,
+	`CREATE TABLE IF NOT EXISTS blocked_domains (
+		domain TEXT PRIMARY KEY
+	)`,
+}
+
+// Postgres is a Store in a PostgreSQL database, through pgx.
+type Postgres struct {
+	sqlStore
+}
+
+// OpenPostgres connects to the database of dsn, a URL or key=value
+// connection string, and creates its tables if they don't exist.
+func OpenPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := createSchema(ctx, db, postgresSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Postgres{newSQLStore(db, numberPlaceholders)}, nil
+}
+
+// numberPlaceholders numbers the ? placeholders of q as PostgreSQL's $1,
+// $2 and so on. The queries it is used on have no ? in literals.
+func numberPlaceholders(q string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// sqlStore is a Store in the urls table of a database/sql database. The SQL
+// backends differ only in their schema and placeholders.
+type sqlStore struct {
+	db *sql.DB
+
+	insert, get, del, increment, blocked string
+}
+
+// newSQLStore prepares the queries of a store in db, with rebind turning
+// their ? placeholders into the driver's.
+func newSQLStore(db *sql.DB, rebind func(q string) string) sqlStore {
+	return sqlStore{
+		db: db,
+		// A taken code inserts nothing, which Insert reports as ErrDuplicate.
+		insert: rebind(`INSERT INTO urls (code, url, clicks, created_at, expires_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (code) DO NOTHING`),
+		// Rows disabled by an operator read as not found.
+		get:       rebind(`SELECT code, url, clicks, created_at, expires_at FROM urls WHERE code = ? AND NOT disabled`),
+		del:       rebind(`DELETE FROM urls WHERE code = ?`),
+		increment: rebind(`UPDATE urls SET clicks = clicks + 1 WHERE code = ? AND NOT disabled`),
+		blocked:   `SELECT domain FROM blocked_domains ORDER BY domain`,
+	}
+}
+
+// createSchema runs the CREATE statements of a backend, which must be
+// idempotent.
+func createSchema(ctx context.Context, db *sql.DB, schema []string) error {
+	for _, stmt := range schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DB is the underlying database, e.g. for an analytics recorder writing to
+// its click_events table.
+func (s sqlStore) DB() *sql.DB {
+	return s.db
+}
+
+func (s sqlStore) Close() error {
+	return s.db.Close()
+}
+
+func (s sqlStore) Insert(ctx context.Context, l Link) error {
+	var expires sql.NullTime
+	if l.ExpiresAt != nil {
+		expires = sql.NullTime{Time: l.ExpiresAt.UTC(), Valid: true}
+	}
+	res, err := s.db.ExecContext(ctx, s.insert, l.Code, l.URL, l.Clicks, l.CreatedAt.UTC(), expires)
+	if err != nil {
+		return err
+	}
+	return affected(res, ErrDuplicate)
+}
+
+func (s sqlStore) Get(ctx context.Context, code string) (Link, error) {
+	var l Link
+	var expires sql.NullTime
+	err := s.db.QueryRowContext(ctx, s.get, code).Scan(&l.Code, &l.URL, &l.Clicks, &l.CreatedAt, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Link{}, ErrNotFound
+	}
+	if err != nil {
+		return Link{}, err
+	}
+	if expires.Valid {
+		l.ExpiresAt = &expires.Time
+	}
+	return l, nil
+}
+
+func (s sqlStore) Delete(ctx context.Context, code string) error {
+	res, err := s.db.ExecContext(ctx, s.del, code)
+	if err != nil {
+		return err
+	}
+	return affected(res, ErrNotFound)
+}
+
+func (s sqlStore) IncrementClicks(ctx context.Context, code string) error {
+	res, err := s.db.ExecContext(ctx, s.increment, code)
+	if err != nil {
+		return err
+	}
+	return affected(res, ErrNotFound)
+}
+
+// BlockedDomains lists the blocked_domains table, the domains that the
+// server refuses destinations on, along with their subdomains. Operators
+// add them with SQL.
+func (s sqlStore) BlockedDomains(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.blocked)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var domains []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// affected returns none if res changed no rows.
+func affected(res sql.Result, none error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return none
+	}
+	return nil
+}
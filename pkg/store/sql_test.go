@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func openTestSQLite(t *testing.T) *SQLite {
+	t.Helper()
+	s, err := OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLite(t *testing.T) {
+	testSQLStore(t, openTestSQLite(t).sqlStore)
+}
+
+func TestSQLiteReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Insert(ctx, Link{Code: "kept", URL: "https://example.com/", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.Get(ctx, "kept"); err != nil {
+		t.Errorf("Get after reopening: %v", err)
+	}
+}
+
+// TestPostgres runs against the database of POSTGRES_TEST_DSN, which it
+// fills with test rows, and is skipped without one.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+	ctx := context.Background()
+	s, err := OpenPostgres(ctx, dsn)
+	if err != nil {
+		t.Fatalf("OpenPostgres: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	for _, table := range []string{"urls", "blocked_domains"} {
+		if _, err := s.DB().ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testSQLStore(t, s.sqlStore)
+}
+
+func TestNumberPlaceholders(t *testing.T) {
+	tests := []struct{ q, want string }{
+		{"SELECT 1", "SELECT 1"},
+		{"WHERE code = ?", "WHERE code = $1"},
+		{"VALUES (?, ?, ?)", "VALUES ($1, $2, $3)"},
+	}
+	for _, tt := range tests {
+		if got := numberPlaceholders(tt.q); got != tt.want {
+			t.Errorf("numberPlaceholders(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+// testSQLStore checks the Store contract, disabled rows and the blocked
+// domains of an empty SQL store.
+func testSQLStore(t *testing.T, s sqlStore) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	past := now.Add(-time.Hour)
+
+	if err := s.Insert(ctx, Link{Code: "live", URL: "https://example.com/live", CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Insert(ctx, Link{Code: "expired", URL: "https://example.com/expired", CreatedAt: now, ExpiresAt: &past}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Insert(ctx, Link{Code: "disabled", URL: "https://example.com/disabled", CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE urls SET disabled = TRUE WHERE code = 'disabled'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO blocked_domains (domain) VALUES ('b.example'), ('a.example')`); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		err := s.Insert(ctx, Link{Code: "live", URL: "https://example.com/other", CreatedAt: now})
+		if !errors.Is(err, ErrDuplicate) {
+			t.Errorf("Insert of a taken code = %v, want ErrDuplicate", err)
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		tests := []struct {
+			code    string
+			url     string
+			expires bool
+			err     error
+		}{
+			{"live", "https://example.com/live", false, nil},
+			{"expired", "https://example.com/expired", true, nil},
+			{"disabled", "", false, ErrNotFound},
+			{"missing", "", false, ErrNotFound},
+		}
+		for _, tt := range tests {
+			l, err := s.Get(ctx, tt.code)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Get(%q) error = %v, want %v", tt.code, err, tt.err)
+				continue
+			}
+			if l.URL != tt.url || (l.ExpiresAt != nil) != tt.expires {
+				t.Errorf("Get(%q) = %+v, want url %q, expires %v", tt.code, l, tt.url, tt.expires)
+			}
+			if err == nil && !l.CreatedAt.Equal(now) {
+				t.Errorf("Get(%q) created_at = %v, want %v", tt.code, l.CreatedAt, now)
+			}
+		}
+	})
+
+	t.Run("IncrementClicks", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if err := s.IncrementClicks(ctx, "live"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if l, _ := s.Get(ctx, "live"); l.Clicks != 2 {
+			t.Errorf("clicks = %d, want 2", l.Clicks)
+		}
+		for _, code := range []string{"disabled", "missing"} {
+			if err := s.IncrementClicks(ctx, code); !errors.Is(err, ErrNotFound) {
+				t.Errorf("IncrementClicks(%q) = %v, want ErrNotFound", code, err)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := s.Delete(ctx, "expired"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Get(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+		}
+		if err := s.Delete(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second Delete = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("BlockedDomains", func(t *testing.T) {
+		got, err := s.BlockedDomains(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"a.example", "b.example"}; !slices.Equal(got, want) {
+			t.Errorf("BlockedDomains = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the urls table, the click_events table that
+// analytics.NewSQLite records to and the blocked_domains table.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS urls (
+		code TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		clicks INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP,
+		disabled BOOLEAN NOT NULL DEFAULT FALSE
+	)`,
+	`CREATE TABLE IF NOT EXISTS click_events (
+		id INTEGER PRIMARY KEY,
+		code TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		ip TEXT NOT NULL,
+		user_agent TEXT,
+		referrer TEXT,
+		bot BOOLEAN NOT NULL DEFAULT FALSE
+	)`,
+	`CREATE INDEX IF NOT EXISTS click_events_code_timestamp ON click_events (code, timestamp)`,
+	`CREATE TABLE IF NOT EXISTS blocked_domains (
+		domain TEXT PRIMARY KEY
+	)`,
+}
+
+// SQLite is a Store in an SQLite database file, for a single server that
+// doesn't want to run MongoDB. The driver is pure Go, so no C toolchain is
+// needed.
+type SQLite struct {
+	sqlStore
+}
+
+// OpenSQLite opens or creates the database at path and its tables.
+func OpenSQLite(ctx context.Context, path string) (*SQLite, error) {
+	// Writers wait for each other instead of failing with SQLITE_BUSY, and
+	// WAL lets redirects read while a link is being created.
+	dsn := "file:" + path + "?" + url.Values{"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)"}}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := createSchema(ctx, db, sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{newSQLStore(db, func(q string) string { return q })}, nil
+}
@@ -0,0 +1,46 @@
+// Package store holds short URLs for package shortener. MongoDB stores
+// links in the same documents as the urlshortener server, so an embedding
+// application and the server can share a collection; SQLite and Postgres
+// keep them in SQL tables for servers without MongoDB; Memory suits tests
+// and single-process programs.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for a code that has no link.
+	ErrNotFound = errors.New("short URL not found")
+	// ErrDuplicate is returned by Insert for a code that is taken.
+	ErrDuplicate = errors.New("short code already in use")
+)
+
+// Link is a short URL. The bson names are the server's, see URLMapping.
+type Link struct {
+	Code      string     `bson:"code" json:"code"`
+	URL       string     `bson:"url" json:"url"`
+	Clicks    int64      `bson:"clicks" json:"clicks"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// Expired reports whether l has expired at now.
+func (l Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.After(now)
+}
+
+// Store is where a Shortener keeps its links. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	// Insert adds l, or returns ErrDuplicate if its code is taken.
+	Insert(ctx context.Context, l Link) error
+	// Get returns the link of code, or ErrNotFound.
+	Get(ctx context.Context, code string) (Link, error)
+	// Delete removes the link of code, or returns ErrNotFound.
+	Delete(ctx context.Context, code string) error
+	// IncrementClicks counts a visit of code, or returns ErrNotFound.
+	IncrementClicks(ctx context.Context, code string) error
+}
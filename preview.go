@@ -0,0 +1,102 @@
+package urlshortener
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// previewTokenTTL is how long a preview page's Continue link stays valid.
+const previewTokenTTL = time.Hour
+
+var previewTpl = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Link preview</title>
+</head>
+<body>
+    <h1>This link goes to</h1>
+    <p><code>{{.URL}}</code></p>
+    {{with .Preview}}
+    <p><strong>{{.Title}}</strong>{{with .SiteName}} &middot; {{.}}{{end}}</p>
+    {{with .Description}}<p>{{.}}</p>{{end}}
+    {{end}}
+    <p><a href="{{.Continue}}" rel="noreferrer">Continue</a></p>
+    {{with .Beacon}}<script>{{.}}</script>{{end}}
+</body>
+</html>
+`))
+
+// wantsPreview reports whether m is shown on a preview page rather than
+// redirected to: its always_preview if set, else ALWAYS_PREVIEW.
+func wantsPreview(m URLMapping) bool {
+	if m.AlwaysPreview != nil {
+		return *m.AlwaysPreview
+	}
+	return cfg.AlwaysPreview
+}
+
+// previewToken signs code and a Unix time under SHORTURL_HMAC_SECRET, so
+// skip_preview only works from a preview page served recently.
+func previewToken(code string, ts int64) string {
+	mac := hmac.New(sha256.New, []byte(cfg.HMACSecret))
+	mac.Write([]byte(code + "." + strconv.FormatInt(ts, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// skipPreview reports whether r asks to bypass code's preview page with
+// ?skip_preview=1 and a valid, unexpired ts and token.
+func skipPreview(r *http.Request, code string) bool {
+	q := r.URL.Query()
+	if q.Get("skip_preview") != "1" || cfg.HMACSecret == "" {
+		return false
+	}
+	ts, err := strconv.ParseInt(q.Get("ts"), 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(ts, 0)); age < -time.Minute || age > previewTokenTTL {
+		return false
+	}
+	return hmac.Equal([]byte(q.Get("token")), []byte(previewToken(code, ts)))
+}
+
+// skipPreviewURL is the short URL r requested, keeping its sig, with a
+// fresh skip_preview token for code.
+func skipPreviewURL(r *http.Request, code string) string {
+	ts := time.Now().Unix()
+	q := r.URL.Query()
+	q.Set("skip_preview", "1")
+	q.Set("ts", strconv.FormatInt(ts, 10))
+	q.Set("token", previewToken(code, ts))
+	return r.URL.Path + "?" + q.Encode()
+}
+
+// renderPreview shows m's destination and a Continue link to next, with
+// the beacon if the click is left for it to report.
+func renderPreview(w http.ResponseWriter, m URLMapping, next string, withBeacon bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	var beacon template.JS
+	if withBeacon {
+		beacon = beaconScript
+	}
+	err := previewTpl.Execute(w, struct {
+		URL      string
+		Preview  *LinkPreview
+		Continue string
+		Beacon   template.JS
+	}{m.URL, m.Preview, next, beacon})
+	if err != nil {
+		log.Printf("Error rendering preview page for %s: %v", m.Code, err)
+	}
+}
@@ -0,0 +1,42 @@
+package urlshortener
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+)
+
+var errProfaneCode = errors.New("code contains a word that isn't allowed")
+
+//go:embed profanity.txt
+var profanityList string
+
+// profaneWords is profanity.txt without comments and blank lines.
+var profaneWords = func() []string {
+	var words []string
+	for _, line := range strings.Split(profanityList, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words
+}()
+
+// profanityFolder undoes the digit substitutions and separators that would
+// otherwise let a listed word through, e.g. "Sh1t" or "Fox-Uniform-Kilo".
+var profanityFolder = strings.NewReplacer(
+	"-", "", "_", "",
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t",
+)
+
+// isProfane reports whether code contains a word from profanity.txt.
+func isProfane(code string) bool {
+	folded := profanityFolder.Replace(strings.ToLower(code))
+	for _, w := range profaneWords {
+		if strings.Contains(folded, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package urlshortener
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// backendProxy forwards to PROXY_BACKEND_URL in --proxy-mode, so the
+// shortener can sit in front of an existing site: /abc123 redirects while
+// /blog/post is served by the site. Paths the shortener routes itself
+// (/api/, /admin, /shorten, ...) are not forwarded, and single-segment
+// paths are only forwarded once the code lookup misses, within
+// REDIRECT_TIMEOUT_MS.
+var backendProxy *httputil.ReverseProxy
+
+func startProxy() {
+	if !cfg.ProxyMode {
+		return
+	}
+	backend, err := url.Parse(cfg.ProxyBackendURL)
+	if err != nil || backend.Scheme == "" || backend.Host == "" {
+		log.Fatalf("Invalid PROXY_BACKEND_URL %q", cfg.ProxyBackendURL)
+	}
+	backendProxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(backend)
+			pr.SetXForwarded()
+			// Keep the visitor's host so the backend builds its own links.
+			pr.Out.Host = pr.In.Host
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Proxying %s to %s failed: %v", r.URL.Path, redactURI(cfg.ProxyBackendURL), err)
+			writeError(w, r, "502 bad gateway", http.StatusBadGateway)
+		},
+	}
+	log.Printf("Proxying unknown paths to %s", redactURI(cfg.ProxyBackendURL))
+}
+
+// unknownPath answers a path that isn't a short code: forwarded to the
+// backend in proxy mode, 404 otherwise.
+func unknownPath(w http.ResponseWriter, r *http.Request) {
+	if backendProxy != nil {
+		backendProxy.ServeHTTP(w, r)
+		return
+	}
+	notFound(w, r)
+}
+
+// proxyOr serves the path from the backend in proxy mode and with h
+// otherwise.
+func proxyOr(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if backendProxy != nil {
+			backendProxy.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,118 @@
+package urlshortener
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultQRSize = 256
+	maxQRSize     = 1024
+)
+
+var sourcePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// clickSource returns the ?src= value of a redirect request, the
+// short-link equivalent of utm_source, if it is well-formed.
+func clickSource(r *http.Request) string {
+	src := r.URL.Query().Get("src")
+	if !sourcePattern.MatchString(src) {
+		return ""
+	}
+	return strings.ToLower(src)
+}
+
+// qrPath is where qrHandler serves m's QR code, signed like Path when m
+// requires a signature.
+func qrPath(m URLMapping) string {
+	path := "/api/v1/" + m.Code + "/qr"
+	if m.RequiresSignature {
+		path += "?sig=" + signCode(m.Code)
+	}
+	return path
+}
+
+// pushQR offers m's QR code to the client ahead of the page that shows it:
+// pushed on HTTP/2, announced with a preload Link header otherwise.
+func pushQR(w http.ResponseWriter, m URLMapping) {
+	qr := qrPath(m)
+	w.Header().Add("Link", "<"+qr+">; rel=preload; as=image")
+	pusher, ok := w.(http.Pusher)
+	if !ok {
+		return
+	}
+	if err := pusher.Push(qr, nil); err != nil && err != http.ErrNotSupported {
+		log.Printf("Failed to push QR code of %s: %v", m.Code, err)
+	}
+}
+
+// qrHandler serves a PNG QR code for the tracking URL /t/{code}?src=qr, so
+// scans show up as their own source in analytics.
+func qrHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+	mapping, err := lookupCode(code)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Failed to resolve code", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || mapping.Expired() || mapping.Disabled {
+		http.NotFound(w, r)
+		return
+	}
+	if !checkSignature(w, r, mapping) {
+		return
+	}
+
+	size := defaultQRSize
+	if s := r.URL.Query().Get("size"); s != "" {
+		if size, err = strconv.Atoi(s); err != nil || size < 64 || size > maxQRSize {
+			http.Error(w, "size must be between 64 and 1024", http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := url.Values{"src": {"qr"}}
+	if mapping.RequiresSignature {
+		query.Set("sig", signCode(mapping.Code))
+	}
+	target := publicBaseURL(r) + "/t/" + mapping.Code + "?" + query.Encode()
+	png, err := qrcode.Encode(target, qrcode.Medium, size)
+	if err != nil {
+		log.Printf("Failed to encode QR code for %s: %v", code, err)
+		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(png)
+}
+
+// trackHandler sends /t/{code}?src=... on to the short URL with its source
+// kept, where redirectHandler records it on the click event. Only one
+// event is recorded per visit.
+func trackHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+	path := "/" + code
+	if strings.Contains(code, "/") {
+		path = "/p/" + code
+	}
+	query := url.Values{}
+	if src := clickSource(r); src != "" {
+		query.Set("src", src)
+	}
+	if sig := r.URL.Query().Get("sig"); sig != "" {
+		query.Set("sig", sig)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	http.Redirect(w, r, path, http.StatusMovedPermanently)
+}
@@ -0,0 +1,127 @@
+package urlshortener
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	redirectLimit   = 1000
+	rateLimitWindow = time.Minute
+)
+
+// tokenBucket allows bursts of up to capacity requests and refills at
+// capacity per window.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// rateQuota is the outcome of spending from a rate limit: whether the
+// request is allowed, how many more are, when the count is back to full,
+// and, for a refused request, how long until one would be allowed.
+type rateQuota struct {
+	allowed   bool
+	remaining int
+	reset     time.Time
+	wait      time.Duration
+}
+
+// take spends one token, or reports how long until one is available.
+func (b *tokenBucket) take(now time.Time, capacity int, window time.Duration) rateQuota {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rate := float64(capacity) / window.Seconds()
+	b.tokens = math.Min(float64(capacity), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	q := rateQuota{allowed: b.tokens >= 1}
+	if q.allowed {
+		b.tokens--
+	} else {
+		q.wait = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	q.remaining = int(b.tokens)
+	q.reset = now.Add(time.Duration((float64(capacity) - b.tokens) / rate * float64(time.Second)))
+	return q
+}
+
+// idle reports whether the bucket has been full for at least window and
+// can be forgotten.
+func (b *tokenBucket) idle(now time.Time, window time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.last) >= window
+}
+
+// rateBuckets holds one bucket per scope and client IP, keyed
+// "scope|ip". The scope is a short code for redirects and the route
+// pattern for limits set through RouteConfig.
+var rateBuckets sync.Map
+
+// sweepRateBuckets drops buckets that have refilled so the map only
+// holds recently active clients.
+func sweepRateBuckets(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitWindow)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rateBuckets.Range(func(k, v interface{}) bool {
+				if v.(*tokenBucket).idle(now, rateLimitWindow) {
+					rateBuckets.Delete(k)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// allowRedirect applies the per-code, per-IP redirect limit, answering
+// 429 with Retry-After when it is exceeded.
+func allowRedirect(w http.ResponseWriter, r *http.Request, code string) bool {
+	return allowRate(w, r, code, redirectLimit)
+}
+
+// allowRate spends a token from the client's bucket for scope, which
+// refills at limit per minute, answering 429 with Retry-After when it is
+// empty. With RATE_LIMIT_REDIS the count is shared through Redis instead,
+// falling back to the local bucket while Redis is unreachable. Either way
+// the response carries the client's quota in X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset (Unix time), so clients
+// can back off before they are refused.
+func allowRate(w http.ResponseWriter, r *http.Request, scope string, limit int) bool {
+	key := scope + "|" + realIP(r)
+	now := time.Now()
+
+	var q rateQuota
+	var ok bool
+	if rateLimiter != nil {
+		q, ok = rateLimiter.take(key, limit, now)
+	}
+	if !ok {
+		v, loaded := rateBuckets.Load(key)
+		if !loaded {
+			v, _ = rateBuckets.LoadOrStore(key, &tokenBucket{tokens: float64(limit), last: now})
+		}
+		q = v.(*tokenBucket).take(now, limit, rateLimitWindow)
+	}
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(q.remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(q.reset.UnixMilli())/1000)), 10))
+	if q.allowed {
+		return true
+	}
+	adminEvents.Publish(eventRateLimited, map[string]string{"scope": scope, "ip": realIP(r)})
+	h.Set("Retry-After", strconv.Itoa(int(math.Ceil(q.wait.Seconds()))))
+	writeError(w, r, "Too many requests", http.StatusTooManyRequests)
+	return false
+}
@@ -0,0 +1,103 @@
+package urlshortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisRateTimeout bounds each Redis round trip, which sits on the
+	// redirect path.
+	redisRateTimeout = 100 * time.Millisecond
+
+	// redisBreakerCooldown is how long the limiter stays on the in-memory
+	// buckets after Redis fails before trying it again.
+	redisBreakerCooldown = 30 * time.Second
+)
+
+// redisLimiter keeps rate limit counters in Redis so every instance sees
+// the same count. It approximates a sliding window from two fixed ones:
+// the current window's count plus the previous one's, weighted by how much
+// of it still overlaps the last rateLimitWindow.
+type redisLimiter struct {
+	client *redis.Client
+
+	mu        sync.Mutex
+	openUntil time.Time
+}
+
+// rateLimiter is set with RATE_LIMIT_REDIS; allowRate uses the in-memory
+// buckets when it is nil or its breaker is open.
+var rateLimiter *redisLimiter
+
+func startRedisRateLimiter() {
+	if !cfg.RateLimitRedis {
+		return
+	}
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		log.Fatalf("Invalid REDIS_URL: %v", err)
+	}
+	rateLimiter = &redisLimiter{client: redis.NewClient(opts)}
+}
+
+// take counts a request for key and reports whether it is within limit,
+// or how long until it would be. The quota resets when the current window
+// ends, which is when the fixed windows roll over. ok is false when Redis
+// couldn't be asked and the caller should decide locally instead.
+func (l *redisLimiter) take(key string, limit int, now time.Time) (q rateQuota, ok bool) {
+	l.mu.Lock()
+	open := now.Before(l.openUntil)
+	l.mu.Unlock()
+	if open {
+		return q, false
+	}
+
+	window := now.Truncate(rateLimitWindow)
+	current := fmt.Sprintf("ratelimit:%s:%d", key, window.Unix())
+	previous := fmt.Sprintf("ratelimit:%s:%d", key, window.Add(-rateLimitWindow).Unix())
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisRateTimeout)
+	defer cancel()
+	var incr *redis.IntCmd
+	var prev *redis.StringCmd
+	l.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		incr = p.Incr(ctx, current)
+		p.Expire(ctx, current, 2*rateLimitWindow)
+		prev = p.Get(ctx, previous)
+		return nil
+	})
+	if err := incr.Err(); err != nil {
+		l.mu.Lock()
+		l.openUntil = now.Add(redisBreakerCooldown)
+		l.mu.Unlock()
+		log.Printf("Redis rate limiter unavailable, using in-memory limits for %s: %v", redisBreakerCooldown, err)
+		return q, false
+	}
+
+	prevCount, _ := prev.Float64()
+	elapsed := now.Sub(window)
+	overlap := 1 - elapsed.Seconds()/rateLimitWindow.Seconds()
+	estimate := prevCount*overlap + float64(incr.Val())
+	q.reset = window.Add(rateLimitWindow)
+	q.remaining = max(0, int(float64(limit)-estimate))
+	if estimate <= float64(limit) {
+		q.allowed = true
+		return q, true
+	}
+
+	// The estimate falls as the previous window slides out; without one,
+	// only the next window brings it down.
+	q.wait = rateLimitWindow - elapsed
+	if prevCount > 0 {
+		if d := time.Duration((estimate - float64(limit)) / prevCount * float64(rateLimitWindow)); d < q.wait {
+			q.wait = d
+		}
+	}
+	return q, true
+}
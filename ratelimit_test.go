@@ -0,0 +1,84 @@
+package urlshortener
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// resetRateBuckets forgets every client's bucket, for tests counting
+// requests from a full one.
+func resetRateBuckets(t *testing.T) {
+	t.Helper()
+	rateBuckets.Range(func(k, _ interface{}) bool {
+		rateBuckets.Delete(k)
+		return true
+	})
+}
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	// A bucket of 6 per minute refills one token every 10 seconds.
+	steps := []struct {
+		at        time.Duration
+		allowed   bool
+		remaining int
+		wait      time.Duration
+	}{
+		{0, true, 5, 0},
+		{0, true, 4, 0},
+		{0, true, 3, 0},
+		{0, true, 2, 0},
+		{0, true, 1, 0},
+		{0, true, 0, 0},
+		{0, false, 0, 10 * time.Second},
+		{5 * time.Second, false, 0, 5 * time.Second},
+		{10 * time.Second, true, 0, 0},
+		{2 * time.Minute, true, 5, 0},
+	}
+	b := &tokenBucket{tokens: 6, last: start}
+	for i, s := range steps {
+		q := b.take(start.Add(s.at), 6, time.Minute)
+		if q.allowed != s.allowed || q.remaining != s.remaining || q.wait.Round(time.Millisecond) != s.wait {
+			t.Errorf("take %d at +%v = allowed %v, remaining %d, wait %v; want %v, %d, %v",
+				i, s.at, q.allowed, q.remaining, q.wait, s.allowed, s.remaining, s.wait)
+		}
+	}
+}
+
+func TestAllowRate(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		allowed    bool
+		remaining  string
+		retryAfter string
+	}{
+		{"198.51.100.1:1000", true, "1", ""},
+		{"198.51.100.1:1001", true, "0", ""},
+		{"198.51.100.1:1002", false, "0", "30"},
+		{"198.51.100.2:1000", true, "1", ""},
+	}
+	resetRateBuckets(t)
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		allowed := allowRate(w, r, "test-allow-rate", 2)
+		h := w.Result().Header
+		if allowed != tt.allowed {
+			t.Errorf("request %d from %s: allowed = %v, want %v", i, tt.remoteAddr, allowed, tt.allowed)
+		}
+		if !allowed && w.Code != 429 {
+			t.Errorf("request %d from %s: status = %d, want 429", i, tt.remoteAddr, w.Code)
+		}
+		if got := h.Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: X-RateLimit-Limit = %q, want 2", i, got)
+		}
+		if got := h.Get("X-RateLimit-Remaining"); got != tt.remaining {
+			t.Errorf("request %d: X-RateLimit-Remaining = %q, want %q", i, got, tt.remaining)
+		}
+		if got := h.Get("Retry-After"); got != tt.retryAfter {
+			t.Errorf("request %d: Retry-After = %q, want %q", i, got, tt.retryAfter)
+		}
+	}
+}
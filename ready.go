@@ -0,0 +1,96 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	stateStarting = "starting"
+	stateReady    = "ready"
+
+	warmUpRetryInterval = 5 * time.Second
+)
+
+// readyState is stateStarting until indexes are confirmed and the in-memory
+// cache is loaded. Until then redirects go straight to MongoDB and creation
+// is refused.
+var readyState atomic.Value
+
+func init() {
+	readyState.Store(stateStarting)
+}
+
+func isReady() bool {
+	return readyState.Load() == stateReady
+}
+
+// warmUp ensures the indexes exist, retrying until they do, checks them
+// against the schema, runs the self-test, then preloads every live mapping
+// into shortURLs and marks the server ready.
+func warmUp(ctx context.Context) {
+	for {
+		err := ensureIndexes(ctx)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to create indexes, retrying in %s: %v", warmUpRetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(warmUpRetryInterval):
+		}
+	}
+
+	checkIndexes(ctx)
+	selfTest(ctx)
+
+	n, err := preloadCache(ctx)
+	if err != nil {
+		// The cache is only an optimisation; lookups fall back to MongoDB.
+		log.Printf("Failed to preload URL cache: %v", err)
+	}
+	readyState.Store(stateReady)
+	log.Printf("Warm-up complete, %d short URLs cached", n)
+}
+
+func preloadCache(ctx context.Context) (int, error) {
+	cur, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	n := 0
+	for cur.Next(ctx) {
+		var m URLMapping
+		if err := cur.Decode(&m); err != nil {
+			return n, err
+		}
+		if id, ok := cur.Current.Lookup("_id").ObjectIDOK(); ok {
+			rememberID(id, m.Code)
+		}
+		if m.Expired() {
+			continue
+		}
+		shortURLs.Set(m.Code, m)
+		n++
+	}
+	return n, cur.Err()
+}
+
+// requireReady answers 503 while the server is warming up and reports
+// whether the caller may proceed.
+func requireReady(w http.ResponseWriter) bool {
+	if isReady() {
+		return true
+	}
+	w.Header().Set("Retry-After", "30")
+	http.Error(w, "Server is starting up, try again shortly", http.StatusServiceUnavailable)
+	return false
+}
@@ -0,0 +1,99 @@
+package urlshortener
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+)
+
+// recoveryMiddleware turns a handler panic into a logged 500 instead of a
+// crashed server. It is the outermost middleware so it sees panics from
+// every layer. http.ErrAbortHandler is re-raised, since net/http uses it to
+// abort a response on purpose.
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			panicsTotal.WithLabelValues(requestNamespace(r)).Inc()
+			logPanic(r, rec, debug.Stack())
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logPanic records rec as one structured log entry. The mux has already
+// matched r, so its path values name the short code involved, if any.
+func logPanic(r *http.Request, rec interface{}, stack []byte) {
+	code := r.PathValue("code")
+	if prefix := r.PathValue("prefix"); prefix != "" {
+		code = prefix + "/" + code
+	}
+
+	attrs := []interface{}{
+		slog.String("panic_type", fmt.Sprintf("%T", rec)),
+		slog.Int64("goroutine_id", goroutineID(stack)),
+		slog.String("request_id", r.Header.Get("X-Request-Id")),
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("short_code", code),
+	}
+	switch v := rec.(type) {
+	case error:
+		attrs = append(attrs, slog.String("panic_value", v.Error()))
+		if chain := unwrapChain(v); len(chain) > 0 {
+			attrs = append(attrs, slog.Any("wrapped_errors", chain))
+		}
+	case string:
+		attrs = append(attrs, slog.String("panic_value", v))
+	case fmt.Stringer:
+		attrs = append(attrs, slog.String("panic_value", v.String()))
+	default:
+		attrs = append(attrs, slog.String("panic_value", fmt.Sprintf("%#v", v)))
+	}
+	attrs = append(attrs, slog.String("stack_trace", string(stack)))
+
+	slog.Error("Panic serving request", attrs...)
+}
+
+// unwrapChain describes each error wrapped by err, depth first, as
+// "type: message".
+func unwrapChain(err error) []string {
+	var chain []string
+	var walk func(error)
+	walk = func(e error) {
+		var wrapped []error
+		if next := errors.Unwrap(e); next != nil {
+			wrapped = []error{next}
+		} else if joined, ok := e.(interface{ Unwrap() []error }); ok {
+			wrapped = joined.Unwrap()
+		}
+		for _, next := range wrapped {
+			chain = append(chain, fmt.Sprintf("%T: %v", next, next))
+			walk(next)
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// goroutineID parses the ID from the "goroutine N [running]:" header of a
+// stack trace, or returns 0.
+func goroutineID(stack []byte) int64 {
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i > 0 {
+		id, _ := strconv.ParseInt(string(stack[:i]), 10, 64)
+		return id
+	}
+	return 0
+}
@@ -0,0 +1,186 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The status a redirect answers with is, in order of precedence, the URL's
+// redirect_type, its namespace's default_redirect_type, its owner's
+// default_redirect_type and finally DEFAULT_REDIRECT_TYPE. The choice
+// matters beyond the visitor's browser:
+//
+//   - 301 Moved Permanently passes the short URL's search ranking to the
+//     destination, but browsers and proxies cache it without limit, so
+//     repeat visits skip the service: those clicks go uncounted and a
+//     changed destination isn't seen until the cache is cleared.
+//   - 302 Found is temporary. It isn't cached without explicit headers, so
+//     every click is counted and destination changes apply at once; search
+//     engines keep indexing the short URL itself.
+//   - 303 See Other is like 302 but always follows up with GET.
+//   - 307 Temporary Redirect and 308 Permanent Redirect are 302 and 301
+//     that keep the request method and body, e.g. for a form POSTed to a
+//     short URL.
+var redirectTypes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusSeeOther:          true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+var errInvalidRedirectType = errors.New("redirect type must be 301, 302, 303, 307 or 308")
+
+// validateRedirectType accepts a redirect status, or 0 for the inherited
+// default.
+func validateRedirectType(status int) error {
+	if status != 0 && !redirectTypes[status] {
+		return errInvalidRedirectType
+	}
+	return nil
+}
+
+// namespaceSettings holds per-namespace options, keyed by the namespace.
+var namespaceSettings *mongo.Collection
+
+type NamespaceSettings struct {
+	Namespace           string `bson:"_id" json:"namespace"`
+	DefaultRedirectType int    `bson:"default_redirect_type,omitempty" json:"default_redirect_type,omitempty"`
+}
+
+// redirectDefaultTTL is how long a namespace's or owner's default is cached
+// before a change is picked up.
+const redirectDefaultTTL = time.Minute
+
+type cachedRedirectDefault struct {
+	status  int
+	expires time.Time
+}
+
+var redirectDefaults sync.Map // "ns:<namespace>" or "user:<id>" -> cachedRedirectDefault
+
+// redirectStatus is the status to redirect m with, following the
+// precedence above. A failed lookup skips that level rather than failing
+// the redirect.
+func redirectStatus(ctx context.Context, m URLMapping) int {
+	if m.RedirectType != 0 {
+		return m.RedirectType
+	}
+	if m.Prefix != "" {
+		if status := cachedDefault(ctx, "ns:"+m.Prefix, namespaceSettings, m.Prefix); status != 0 {
+			return status
+		}
+	}
+	if m.OwnerID != "" {
+		if status := cachedDefault(ctx, "user:"+m.OwnerID, users, m.OwnerID); status != 0 {
+			return status
+		}
+	}
+	return cfg.DefaultRedirectType
+}
+
+func cachedDefault(ctx context.Context, key string, coll *mongo.Collection, id string) int {
+	if v, ok := redirectDefaults.Load(key); ok && time.Now().Before(v.(cachedRedirectDefault).expires) {
+		return v.(cachedRedirectDefault).status
+	}
+	var doc struct {
+		DefaultRedirectType int `bson:"default_redirect_type"`
+	}
+	err := coll.FindOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"default_redirect_type": 1})).Decode(&doc)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		loggerFrom(ctx).WarnContext(ctx, "Failed to load default redirect type", "key", key, "error", err.Error())
+		return 0
+	}
+	redirectDefaults.Store(key, cachedRedirectDefault{status: doc.DefaultRedirectType, expires: time.Now().Add(redirectDefaultTTL)})
+	return doc.DefaultRedirectType
+}
+
+type redirectDefaultRequest struct {
+	DefaultRedirectType int `json:"default_redirect_type"`
+}
+
+func decodeRedirectDefault(w http.ResponseWriter, r *http.Request) (int, bool) {
+	var req redirectDefaultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return 0, false
+	}
+	if err := validateRedirectType(req.DefaultRedirectType); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	return req.DefaultRedirectType, true
+}
+
+func getNamespaceSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	ns := normalizeCode(r.PathValue("ns"))
+	s := NamespaceSettings{Namespace: ns}
+	err := namespaceSettings.FindOne(r.Context(), bson.M{"_id": ns}).Decode(&s)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Failed to load settings of namespace %s: %v", ns, err)
+		http.Error(w, "Failed to load namespace settings", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// putNamespaceSettingsHandler sets a namespace's default redirect type; 0
+// returns it to the owner's or global default.
+func putNamespaceSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	ns := normalizeCode(r.PathValue("ns"))
+	if !codePattern.MatchString(ns) {
+		http.Error(w, "namespace must be 3-32 letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+	status, ok := decodeRedirectDefault(w, r)
+	if !ok {
+		return
+	}
+	s := NamespaceSettings{Namespace: ns, DefaultRedirectType: status}
+	_, err := namespaceSettings.ReplaceOne(r.Context(), bson.M{"_id": ns}, s, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("Failed to save settings of namespace %s: %v", ns, err)
+		http.Error(w, "Failed to save namespace settings", http.StatusInternalServerError)
+		return
+	}
+	redirectDefaults.Delete("ns:" + ns)
+	writeJSON(w, http.StatusOK, s)
+}
+
+// apiUpdateUserHandler sets a user's default redirect type for the URLs
+// they own.
+func apiUpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	status, ok := decodeRedirectDefault(w, r)
+	if !ok {
+		return
+	}
+	update := bson.M{"$set": bson.M{"default_redirect_type": status}}
+	if status == 0 {
+		update = bson.M{"$unset": bson.M{"default_redirect_type": ""}}
+	}
+	var u User
+	err := users.FindOneAndUpdate(r.Context(), bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&u)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to update user %s: %v", id, err)
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+	redirectDefaults.Delete("user:" + id)
+	writeJSON(w, http.StatusOK, u)
+}
@@ -0,0 +1,224 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"urlshortener/pkg/store"
+)
+
+const (
+	queueNone    = ""
+	queueChannel = "channel"
+	queueRedis   = "redis"
+	queueKafka   = "kafka"
+
+	replicateUpsert = "upsert"
+	replicateDelete = "delete"
+
+	channelQueueSize = 1024
+)
+
+// replicationQueue carries encoded replicationEvents between regions.
+// Consume blocks until ctx is done or the queue fails.
+type replicationQueue interface {
+	Publish(ctx context.Context, payload []byte) error
+	Consume(ctx context.Context, handle func(payload []byte)) error
+}
+
+type replicationEvent struct {
+	Op      string     `bson:"op"`
+	Mapping URLMapping `bson:"mapping"`
+}
+
+// RegionReplicator fans URL mapping writes out to other regions. When a
+// regional MongoDB is configured it also consumes the queue into that
+// database, which the redirect path then reads first.
+type RegionReplicator struct {
+	queue replicationQueue
+	local *mongo.Collection
+}
+
+// replicator is nil when REPLICATION_QUEUE is unset; its methods are no-ops
+// then.
+var replicator *RegionReplicator
+
+func startReplication(ctx context.Context) {
+	var queue replicationQueue
+	switch cfg.ReplicationQueue {
+	case queueNone:
+		return
+	case queueChannel:
+		queue = channelQueue(make(chan []byte, channelQueueSize))
+	case queueRedis:
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Invalid REDIS_URL: %v", err)
+		}
+		queue = &redisQueue{client: redis.NewClient(opts), channel: cfg.ReplicationTopic}
+	case queueKafka:
+		brokers := strings.Split(cfg.KafkaBrokers, ",")
+		queue = &kafkaQueue{
+			writer:  &kafka.Writer{Addr: kafka.TCP(brokers...), Topic: cfg.ReplicationTopic},
+			brokers: brokers,
+			topic:   cfg.ReplicationTopic,
+			group:   "urlshortener-" + cfg.Region,
+		}
+	}
+
+	replicator = &RegionReplicator{queue: queue}
+	if cfg.RegionMongoURI == "" {
+		return
+	}
+	local, err := dial(ctx, cfg.RegionMongoURI)
+	if err != nil {
+		log.Fatalf("Failed to connect to regional MongoDB %s: %v", redactURI(cfg.RegionMongoURI), err)
+	}
+	replicator.local = local.Database("urlshortener").Collection("urls")
+	log.Printf("Replicating URL mappings into regional MongoDB %s (region %s)", redactURI(cfg.RegionMongoURI), cfg.Region)
+	go func() {
+		if err := queue.Consume(ctx, replicator.apply); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Replication consumer stopped: %v", err)
+		}
+	}()
+}
+
+// Publish queues m for the other regions without blocking the caller.
+func (rr *RegionReplicator) Publish(op string, m URLMapping) {
+	if rr == nil {
+		return
+	}
+	payload, err := bson.Marshal(replicationEvent{Op: op, Mapping: m})
+	if err != nil {
+		log.Printf("Failed to encode replication event for %s: %v", m.Code, err)
+		return
+	}
+	go func() {
+		if err := rr.queue.Publish(context.Background(), payload); err != nil {
+			log.Printf("Failed to publish replication event for %s: %v", m.Code, err)
+		}
+	}()
+}
+
+func (rr *RegionReplicator) apply(payload []byte) {
+	var ev replicationEvent
+	if err := bson.Unmarshal(payload, &ev); err != nil {
+		log.Printf("Dropping undecodable replication event: %v", err)
+		return
+	}
+
+	ctx := context.Background()
+	filter := bson.M{"code": ev.Mapping.Code}
+	var err error
+	switch ev.Op {
+	case replicateUpsert:
+		if n, ok := store.CodeToInt(ev.Mapping.Code); ok {
+			ev.Mapping.CodeInt = &n
+		}
+		_, err = rr.local.ReplaceOne(ctx, filter, ev.Mapping, options.Replace().SetUpsert(true))
+	case replicateDelete:
+		_, err = rr.local.DeleteOne(ctx, filter)
+	default:
+		log.Printf("Dropping replication event with unknown op %q", ev.Op)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to apply replication %s for %s: %v", ev.Op, ev.Mapping.Code, err)
+	}
+}
+
+// findLocal looks code up in the regional copy. A nil replicator or one
+// without a regional database reports mongo.ErrNoDocuments.
+func (rr *RegionReplicator) findLocal(code string) (URLMapping, error) {
+	if rr == nil || rr.local == nil {
+		return URLMapping{}, mongo.ErrNoDocuments
+	}
+	var m URLMapping
+	err := rr.local.FindOne(context.Background(), codeFilter(code)).Decode(&m)
+	return m, err
+}
+
+// channelQueue replicates within a single process, which is mostly useful
+// for trying the setup out.
+type channelQueue chan []byte
+
+func (q channelQueue) Publish(ctx context.Context, payload []byte) error {
+	select {
+	case q <- payload:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q channelQueue) Consume(ctx context.Context, handle func([]byte)) error {
+	for {
+		select {
+		case payload := <-q:
+			handle(payload)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// redisQueue uses Redis pub/sub. Events published while a consumer is
+// disconnected are lost.
+type redisQueue struct {
+	client  *redis.Client
+	channel string
+}
+
+func (q *redisQueue) Publish(ctx context.Context, payload []byte) error {
+	return q.client.Publish(ctx, q.channel, payload).Err()
+}
+
+func (q *redisQueue) Consume(ctx context.Context, handle func([]byte)) error {
+	sub := q.client.Subscribe(ctx, q.channel)
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("redis subscription closed")
+			}
+			handle([]byte(msg.Payload))
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// kafkaQueue uses one consumer group per region so every region sees every
+// event.
+type kafkaQueue struct {
+	writer  *kafka.Writer
+	brokers []string
+	topic   string
+	group   string
+}
+
+func (q *kafkaQueue) Publish(ctx context.Context, payload []byte) error {
+	return q.writer.WriteMessages(ctx, kafka.Message{Value: payload})
+}
+
+func (q *kafkaQueue) Consume(ctx context.Context, handle func([]byte)) error {
+	r := kafka.NewReader(kafka.ReaderConfig{Brokers: q.brokers, Topic: q.topic, GroupID: q.group})
+	defer r.Close()
+	for {
+		msg, err := r.ReadMessage(ctx)
+		if err != nil {
+			return err
+		}
+		handle(msg.Value)
+	}
+}
@@ -0,0 +1,168 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	retentionJobID     = "analytics_retention"
+	retentionBatchSize = 1000
+)
+
+type retentionRun struct {
+	Expired  int64     `bson:"expired" json:"expired"`
+	Orphaned int64     `bson:"orphaned" json:"orphaned"`
+	RanAt    time.Time `bson:"ran_at" json:"ran_at"`
+}
+
+type retentionPolicy struct {
+	RetentionDays int           `json:"retention_days"`
+	RunAt         string        `json:"run_at"`
+	NextRunAt     time.Time     `json:"next_run_at"`
+	LastRun       *retentionRun `json:"last_run,omitempty"`
+}
+
+var lastRetention struct {
+	sync.Mutex
+	run *retentionRun
+}
+
+// startRetention deletes click events older than ANALYTICS_RETENTION_DAYS
+// once a day at ANALYTICS_RETENTION_AT, server local time.
+func startRetention(ctx context.Context) {
+	var run retentionRun
+	if err := jobs.FindOne(ctx, bson.M{"_id": retentionJobID}).Decode(&run); err == nil {
+		setLastRetention(run)
+	}
+
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextRetentionRun(time.Now())))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				if _, err := applyRetention(ctx); err != nil {
+					log.Printf("Analytics retention failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// nextRetentionRun is the first ANALYTICS_RETENTION_AT after now.
+func nextRetentionRun(now time.Time) time.Time {
+	at, _ := time.Parse("15:04", cfg.AnalyticsRetentionAt)
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// applyRetention deletes click events past the retention period, plus any
+// left behind by short codes that no longer exist. Deleting a code already
+// removes its events; the orphan sweep catches deletions that failed
+// part-way.
+func applyRetention(ctx context.Context) (retentionRun, error) {
+	run := retentionRun{RanAt: time.Now()}
+	cutoff := run.RanAt.AddDate(0, 0, -cfg.AnalyticsRetentionDays)
+
+	res, err := clickEvents.DeleteMany(ctx, bson.M{"timestamp": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return run, err
+	}
+	run.Expired = res.DeletedCount
+
+	if run.Orphaned, err = deleteOrphanedClickEvents(ctx); err != nil {
+		log.Printf("Failed to delete click events for deleted codes: %v", err)
+	}
+
+	_, err = jobs.UpdateOne(ctx,
+		bson.M{"_id": retentionJobID},
+		bson.M{"$set": run},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Failed to record analytics retention run: %v", err)
+	}
+	setLastRetention(run)
+	log.Printf("Analytics retention deleted %d click events older than %d days and %d for deleted codes",
+		run.Expired, cfg.AnalyticsRetentionDays, run.Orphaned)
+	return run, nil
+}
+
+func deleteOrphanedClickEvents(ctx context.Context) (int64, error) {
+	cur, err := clickEvents.Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{"_id": "$code"}},
+		bson.M{"$lookup": bson.M{"from": "urls", "localField": "_id", "foreignField": "code", "as": "url"}},
+		bson.M{"$match": bson.M{"url": bson.M{"$size": 0}}},
+		bson.M{"$project": bson.M{"_id": 1}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var (
+		deleted int64
+		batch   []string
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		res, err := clickEvents.DeleteMany(ctx, bson.M{"code": bson.M{"$in": batch}})
+		if err != nil {
+			return err
+		}
+		deleted += res.DeletedCount
+		batch = batch[:0]
+		return nil
+	}
+	for cur.Next(ctx) {
+		var doc struct {
+			Code string `bson:"_id"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return deleted, err
+		}
+		batch = append(batch, doc.Code)
+		if len(batch) == retentionBatchSize {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
+
+func setLastRetention(run retentionRun) {
+	lastRetention.Lock()
+	lastRetention.run = &run
+	lastRetention.Unlock()
+}
+
+func retentionHandler(w http.ResponseWriter, r *http.Request) {
+	lastRetention.Lock()
+	last := lastRetention.run
+	lastRetention.Unlock()
+
+	writeJSON(w, http.StatusOK, retentionPolicy{
+		RetentionDays: cfg.AnalyticsRetentionDays,
+		RunAt:         cfg.AnalyticsRetentionAt,
+		NextRunAt:     nextRetentionRun(time.Now()),
+		LastRun:       last,
+	})
+}
@@ -0,0 +1,23 @@
+package urlshortener
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// robotsHandler serves robots.txt. Short codes stay crawlable so search
+// engines follow the redirects; ROBOTS_TXT_DISALLOW lists what they must not
+// fetch.
+func robotsHandler(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	for _, path := range cfg.RobotsDisallow {
+		fmt.Fprintf(&b, "Disallow: %s\n", path)
+	}
+	b.WriteString("Allow: /\n")
+	fmt.Fprintf(&b, "\nSitemap: %s/sitemap_index.xml\n", publicBaseURL(r))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
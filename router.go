@@ -0,0 +1,159 @@
+package urlshortener
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RouteConfig describes a route and the limits applied to it. A zero
+// Timeout or RateLimit leaves that limit off.
+type RouteConfig struct {
+	Path      string
+	Method    string
+	Timeout   time.Duration
+	RateLimit int // requests per minute per client IP
+}
+
+// pattern returns the ServeMux pattern for the route.
+func (rc RouteConfig) pattern() string {
+	if rc.Method == "" {
+		return rc.Path
+	}
+	return rc.Method + " " + rc.Path
+}
+
+// Router registers handlers on a ServeMux, wrapping each in the timeout
+// and rate limit from its RouteConfig so per-route limits live in one
+// place.
+type Router struct {
+	mux *http.ServeMux
+
+	// patterns holds every registered pattern, for finding v2 successors.
+	// It is only written while routes are registered, before serving.
+	patterns map[string]bool
+}
+
+func newRouter() *Router {
+	return &Router{mux: http.NewServeMux(), patterns: make(map[string]bool)}
+}
+
+// Handle registers h for rc. Like ServeMux.Handle it panics if the
+// pattern conflicts with one already registered.
+func (rt *Router) Handle(rc RouteConfig, h http.Handler) {
+	if rc.Timeout > 0 {
+		h = timeoutMiddleware(rc.Timeout)(h)
+	}
+	if rc.RateLimit > 0 {
+		h = rateLimitMiddleware(rc.pattern(), rc.RateLimit)(h)
+	}
+	if strings.HasPrefix(rc.Path, apiV1) && cfg.APIDeprecationDate != "" {
+		h = rt.deprecationMiddleware(rc)(h)
+	}
+	if strings.Contains(rc.Path, "{code") {
+		h = codeContext(h)
+	}
+	rt.mux.Handle(rc.pattern(), h)
+	rt.patterns[rc.pattern()] = true
+	reserveRoute(rc.Path)
+}
+
+// reserveRoute adds the codes path would shadow to reservedCodes: its
+// first segment, and for an API path the segment after the version, unless
+// it is a wildcard.
+func reserveRoute(path string) {
+	segments := []string{strings.TrimPrefix(path, "/")}
+	for _, version := range []string{apiV1, apiV2} {
+		if rest, ok := strings.CutPrefix(path, version); ok {
+			segments = append(segments, rest)
+		}
+	}
+	for _, s := range segments {
+		s, _, _ = strings.Cut(s, "/")
+		if s != "" && !strings.HasPrefix(s, "{") {
+			reservedCodes[normalizeCode(s)] = true
+		}
+	}
+}
+
+// HandleFunc is Handle for a plain handler function.
+func (rt *Router) HandleFunc(rc RouteConfig, h http.HandlerFunc) {
+	rt.Handle(rc, h)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+const (
+	apiV1 = "/api/v1/"
+	apiV2 = "/api/v2/"
+)
+
+// successor returns the v2 pattern replacing the v1 route rc, if one is
+// registered.
+func (rt *Router) successor(rc RouteConfig) (string, bool) {
+	v2 := rc
+	v2.Path = apiV2 + strings.TrimPrefix(rc.Path, apiV1)
+	return v2.pattern(), rt.patterns[v2.pattern()]
+}
+
+// deprecationMiddleware marks responses of the v1 route rc as deprecated
+// since --api-deprecation-date (RFC 9745) and links the v2 successor,
+// once one is registered. The check runs per request because v2 routes
+// may be registered after their v1 counterparts.
+func (rt *Router) deprecationMiddleware(rc RouteConfig) Middleware {
+	since, _ := time.Parse(time.DateOnly, cfg.APIDeprecationDate)
+	deprecation := fmt.Sprintf("@%d", since.Unix())
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := rt.successor(rc); ok {
+				w.Header().Set("Deprecation", deprecation)
+				w.Header().Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, apiV2, strings.TrimPrefix(r.URL.Path, apiV1)))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// warnMissingSuccessors logs the v1 routes that --api-deprecation-date
+// can't mark deprecated because they have no v2 counterpart yet.
+func (rt *Router) warnMissingSuccessors() {
+	if cfg.APIDeprecationDate == "" {
+		return
+	}
+	var missing []string
+	for p := range rt.patterns {
+		method, path, found := strings.Cut(p, " ")
+		if !found {
+			method, path = "", p
+		}
+		if !strings.HasPrefix(path, apiV1) {
+			continue
+		}
+		if _, ok := rt.successor(RouteConfig{Method: method, Path: path}); !ok {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		log.Printf("Warning: --api-deprecation-date is set but %d v1 endpoints have no v2 counterpart and stay undeprecated: %s", len(missing), strings.Join(missing, ", "))
+	}
+}
+
+// rateLimitMiddleware allows limit requests per minute per client IP on
+// the route identified by pattern. The limit is checked before the
+// timeout starts so rejected requests don't count against it.
+func rateLimitMiddleware(pattern string, limit int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowRate(w, r, pattern, limit) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package urlshortener
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+// isolateReservedCodes swaps in an empty reservedCodes for the test, so
+// that routes registered by it don't leak into the server's.
+func isolateReservedCodes(t *testing.T) {
+	t.Helper()
+	saved := reservedCodes
+	reservedCodes = map[string]bool{}
+	t.Cleanup(func() { reservedCodes = saved })
+}
+
+func TestReserveRoute(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/", nil},
+		{"/{code}", nil},
+		{"/shorten", []string{"shorten"}},
+		{"/admin/stream", []string{"admin"}},
+		{"/p/{prefix}/{code}", []string{"p"}},
+		{"/t/{code...}", []string{"t"}},
+		{"/api/v1/shorten", []string{"api", "shorten"}},
+		{"/api/v1/{code}/qr", []string{"api"}},
+		{"/api/v1/admin/splits/{code...}", []string{"admin", "api"}},
+		{"/api/v2/links", []string{"api", "links"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			isolateReservedCodes(t)
+			reserveRoute(tt.path)
+			var got []string
+			for code := range reservedCodes {
+				got = append(got, code)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("reserveRoute(%q) reserved %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterRateLimit(t *testing.T) {
+	isolateReservedCodes(t)
+	resetRateBuckets(t)
+	rt := newRouter()
+	rt.HandleFunc(RouteConfig{Method: "GET", Path: "/router-test/limited", RateLimit: 2}, func(w http.ResponseWriter, r *http.Request) {})
+	rt.HandleFunc(RouteConfig{Method: "GET", Path: "/router-test/open"}, func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		path       string
+		remoteAddr string
+		status     int
+	}{
+		{"/router-test/limited", "192.0.2.1:1234", http.StatusOK},
+		{"/router-test/limited", "192.0.2.1:1234", http.StatusOK},
+		{"/router-test/limited", "192.0.2.1:1234", http.StatusTooManyRequests},
+		{"/router-test/limited", "192.0.2.2:1234", http.StatusOK},
+		{"/router-test/open", "192.0.2.1:1234", http.StatusOK},
+		{"/router-test/open", "192.0.2.1:1234", http.StatusOK},
+		{"/router-test/open", "192.0.2.1:1234", http.StatusOK},
+		{"/router-test/missing", "192.0.2.1:1234", http.StatusNotFound},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("request %d: GET %s from %s = %d, want %d", i, tt.path, tt.remoteAddr, w.Code, tt.status)
+		}
+	}
+	if !reservedCodes["router-test"] {
+		t.Error("Handle didn't reserve the route's first segment")
+	}
+}
+
+func TestShortenReservedCodes(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		code   string
+		status int
+	}{
+		{"ping", http.StatusBadRequest},
+		{"shorten", http.StatusBadRequest},
+		{"api", http.StatusBadRequest},
+		{"admin", http.StatusBadRequest},
+		{"bookmarklet", http.StatusBadRequest},
+		{"graphql", http.StatusBadRequest},
+		{fmt.Sprintf("unreserved-%d", time.Now().UnixNano()), http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"url": "https://example.com/reserved", "code": tt.code})
+			resp, err := http.Post(srv.URL+"/api/v1/shorten", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("shortening with code %q = %d, want %d", tt.code, resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	samplingInterval = time.Minute
+
+	// samplingActor is the audit actor of automatic sampling changes.
+	samplingActor = "click sampling"
+)
+
+var errInvalidSamplingRate = errors.New("sampling_rate must be greater than 0 and at most 1")
+
+// clickRates counts each code's redirects on this instance since the last
+// samplingInterval tick.
+var clickRates sync.Map // code -> *atomic.Int64
+
+// clickCount sums click events by their weight, the clicks each one stands
+// for under sampling, so that counts are estimated totals. Events recorded
+// without sampling have no weight and count once.
+var clickCount = bson.M{"$sum": bson.M{"$ifNull": bson.A{"$weight", 1}}}
+
+func validateSamplingRate(rate float64) error {
+	if rate <= 0 || rate > 1 {
+		return errInvalidSamplingRate
+	}
+	return nil
+}
+
+// samplingRate is the share of the URL's clicks recorded; an unset rate
+// records them all.
+func (m URLMapping) samplingRate() float64 {
+	if m.SamplingRate <= 0 || m.SamplingRate > 1 {
+		return 1
+	}
+	return m.SamplingRate
+}
+
+// sampleClick counts a redirect of code towards its click rate and draws
+// whether its click event is recorded at rate. A recorded event stands for
+// the weight clicks it returns.
+func sampleClick(code string, rate float64) (weight int64, record bool) {
+	if cfg.ClickSamplingThreshold > 0 {
+		n, _ := clickRates.LoadOrStore(code, new(atomic.Int64))
+		n.(*atomic.Int64).Add(1)
+	}
+	if rate >= 1 {
+		return 1, true
+	}
+	if rand.Float64() >= rate {
+		return 0, false
+	}
+	return int64(math.Round(1 / rate)), true
+}
+
+// startClickSampling lowers the sampling rate of every URL redirected more
+// than CLICK_SAMPLING_THRESHOLD times a minute on this instance, so that
+// about that many click events a minute are still recorded. It never
+// raises a rate again; that is left to PATCH.
+func startClickSampling(ctx context.Context) {
+	if cfg.ClickSamplingThreshold <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(samplingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				adjustSampling(ctx)
+			}
+		}
+	}()
+}
+
+func adjustSampling(ctx context.Context) {
+	threshold := int64(cfg.ClickSamplingThreshold)
+	clickRates.Range(func(k, v any) bool {
+		code := k.(string)
+		n := v.(*atomic.Int64).Swap(0)
+		if n == 0 {
+			clickRates.Delete(code)
+			return true
+		}
+		if n <= threshold {
+			return true
+		}
+		rate := 1 / math.Ceil(float64(n)/float64(threshold))
+		mapping, err := findInMongoDB(code)
+		if err != nil {
+			log.Printf("Failed to load %s for click sampling: %v", code, err)
+			return true
+		}
+		if rate >= mapping.samplingRate() {
+			return true
+		}
+		if _, err := updateShortURL(ctx, code, samplingActor, bson.M{"sampling_rate": rate}, nil); err != nil {
+			log.Printf("Failed to lower the sampling rate of %s: %v", code, err)
+			return true
+		}
+		log.Printf("Sampling 1 in %.0f clicks of %s after %d redirects in %s", 1/rate, code, n, samplingInterval)
+		return true
+	})
+}
@@ -0,0 +1,258 @@
+package urlshortener
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	screenshotInterval = time.Second
+	screenshotTimeout  = 20 * time.Second
+	screenshotQuality  = 80
+	screenshotCacheAge = "public, max-age=86400"
+	robotsMaxBytes     = 512 << 10
+)
+
+type screenshotJob struct {
+	code, dest string
+}
+
+var screenshotQueue = make(chan screenshotJob, 256)
+
+// enqueueScreenshot schedules a screenshot of dest for code. Jobs are
+// dropped when screenshots are off or the queue is full; a missing
+// thumbnail is harmless.
+func enqueueScreenshot(code, dest string) {
+	if !cfg.Screenshots {
+		return
+	}
+	select {
+	case screenshotQueue <- screenshotJob{code, dest}:
+	default:
+		log.Printf("Screenshot queue full, skipping %s", code)
+	}
+}
+
+func screenshotBucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(client.Database("urlshortener"), options.GridFSBucket().SetName("screenshots"))
+}
+
+// startScreenshots runs the screenshot worker when SCREENSHOTS is enabled.
+// It needs a Chrome or Chromium binary on the PATH and takes at most one
+// screenshot per screenshotInterval.
+func startScreenshots(ctx context.Context) {
+	if !cfg.Screenshots {
+		return
+	}
+	// Chrome can't be given publicDialer, so it browses through a proxy that
+	// uses it. Without the bypass list Chrome would reach loopback directly.
+	proxy, err := startPublicProxy()
+	if err != nil {
+		log.Printf("Screenshots disabled: %v", err)
+		return
+	}
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.ProxyServer("http://"+proxy),
+		chromedp.Flag("proxy-bypass-list", "<-loopback>"),
+	)
+	allocCtx, _ := chromedp.NewExecAllocator(ctx, opts...)
+	browserCtx, _ := chromedp.NewContext(allocCtx)
+
+	go func() {
+		ticker := time.NewTicker(screenshotInterval)
+		defer ticker.Stop()
+		for {
+			var job screenshotJob
+			select {
+			case <-ctx.Done():
+				return
+			case job = <-screenshotQueue:
+			}
+			<-ticker.C
+
+			if !robotsAllowHeadless(job.dest) {
+				log.Printf("Skipping screenshot of %s for %s: disallowed by robots.txt", job.dest, job.code)
+				continue
+			}
+			if err := takeScreenshot(browserCtx, job); err != nil {
+				log.Printf("Failed to screenshot %s for %s: %v", job.dest, job.code, err)
+			}
+		}
+	}()
+}
+
+func takeScreenshot(browserCtx context.Context, job screenshotJob) error {
+	tabCtx, cancel := chromedp.NewContext(browserCtx)
+	defer cancel()
+	tabCtx, cancel = context.WithTimeout(tabCtx, screenshotTimeout)
+	defer cancel()
+
+	var img []byte
+	err := chromedp.Run(tabCtx,
+		chromedp.EmulateViewport(1280, 800),
+		chromedp.Navigate(job.dest),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			img, err = page.CaptureScreenshot().
+				WithFormat(page.CaptureScreenshotFormatJpeg).
+				WithQuality(screenshotQuality).
+				Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	bucket, err := screenshotBucket()
+	if err != nil {
+		return err
+	}
+	// Replace any earlier screenshot for the code, e.g. after a URL change.
+	if cur, err := bucket.Find(bson.M{"filename": job.code}); err == nil {
+		var old []struct {
+			ID interface{} `bson:"_id"`
+		}
+		if cur.All(context.Background(), &old) == nil {
+			for _, f := range old {
+				bucket.Delete(f.ID)
+			}
+		}
+	}
+	_, err = bucket.UploadFromStream(job.code, bytes.NewReader(img),
+		options.GridFSUpload().SetMetadata(bson.M{"content_type": "image/jpeg", "url": job.dest}))
+	return err
+}
+
+// robotsAllowHeadless checks dest's robots.txt, preferring a group for
+// HeadlessChrome over the * group. Unreachable or missing robots.txt files
+// allow everything, as crawlers treat them.
+func robotsAllowHeadless(dest string) bool {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return false
+	}
+	resp, err := fetchClient.Get(u.Scheme + "://" + u.Host + "/robots.txt")
+	if err != nil {
+		return true
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return true
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return robotsAllows(io.LimitReader(resp.Body, robotsMaxBytes), "headlesschrome", path)
+}
+
+type robotsRule struct {
+	allow  bool
+	prefix string
+}
+
+// robotsAllows applies the longest matching Allow/Disallow rule from the
+// group for agent, or the * group when there is none.
+func robotsAllows(r io.Reader, agent, path string) bool {
+	groups := map[string][]robotsRule{}
+	var current []string
+	inRules := false
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				current, inRules = nil, false
+			}
+			current = append(current, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			for _, ua := range current {
+				groups[ua] = append(groups[ua], robotsRule{allow: key == "allow", prefix: value})
+			}
+		}
+	}
+
+	rules, ok := groups[agent]
+	if !ok {
+		rules = groups["*"]
+	}
+	best, allowed := -1, true
+	for _, rule := range rules {
+		if strings.HasPrefix(path, rule.prefix) && (len(rule.prefix) > best || (len(rule.prefix) == best && rule.allow)) {
+			best, allowed = len(rule.prefix), rule.allow
+		}
+	}
+	return allowed
+}
+
+func screenshotHandler(w http.ResponseWriter, r *http.Request) {
+	mapping, err := lookupCode(codeParam(r))
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Failed to load screenshot", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if !checkSignature(w, r, mapping) {
+		return
+	}
+	code := mapping.Code
+
+	bucket, err := screenshotBucket()
+	if err != nil {
+		http.Error(w, "Failed to load screenshot", http.StatusInternalServerError)
+		return
+	}
+	stream, err := bucket.OpenDownloadStreamByName(code)
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to open screenshot for %s: %v", code, err)
+		http.Error(w, "Failed to load screenshot", http.StatusInternalServerError)
+		return
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", screenshotCacheAge)
+	if _, err := io.Copy(w, stream); err != nil {
+		log.Printf("Error sending screenshot for %s: %v", code, err)
+	}
+}
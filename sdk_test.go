@@ -0,0 +1,45 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	sdk "urlshortener/sdk/go"
+)
+
+// TestSDKRoundTrip shortens a URL and resolves its code through the
+// generated Go client.
+func TestSDKRoundTrip(t *testing.T) {
+	srv := newTestServer(t)
+	c := sdk.New(srv.URL)
+	ctx := context.Background()
+
+	created, err := c.ShortenURL(ctx, sdk.ShortenRequest{URL: sdk.Ptr("https://example.com/sdk")}, nil)
+	if err != nil {
+		t.Fatalf("ShortenURL: %v", err)
+	}
+	if created.Code == "" || created.URL == nil || *created.URL != "https://example.com/sdk" {
+		t.Fatalf("ShortenURL = %+v, want a code for https://example.com/sdk", created)
+	}
+
+	res, err := c.ResolveCode(ctx, created.Code, nil)
+	if err != nil {
+		t.Fatalf("ResolveCode(%q): %v", created.Code, err)
+	}
+	if res.URL != "https://example.com/sdk" {
+		t.Errorf("ResolveCode(%q).URL = %q, want https://example.com/sdk", created.Code, res.URL)
+	}
+
+	custom, err := c.ShortenURL(ctx, sdk.ShortenRequest{URL: sdk.Ptr("https://example.com/other"), Code: sdk.Ptr(created.Code)}, nil)
+	var apiErr *sdk.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("ShortenURL with taken code = %+v, %v; want a 409 error", custom, err)
+	}
+
+	_, err = c.ResolveCode(ctx, "sdk-missing", nil)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("ResolveCode of unknown code: err = %v, want a 404 error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package urlshortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// selfTestCode is in reservedCodes, so no real short URL has it.
+	selfTestCode = "__healthcheck__"
+	selfTestURL  = "https://example.com"
+
+	selfTestTimeout = 10 * time.Second
+
+	// selfTestAttempts bounds the waits for another instance that is
+	// running its own self-test with the same code.
+	selfTestAttempts = 5
+)
+
+var selfTestSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "startup_self_test_seconds",
+	Help: "How long the startup self-test took to write, read and delete a short URL.",
+})
+
+// selfTest writes a synthetic short URL, reads it back with findInMongoDB
+// and deletes it, and exits if any step fails, so that bad credentials,
+// missing write permissions or conflicting indexes are caught before the
+// server is marked ready rather than on the first real request.
+func selfTest(ctx context.Context) {
+	start := time.Now()
+	if err := runSelfTest(ctx); err != nil {
+		log.Fatalf("Startup self-test failed: %v", err)
+	}
+	d := time.Since(start)
+	selfTestSeconds.Set(d.Seconds())
+	log.Printf("Startup self-test passed in %s", d.Round(time.Millisecond))
+}
+
+func runSelfTest(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	// A leftover from a run that died midway would otherwise fail every
+	// later one; a recent one may belong to an instance starting alongside.
+	_, err := collection.DeleteOne(ctx, bson.M{
+		"code":       selfTestCode,
+		"created_at": bson.M{"$lt": time.Now().Add(-selfTestTimeout)},
+	})
+	if err != nil {
+		return fmt.Errorf("delete leftover: %w", err)
+	}
+
+	m := URLMapping{Code: selfTestCode, URL: selfTestURL, CreatedAt: time.Now()}
+	for attempt := 1; ; attempt++ {
+		_, err = collection.InsertOne(ctx, m)
+		if !mongo.IsDuplicateKeyError(err) || attempt == selfTestAttempts {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	got, err := findInMongoDB(selfTestCode)
+	if err == nil && got.URL != selfTestURL {
+		err = fmt.Errorf("got destination %q", got.URL)
+	}
+	if err != nil {
+		if _, err := collection.DeleteOne(ctx, bson.M{"code": selfTestCode}); err != nil {
+			log.Printf("Failed to delete %s after the self-test: %v", selfTestCode, err)
+		}
+		return fmt.Errorf("read: %w", err)
+	}
+
+	res, err := collection.DeleteOne(ctx, bson.M{"code": selfTestCode})
+	if err == nil && res.DeletedCount == 0 {
+		err = fmt.Errorf("%s was already gone", selfTestCode)
+	}
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package urlshortener
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	sessionCookie = "session"
+	sessionTTL    = 12 * time.Hour
+)
+
+var sessions *mongo.Collection
+
+type Session struct {
+	SessionID string    `bson:"session_id"`
+	UserID    string    `bson:"user_id"`
+	Admin     bool      `bson:"admin"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// startSession stores a new session for user and sets its cookie on w. Only
+// admin sessions pass requireAdmin.
+func startSession(ctx context.Context, w http.ResponseWriter, user string, admin bool) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	now := time.Now()
+	s := Session{SessionID: hex.EncodeToString(b), UserID: user, Admin: admin, CreatedAt: now, ExpiresAt: now.Add(sessionTTL)}
+	if _, err := sessions.InsertOne(ctx, s); err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.SessionID,
+		Path:     "/",
+		Expires:  s.ExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// currentSession returns the unexpired session named by r's cookie.
+func currentSession(r *http.Request) (Session, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return Session{}, false
+	}
+	var s Session
+	err = sessions.FindOne(r.Context(), bson.M{
+		"session_id": c.Value,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&s)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Failed to check session: %v", err)
+		}
+		return Session{}, false
+	}
+	return s, true
+}
+
+// validSession reports whether r carries an unexpired admin session.
+func validSession(r *http.Request) bool {
+	s, ok := currentSession(r)
+	return ok && s.Admin
+}
+
+// logoutHandler deletes the caller's session, clears the cookie and sends
+// the browser home. Browsers
+// keep resending cached Basic Auth credentials, so the next admin request
+// may still log straight back in.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if _, err := sessions.DeleteOne(r.Context(), bson.M{"session_id": c.Value}); err != nil {
+			log.Printf("Failed to delete session: %v", err)
+			http.Error(w, "Failed to log out", http.StatusInternalServerError)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
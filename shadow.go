@@ -0,0 +1,72 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const shadowTimeout = 10 * time.Second
+
+// shadowClient mirrors redirects. Unlike fetchClient it may reach private
+// addresses, since only admins set shadow URLs, often to internal services.
+var shadowClient = &http.Client{Timeout: shadowTimeout}
+
+var shadowEvents *mongo.Collection
+
+type ShadowEvent struct {
+	Code       string    `bson:"code"`
+	ShadowURL  string    `bson:"shadow_url"`
+	StatusCode int       `bson:"status_code,omitempty"`
+	LatencyMS  int64     `bson:"latency_ms"`
+	Error      string    `bson:"error,omitempty"`
+	Timestamp  time.Time `bson:"timestamp"`
+}
+
+// shadowURLFor appends the visitor's query parameters to the shadow URL so
+// the secondary service sees the same request shape as the primary.
+func shadowURLFor(shadow string, r *http.Request) (string, error) {
+	u, err := url.Parse(shadow)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for k, vs := range r.URL.Query() {
+		for _, v := range vs {
+			q.Add(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// sendShadowRequest mirrors a redirect to the mapping's shadow URL and records
+// how it went. It runs in the background and never affects the visitor.
+func sendShadowRequest(code, target string) {
+	ev := ShadowEvent{Code: code, ShadowURL: target, Timestamp: time.Now()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+	defer cancel()
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = shadowClient.Do(req); err == nil {
+			resp.Body.Close()
+			ev.StatusCode = resp.StatusCode
+		}
+	}
+	ev.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		ev.Error = err.Error()
+	}
+
+	if _, err := shadowEvents.InsertOne(context.Background(), ev); err != nil {
+		log.Printf("Error recording shadow event for %s: %v", code, err)
+	}
+}
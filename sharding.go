@@ -0,0 +1,61 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const shardingWarnThreshold = 10000000
+
+// urlsSharded records whether the urls collection is sharded, checked at
+// startup.
+var urlsSharded atomic.Bool
+
+// checkSharding records whether the urls collection is sharded and warns
+// when a large collection isn't.
+//
+// For deployments that outgrow a replica set, shard the urls collection
+// on a hashed code:
+//
+//	sh.enableSharding("urlshortener")
+//	sh.shardCollection("urlshortener.urls", {code: "hashed"})
+//
+// Redirects look a mapping up by its exact code, so hashing spreads new
+// codes evenly across shards while every lookup still goes to a single
+// shard. The unique {code: 1} index keeps enforcing uniqueness alongside
+// the hashed shard key index.
+//
+// Queries and updates on a single mapping filter on code so mongos can
+// route them to one shard; changing a document's code also requires the
+// old code in the filter. Anything that selects by a range or another
+// field instead - listing, the sitemap, vacuum, retention, health checks,
+// GDPR erase and export by owner_id, and the aggregate summaries - is a
+// broadcast to every shard. That's acceptable for those admin and
+// background paths, but keep new per-request queries keyed on code.
+func checkSharding(ctx context.Context) {
+	var stats struct {
+		Sharded bool `bson:"sharded"`
+	}
+	err := collection.Database().RunCommand(ctx, bson.D{{Key: "collStats", Value: collection.Name()}}).Decode(&stats)
+	if err != nil {
+		log.Printf("Failed to check whether %s is sharded: %v", collection.Name(), err)
+		return
+	}
+	urlsSharded.Store(stats.Sharded)
+	if stats.Sharded {
+		return
+	}
+
+	n, err := collection.EstimatedDocumentCount(ctx)
+	if err != nil {
+		log.Printf("Failed to count %s: %v", collection.Name(), err)
+		return
+	}
+	if n > shardingWarnThreshold {
+		log.Printf("Warning: %s holds %d documents and is not sharded; consider sh.shardCollection(%q, {code: \"hashed\"})",
+			collection.Name(), n, collection.Database().Name()+"."+collection.Name())
+	}
+}
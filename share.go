@@ -0,0 +1,60 @@
+package urlshortener
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ShareLinks are pre-composed sharing URLs for a short URL, using the
+// destination title scraped when the link was created. Social networks
+// get ShareURL, whose page carries the destination's preview; email gets
+// the short URL itself.
+type ShareLinks struct {
+	ShortURL string `json:"short_url"`
+	ShareURL string `json:"share_url"`
+	Title    string `json:"title,omitempty"`
+	Twitter  string `json:"twitter"`
+	LinkedIn string `json:"linkedin"`
+	Facebook string `json:"facebook"`
+	Email    string `json:"email"`
+}
+
+func shareLinks(base string, m URLMapping) ShareLinks {
+	s := ShareLinks{ShortURL: base + m.Path(), ShareURL: base + ogPath(m)}
+	switch {
+	case m.Preview != nil && m.Preview.Title != "":
+		s.Title = m.Preview.Title
+	case m.Archive != nil:
+		s.Title = m.Archive.Title
+	}
+
+	tweet := url.Values{"url": {s.ShareURL}}
+	if s.Title != "" {
+		tweet.Set("text", s.Title)
+	}
+	s.Twitter = "https://twitter.com/intent/tweet?" + tweet.Encode()
+	s.LinkedIn = "https://www.linkedin.com/sharing/share-offsite/?" + url.Values{"url": {s.ShareURL}}.Encode()
+	s.Facebook = "https://www.facebook.com/sharer/sharer.php?" + url.Values{"u": {s.ShareURL}}.Encode()
+	// url.Values encodes spaces as "+", which mail clients show literally.
+	s.Email = "mailto:?subject=" + url.PathEscape(s.Title) + "&body=" + url.PathEscape(s.ShortURL)
+	return s
+}
+
+func apiShareHandler(w http.ResponseWriter, r *http.Request) {
+	mapping, err := lookupCode(codeParam(r))
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Failed to resolve code", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || mapping.Expired() || mapping.Disabled {
+		http.NotFound(w, r)
+		return
+	}
+	if !checkSignature(w, r, mapping) {
+		return
+	}
+	writeJSON(w, http.StatusOK, shareLinks(publicBaseURL(r), mapping))
+}
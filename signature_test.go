@@ -0,0 +1,42 @@
+package urlshortener
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckSignature(t *testing.T) {
+	valid := signCode("signed-code")
+	tests := []struct {
+		name     string
+		requires bool
+		secret   string
+		sig      string
+		want     bool
+	}{
+		{"not required", false, testHMACSecret, "", true},
+		{"not required without secret", false, "", "", true},
+		{"valid", true, testHMACSecret, valid, true},
+		{"missing", true, testHMACSecret, "", false},
+		{"wrong", true, testHMACSecret, "AAAAAAAAAAAAAAAAAAAAAA", false},
+		{"for another code", true, testHMACSecret, signCode("other-code"), false},
+		{"no secret configured", true, "", valid, false},
+	}
+	saved := cfg.HMACSecret
+	t.Cleanup(func() { cfg.HMACSecret = saved })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.HMACSecret = tt.secret
+			r := httptest.NewRequest("GET", "/signed-code?sig="+tt.sig, nil)
+			w := httptest.NewRecorder()
+			got := checkSignature(w, r, URLMapping{Code: "signed-code", RequiresSignature: tt.requires})
+			if got != tt.want {
+				t.Errorf("checkSignature = %v, want %v", got, tt.want)
+			}
+			if !got && w.Code != http.StatusForbidden {
+				t.Errorf("refused with status %d, want 403", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	sitemapMaxURLs = 50000
+	sitemapXMLNS   = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"sitemapindex"`
+	XMLNS    string         `xml:"xmlns,attr"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc string `xml:"loc"`
+}
+
+// publicBaseURL is BASE_URL when configured, otherwise the scheme and host
+// the request arrived on.
+func publicBaseURL(r *http.Request) string {
+	if cfg.BaseURL != "" {
+		return cfg.BaseURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// changeFreq estimates how often a link is "updated" from its click rate, as
+// a hint to crawlers about how often it's worth revisiting.
+func changeFreq(m URLMapping) string {
+	days := time.Since(m.CreatedAt).Hours() / 24
+	if days < 1 {
+		days = 1
+	}
+	switch rate := float64(m.Clicks) / days; {
+	case rate >= 100:
+		return "hourly"
+	case rate >= 1:
+		return "daily"
+	case rate >= 1.0/7:
+		return "weekly"
+	default:
+		return "monthly"
+	}
+}
+
+// sitemapFilter matches the live mappings worth listing. Signed URLs are
+// left out: listing them would hand out the codes they hide.
+func sitemapFilter() bson.M {
+	filter := liveFilter()
+	filter["requires_signature"] = bson.M{"$ne": true}
+	return filter
+}
+
+// sitemapHandler serves one page of up to sitemapMaxURLs links, selected by
+// ?page=N (default 1).
+func sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetSkip(int64((page - 1) * sitemapMaxURLs)).
+		SetLimit(sitemapMaxURLs).
+		SetProjection(bson.M{"code": 1, "prefix": 1, "clicks": 1, "created_at": 1})
+	cur, err := collection.Find(r.Context(), sitemapFilter(), opts)
+	if err != nil {
+		log.Printf("Failed to query sitemap URLs: %v", err)
+		http.Error(w, "Failed to build sitemap", http.StatusInternalServerError)
+		return
+	}
+	var mappings []URLMapping
+	if err := cur.All(r.Context(), &mappings); err != nil {
+		log.Printf("Failed to decode sitemap URLs: %v", err)
+		http.Error(w, "Failed to build sitemap", http.StatusInternalServerError)
+		return
+	}
+	if page > 1 && len(mappings) == 0 {
+		notFound(w, r)
+		return
+	}
+
+	base := publicBaseURL(r)
+	set := sitemapURLSet{XMLNS: sitemapXMLNS, URLs: make([]sitemapURL, 0, len(mappings))}
+	for _, m := range mappings {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:        base + m.Path(),
+			LastMod:    m.CreatedAt.UTC().Format("2006-01-02"),
+			ChangeFreq: changeFreq(m),
+		})
+	}
+	writeXML(w, set)
+}
+
+// sitemapIndexHandler lists every sitemap page.
+func sitemapIndexHandler(w http.ResponseWriter, r *http.Request) {
+	pages, err := sitemapPages(r.Context())
+	if err != nil {
+		log.Printf("Failed to count sitemap URLs: %v", err)
+		http.Error(w, "Failed to build sitemap index", http.StatusInternalServerError)
+		return
+	}
+
+	base := publicBaseURL(r)
+	index := sitemapIndex{XMLNS: sitemapXMLNS}
+	for page := 1; page <= pages; page++ {
+		index.Sitemaps = append(index.Sitemaps, sitemapEntry{Loc: fmt.Sprintf("%s/sitemap.xml?page=%d", base, page)})
+	}
+	writeXML(w, index)
+}
+
+func sitemapPages(ctx context.Context) (int, error) {
+	n, err := collection.CountDocuments(ctx, sitemapFilter())
+	if err != nil {
+		return 0, err
+	}
+	pages := int((n + sitemapMaxURLs - 1) / sitemapMaxURLs)
+	if pages < 1 {
+		pages = 1
+	}
+	return pages, nil
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding XML response: %v", err)
+	}
+}
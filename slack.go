@@ -0,0 +1,81 @@
+package urlshortener
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	slackAttempts     = 3
+	slackFirstBackoff = time.Second
+)
+
+var slackClient = &http.Client{Timeout: 10 * time.Second}
+
+// slackEscape escapes the characters Slack treats as markup in message
+// text.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// notifySlack posts a newly created mapping to SLACK_WEBHOOK_URL, retrying
+// failed deliveries with exponential backoff. It is a no-op when the
+// webhook isn't configured. Run it in a goroutine.
+func notifySlack(base string, m URLMapping, creatorIP string) {
+	if cfg.SlackWebhookURL == "" {
+		return
+	}
+
+	dest := m.URL
+	if m.TargetCode != "" {
+		dest = base + "/" + m.TargetCode
+	}
+	lines := []string{
+		fmt.Sprintf("New short URL <%s|%s>", base+m.Path(), slackEscape.Replace(m.Code)),
+		"Destination: " + slackEscape.Replace(dest),
+		"Created by: " + slackEscape.Replace(creatorIP),
+	}
+	if len(m.Tags) > 0 {
+		lines = append(lines, "Tags: "+slackEscape.Replace(strings.Join(m.Tags, ", ")))
+	}
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{strings.Join(lines, "\n")})
+	if err != nil {
+		log.Printf("Failed to encode Slack message for %s: %v", m.Code, err)
+		return
+	}
+
+	backoff := slackFirstBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := postSlack(body)
+		if err == nil {
+			recordAudit(m.Code, auditWebhookSent, "", "Slack")
+			return
+		}
+		if !retry || attempt == slackAttempts {
+			log.Printf("Failed to notify Slack of %s after %d attempts: %v", m.Code, attempt, err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postSlack delivers one message, reporting whether a failure is worth
+// retrying.
+func postSlack(body []byte) (retry bool, err error) {
+	resp, err := slackClient.Post(cfg.SlackWebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook answered %s", resp.Status)
+}
@@ -0,0 +1,20 @@
+package urlshortener
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed frontend/dist
+var frontendFS embed.FS
+
+// spaHandler serves the single-page app under /app/. The app talks to the
+// JSON API on the same origin, so no CORS configuration is needed.
+func spaHandler() http.Handler {
+	dist, err := fs.Sub(frontendFS, "frontend/dist")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/app/", http.FileServer(http.FS(dist)))
+}
@@ -0,0 +1,177 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	splitA = "a"
+	splitB = "b"
+)
+
+var errInvalidSplitPct = errors.New("pct_a must be between 0 and 100")
+
+// TrafficSplit sends PctA percent of a short URL's redirects to URLA and
+// the rest to URLB, for moving traffic between two real destinations
+// gradually rather than testing copy. It overrides the URL's own
+// destination; ClicksA and ClicksB count each side separately, while the
+// URL's clicks still count both.
+type TrafficSplit struct {
+	URLA    string  `bson:"url_a" json:"url_a"`
+	URLB    string  `bson:"url_b" json:"url_b"`
+	PctA    float64 `bson:"pct_a" json:"pct_a"`
+	ClicksA int64   `bson:"clicks_a" json:"clicks_a"`
+	ClicksB int64   `bson:"clicks_b" json:"clicks_b"`
+}
+
+// pick draws the side of the split a redirect goes to, splitA or splitB,
+// and its destination.
+func (s TrafficSplit) pick() (string, string) {
+	if float64(rand.Float32())*100 < s.PctA {
+		return splitA, s.URLA
+	}
+	return splitB, s.URLB
+}
+
+// splitHook sends the redirect to a side of the followed link's traffic
+// split, if it has one.
+func splitHook(ctx context.Context, code, url string, r *http.Request) (string, bool, error) {
+	st := redirectStateFrom(ctx)
+	if st == nil || st.split == nil {
+		return "", false, nil
+	}
+	st.splitSide, url = st.split.pick()
+	traceRule(ctx, "split", st.splitSide)
+	return url, false, nil
+}
+
+type splitRequest struct {
+	URLA string   `json:"url_a"`
+	URLB string   `json:"url_b"`
+	PctA *float64 `json:"pct_a"`
+}
+
+func validateSplitPct(pct float64) error {
+	if pct < 0 || pct > 100 {
+		return errInvalidSplitPct
+	}
+	return nil
+}
+
+// putSplitHandler serves PUT /api/v1/admin/splits/{code}, which starts
+// splitting the URL's traffic, or replaces its split with one whose counts
+// start from zero.
+func putSplitHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	var req splitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.PctA == nil {
+		http.Error(w, "pct_a is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateSplitPct(*req.PctA); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	split := TrafficSplit{PctA: *req.PctA}
+	for _, side := range []struct {
+		name, raw string
+		dest      *string
+	}{{"url_a", req.URLA, &split.URLA}, {"url_b", req.URLB, &split.URLB}} {
+		dest, err := validateDestinationURL(side.raw)
+		if err != nil {
+			http.Error(w, side.name+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		*side.dest = dest
+	}
+
+	updated, err := updateShortURL(r.Context(), code, requestActor(r), bson.M{"traffic_split": split}, nil)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to split traffic of %s: %v", code, err)
+		http.Error(w, "Failed to update traffic split", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated.TrafficSplit)
+}
+
+// patchSplitHandler serves PATCH /api/v1/admin/splits/{code}, which moves
+// pct_a of a running split. Redirects follow at once, the counts carry on.
+func patchSplitHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	var req struct {
+		PctA *float64 `json:"pct_a"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.PctA == nil {
+		http.Error(w, "pct_a is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateSplitPct(*req.PctA); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Setting traffic_split.pct_a without a split would leave one with no
+	// destinations.
+	mapping, err := findInMongoDB(code)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Failed to load %s: %v", code, err)
+		http.Error(w, "Failed to update traffic split", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || mapping.TrafficSplit == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	updated, err := updateShortURL(r.Context(), code, requestActor(r), bson.M{"traffic_split.pct_a": *req.PctA}, nil)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to update traffic split of %s: %v", code, err)
+		http.Error(w, "Failed to update traffic split", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated.TrafficSplit)
+}
+
+// deleteSplitHandler serves DELETE /api/v1/admin/splits/{code}, which ends
+// the split: the URL redirects to its own destination again.
+func deleteSplitHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	_, err := updateShortURL(r.Context(), code, requestActor(r), nil, bson.M{"traffic_split": ""})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to remove traffic split of %s: %v", code, err)
+		http.Error(w, "Failed to update traffic split", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,162 @@
+package urlshortener
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"urlshortener/pkg/analytics"
+	"urlshortener/pkg/api"
+	"urlshortener/pkg/shortener"
+	"urlshortener/pkg/store"
+)
+
+// STORE_BACKEND values. The features beyond package api's core routes are
+// written against MongoDB, so only mongo runs the full server.
+const (
+	storeMongo    = "mongo"
+	storeSQLite   = "sqlite"
+	storePostgres = "postgres"
+)
+
+// sqlStore is what runSQLStore needs of store.SQLite and store.Postgres.
+type sqlStore interface {
+	store.Store
+	DB() *sql.DB
+	BlockedDomains(ctx context.Context) ([]string, error)
+	Close() error
+}
+
+// runSQLStore serves package api over the STORE_BACKEND database instead
+// of the full server's routes.
+func runSQLStore() {
+	ctx := context.Background()
+	var st sqlStore
+	var rec analytics.Recorder
+	if cfg.StoreBackend == storePostgres {
+		pg, err := store.OpenPostgres(ctx, cfg.PostgresDSN)
+		if err != nil {
+			log.Fatalf("Failed to open PostgreSQL database: %v", err)
+		}
+		st, rec = pg, analytics.NewPostgres(pg.DB())
+	} else {
+		lite, err := store.OpenSQLite(ctx, cfg.SQLitePath)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database %s: %v", cfg.SQLitePath, err)
+		}
+		st, rec = lite, analytics.NewSQLite(lite.DB())
+	}
+	defer st.Close()
+
+	load := sqlBlockedDomains(st)
+	if err := load(ctx); err != nil {
+		log.Printf("Failed to load blocked domains: %v", err)
+	}
+	go watchBlockedDomains(ctx, load)
+	go sweepRateBuckets(ctx)
+	startRedisRateLimiter()
+	readyState.Store(stateReady)
+
+	serve(sqlStoreHandler(st, rec))
+}
+
+// sqlStoreHandler is package api over st with the checks the full server
+// makes on the same routes: the shorten rate limit, blocked domains,
+// reserved and profane codes, and the health and readiness probes.
+func sqlStoreHandler(st sqlStore, rec analytics.Recorder) http.Handler {
+	shortenLimit := Middleware(func(h http.Handler) http.Handler { return h })
+	if cfg.ShortenRateLimit > 0 {
+		shortenLimit = rateLimitMiddleware("POST /api/v1/shorten", cfg.ShortenRateLimit)
+	}
+	s := shortener.New(st, shortener.Options{Recorder: rec})
+
+	r := newRouter()
+	r.Handle(RouteConfig{Path: "/"}, api.Handler(s, api.Options{
+		BaseURL:  cfg.BaseURL,
+		ClientIP: realIP,
+		Admin:    sqlStoreAdmin,
+		OnError: func(r *http.Request, err error) {
+			loggerFrom(r.Context()).Error("Request failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+		},
+		ValidateURL:  validateDestinationURL,
+		ValidateCode: validateCode,
+		CodeStatus:   validateCodeStatus,
+		ShortenLimit: shortenLimit,
+	}))
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/healthz"}, sqlHealthHandler(st.DB()))
+	r.HandleFunc(RouteConfig{Method: "GET", Path: "/ready"}, readyHandler)
+	if cfg.KubernetesMode {
+		r.Handle(RouteConfig{Method: "GET", Path: "/prestop"}, localOnly(preStopHandler))
+	}
+	// Package api's routes are registered on its own mux.
+	reserveRoute("/api/v1/shorten")
+	return r
+}
+
+// sqlBlockedDomains loads blocklist from st's blocked_domains table.
+// Domains are normalised as the admin API would, since they are added by
+// hand.
+func sqlBlockedDomains(st sqlStore) func(context.Context) error {
+	return func(ctx context.Context) error {
+		list, err := st.BlockedDomains(ctx)
+		if err != nil {
+			return err
+		}
+		domains := make(map[string]struct{}, len(list))
+		for _, raw := range list {
+			d, err := normalizeDomain(raw)
+			if err != nil {
+				log.Printf("Skipping blocked domain %q: %v", raw, err)
+				continue
+			}
+			domains[d] = struct{}{}
+		}
+		blocklistMu.Lock()
+		defer blocklistMu.Unlock()
+		blocklist.Store(&domains)
+		return nil
+	}
+}
+
+type sqlHealthResponse struct {
+	Status   string `json:"status"`
+	Database string `json:"database"`
+}
+
+// sqlHealthHandler is healthHandler for the SQL backends: 503 while the
+// server is starting or db is unreachable.
+func sqlHealthHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := sqlHealthResponse{Status: readyState.Load().(string), Database: "ok"}
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			resp.Database = err.Error()
+		}
+		status := http.StatusOK
+		if resp.Status != stateReady || resp.Database != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, resp)
+	}
+}
+
+// sqlStoreAdmin is requireAdmin without sessions and impersonation, which
+// are kept in MongoDB: the admin Basic Auth credentials from an allowed
+// address.
+func sqlStoreAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !adminClientAllowed(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if _, ok := adminBasicAuth(r); !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="urlshortener admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
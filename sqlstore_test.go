@@ -0,0 +1,95 @@
+package urlshortener
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"urlshortener/pkg/analytics"
+	"urlshortener/pkg/store"
+)
+
+func TestSQLStoreHandler(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.OpenSQLite(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	if _, err := st.DB().ExecContext(ctx, `INSERT INTO blocked_domains (domain) VALUES ('Blocked.Example')`); err != nil {
+		t.Fatal(err)
+	}
+
+	savedCodes, savedLimit, savedList := reservedCodes, cfg.ShortenRateLimit, blocklist.Load()
+	t.Cleanup(func() {
+		reservedCodes, cfg.ShortenRateLimit = savedCodes, savedLimit
+		blocklist.Store(savedList)
+	})
+	reservedCodes = maps.Clone(savedCodes)
+	cfg.ShortenRateLimit = 6
+	resetRateBuckets(t)
+	if err := sqlBlockedDomains(st)(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(pingMiddleware(sqlStoreHandler(st, analytics.NewSQLite(st.DB()))))
+	t.Cleanup(srv.Close)
+
+	shorten := func(url, code string) int {
+		body, _ := json.Marshal(map[string]string{"url": url, "code": code})
+		resp, err := http.Post(srv.URL+"/api/v1/shorten", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Six shortens are allowed per minute; the seventh is refused.
+	shortenTests := []struct {
+		name   string
+		url    string
+		code   string
+		status int
+	}{
+		{"created", "https://example.com/sql", "sql-live", http.StatusCreated},
+		{"blocked domain", "https://blocked.example/", "", http.StatusBadRequest},
+		{"blocked subdomain", "https://www.blocked.example/", "", http.StatusBadRequest},
+		{"reserved code", "https://example.com/", "healthz", http.StatusBadRequest},
+		{"route code", "https://example.com/", "api", http.StatusBadRequest},
+		{"profane code", "https://example.com/", "fuck", http.StatusUnprocessableEntity},
+		{"rate limited", "https://example.com/", "", http.StatusTooManyRequests},
+	}
+	for _, tt := range shortenTests {
+		if got := shorten(tt.url, tt.code); got != tt.status {
+			t.Errorf("%s: shorten(%q, %q) = %d, want %d", tt.name, tt.url, tt.code, got, tt.status)
+		}
+	}
+
+	getTests := []struct {
+		path   string
+		status int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/ready", http.StatusOK},
+		{"/ping", http.StatusOK},
+		{"/api/v1/sql-live", http.StatusOK},
+		{"/sql-live", http.StatusFound},
+		{"/missing", http.StatusNotFound},
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for _, tt := range getTests {
+		resp, err := client.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package urlshortener
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var statsTpl = template.Must(template.New("").Funcs(template.FuncMap{"asset": assetURL}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Stats for /{{.Code}}</title>
+    <style>
+        .chart { display: flex; align-items: flex-end; gap: 2px; height: 160px; border-bottom: 1px solid #999; }
+        .bar { flex: 1; background: #4a7bd0; min-height: 1px; }
+    </style>
+</head>
+<body>
+    <h1>Stats for /{{.Code}}</h1>
+    <p>Total clicks: {{.Clicks}}</p>
+    {{if .Impressions}}<p>Impressions: {{.Impressions}}, click-through rate {{printf "%.1f" .CTR}}%</p>{{end}}
+    <p>Created: {{.CreatedAt.Format "2 January 2006"}}</p>
+    {{with .Share}}
+    <p>
+        <a href="{{.Twitter}}" target="_blank" rel="noopener">Share on X</a>
+        <a href="{{.LinkedIn}}" target="_blank" rel="noopener">Share on LinkedIn</a>
+        <button type="button" id="copy" data-url="{{.ShortURL}}">Copy to clipboard</button>
+    </p>
+    {{end}}
+    <h2>Clicks in the last 30 days</h2>
+    {{if .Bars}}
+    <div class="chart">
+        {{range .Bars}}<div class="bar" style="height: {{.Height}}%" title="{{.Date}}: {{.Clicks}}"></div>{{end}}
+    </div>
+    {{else}}
+    <p>No clicks yet.</p>
+    {{end}}
+    <script src="{{asset "stats.js"}}"></script>
+</body>
+</html>
+`))
+
+type statsBar struct {
+	Date   string
+	Clicks int64
+	Height int
+}
+
+type StatsPageVariables struct {
+	Code      string
+	Clicks    int64
+	CreatedAt time.Time
+	Bars      []statsBar
+	Share     ShareLinks
+
+	// Impressions counts listings of the URL; CTR is clicks per
+	// impression, as a percentage.
+	Impressions int64
+	CTR         float64
+}
+
+// publicStatsHandler renders the shareable stats page. It deliberately shows
+// only aggregate counts: no referrers, IPs or geography.
+func publicStatsHandler(w http.ResponseWriter, r *http.Request) {
+	code := codeParam(r)
+
+	mapping, err := findInMongoDB(code)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		writeError(w, r, "Failed to load stats", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !mapping.PublicStats {
+		notFound(w, r)
+		return
+	}
+	if !checkSignature(w, r, mapping) {
+		return
+	}
+
+	daily, err := dailyClicks(r.Context(), code, 30)
+	if err != nil {
+		log.Printf("Failed to aggregate clicks for %s: %v", code, err)
+		writeError(w, r, "Failed to load stats", http.StatusInternalServerError)
+		return
+	}
+
+	var peak int64
+	for _, d := range daily {
+		if d.Clicks > peak {
+			peak = d.Clicks
+		}
+	}
+	bars := make([]statsBar, len(daily))
+	for i, d := range daily {
+		bars[i] = statsBar{Date: d.Date, Clicks: d.Clicks, Height: int(d.Clicks * 100 / peak)}
+	}
+
+	err = statsTpl.Execute(w, StatsPageVariables{
+		Code:      mapping.Code,
+		Clicks:    mapping.Clicks,
+		CreatedAt: mapping.CreatedAt,
+		Bars:      bars,
+		Share:     shareLinks(publicBaseURL(r), mapping),
+
+		Impressions: mapping.Impressions,
+		CTR:         100 * clickThroughRate(mapping.Clicks, mapping.Impressions),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
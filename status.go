@@ -0,0 +1,209 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	healthCheckInterval = 30 * time.Second
+
+	// healthCheckTTL keeps a day of checks beyond the uptime window, for
+	// looking into an outage after the fact.
+	healthCheckTTL = 48 * time.Hour
+
+	// maxPendingHealthChecks bounds the checks held while MongoDB is down:
+	// a day's worth, which covers the uptime window.
+	maxPendingHealthChecks = int(24 * time.Hour / healthCheckInterval)
+
+	// uptimeCacheTTL spares MongoDB two counts per /status request; the
+	// figure only moves once per healthCheckInterval anyway.
+	uptimeCacheTTL = healthCheckInterval
+
+	latencySamples = 1024
+
+	statusOperational = "operational"
+	statusDegraded    = "degraded"
+	statusOutage      = "outage"
+)
+
+// healthChecks holds the outcome of every self-probe, from which /status
+// computes uptime.
+var healthChecks *mongo.Collection
+
+// ServiceCheck is a self-probe of one instance. The service counts as up if
+// the instance was ready and reached MongoDB.
+type ServiceCheck struct {
+	Timestamp time.Time `bson:"timestamp"`
+	Instance  string    `bson:"instance"`
+	Up        bool      `bson:"up"`
+	Ready     bool      `bson:"ready"`
+	MongoDB   bool      `bson:"mongodb"`
+}
+
+// latencyWindow keeps the latest latencySamples durations of an operation.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [latencySamples]time.Duration
+	n       int
+}
+
+var (
+	redirectLatency latencyWindow
+	shortenLatency  latencyWindow
+)
+
+func (l *latencyWindow) observe(d time.Duration) {
+	l.mu.Lock()
+	l.samples[l.n%latencySamples] = d
+	l.n++
+	l.mu.Unlock()
+}
+
+// since observes the time from start, for deferring at the top of a
+// handler.
+func (l *latencyWindow) since(start time.Time) {
+	l.observe(time.Since(start))
+}
+
+// p99Ms is the 99th percentile of the window in milliseconds, or 0 before
+// anything was observed.
+func (l *latencyWindow) p99Ms() float64 {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples[:min(l.n, latencySamples)]...)
+	l.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	p := sorted[(len(sorted)*99+99)/100-1]
+	return math.Round(float64(p)/float64(time.Millisecond)*10) / 10
+}
+
+// startHealthChecks probes this instance every healthCheckInterval and
+// records the outcome in health_checks. Checks that can't be written,
+// because MongoDB is what's down, are kept and written once it's back, so
+// that the outage counts against uptime.
+func startHealthChecks(ctx context.Context) {
+	go func() {
+		var pending []any
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			pending = append(pending, probeHealth(ctx))
+			if len(pending) > maxPendingHealthChecks {
+				pending = pending[len(pending)-maxPendingHealthChecks:]
+			}
+			if _, err := healthChecks.InsertMany(ctx, pending); err != nil {
+				log.Printf("Failed to record %d health checks: %v", len(pending), err)
+				continue
+			}
+			pending = pending[:0]
+		}
+	}()
+}
+
+func probeHealth(ctx context.Context) ServiceCheck {
+	c := ServiceCheck{Timestamp: time.Now(), Instance: instanceID, Ready: isReady()}
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	c.MongoDB = client.Ping(pingCtx, nil) == nil
+	c.Up = c.Ready && c.MongoDB
+	return c
+}
+
+var uptimeCache struct {
+	sync.Mutex
+	at  time.Time
+	pct *float64
+}
+
+// uptime24h is the share of the last day's health checks, across all
+// instances, that found the service up, as a percentage. It is nil before
+// the first check or while MongoDB can't be counted.
+func uptime24h(ctx context.Context) *float64 {
+	uptimeCache.Lock()
+	defer uptimeCache.Unlock()
+	if time.Since(uptimeCache.at) < uptimeCacheTTL {
+		return uptimeCache.pct
+	}
+
+	since := bson.M{"$gte": time.Now().Add(-24 * time.Hour)}
+	total, err := healthChecks.CountDocuments(ctx, bson.M{"timestamp": since})
+	if err != nil {
+		log.Printf("Failed to count health checks: %v", err)
+		return uptimeCache.pct
+	}
+	var pct *float64
+	if total > 0 {
+		up, err := healthChecks.CountDocuments(ctx, bson.M{"timestamp": since, "up": true})
+		if err != nil {
+			log.Printf("Failed to count health checks: %v", err)
+			return uptimeCache.pct
+		}
+		p := math.Round(float64(up)/float64(total)*1e4) / 100
+		pct = &p
+	}
+	uptimeCache.at, uptimeCache.pct = time.Now(), pct
+	return pct
+}
+
+type serviceStatus struct {
+	Status       string            `json:"status"`
+	RedirectP99  float64           `json:"redirect_p99_ms"`
+	ShortenP99   float64           `json:"shorten_p99_ms"`
+	UptimePct24h *float64          `json:"uptime_pct_24h"`
+	Components   map[string]string `json:"components"`
+}
+
+// statusHandler serves GET /status, the service status for API consumers,
+// e.g. to open a client-side circuit breaker. It answers 200 whatever the
+// status: unlike /healthz it is informational, not a readiness probe.
+// Latencies are this instance's over its latest latencySamples requests;
+// uptime is the service's, from health_checks.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	mongoStatus := statusOperational
+	if err := client.Ping(ctx, nil); err != nil {
+		mongoStatus = statusOutage
+	}
+	resp := serviceStatus{
+		Status:      statusOperational,
+		RedirectP99: redirectLatency.p99Ms(),
+		ShortenP99:  shortenLatency.p99Ms(),
+		Components: map[string]string{
+			"mongodb": mongoStatus,
+			// The cache is in process: it works whenever this answers,
+			// though it is still filling while warming up.
+			"cache": statusOperational,
+		},
+	}
+	if !isReady() {
+		resp.Components["cache"] = statusDegraded
+	}
+	if mongoStatus == statusOperational {
+		resp.UptimePct24h = uptime24h(ctx)
+	}
+	for _, s := range resp.Components {
+		if s != statusOperational {
+			resp.Status = statusDegraded
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
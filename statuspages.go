@@ -0,0 +1,195 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const statusPageTTL = 30 * time.Second
+
+var (
+	statusPages *mongo.Collection
+
+	// Status codes that may be given a custom page.
+	customizableStatuses = map[int]bool{
+		http.StatusNotFound:            true,
+		http.StatusGone:                true,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+	}
+
+	statusPagePolicy = bluemonday.UGCPolicy()
+)
+
+type StatusPage struct {
+	Status    int       `bson:"status" json:"status"`
+	HTML      string    `bson:"html" json:"html"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+var statusPageTpl = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
+
+var statusPageCache = struct {
+	sync.Mutex
+	entries map[int]statusPageEntry
+}{entries: make(map[int]statusPageEntry)}
+
+type statusPageEntry struct {
+	html    string
+	found   bool
+	fetched time.Time
+}
+
+func lookupStatusPage(ctx context.Context, status int) (string, bool) {
+	if cfg.DryRun {
+		// PUT can't have stored one.
+		return "", false
+	}
+	statusPageCache.Lock()
+	e, ok := statusPageCache.entries[status]
+	statusPageCache.Unlock()
+	if ok && time.Since(e.fetched) < statusPageTTL {
+		return e.html, e.found
+	}
+
+	var page StatusPage
+	err := statusPages.FindOne(ctx, bson.M{"status": status}).Decode(&page)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Error loading status page %d: %v", status, err)
+		return "", false
+	}
+	e = statusPageEntry{html: page.HTML, found: err == nil, fetched: time.Now()}
+
+	statusPageCache.Lock()
+	statusPageCache.entries[status] = e
+	statusPageCache.Unlock()
+	return e.html, e.found
+}
+
+// serveStatusPage writes the admin-defined page for status, if there is one,
+// and reports whether it did.
+func serveStatusPage(w http.ResponseWriter, r *http.Request, status int) bool {
+	if !customizableStatuses[status] {
+		return false
+	}
+	body, ok := lookupStatusPage(r.Context(), status)
+	if !ok {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err := statusPageTpl.Execute(w, struct {
+		Title string
+		Body  template.HTML
+	}{http.StatusText(status), template.HTML(body)})
+	if err != nil {
+		log.Printf("Error rendering status page %d: %v", status, err)
+	}
+	return true
+}
+
+// writeError replies with the custom page for status when one is defined and
+// falls back to a plain-text error otherwise.
+func writeError(w http.ResponseWriter, r *http.Request, msg string, status int) {
+	if serveStatusPage(w, r, status) {
+		return
+	}
+	http.Error(w, msg, status)
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, "404 page not found", http.StatusNotFound)
+}
+
+// gone answers 410 for a code that exists but no longer redirects, so
+// search engines drop it instead of retrying as they would after a 404.
+func gone(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Robots-Tag", "noindex")
+	writeError(w, r, "410 gone", http.StatusGone)
+}
+
+func statusFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	status, err := strconv.Atoi(r.PathValue("status"))
+	if err != nil || !customizableStatuses[status] {
+		http.Error(w, "Status must be one of 404, 410, 429 or 500", http.StatusBadRequest)
+		return 0, false
+	}
+	return status, true
+}
+
+func getStatusPageHandler(w http.ResponseWriter, r *http.Request) {
+	status, ok := statusFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var page StatusPage
+	err := statusPages.FindOne(r.Context(), bson.M{"status": status}).Decode(&page)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Failed to load status page %d: %v", status, err)
+		http.Error(w, "Failed to load status page", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func putStatusPageHandler(w http.ResponseWriter, r *http.Request) {
+	status, ok := statusFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		HTML string `json:"html"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	page := StatusPage{
+		Status:    status,
+		HTML:      statusPagePolicy.Sanitize(req.HTML),
+		UpdatedAt: time.Now(),
+	}
+	_, err := statusPages.ReplaceOne(r.Context(), bson.M{"status": status}, page, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("Failed to save status page %d: %v", status, err)
+		http.Error(w, "Failed to save status page", http.StatusInternalServerError)
+		return
+	}
+
+	statusPageCache.Lock()
+	delete(statusPageCache.entries, status)
+	statusPageCache.Unlock()
+
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,296 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const summaryTTL = 60 * time.Second
+
+type CountEntry struct {
+	Key   string `bson:"_id" json:"key"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+type AnalyticsSummary struct {
+	TotalURLs       int64        `json:"total_urls"`
+	TotalRedirects  int64        `json:"total_redirects"`
+	Redirects24h    int64        `json:"redirects_24h"`
+	TopCountries    []CountEntry `json:"top_countries"`
+	TopReferrers    []CountEntry `json:"top_referrers"`
+	AvgClicksPerURL float64      `json:"avg_clicks_per_url"`
+	GeneratedAt     time.Time    `json:"generated_at"`
+}
+
+var summaryCache struct {
+	sync.Mutex
+	value   *AnalyticsSummary
+	expires time.Time
+}
+
+func analyticsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	summary, err := cachedSummary(r.Context())
+	if err != nil {
+		log.Printf("Failed to compute analytics summary: %v", err)
+		http.Error(w, "Failed to compute analytics summary", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func cachedSummary(ctx context.Context) (*AnalyticsSummary, error) {
+	summaryCache.Lock()
+	defer summaryCache.Unlock()
+
+	if summaryCache.value != nil && time.Now().Before(summaryCache.expires) {
+		return summaryCache.value, nil
+	}
+
+	summary, err := computeSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summaryCache.value = summary
+	summaryCache.expires = time.Now().Add(summaryTTL)
+	return summary, nil
+}
+
+func computeSummary(ctx context.Context) (*AnalyticsSummary, error) {
+	summary := &AnalyticsSummary{
+		TopCountries: []CountEntry{},
+		TopReferrers: []CountEntry{},
+		GeneratedAt:  time.Now(),
+	}
+
+	cur, err := collection.Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{
+			"_id":    nil,
+			"urls":   bson.M{"$sum": 1},
+			"clicks": bson.M{"$sum": "$clicks"},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var totals []struct {
+		URLs   int64 `bson:"urls"`
+		Clicks int64 `bson:"clicks"`
+	}
+	if err := cur.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	if len(totals) > 0 && totals[0].URLs > 0 {
+		summary.TotalURLs = totals[0].URLs
+		summary.TotalRedirects = totals[0].Clicks
+		summary.AvgClicksPerURL = float64(totals[0].Clicks) / float64(totals[0].URLs)
+	}
+
+	top := func(field string) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{field: bson.M{"$nin": bson.A{nil, ""}}}},
+			bson.M{"$group": bson.M{"_id": "$" + field, "count": clickCount}},
+			bson.M{"$sort": bson.M{"count": -1}},
+			bson.M{"$limit": 5},
+		}
+	}
+	cur, err = clickEvents.Aggregate(ctx, bson.A{
+		bson.M{"$facet": bson.M{
+			"recent": bson.A{
+				bson.M{"$match": bson.M{"timestamp": bson.M{"$gte": time.Now().Add(-24 * time.Hour)}}},
+				bson.M{"$group": bson.M{"_id": nil, "count": clickCount}},
+			},
+			"countries": top("country"),
+			"referrers": top("referrer"),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var facets []struct {
+		Recent []struct {
+			Count int64 `bson:"count"`
+		} `bson:"recent"`
+		Countries []CountEntry `bson:"countries"`
+		Referrers []CountEntry `bson:"referrers"`
+	}
+	if err := cur.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+	if len(facets) > 0 {
+		if len(facets[0].Recent) > 0 {
+			summary.Redirects24h = facets[0].Recent[0].Count
+		}
+		summary.TopCountries = append(summary.TopCountries, facets[0].Countries...)
+		summary.TopReferrers = append(summary.TopReferrers, facets[0].Referrers...)
+	}
+
+	return summary, nil
+}
+
+const (
+	defaultNamespacePeriodDays = 30
+	maxNamespacePeriodDays     = 365
+)
+
+// NamespaceAnalytics aggregates the usage of every short URL in one
+// namespace. The clicks and visitors cover the period; TotalClicks is the
+// all-time counter.
+type NamespaceAnalytics struct {
+	Namespace       string       `json:"namespace"`
+	PeriodDays      int          `json:"period_days"`
+	Since           time.Time    `json:"since"`
+	TotalURLs       int64        `json:"total_urls"`
+	TotalClicks     int64        `json:"total_clicks"`
+	PeriodClicks    int64        `json:"period_clicks"`
+	UniqueVisitors  int64        `json:"unique_visitors"`
+	TopCountries    []CountEntry `json:"top_countries"`
+	TopReferrers    []CountEntry `json:"top_referrers"`
+	CreatedInPeriod int64        `json:"created_in_period"`
+	ExpiredInPeriod int64        `json:"expired_in_period"`
+	GeneratedAt     time.Time    `json:"generated_at"`
+}
+
+// namespaceAnalyticsHandler serves the aggregate analytics of the {ns}
+// namespace over the last ?days= days (30 by default).
+func namespaceAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	ns := normalizeCode(r.PathValue("ns"))
+	if !codePattern.MatchString(ns) {
+		http.Error(w, "Invalid namespace", http.StatusBadRequest)
+		return
+	}
+	days := defaultNamespacePeriodDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxNamespacePeriodDays {
+			http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	stats, err := computeNamespaceAnalytics(r.Context(), ns, days)
+	if err != nil {
+		log.Printf("Failed to compute analytics for namespace %s: %v", ns, err)
+		http.Error(w, "Failed to compute namespace analytics", http.StatusInternalServerError)
+		return
+	}
+	if stats.TotalURLs == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func computeNamespaceAnalytics(ctx context.Context, ns string, days int) (*NamespaceAnalytics, error) {
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	stats := &NamespaceAnalytics{
+		Namespace:    ns,
+		PeriodDays:   days,
+		Since:        since,
+		TopCountries: []CountEntry{},
+		TopReferrers: []CountEntry{},
+		GeneratedAt:  now,
+	}
+
+	type count struct {
+		Count int64 `bson:"count"`
+	}
+	cur, err := collection.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"prefix": ns}},
+		bson.M{"$facet": bson.M{
+			"totals": bson.A{bson.M{"$group": bson.M{
+				"_id":    nil,
+				"urls":   bson.M{"$sum": 1},
+				"clicks": bson.M{"$sum": "$clicks"},
+			}}},
+			"created": bson.A{
+				bson.M{"$match": bson.M{"created_at": bson.M{"$gte": since}}},
+				bson.M{"$count": "count"},
+			},
+			"expired": bson.A{
+				bson.M{"$match": bson.M{"expires_at": bson.M{"$gte": since, "$lte": now}}},
+				bson.M{"$count": "count"},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var urls []struct {
+		Totals []struct {
+			URLs   int64 `bson:"urls"`
+			Clicks int64 `bson:"clicks"`
+		} `bson:"totals"`
+		Created []count `bson:"created"`
+		Expired []count `bson:"expired"`
+	}
+	if err := cur.All(ctx, &urls); err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 || len(urls[0].Totals) == 0 {
+		return stats, nil
+	}
+	stats.TotalURLs = urls[0].Totals[0].URLs
+	stats.TotalClicks = urls[0].Totals[0].Clicks
+	if len(urls[0].Created) > 0 {
+		stats.CreatedInPeriod = urls[0].Created[0].Count
+	}
+	if len(urls[0].Expired) > 0 {
+		stats.ExpiredInPeriod = urls[0].Expired[0].Count
+	}
+
+	top := func(field string) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{field: bson.M{"$nin": bson.A{nil, ""}}}},
+			bson.M{"$group": bson.M{"_id": "$" + field, "count": clickCount}},
+			bson.M{"$sort": bson.M{"count": -1}},
+			bson.M{"$limit": 5},
+		}
+	}
+	// Namespaced codes are stored as "ns/code", so an anchored prefix
+	// match uses the {code, timestamp} index.
+	cur, err = clickEvents.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{
+			"code":      bson.M{"$regex": "^" + regexp.QuoteMeta(ns+"/")},
+			"timestamp": bson.M{"$gte": since},
+		}},
+		bson.M{"$facet": bson.M{
+			"clicks":    bson.A{bson.M{"$group": bson.M{"_id": nil, "count": clickCount}}},
+			"visitors":  bson.A{bson.M{"$group": bson.M{"_id": "$ip"}}, bson.M{"$count": "count"}},
+			"countries": top("country"),
+			"referrers": top("referrer"),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var facets []struct {
+		Clicks    []count      `bson:"clicks"`
+		Visitors  []count      `bson:"visitors"`
+		Countries []CountEntry `bson:"countries"`
+		Referrers []CountEntry `bson:"referrers"`
+	}
+	if err := cur.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+	if len(facets) > 0 {
+		if len(facets[0].Clicks) > 0 {
+			stats.PeriodClicks = facets[0].Clicks[0].Count
+		}
+		if len(facets[0].Visitors) > 0 {
+			stats.UniqueVisitors = facets[0].Visitors[0].Count
+		}
+		stats.TopCountries = append(stats.TopCountries, facets[0].Countries...)
+		stats.TopReferrers = append(stats.TopReferrers, facets[0].Referrers...)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,98 @@
+package urlshortener
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Service tiers of a short URL. A URL without a priority is free.
+const (
+	priorityFree    = "free"
+	priorityPremium = "premium"
+)
+
+var errInvalidPriority = errors.New(`priority must be "premium" or "free"`)
+
+var redirectDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "redirect_duration_seconds",
+	Help:    "Time to answer a short URL redirect, by the URL's tier.",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+}, []string{"tier", namespaceLabel})
+
+var throttledRedirects = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "throttled_redirects_total",
+	Help: "Redirects held back by the tier's FREE_REDIRECT_DELAY_MS or PREMIUM_REDIRECT_DELAY_MS.",
+}, []string{"tier"})
+
+func tier(m URLMapping) string {
+	if m.Priority == priorityPremium {
+		return priorityPremium
+	}
+	return priorityFree
+}
+
+// redirectDelay is how long redirects of URLs in tier are held back, to
+// make the premium tier worth upgrading to.
+func redirectDelay(tier string) time.Duration {
+	ms := cfg.FreeRedirectDelayMS
+	if tier == priorityPremium {
+		ms = cfg.PremiumRedirectDelayMS
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// throttleRedirect sleeps for tier's redirectDelay before a redirect, or
+// until the client goes away, and returns how long it slept.
+func throttleRedirect(r *http.Request, tier string) time.Duration {
+	delay := redirectDelay(tier)
+	if delay <= 0 {
+		return 0
+	}
+	throttledRedirects.WithLabelValues(tier).Inc()
+	start := time.Now()
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+	return time.Since(start)
+}
+
+// lookupRedirect is lookupCode for the redirect path. Premium URLs are
+// answered from shortURLs alone, trusting the change stream to keep it
+// current. Free URLs are re-read from MongoDB even on a cache hit, so an
+// expiry or disable made elsewhere applies at once; if MongoDB fails the
+// cached copy is used.
+func lookupRedirect(code string) (URLMapping, error) {
+	if !isReady() {
+		return lookupCode(code)
+	}
+	cached, ok := shortURLs.Get(code)
+	if !ok {
+		return lookupCode(code)
+	}
+	if cached.Priority == priorityPremium {
+		return cached, nil
+	}
+	fresh, err := findInMongoDB(code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if awaitingSave(code) {
+				return cached, nil
+			}
+			shortURLs.Delete(code)
+			return fresh, err
+		}
+		log.Printf("Serving cached %s: %v", code, err)
+		return cached, nil
+	}
+	shortURLs.Set(code, fresh)
+	return fresh, nil
+}
@@ -0,0 +1,24 @@
+package urlshortener
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// timeoutMiddleware answers 503 and cancels the request context when a
+// handler runs longer than d, so a slow MongoDB can't pin goroutines
+// indefinitely. The handler keeps running until it notices the
+// cancellation, but its late writes are discarded.
+func timeoutMiddleware(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		th := http.TimeoutHandler(next, d, "Request timed out")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			th.ServeHTTP(w, r)
+			if time.Since(start) >= d {
+				log.Printf("Handler timeout after %s: %s %s", d, r.Method, r.URL.Path)
+			}
+		})
+	}
+}
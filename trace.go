@@ -0,0 +1,99 @@
+package urlshortener
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const traceHeader = "X-URLShortener-Trace"
+
+type traceKey struct{}
+
+// redirectTrace collects the routing decisions made while resolving a
+// redirect, e.g. "geo:DE→de.example.com" or "device:mobile→skip".
+type redirectTrace struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func withRedirectTrace(ctx context.Context) (context.Context, *redirectTrace) {
+	t := &redirectTrace{}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// traceRule records that rule produced outcome. Hooks may call it to explain
+// their decision; it is a no-op unless the request asked for a trace.
+func traceRule(ctx context.Context, rule, outcome string) {
+	t, ok := ctx.Value(traceKey{}).(*redirectTrace)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	t.entries = append(t.entries, rule+"→"+outcome)
+	t.mu.Unlock()
+}
+
+func (t *redirectTrace) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries)
+}
+
+func (t *redirectTrace) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.entries, ", ")
+}
+
+func traceLen(ctx context.Context) int {
+	if t, ok := ctx.Value(traceKey{}).(*redirectTrace); ok {
+		return t.len()
+	}
+	return 0
+}
+
+func hookName(hook RedirectHook) string {
+	if n, ok := hook.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", hook)
+}
+
+// wantsTrace reports whether r asked for a redirect trace and is allowed to
+// see one. Traces reveal routing rules, so only private-network clients get
+// them.
+func wantsTrace(r *http.Request) bool {
+	return r.URL.Query().Get("debug") == "1" && isPrivateIP(realIP(r))
+}
+
+// traceWriter adds the trace header just before the response is committed,
+// so every branch of redirectHandler reports the rules that fired.
+type traceWriter struct {
+	http.ResponseWriter
+	trace       *redirectTrace
+	wroteHeader bool
+}
+
+func (w *traceWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if s := w.trace.String(); s != "" {
+			w.Header().Set(traceHeader, s)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *traceWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *traceWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,171 @@
+package urlshortener
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	vacuumInterval  = 7 * 24 * time.Hour
+	vacuumRetention = 30 * 24 * time.Hour
+	vacuumBatchSize = 1000
+	vacuumJobID     = "vacuum"
+)
+
+type vacuumResult struct {
+	URLs        int64     `bson:"urls" json:"urls"`
+	ClickEvents int64     `bson:"click_events" json:"click_events"`
+	RanAt       time.Time `bson:"ran_at" json:"ran_at"`
+}
+
+var lastVacuum struct {
+	sync.Mutex
+	at time.Time
+}
+
+// vacuumMu keeps the weekly job and manual triggers from running at once.
+var vacuumMu sync.Mutex
+
+// startVacuum runs the vacuum weekly in the background.
+func startVacuum(ctx context.Context) {
+	var state struct {
+		RanAt time.Time `bson:"ran_at"`
+	}
+	if err := jobs.FindOne(ctx, bson.M{"_id": vacuumJobID}).Decode(&state); err == nil {
+		setLastVacuum(state.RanAt)
+	}
+
+	go func() {
+		ticker := time.NewTicker(vacuumInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := vacuum(ctx); err != nil {
+					log.Printf("Vacuum failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// vacuum permanently deletes URLs that were disabled or expired more than
+// vacuumRetention ago, along with their click events. URLs disabled for
+// inactivity are kept, since their owner can still bring them back.
+func vacuum(ctx context.Context) (vacuumResult, error) {
+	vacuumMu.Lock()
+	defer vacuumMu.Unlock()
+
+	res := vacuumResult{RanAt: time.Now()}
+	cutoff := res.RanAt.Add(-vacuumRetention)
+
+	// URLs disabled before disabled_at was recorded start their retention
+	// now.
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"disabled": true, "disabled_at": bson.M{"$exists": false}, "inactive_disabled_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"disabled_at": res.RanAt}},
+	)
+	if err != nil {
+		return res, err
+	}
+
+	cur, err := collection.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"$or": bson.A{
+			bson.M{"disabled": true, "inactive_disabled_at": bson.M{"$exists": false}, "disabled_at": bson.M{"$lt": cutoff}},
+			bson.M{"expires_at": bson.M{"$lt": cutoff}},
+		}}},
+		bson.M{"$project": bson.M{"_id": 0, "code": 1}},
+	})
+	if err != nil {
+		return res, err
+	}
+	defer cur.Close(ctx)
+
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		filter := bson.M{"code": bson.M{"$in": batch}}
+		urls, err := collection.DeleteMany(ctx, filter)
+		if err != nil {
+			return err
+		}
+		events, err := clickEvents.DeleteMany(ctx, filter)
+		if err != nil {
+			return err
+		}
+		res.URLs += urls.DeletedCount
+		res.ClickEvents += events.DeletedCount
+
+		for _, code := range batch {
+			shortURLs.Delete(code)
+			publishMutation(replicateDelete, URLMapping{Code: code})
+			lifecycle.PublishDeleted(code)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for cur.Next(ctx) {
+		var doc struct {
+			Code string `bson:"code"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return res, err
+		}
+		batch = append(batch, doc.Code)
+		if len(batch) == vacuumBatchSize {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return res, err
+	}
+	if err := flush(); err != nil {
+		return res, err
+	}
+
+	_, err = jobs.UpdateOne(ctx,
+		bson.M{"_id": vacuumJobID},
+		bson.M{"$set": res},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Failed to record vacuum run: %v", err)
+	}
+	setLastVacuum(res.RanAt)
+	log.Printf("Vacuum removed %d URLs and %d click events", res.URLs, res.ClickEvents)
+	return res, nil
+}
+
+func setLastVacuum(t time.Time) {
+	lastVacuum.Lock()
+	lastVacuum.at = t
+	lastVacuum.Unlock()
+}
+
+func lastVacuumAt() time.Time {
+	lastVacuum.Lock()
+	defer lastVacuum.Unlock()
+	return lastVacuum.at
+}
+
+func vacuumHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := vacuum(r.Context())
+	if err != nil {
+		log.Printf("Vacuum failed: %v", err)
+		http.Error(w, "Failed to vacuum", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
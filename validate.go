@@ -0,0 +1,88 @@
+package urlshortener
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// validateTimeout bounds the probe on the redirect path; a destination
+	// slower than this gets the warning page.
+	validateTimeout = 500 * time.Millisecond
+	validateTTL     = 5 * time.Minute
+)
+
+var unreachableTpl = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Destination may be unavailable</title>
+</head>
+<body>
+    <h1>This link's destination may be unavailable</h1>
+    <p><code>{{.URL}}</code> {{.Problem}}.</p>
+    <p><a href="{{.URL}}">Continue anyway</a></p>
+</body>
+</html>
+`))
+
+var validatedDestinations sync.Map // code -> validatedDestination
+
+type validatedDestination struct {
+	url     string
+	checked time.Time
+}
+
+// validateDestination sends a HEAD request to dest with --validate-on-redirect
+// and describes why it looks unreachable, or returns "" when it answered
+// below 400. Only successes are cached, for validateTTL per code, so a
+// destination that comes back is noticed on the next redirect. Unlike the
+// hourly link health check this runs on the redirect itself.
+func validateDestination(code, dest string) string {
+	if v, ok := validatedDestinations.Load(code); ok {
+		if v := v.(validatedDestination); v.url == dest && time.Since(v.checked) < validateTTL {
+			return ""
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, dest, nil)
+	if err != nil {
+		return "is not a valid URL"
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Sprintf("did not answer within %s", validateTimeout)
+		}
+		return "could not be reached"
+	}
+	resp.Body.Close()
+	// Servers that don't implement HEAD are up all the same.
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+		return "answered " + resp.Status
+	}
+
+	validatedDestinations.Store(code, validatedDestination{url: dest, checked: time.Now()})
+	return ""
+}
+
+// renderUnreachable warns that m's destination failed validation and lets
+// the visitor continue to it.
+func renderUnreachable(w http.ResponseWriter, m URLMapping, problem string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := unreachableTpl.Execute(w, struct{ URL, Problem string }{m.URL, problem})
+	if err != nil {
+		log.Printf("Error rendering unreachable page for %s: %v", m.Code, err)
+	}
+}
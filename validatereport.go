@@ -0,0 +1,172 @@
+package urlshortener
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const validateAllTimeout = 10 * time.Second
+
+// validateAllClient neither follows redirects nor verifies certificates,
+// so every destination reports its own status and its certificate is
+// judged separately, by verifyPeer. Like the other fetchers of
+// destinations it only connects to public addresses.
+var validateAllClient = &http.Client{
+	Timeout: validateAllTimeout,
+	Transport: func() *http.Transport {
+		t := publicTransport()
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		return t
+	}(),
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// ValidationResult is a line of GET /api/v1/admin/validate-all. SSLValid
+// is only set for https destinations that completed a handshake.
+type ValidationResult struct {
+	Code       string `json:"code"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	SSLValid   *bool  `json:"ssl_valid,omitempty"`
+	SSLError   string `json:"ssl_error,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// validateAllHandler sends a HEAD request to the destination of every
+// active short URL, VALIDATE_ALL_WORKERS at a time, and streams the results
+// as NDJSON in the order they complete. ?dry_run=true only counts the URLs
+// that would be checked. It must not run behind timeoutMiddleware, which
+// buffers.
+func validateAllHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	filter := monitoredFilter(time.Now())
+
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		n, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			log.Printf("Failed to count URLs to validate: %v", err)
+			http.Error(w, "Failed to count URLs", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int64{"count": n})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	cur, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"code": 1, "url": 1}))
+	if err != nil {
+		log.Printf("Failed to list URLs to validate: %v", err)
+		http.Error(w, "Failed to list URLs", http.StatusInternalServerError)
+		return
+	}
+	defer cur.Close(ctx)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	targets := make(chan healthTarget)
+	results := make(chan ValidationResult)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.ValidateAllWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range targets {
+				select {
+				case results <- validateURLDestination(ctx, t):
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(targets)
+		for cur.Next(ctx) {
+			var t healthTarget
+			if err := cur.Decode(&t); err != nil {
+				log.Printf("Failed to decode URL to validate: %v", err)
+				continue
+			}
+			select {
+			case targets <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := cur.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to list URLs to validate: %v", err)
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	enc := json.NewEncoder(w)
+	for res := range results {
+		if err := enc.Encode(res); err != nil {
+			// The client went away; the request context stops the workers.
+			continue
+		}
+		flusher.Flush()
+	}
+}
+
+func validateURLDestination(ctx context.Context, t healthTarget) ValidationResult {
+	res := ValidationResult{Code: t.Code, URL: t.URL}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, t.URL, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	start := time.Now()
+	resp, err := validateAllClient.Do(req)
+	res.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	resp.Body.Close()
+	res.StatusCode = resp.StatusCode
+	if resp.TLS != nil {
+		err := verifyPeer(resp.TLS, req.URL.Hostname())
+		valid := err == nil
+		res.SSLValid = &valid
+		if err != nil {
+			res.SSLError = err.Error()
+		}
+	}
+	return res
+}
+
+// verifyPeer runs the verification that validateAllClient skipped on the
+// chain presented in cs, for host, which may be an IP address.
+func verifyPeer(cs *tls.ConnectionState, host string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no certificate presented")
+	}
+	opts := x509.VerifyOptions{DNSName: host, Intermediates: x509.NewCertPool()}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
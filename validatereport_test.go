@@ -0,0 +1,33 @@
+package urlshortener
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateURLDestinationRefusesPrivateAddresses(t *testing.T) {
+	internal := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("validation reached the internal server: %s %s", r.Method, r.URL)
+	}))
+	t.Cleanup(internal.Close)
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"loopback https", internal.URL + "/"},
+		{"localhost", strings.Replace(internal.URL, "127.0.0.1", "localhost", 1) + "/"},
+		{"metadata", "http://169.254.169.254/latest/meta-data/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := validateURLDestination(context.Background(), healthTarget{Code: "validate-test", URL: tt.url})
+			if res.StatusCode != 0 || !strings.Contains(res.Error, errPrivateAddress.Error()) {
+				t.Errorf("validateURLDestination(%q) = status %d, error %q; want refused", tt.url, res.StatusCode, res.Error)
+			}
+		})
+	}
+}